@@ -0,0 +1,189 @@
+// Package bidi implements the Unicode Bidirectional Algorithm,
+// described in Unicode Standard Annex #9 (https://www.unicode.org/reports/tr9/),
+// version 13.0.0.
+//
+// A paragraph of text is first analysed with NewParagraph, which resolves its
+// embedding levels. The paragraph is then split in lines, and each
+// Line provides the runs to be shaped, in visual order.
+// The optional rule L3 (reordering of combining marks) is not implemented.
+package bidi
+
+import (
+	"unicode"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// Class is a bidirectional character type (Bidi_Class property).
+type Class uint8
+
+const (
+	L   Class = iota // Left-to-Right
+	R                // Right-to-Left
+	AL               // Right-to-Left Arabic
+	EN               // European Number
+	ES               // European Number Separator
+	ET               // European Number Terminator
+	AN               // Arabic Number
+	CS               // Common Number Separator
+	NSM              // Nonspacing Mark
+	BN               // Boundary Neutral
+	B                // Paragraph Separator
+	S                // Segment Separator
+	WS               // Whitespace
+	ON               // Other Neutrals
+	LRE              // Left-to-Right Embedding
+	LRO              // Left-to-Right Override
+	RLE              // Right-to-Left Embedding
+	RLO              // Right-to-Left Override
+	PDF              // Pop Directional Format
+	LRI              // Left-to-Right Isolate
+	RLI              // Right-to-Left Isolate
+	FSI              // First Strong Isolate
+	PDI              // Pop Directional Isolate
+)
+
+var classNames = [...]string{
+	L: "L", R: "R", AL: "AL", EN: "EN", ES: "ES", ET: "ET", AN: "AN", CS: "CS", NSM: "NSM", BN: "BN",
+	B: "B", S: "S", WS: "WS", ON: "ON", LRE: "LRE", LRO: "LRO", RLE: "RLE", RLO: "RLO", PDF: "PDF",
+	LRI: "LRI", RLI: "RLI", FSI: "FSI", PDI: "PDI",
+}
+
+func (c Class) String() string {
+	if int(c) < len(classNames) {
+		return classNames[c]
+	}
+	return "<invalid class>"
+}
+
+var classes = map[*unicode.RangeTable]Class{
+	ucd.BidiL:   L,
+	ucd.BidiR:   R,
+	ucd.BidiAL:  AL,
+	ucd.BidiEN:  EN,
+	ucd.BidiES:  ES,
+	ucd.BidiET:  ET,
+	ucd.BidiAN:  AN,
+	ucd.BidiCS:  CS,
+	ucd.BidiNSM: NSM,
+	ucd.BidiBN:  BN,
+	ucd.BidiB:   B,
+	ucd.BidiS:   S,
+	ucd.BidiWS:  WS,
+	ucd.BidiON:  ON,
+	ucd.BidiLRE: LRE,
+	ucd.BidiLRO: LRO,
+	ucd.BidiRLE: RLE,
+	ucd.BidiRLO: RLO,
+	ucd.BidiPDF: PDF,
+	ucd.BidiLRI: LRI,
+	ucd.BidiRLI: RLI,
+	ucd.BidiFSI: FSI,
+	ucd.BidiPDI: PDI,
+}
+
+// LookupClass returns the bidirectional class of `r`.
+func LookupClass(r rune) Class { return classes[ucd.LookupBidiClass(r)] }
+
+// isRemovedByX9 returns true for the classes ignored by the rules following X9.
+func (c Class) isRemovedByX9() bool {
+	switch c {
+	case RLE, LRE, RLO, LRO, PDF, BN:
+		return true
+	}
+	return false
+}
+
+func (c Class) isIsolateInitiator() bool { return c == LRI || c == RLI || c == FSI }
+
+// Level is an embedding level. Even levels are left-to-right,
+// odd levels are right-to-left.
+type Level uint8
+
+// IsRTL returns true for odd levels.
+func (l Level) IsRTL() bool { return l&1 == 1 }
+
+// Direction is the base direction of a paragraph.
+type Direction uint8
+
+const (
+	// Auto uses the first strong character of the paragraph
+	// (rules P2 and P3), defaulting to left-to-right.
+	Auto Direction = iota
+	LeftToRight
+	RightToLeft
+)
+
+// Paragraph stores the resolved embedding levels of a paragraph.
+// It should be split into lines (see the Line method) to be displayed.
+type Paragraph struct {
+	text []rune
+
+	classes []Class // original classes
+	types   []Class // resolved classes, updated by the rules
+
+	// for paired brackets, the opening bracket of the pair,
+	// used to identify matching brackets
+	pairs    []rune
+	brackets []ucd.BracketType
+
+	// index of the matching PDI (resp. isolate initiator)
+	// for isolate initiators (resp. PDI), or -1
+	matchingPDI       []int
+	matchingInitiator []int
+
+	level  Level
+	levels []Level
+}
+
+// NewParagraph resolves the embedding levels of `text`, which should
+// be one paragraph, that is it should only contain a paragraph separator as its last rune.
+// The paragraph level is defined by `dir`.
+func NewParagraph(text []rune, dir Direction) *Paragraph {
+	classes := make([]Class, len(text))
+	pairs := make([]rune, len(text))
+	brackets := make([]ucd.BracketType, len(text))
+	for i, r := range text {
+		classes[i] = LookupClass(r)
+		pair, typ := ucd.LookupPairedBracket(r)
+		brackets[i] = typ
+		switch typ {
+		case ucd.BracketOpen:
+			pairs[i] = canonicalBracket(r)
+		case ucd.BracketClose:
+			pairs[i] = canonicalBracket(pair)
+		}
+	}
+	p := newParagraph(classes, brackets, pairs, dir)
+	p.text = text
+	return p
+}
+
+// canonicalBracket returns the canonical equivalent of `r`, so that
+// U+2329 and U+232A match U+3008 and U+3009 (see BD16).
+func canonicalBracket(r rune) rune {
+	if a, b, ok := ucd.Decompose(r); ok && b == 0 {
+		return a
+	}
+	return r
+}
+
+// Level returns the embedding level of the paragraph.
+func (p *Paragraph) Level() Level { return p.level }
+
+// Levels returns the resolved embedding levels of the paragraph,
+// before the line-dependent rule L1 is applied (see Line.Levels).
+// The characters removed by the rule X9 are given the level of the
+// preceding character.
+// The returned slice must not be modified.
+func (p *Paragraph) Levels() []Level { return p.levels }
+
+// Run is a range of the paragraph text, [Start, End), with
+// a constant embedding level.
+type Run struct {
+	Start, End int
+	Level      Level
+}
+
+// IsRTL returns true if the run is right-to-left.
+func (r Run) IsRTL() bool { return r.Level.IsRTL() }
@@ -0,0 +1,228 @@
+package bidi
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// the conformance files are vendored from the Unicode 15.1.0 database
+// (the tables are generated from the 13.0.0 one): BidiTest.txt only depends
+// on the bidi classes, and the test cases of BidiCharacterTest.txt also hold
+// for the 13.0.0 properties.
+const (
+	bidiTestVersion       = "15.1.0"
+	bidiTestFile          = "../unicodedata/generate/BidiTest.txt"
+	bidiCharacterTestFile = "../unicodedata/generate/BidiCharacterTest.txt"
+)
+
+func openTestFile(t *testing.T, filename string) *bufio.Scanner {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	// the first line gives the version of the file
+	if !scanner.Scan() || !strings.HasSuffix(scanner.Text(), "-"+bidiTestVersion+".txt") {
+		t.Fatalf("%s is not the test file of Unicode %s (header %q)", filename, bidiTestVersion, scanner.Text())
+	}
+	return scanner
+}
+
+func parseLevels(t *testing.T, fields []string) []int {
+	out := make([]int, len(fields))
+	for i, s := range fields {
+		if s == "x" {
+			out[i] = -1
+			continue
+		}
+		l, err := strconv.Atoi(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// checkLine compares the levels and the visual order of the whole paragraph
+// with the expected ones, where removed characters have a -1 level.
+func checkLine(p *Paragraph, levels []int, order []int) bool {
+	line := p.Line(0, len(p.classes))
+	for i, l := range levels {
+		if l != -1 && int(line.Levels()[i]) != l {
+			return false
+		}
+	}
+	var gotOrder []int
+	for _, i := range line.VisualOrder() {
+		if levels[i] != -1 {
+			gotOrder = append(gotOrder, i)
+		}
+	}
+	return reflect.DeepEqual(gotOrder, order) || len(gotOrder) == 0 && len(order) == 0
+}
+
+func TestBidiClasses(t *testing.T) {
+	scanner := openTestFile(t, bidiTestFile)
+	names := map[string]Class{}
+	for c, name := range classNames {
+		names[name] = Class(c)
+	}
+
+	var levels, order []int
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "@Levels:") {
+			levels = parseLevels(t, strings.Fields(strings.TrimPrefix(line, "@Levels:")))
+			continue
+		}
+		if strings.HasPrefix(line, "@Reorder:") {
+			order = parseLevels(t, strings.Fields(strings.TrimPrefix(line, "@Reorder:")))
+			continue
+		}
+		fields := strings.Split(line, ";")
+		var classes []Class
+		for _, name := range strings.Fields(fields[0]) {
+			classes = append(classes, names[name])
+		}
+		bitset, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		brackets, pairs := make([]ucd.BracketType, len(classes)), make([]rune, len(classes))
+		for _, dir := range [...]Direction{Auto, LeftToRight, RightToLeft} {
+			if bitset&(1<<dir) == 0 {
+				continue
+			}
+			p := newParagraph(classes, brackets, pairs, dir)
+			if !checkLine(p, levels, order) {
+				t.Errorf("line %d, direction %d: for %v, expected levels %v and order %v, got %v and %v",
+					lineNumber, dir, classes, levels, order, p.Line(0, len(classes)).Levels(), p.Line(0, len(classes)).VisualOrder())
+			}
+		}
+	}
+}
+
+func TestBidiCharacters(t *testing.T) {
+	scanner := openTestFile(t, bidiCharacterTestFile)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		var text []rune
+		for _, s := range strings.Fields(fields[0]) {
+			r, err := strconv.ParseUint(s, 16, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text = append(text, rune(r))
+		}
+		dir := [...]Direction{LeftToRight, RightToLeft, Auto}[fields[1][0]-'0']
+		level := parseLevels(t, []string{fields[2]})[0]
+		levels := parseLevels(t, strings.Fields(fields[3]))
+		order := parseLevels(t, strings.Fields(fields[4]))
+
+		p := NewParagraph(text, dir)
+		if int(p.Level()) != level || !checkLine(p, levels, order) {
+			t.Errorf("line %d: for %U, expected level %d, levels %v and order %v, got %d, %v and %v",
+				lineNumber, text, level, levels, order, p.Level(), p.Line(0, len(text)).Levels(), p.Line(0, len(text)).VisualOrder())
+		}
+	}
+}
+
+func TestLookupClass(t *testing.T) {
+	for r, expected := range map[rune]Class{
+		'a': L, 'א': R, 'ب': AL, '1': EN, '+': ES, '$': ET, '٣': AN, ',': CS,
+		0x0300: NSM, 0x200B: BN, 0x2029: B, '\t': S, ' ': WS, '!': ON,
+		0x202A: LRE, 0x202D: LRO, 0x202B: RLE, 0x202E: RLO, 0x202C: PDF,
+		0x2066: LRI, 0x2067: RLI, 0x2068: FSI, 0x2069: PDI,
+	} {
+		if got := LookupClass(r); got != expected {
+			t.Errorf("for %U, expected %s, got %s", r, expected, got)
+		}
+	}
+}
+
+func TestParagraph(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		dir    Direction
+		level  Level
+		levels []Level
+	}{
+		{"abc אבג def", Auto, 0, []Level{0, 0, 0, 0, 1, 1, 1, 0, 0, 0, 0}},
+		{"אבג abc!", Auto, 1, []Level{1, 1, 1, 1, 2, 2, 2, 1}},
+		{"א 123", Auto, 1, []Level{1, 1, 2, 2, 2}},
+		{"abc  ", RightToLeft, 1, []Level{2, 2, 2, 1, 1}},
+		// the closing bracket follows the opening one (N0)
+		{"a(b)א", RightToLeft, 1, []Level{2, 2, 2, 2, 1}},
+		// the isolate is skipped by P2
+		{"⁦abc⁩א", Auto, 1, []Level{1, 2, 2, 2, 1, 1}},
+		// explicit embedding: the removed characters take the preceding level
+		{"a‫bc‬d", LeftToRight, 0, []Level{0, 0, 2, 2, 2, 0}},
+	} {
+		p := NewParagraph([]rune(test.text), test.dir)
+		if p.Level() != test.level {
+			t.Errorf("for %q, expected paragraph level %d, got %d", test.text, test.level, p.Level())
+		}
+		if got := p.Line(0, len(p.text)).Levels(); !reflect.DeepEqual(got, test.levels) {
+			t.Errorf("for %q, expected levels %v, got %v", test.text, test.levels, got)
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	text := []rune("abc אבג def")
+	line := NewParagraph(text, Auto).Line(0, len(text))
+	expectedOrder := []int{0, 1, 2, 3, 6, 5, 4, 7, 8, 9, 10}
+	if got := line.VisualOrder(); !reflect.DeepEqual(got, expectedOrder) {
+		t.Errorf("expected %v, got %v", expectedOrder, got)
+	}
+	expectedRuns := []Run{{0, 4, 0}, {4, 7, 1}, {7, 11, 0}}
+	if got := line.Runs(); !reflect.DeepEqual(got, expectedRuns) {
+		t.Errorf("expected %v, got %v", expectedRuns, got)
+	}
+
+	text = []rune("אבג abc!")
+	line = NewParagraph(text, Auto).Line(0, len(text))
+	expectedRuns = []Run{{7, 8, 1}, {4, 7, 2}, {0, 4, 1}}
+	if got := line.Runs(); !reflect.DeepEqual(got, expectedRuns) {
+		t.Errorf("expected %v, got %v", expectedRuns, got)
+	}
+
+	// the levels depend on the line: trailing whitespace is reset (L1)
+	text = []rune("אב cd ef")
+	p := NewParagraph(text, RightToLeft)
+	if got := p.Levels()[5]; got != 2 {
+		t.Errorf("expected level 2, got %d", got)
+	}
+	if got := p.Line(0, 6).Levels()[5]; got != 1 {
+		t.Errorf("expected level 1, got %d", got)
+	}
+	expectedRuns = []Run{{3, 5, 2}, {0, 3, 1}}
+	if got := p.Line(0, 5).Runs(); !reflect.DeepEqual(got, expectedRuns) {
+		t.Errorf("expected %v, got %v", expectedRuns, got)
+	}
+}
+
+func TestMirrored(t *testing.T) {
+	text := []rune("a(א)[b]")
+	line := NewParagraph(text, RightToLeft).Line(0, len(text))
+	// all the brackets are resolved to right-to-left (N0)
+	if got := string(line.Mirrored()); got != "a)א(]b[" {
+		t.Errorf("unexpected mirrored text %q (levels %v)", got, line.Levels())
+	}
+}
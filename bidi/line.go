@@ -0,0 +1,126 @@
+package bidi
+
+import ucd "github.com/boxesandglue/textlayout/unicodedata"
+
+// This file implements the rules L1, L2 and L4 of UAX #9,
+// applied on each line of a paragraph.
+
+// Line is a line of a paragraph, with its final embedding levels.
+type Line struct {
+	p          *Paragraph
+	start, end int
+	levels     []Level // indexed from start
+}
+
+// Line returns the line [start, end) of the paragraph, applying the rule L1.
+// It panics if the range is invalid.
+func (p *Paragraph) Line(start, end int) *Line {
+	line := &Line{
+		p:      p,
+		start:  start,
+		end:    end,
+		levels: append([]Level(nil), p.levels[start:end]...),
+	}
+	// reset the separators, and the whitespace preceding them or ending the line
+	trailing := true
+	for i := end - 1; i >= start; i-- {
+		switch c := p.classes[i]; {
+		case c == B || c == S:
+			line.levels[i-start] = p.level
+			trailing = true
+		case trailing && (c == WS || c.isIsolateInitiator() || c == PDI || c.isRemovedByX9()):
+			line.levels[i-start] = p.level
+		default:
+			trailing = false
+		}
+	}
+	return line
+}
+
+// Levels returns the embedding levels of the line, starting at
+// the beginning of the line.
+// The returned slice must not be modified.
+func (l *Line) Levels() []Level { return l.levels }
+
+// reorder applies the rule L2 on `order`, whose levels
+// are given by `levels` (which is modified).
+func reorder(levels []Level, order []int) {
+	var highest, lowestOdd Level = 0, maxDepth + 2
+	for _, level := range levels {
+		if level > highest {
+			highest = level
+		}
+		if level.IsRTL() && level < lowestOdd {
+			lowestOdd = level
+		}
+	}
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(levels); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(levels) && levels[end] >= level {
+				end++
+			}
+			for a, b := i, end-1; a < b; a, b = a+1, b-1 {
+				levels[a], levels[b] = levels[b], levels[a]
+				order[a], order[b] = order[b], order[a]
+			}
+			i = end
+		}
+	}
+}
+
+// VisualOrder returns the indices of the characters of the line in the
+// paragraph, in visual order (rule L2).
+func (l *Line) VisualOrder() []int {
+	order := make([]int, len(l.levels))
+	for i := range order {
+		order[i] = l.start + i
+	}
+	reorder(append([]Level(nil), l.levels...), order)
+	return order
+}
+
+// Runs returns the maximal runs of the line with the same level, in visual order.
+// Each run may be shaped on its own, with a direction given by its level.
+func (l *Line) Runs() []Run {
+	var (
+		runs   []Run
+		levels []Level
+	)
+	for i, level := range l.levels {
+		if len(runs) != 0 && runs[len(runs)-1].Level == level {
+			runs[len(runs)-1].End++
+			continue
+		}
+		runs = append(runs, Run{Start: l.start + i, End: l.start + i + 1, Level: level})
+		levels = append(levels, level)
+	}
+	order := make([]int, len(runs))
+	for i := range order {
+		order[i] = i
+	}
+	reorder(levels, order)
+	out := make([]Run, len(runs))
+	for i, j := range order {
+		out[i] = runs[j]
+	}
+	return out
+}
+
+// Mirrored returns the text of the line, in logical order, where
+// the characters with a right-to-left level are replaced by their
+// mirrored glyph, when it exists (rule L4).
+// The paragraph must have been created from a text.
+func (l *Line) Mirrored() []rune {
+	out := append([]rune(nil), l.p.text[l.start:l.end]...)
+	for i, r := range out {
+		if l.levels[i].IsRTL() {
+			out[i], _ = ucd.LookupMirrorChar(r)
+		}
+	}
+	return out
+}
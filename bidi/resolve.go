@@ -0,0 +1,570 @@
+package bidi
+
+import (
+	"sort"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// This file implements the resolution of the embedding levels
+// of a paragraph, that is the rules P2 to I2 of UAX #9.
+
+const (
+	maxDepth        = 125 // maximum explicit embedding level
+	maxPairingDepth = 63  // size of the stack used by BD16
+)
+
+func newParagraph(classes []Class, brackets []ucd.BracketType, pairs []rune, dir Direction) *Paragraph {
+	p := &Paragraph{
+		classes:  classes,
+		types:    append([]Class(nil), classes...),
+		brackets: brackets,
+		pairs:    pairs,
+		levels:   make([]Level, len(classes)),
+	}
+	p.matchIsolates()
+
+	switch dir {
+	case LeftToRight:
+		p.level = 0
+	case RightToLeft:
+		p.level = 1
+	default: // P2 and P3
+		if p.firstStrong(0, len(classes)) == R {
+			p.level = 1
+		}
+	}
+
+	p.resolveExplicit()
+	for i, c := range p.classes { // X9
+		if c.isRemovedByX9() {
+			p.types[i] = BN
+		}
+	}
+	for _, seq := range p.isolatingRunSequences() {
+		seq.resolveWeak()
+		seq.resolveBrackets()
+		seq.resolveNeutrals()
+		seq.resolveImplicit()
+	}
+	p.assignRemovedLevels()
+	return p
+}
+
+// matchIsolates applies BD9
+func (p *Paragraph) matchIsolates() {
+	p.matchingPDI = make([]int, len(p.classes))
+	p.matchingInitiator = make([]int, len(p.classes))
+	var stack []int
+	for i, c := range p.classes {
+		p.matchingPDI[i], p.matchingInitiator[i] = -1, -1
+		switch c {
+		case LRI, RLI, FSI:
+			stack = append(stack, i)
+		case PDI:
+			if len(stack) != 0 {
+				initiator := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				p.matchingPDI[initiator], p.matchingInitiator[i] = i, initiator
+			}
+		case B:
+			stack = stack[:0]
+		}
+	}
+}
+
+// firstStrong returns the direction (L or R) of the first strong character
+// in [start, end), skipping isolates, or ON if there is none.
+func (p *Paragraph) firstStrong(start, end int) Class {
+	for i := start; i < end; i++ {
+		switch p.classes[i] {
+		case L:
+			return L
+		case R, AL:
+			return R
+		case LRI, RLI, FSI:
+			if p.matchingPDI[i] == -1 {
+				return ON
+			}
+			i = p.matchingPDI[i]
+		case B:
+			return ON
+		}
+	}
+	return ON
+}
+
+// nextLevel returns the least greater odd (if `rtl`) or even level
+func nextLevel(level Level, rtl bool) Level {
+	if rtl {
+		return (level + 1) | 1
+	}
+	return (level + 2) &^ 1
+}
+
+type directionalStatus struct {
+	level    Level
+	override Class // ON for neutral, L or R
+	isolate  bool
+}
+
+// resolveExplicit applies the rules X1 to X8.
+func (p *Paragraph) resolveExplicit() {
+	stack := make([]directionalStatus, 1, maxDepth+2)
+	stack[0] = directionalStatus{level: p.level, override: ON}
+	var overflowIsolates, overflowEmbeddings, validIsolates int
+
+	for i, c := range p.classes {
+		top := stack[len(stack)-1]
+		switch c {
+		case RLE, LRE, RLO, LRO: // X2 to X5
+			p.levels[i] = top.level
+			level := nextLevel(top.level, c == RLE || c == RLO)
+			if level <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				status := directionalStatus{level: level, override: ON}
+				if c == RLO {
+					status.override = R
+				} else if c == LRO {
+					status.override = L
+				}
+				stack = append(stack, status)
+			} else if overflowIsolates == 0 {
+				overflowEmbeddings++
+			}
+		case RLI, LRI, FSI: // X5a to X5c
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.types[i] = top.override
+			}
+			rtl := c == RLI
+			if c == FSI {
+				end := p.matchingPDI[i]
+				if end == -1 {
+					end = len(p.classes)
+				}
+				rtl = p.firstStrong(i+1, end) == R
+			}
+			level := nextLevel(top.level, rtl)
+			if level <= maxDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				validIsolates++
+				stack = append(stack, directionalStatus{level: level, override: ON, isolate: true})
+			} else {
+				overflowIsolates++
+			}
+		case PDI: // X6a
+			if overflowIsolates > 0 {
+				overflowIsolates--
+			} else if validIsolates > 0 {
+				overflowEmbeddings = 0
+				for !stack[len(stack)-1].isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolates--
+			}
+			top = stack[len(stack)-1]
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.types[i] = top.override
+			}
+		case PDF: // X7
+			p.levels[i] = top.level
+			if overflowIsolates > 0 {
+			} else if overflowEmbeddings > 0 {
+				overflowEmbeddings--
+			} else if !top.isolate && len(stack) >= 2 {
+				stack = stack[:len(stack)-1]
+			}
+		case B: // X8
+			p.levels[i] = p.level
+			stack = stack[:1]
+			overflowIsolates, overflowEmbeddings, validIsolates = 0, 0, 0
+		case BN:
+			p.levels[i] = top.level
+		default: // X6
+			p.levels[i] = top.level
+			if top.override != ON {
+				p.types[i] = top.override
+			}
+		}
+	}
+}
+
+// levelRuns returns the level runs of the paragraph (BD7),
+// ignoring the characters removed by X9.
+func (p *Paragraph) levelRuns() [][]int {
+	var (
+		runs    [][]int
+		current []int
+	)
+	for i, c := range p.classes {
+		if c.isRemovedByX9() {
+			continue
+		}
+		if len(current) != 0 && p.levels[i] != p.levels[current[0]] {
+			runs = append(runs, current)
+			current = nil
+		}
+		current = append(current, i)
+	}
+	if len(current) != 0 {
+		runs = append(runs, current)
+	}
+	return runs
+}
+
+// isolatingRunSequence is a sequence of characters
+// on which the rules W1 to I2 are applied (BD13)
+type isolatingRunSequence struct {
+	p       *Paragraph
+	indices []int   // into the paragraph
+	types   []Class // resolved types of the characters
+	level   Level
+	sos     Class // L or R
+	eos     Class // L or R
+}
+
+// isolatingRunSequences applies the rule X10
+func (p *Paragraph) isolatingRunSequences() []*isolatingRunSequence {
+	runs := p.levelRuns()
+	runOf := make([]int, len(p.classes))
+	for k, run := range runs {
+		for _, i := range run {
+			runOf[i] = k
+		}
+	}
+	// chain the runs ending with an isolate initiator with the
+	// run starting with its matching PDI
+	next := make([]int, len(runs))
+	chained := make([]bool, len(runs))
+	for k, run := range runs {
+		next[k] = -1
+		last := run[len(run)-1]
+		if pdi := p.matchingPDI[last]; p.classes[last].isIsolateInitiator() && pdi != -1 {
+			if other := runOf[pdi]; runs[other][0] == pdi {
+				next[k] = other
+				chained[other] = true
+			}
+		}
+	}
+
+	var out []*isolatingRunSequence
+	for k := range runs {
+		if chained[k] {
+			continue
+		}
+		var indices []int
+		for r := k; r != -1; r = next[r] {
+			indices = append(indices, runs[r]...)
+		}
+		out = append(out, p.newIsolatingRunSequence(indices))
+	}
+	return out
+}
+
+func typeForLevel(level Level) Class {
+	if level.IsRTL() {
+		return R
+	}
+	return L
+}
+
+func (p *Paragraph) newIsolatingRunSequence(indices []int) *isolatingRunSequence {
+	seq := isolatingRunSequence{
+		p:       p,
+		indices: indices,
+		types:   make([]Class, len(indices)),
+		level:   p.levels[indices[0]],
+	}
+	for k, i := range indices {
+		seq.types[k] = p.types[i]
+	}
+
+	prevLevel := p.level
+	for i := indices[0] - 1; i >= 0; i-- {
+		if !p.classes[i].isRemovedByX9() {
+			prevLevel = p.levels[i]
+			break
+		}
+	}
+	seq.sos = typeForLevel(maxLevel(prevLevel, seq.level))
+
+	last := indices[len(indices)-1]
+	nextLevel := p.level
+	if !p.classes[last].isIsolateInitiator() {
+		for i := last + 1; i < len(p.classes); i++ {
+			if !p.classes[i].isRemovedByX9() {
+				nextLevel = p.levels[i]
+				break
+			}
+		}
+	}
+	seq.eos = typeForLevel(maxLevel(nextLevel, seq.level))
+	return &seq
+}
+
+func maxLevel(a, b Level) Level {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// resolveWeak applies the rules W1 to W7
+func (s *isolatingRunSequence) resolveWeak() {
+	types := s.types
+
+	// W1
+	for k, t := range types {
+		if t != NSM {
+			continue
+		}
+		if k == 0 {
+			types[k] = s.sos
+		} else if prev := types[k-1]; prev.isIsolateInitiator() || prev == PDI {
+			types[k] = ON
+		} else {
+			types[k] = prev
+		}
+	}
+
+	// W2 and W3
+	lastStrong := s.sos
+	for k, t := range types {
+		switch t {
+		case L, R:
+			lastStrong = t
+		case AL:
+			lastStrong = t
+			types[k] = R
+		case EN:
+			if lastStrong == AL {
+				types[k] = AN
+			}
+		}
+	}
+
+	// W4
+	for k := 1; k < len(types)-1; k++ {
+		prev, next := types[k-1], types[k+1]
+		switch types[k] {
+		case ES:
+			if prev == EN && next == EN {
+				types[k] = EN
+			}
+		case CS:
+			if prev == EN && next == EN {
+				types[k] = EN
+			} else if prev == AN && next == AN {
+				types[k] = AN
+			}
+		}
+	}
+
+	// W5
+	for k := 0; k < len(types); {
+		if types[k] != ET {
+			k++
+			continue
+		}
+		end := k
+		for end < len(types) && types[end] == ET {
+			end++
+		}
+		if k > 0 && types[k-1] == EN || end < len(types) && types[end] == EN {
+			for ; k < end; k++ {
+				types[k] = EN
+			}
+		}
+		k = end
+	}
+
+	// W6
+	for k, t := range types {
+		if t == ES || t == ET || t == CS {
+			types[k] = ON
+		}
+	}
+
+	// W7
+	lastStrong = s.sos
+	for k, t := range types {
+		switch t {
+		case L, R:
+			lastStrong = t
+		case EN:
+			if lastStrong == L {
+				types[k] = L
+			}
+		}
+	}
+}
+
+// strongDirection returns L or R for strong types, where numbers count as R,
+// or ON for the others.
+func strongDirection(t Class) Class {
+	switch t {
+	case L:
+		return L
+	case R, EN, AN:
+		return R
+	}
+	return ON
+}
+
+// locateBrackets applies BD16, returning the pairs of brackets, as indices
+// into the sequence, sorted by opening position.
+func (s *isolatingRunSequence) locateBrackets() [][2]int {
+	type opener struct {
+		pos  int
+		pair rune
+	}
+	var (
+		stack []opener
+		pairs [][2]int
+	)
+	for k, i := range s.indices {
+		if s.types[k] != ON {
+			continue
+		}
+		switch s.p.brackets[i] {
+		case ucd.BracketOpen:
+			if len(stack) == maxPairingDepth {
+				return pairs
+			}
+			stack = append(stack, opener{pos: k, pair: s.p.pairs[i]})
+		case ucd.BracketClose:
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].pair == s.p.pairs[i] {
+					pairs = append(pairs, [2]int{stack[j].pos, k})
+					stack = stack[:j]
+					break
+				}
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a][0] < pairs[b][0] })
+	return pairs
+}
+
+// resolveBrackets applies the rule N0
+func (s *isolatingRunSequence) resolveBrackets() {
+	embedding := typeForLevel(s.level)
+	for _, pair := range s.locateBrackets() {
+		open, close := pair[0], pair[1]
+		inside := ON
+		for k := open + 1; k < close; k++ {
+			if dir := strongDirection(s.types[k]); dir == embedding {
+				inside = dir
+				break
+			} else if dir != ON {
+				inside = dir
+			}
+		}
+
+		var dir Class
+		switch inside {
+		case ON: // N0 d
+			continue
+		case embedding: // N0 b
+			dir = embedding
+		default: // N0 c: use the context before the opening bracket
+			before := s.sos
+			for k := open - 1; k >= 0; k-- {
+				if d := strongDirection(s.types[k]); d != ON {
+					before = d
+					break
+				}
+			}
+			dir = embedding
+			if before == inside {
+				dir = inside
+			}
+		}
+		s.setBracketType(open, dir)
+		s.setBracketType(close, dir)
+	}
+}
+
+// setBracketType sets the type of the bracket at `k`,
+// and of the original NSM following it
+func (s *isolatingRunSequence) setBracketType(k int, dir Class) {
+	s.types[k] = dir
+	for k++; k < len(s.types) && s.p.classes[s.indices[k]] == NSM; k++ {
+		s.types[k] = dir
+	}
+}
+
+func isNeutral(t Class) bool {
+	switch t {
+	case B, S, WS, ON, LRI, RLI, FSI, PDI:
+		return true
+	}
+	return false
+}
+
+// resolveNeutrals applies the rules N1 and N2
+func (s *isolatingRunSequence) resolveNeutrals() {
+	types := s.types
+	embedding := typeForLevel(s.level)
+	for k := 0; k < len(types); {
+		if !isNeutral(types[k]) {
+			k++
+			continue
+		}
+		end := k
+		for end < len(types) && isNeutral(types[end]) {
+			end++
+		}
+		before, after := s.sos, s.eos
+		if k > 0 {
+			before = strongDirection(types[k-1])
+		}
+		if end < len(types) {
+			after = strongDirection(types[end])
+		}
+		dir := embedding
+		if before == after {
+			dir = before
+		}
+		for ; k < end; k++ {
+			types[k] = dir
+		}
+	}
+}
+
+// resolveImplicit applies the rules I1 and I2, and
+// stores the result in the paragraph
+func (s *isolatingRunSequence) resolveImplicit() {
+	for k, i := range s.indices {
+		t, level := s.types[k], s.level
+		if level.IsRTL() {
+			if t == L || t == EN || t == AN {
+				level++
+			}
+		} else {
+			if t == R {
+				level++
+			} else if t == AN || t == EN {
+				level += 2
+			}
+		}
+		s.p.levels[i] = level
+		s.p.types[i] = t
+	}
+}
+
+// assignRemovedLevels gives the characters removed by X9
+// the level of the preceding character.
+func (p *Paragraph) assignRemovedLevels() {
+	for i, c := range p.classes {
+		if !c.isRemovedByX9() {
+			continue
+		}
+		if i == 0 {
+			p.levels[i] = p.level
+		} else {
+			p.levels[i] = p.levels[i-1]
+		}
+	}
+}
@@ -0,0 +1,563 @@
+// Command hb-shape shapes text with a font and prints the resulting glyphs,
+// mirroring the hb-shape tool distributed with HarfBuzz, so that both outputs
+// may be compared directly.
+//
+// Usage:
+//
+//	hb-shape [OPTION...] [FONT-FILE] [TEXT]
+//
+// The text is read from the TEXT argument, the --text, --unicodes or --text-file options,
+// and each line is shaped separately. Run hb-shape --help for the list of options.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boxesandglue/textlayout/fonts"
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
+	"github.com/boxesandglue/textlayout/harfbuzz"
+	"github.com/boxesandglue/textlayout/language"
+)
+
+// ported from harfbuzz/util/hb-shape.cc, main-font-text.hh Copyright © 2010, 2011,2012  Google, Inc. Behdad Esfahbod
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "hb-shape:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// options stores the parsed command line.
+type options struct {
+	fontFile  string
+	faceIndex int
+	fontSizeX int // fontSizeUpem for the default
+	fontSizeY int
+	xPpem     uint16
+	yPpem     uint16
+	ptem      float64
+
+	variations []tt.Variation
+	features   []harfbuzz.Feature
+	shapers    []string
+
+	props          harfbuzz.SegmentProperties
+	flags          harfbuzz.ShapingOptions
+	clusterLevel   harfbuzz.ClusterLevel
+	invisibleGlyph fonts.GID
+	notFoundGlyph  fonts.GID
+	utf8Clusters   bool
+
+	textBefore, textAfter []rune
+	text                  *string // nil if not set by --text or --unicodes
+	textFile              string
+	glyphs                bool // the text is a glyph string
+
+	format      harfbuzz.SerializeFormat
+	formatFlags harfbuzz.SerializeFlags
+	outputFile  string
+	trace       bool
+}
+
+const fontSizeUpem = 0x7FFFFFFF
+
+const featuresUsage = `Comma-separated list of font features
+
+    Features can be enabled or disabled, either globally or limited to
+    specific character ranges.  The format for specifying feature settings
+    follows.  All valid CSS font-feature-settings values other than 'normal'
+    and the global values are also accepted, though not documented below.
+    CSS string escapes are not supported.
+
+    The range indices refer to the positions between Unicode characters,
+    unless the --utf8-clusters is provided, in which case range indices
+    refer to UTF-8 byte indices. The position before the first character
+    is always 0.
+
+    The format is Python-esque.  Here is how it all works:
+
+      Syntax:       Value:    Start:    End:
+
+    Setting value:
+      "kern"        1         0         ∞         // Turn feature on
+      "+kern"       1         0         ∞         // Turn feature on
+      "-kern"       0         0         ∞         // Turn feature off
+      "kern=0"      0         0         ∞         // Turn feature off
+      "kern=1"      1         0         ∞         // Turn feature on
+      "aalt=2"      2         0         ∞         // Choose 2nd alternate
+
+    Setting index:
+      "kern[]"      1         0         ∞         // Turn feature on
+      "kern[:]"     1         0         ∞         // Turn feature on
+      "kern[5:]"    1         5         ∞         // Turn feature on, partial
+      "kern[:5]"    1         0         5         // Turn feature on, partial
+      "kern[3:5]"   1         3         5         // Turn feature on, range
+      "kern[3]"     1         3         3+1       // Turn feature on, single char
+
+    Mixing it all:
+
+      "aalt[3:5]=2" 2         3         5         // Turn 2nd alternate on for range
+`
+
+const variationsUsage = `Comma-separated list of font variations
+
+    Variations are set globally. The format for specifying variation settings
+    follows.  All valid CSS font-variation-settings values other than 'normal'
+    and 'inherited' are also accepted, although not documented below.
+
+    The format is a tag, optionally followed by an equals sign, followed by a
+    number. For example:
+
+      "wght=500"
+      "slnt=-7.5"
+`
+
+// splitList splits a comma (or space) separated list, ignoring empty items
+func splitList(s string) []string {
+	s = strings.Trim(s, `"`)
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+func parseUnicodes(s string) ([]rune, error) {
+	var text []rune
+	for _, chunk := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' || r == '\t' }) {
+		hex := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(chunk, "U+"), "u+"), "0x")
+		r, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Unicode codepoint %s", chunk)
+		}
+		text = append(text, rune(r))
+	}
+	return text, nil
+}
+
+func parseDirection(s string) (harfbuzz.Direction, error) {
+	if s != "" {
+		switch s[0] {
+		case 'l', 'L':
+			return harfbuzz.LeftToRight, nil
+		case 'r', 'R':
+			return harfbuzz.RightToLeft, nil
+		case 't', 'T':
+			return harfbuzz.TopToBottom, nil
+		case 'b', 'B':
+			return harfbuzz.BottomToTop, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid direction %s", s)
+}
+
+// parseTwoInts parses "x" or "x y" (or "x,y")
+func parseTwoInts(s, name string) (x, y int, err error) {
+	fields := splitList(s)
+	if len(fields) != 1 && len(fields) != 2 {
+		return 0, 0, fmt.Errorf("%s argument should be one or two space-separated numbers", name)
+	}
+	if x, err = strconv.Atoi(fields[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %s", name, err)
+	}
+	y = x
+	if len(fields) == 2 {
+		if y, err = strconv.Atoi(fields[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %s", name, err)
+		}
+	}
+	return x, y, nil
+}
+
+func parseOptions(args []string, stderr io.Writer) (options, error) {
+	opts := options{fontSizeX: fontSizeUpem, fontSizeY: fontSizeUpem}
+	flags := flag.NewFlagSet("hb-shape", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: hb-shape [OPTION...] [FONT-FILE] [TEXT]")
+		fmt.Fprintln(stderr, "Shape text with given font.")
+		flags.PrintDefaults()
+	}
+
+	// font options
+	flags.StringVar(&opts.fontFile, "font-file", "", "Set font file-name")
+	flags.IntVar(&opts.faceIndex, "face-index", 0, "Set face index")
+	flags.Func("font-size", "Font size (default: upem)", func(s string) (err error) {
+		if s == "upem" {
+			opts.fontSizeX, opts.fontSizeY = fontSizeUpem, fontSizeUpem
+			return nil
+		}
+		opts.fontSizeX, opts.fontSizeY, err = parseTwoInts(s, "font-size")
+		return err
+	})
+	flags.Func("font-ppem", "Set x,y pixels per EM (default: 0; disabled)", func(s string) error {
+		x, y, err := parseTwoInts(s, "font-ppem")
+		opts.xPpem, opts.yPpem = uint16(x), uint16(y)
+		return err
+	})
+	flags.Float64Var(&opts.ptem, "font-ptem", 0, "Set font point-size (default: 0; disabled)")
+	flags.Func("variations", variationsUsage, func(s string) error {
+		for _, item := range splitList(s) {
+			v, err := harfbuzz.ParseVariation(item)
+			if err != nil {
+				return err
+			}
+			opts.variations = append(opts.variations, v)
+		}
+		return nil
+	})
+	flags.String("font-funcs", "", "(ignored)")
+	flags.String("ft-load-flags", "", "(ignored)")
+
+	// text options
+	flags.Func("text", "Set input text", func(s string) error {
+		opts.text = &s
+		return nil
+	})
+	flags.StringVar(&opts.textFile, "text-file", "", `Set input text file-name ("-" for stdin)`)
+	flags.Func("unicodes", "Set input Unicode codepoints", func(s string) error {
+		runes, err := parseUnicodes(s)
+		text := string(runes)
+		opts.text = &text
+		return err
+	})
+	flags.BoolVar(&opts.glyphs, "glyphs", false, "Treat text as glyph string, such as [gid12|gid13] or [a=0|b=1]")
+	flags.Func("text-before", "Set text context before each line", func(s string) error {
+		opts.textBefore = []rune(s)
+		return nil
+	})
+	flags.Func("text-after", "Set text context after each line", func(s string) error {
+		opts.textAfter = []rune(s)
+		return nil
+	})
+	flags.Func("unicodes-before", "Set Unicode codepoints context before each line", func(s string) (err error) {
+		opts.textBefore, err = parseUnicodes(s)
+		return err
+	})
+	flags.Func("unicodes-after", "Set Unicode codepoints context after each line", func(s string) (err error) {
+		opts.textAfter, err = parseUnicodes(s)
+		return err
+	})
+
+	// shape options
+	flags.Bool("list-shapers", false, "(ignored)")
+	flags.Func("shapers", "Set comma-separated list of shapers to try (ot, graphite2, fallback)", func(s string) error {
+		opts.shapers = splitList(s)
+		for _, shaper := range opts.shapers {
+			if shaper != "ot" && shaper != "graphite2" && shaper != "fallback" {
+				return fmt.Errorf("unknown shaper %s", shaper)
+			}
+		}
+		return nil
+	})
+	flags.Func("shaper", "Force a shaper", func(s string) error {
+		return flags.Set("shapers", s)
+	})
+	flags.Func("direction", "Set text direction (default: auto)", func(s string) (err error) {
+		opts.props.Direction, err = parseDirection(s)
+		return err
+	})
+	flags.Func("language", "Set text language (default: $LANG)", func(s string) error {
+		opts.props.Language = language.NewLanguage(s)
+		return nil
+	})
+	flags.Func("script", "Set text script, as an ISO-15924 tag (default: auto)", func(s string) (err error) {
+		opts.props.Script, err = language.ParseScript(s)
+		return err
+	})
+	flags.Func("features", featuresUsage, func(s string) error {
+		for _, item := range strings.Split(strings.Trim(s, `"`), ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			f, err := harfbuzz.ParseFeature(item)
+			if err != nil {
+				return fmt.Errorf("parsing features %s: %s", s, err)
+			}
+			opts.features = append(opts.features, f)
+		}
+		return nil
+	})
+	bufferFlag := func(name, usage string, flag harfbuzz.ShapingOptions) {
+		flags.BoolFunc(name, usage, func(s string) error {
+			set, err := strconv.ParseBool(s)
+			if set {
+				opts.flags |= flag
+			} else {
+				opts.flags &^= flag
+			}
+			return err
+		})
+	}
+	bufferFlag("bot", "Treat text as beginning-of-paragraph", harfbuzz.Bot)
+	bufferFlag("eot", "Treat text as end-of-paragraph", harfbuzz.Eot)
+	bufferFlag("preserve-default-ignorables", "Preserve Default-Ignorable characters", harfbuzz.PreserveDefaultIgnorables)
+	bufferFlag("remove-default-ignorables", "Remove Default-Ignorable characters", harfbuzz.RemoveDefaultIgnorables)
+	bufferFlag("unsafe-to-concat", "Produce unsafe-to-concat glyph flag", harfbuzz.ProduceUnsafeToConcat)
+	bufferFlag("safe-to-insert-tatweel", "Produce safe-to-insert-tatweel glyph flag", harfbuzz.ProduceSafeToInsertTatweel)
+	flags.Func("invisible-glyph", "Glyph value to replace Default-Ignorables with", func(s string) error {
+		gid, err := strconv.ParseUint(s, 10, 16)
+		opts.invisibleGlyph = fonts.GID(gid)
+		return err
+	})
+	flags.Func("not-found-glyph", "Glyph value to replace not-found characters with", func(s string) error {
+		gid, err := strconv.ParseUint(s, 10, 16)
+		opts.notFoundGlyph = fonts.GID(gid)
+		return err
+	})
+	flags.Func("cluster-level", "Cluster merging level (0/1/2, default: 0)", func(s string) error {
+		l, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid cluster-level option: %s", err)
+		}
+		if l < 0 || l > 2 {
+			return fmt.Errorf("invalid cluster-level option: %d", l)
+		}
+		opts.clusterLevel = harfbuzz.ClusterLevel(l)
+		return nil
+	})
+	flags.BoolVar(&opts.utf8Clusters, "utf8-clusters", false, "Use UTF-8 byte indices, not char indices")
+
+	// output options
+	flags.StringVar(&opts.outputFile, "output-file", "", "Set output file-name (default: stdout)")
+	flags.Func("output-format", "Set output serialization format (text, json)", func(s string) (err error) {
+		opts.format, err = harfbuzz.ParseSerializeFormat(s)
+		return err
+	})
+	formatFlag := func(name, usage string, flag harfbuzz.SerializeFlags) {
+		flags.BoolFunc(name, usage, func(s string) error {
+			set, err := strconv.ParseBool(s)
+			if set {
+				opts.formatFlags |= flag
+			} else {
+				opts.formatFlags &^= flag
+			}
+			return err
+		})
+	}
+	formatFlag("no-glyph-names", "Output glyph indices instead of names", harfbuzz.SerializeFlagNoGlyphNames)
+	formatFlag("no-positions", "Do not output glyph positions", harfbuzz.SerializeFlagNoPositions)
+	formatFlag("no-advances", "Do not output glyph advances", harfbuzz.SerializeFlagNoAdvances)
+	formatFlag("no-clusters", "Do not output cluster indices", harfbuzz.SerializeFlagNoClusters)
+	formatFlag("show-extents", "Output glyph extents", harfbuzz.SerializeFlagGlyphExtents)
+	formatFlag("show-flags", "Output glyph flags", harfbuzz.SerializeFlagGlyphFlags)
+	flags.BoolVar(&opts.trace, "trace", false, "Output interim shaping results")
+	formatFlag("ned", "No Extra Data; Do not output clusters or advances", harfbuzz.SerializeFlagNoClusters|harfbuzz.SerializeFlagNoAdvances)
+
+	// as in HarfBuzz, options and positional arguments may be interleaved
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return opts, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+
+	if opts.fontFile == "" && len(positional) != 0 {
+		opts.fontFile, positional = positional[0], positional[1:]
+	}
+	if opts.text == nil && opts.textFile == "" && len(positional) != 0 {
+		text := positional[0]
+		opts.text, positional = &text, positional[1:]
+	}
+	if len(positional) != 0 {
+		return opts, fmt.Errorf("too many arguments on the command line")
+	}
+	if opts.fontFile == "" {
+		return opts, errors.New("no font file specified")
+	}
+	if opts.text == nil && opts.textFile == "" {
+		return opts, errors.New("no text specified")
+	}
+	return opts, nil
+}
+
+// noGraphiteFace disables the Graphite shaper.
+type noGraphiteFace struct {
+	*tt.Font
+}
+
+func (noGraphiteFace) IsGraphite() (*tt.Font, bool) { return nil, false }
+
+// fallbackFace hides the OpenType layout tables,
+// so that the fallback shaper is used.
+type fallbackFace struct {
+	harfbuzz.Face
+}
+
+// loadFont returns the font to use for shaping, taking into
+// account the shapers list.
+func (opts options) loadFont() (*harfbuzz.Font, error) {
+	file, err := os.Open(opts.fontFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	faces, err := tt.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	if opts.faceIndex < 0 || opts.faceIndex >= len(faces) {
+		return nil, fmt.Errorf("invalid face index %d for %d faces", opts.faceIndex, len(faces))
+	}
+	face := faces[opts.faceIndex].(*tt.Font)
+	tt.SetVariations(face, opts.variations)
+
+	var hbFace harfbuzz.Face = face
+	for _, shaper := range opts.shapers {
+		if shaper == "graphite2" {
+			if _, is := face.IsGraphite(); !is {
+				continue
+			}
+			break
+		}
+		if shaper == "ot" {
+			hbFace = noGraphiteFace{face}
+		} else { // fallback
+			hbFace = fallbackFace{face}
+		}
+		break
+	}
+
+	font := harfbuzz.NewFont(hbFace)
+	upem := int32(face.Upem())
+	font.XScale, font.YScale = upem, upem
+	if opts.fontSizeX != fontSizeUpem {
+		font.XScale = int32(opts.fontSizeX)
+	}
+	if opts.fontSizeY != fontSizeUpem {
+		font.YScale = int32(opts.fontSizeY)
+	}
+	font.XPpem, font.YPpem = opts.xPpem, opts.yPpem
+	font.Ptem = float32(opts.ptem)
+	return font, nil
+}
+
+// readLines returns the lines of text to shape
+func (opts options) readLines(stdin io.Reader) ([]string, error) {
+	if opts.text != nil {
+		return strings.Split(*opts.text, "\n"), nil
+	}
+
+	input := stdin
+	if opts.textFile != "-" {
+		file, err := os.Open(opts.textFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	var lines []string
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// shapeLine shapes one line of text, returning the serialized glyphs.
+// If trace is not nil, the interim results are written into it.
+func (opts options) shapeLine(font *harfbuzz.Font, line string, trace io.Writer) (string, error) {
+	buffer := harfbuzz.NewBuffer()
+	if trace != nil {
+		buffer.SetMessageFunc(func(buf *harfbuzz.Buffer, font *harfbuzz.Font, msg string) bool {
+			fmt.Fprintf(trace, "trace: %s\tbuffer: %s\n", msg, buf.Serialize(font, opts.format, opts.formatFlags))
+			return true
+		})
+	}
+	if opts.glyphs {
+		if !strings.HasPrefix(line, "[") {
+			line = "[" + line + "]"
+		}
+		if err := buffer.Deserialize(font, line, harfbuzz.SerializeFormatText); err != nil {
+			return "", err
+		}
+	} else {
+		opts.addText(buffer, line)
+	}
+
+	buffer.Props = opts.props
+	buffer.Flags = opts.flags
+	buffer.ClusterLevel = opts.clusterLevel
+	buffer.Invisible = opts.invisibleGlyph
+	buffer.NotFound = opts.notFoundGlyph
+	buffer.GuessSegmentProperties()
+
+	buffer.Shape(font, opts.features)
+
+	return buffer.Serialize(font, opts.format, opts.formatFlags), nil
+}
+
+// addText adds the runes of line and the context to buffer
+func (opts options) addText(buffer *harfbuzz.Buffer, line string) {
+	if len(opts.textBefore) != 0 {
+		buffer.AddRunes(opts.textBefore, len(opts.textBefore), 0)
+	}
+	text := []rune(line)
+	buffer.AddRunes(text, 0, len(text))
+	if opts.utf8Clusters { // use byte offsets instead of rune indices
+		offset := 0
+		for i := range buffer.Info {
+			buffer.Info[i].Cluster = offset
+			offset += utf8.RuneLen(text[i])
+		}
+	}
+	if len(opts.textAfter) != 0 {
+		buffer.AddRunes(opts.textAfter, 0, 0)
+	}
+}
+
+// run executes the command with the given arguments (without the program name)
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	opts, err := parseOptions(args, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	font, err := opts.loadFont()
+	if err != nil {
+		return err
+	}
+
+	lines, err := opts.readLines(stdin)
+	if err != nil {
+		return err
+	}
+
+	if opts.outputFile != "" {
+		file, err := os.Create(opts.outputFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		stdout = file
+	}
+
+	out := bufio.NewWriter(stdout)
+	for _, line := range lines {
+		var trace io.Writer
+		if opts.trace {
+			trace = out
+		}
+		glyphs, err := opts.shapeLine(font, line, trace)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, glyphs)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/harfbuzz"
+)
+
+// runTestFile runs the HarfBuzz shaping tests of `filename`, whose lines are
+// formatted as <font file>;<options>;<unicodes>;<expected output>
+func runTestFile(t *testing.T, dir, filename string) {
+	content, err := testdata.Files.ReadFile(filepath.Join(dir, "tests", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := t.TempDir()
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		chunks := strings.Split(line, ";")
+		if len(chunks) != 4 {
+			t.Fatalf("invalid test line %s", line)
+		}
+		fontFile, options, unicodes, expected := chunks[0], chunks[1], chunks[2], chunks[3]
+		fontFile = strings.Split(fontFile, "@")[0] // remove the hash
+
+		// copy the embedded font on disk
+		fontPath := filepath.Join(tmp, filepath.Base(fontFile))
+		if _, err := os.Stat(fontPath); err != nil {
+			font, err := testdata.Files.ReadFile(filepath.Join(dir, "tests", fontFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(fontPath, font, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		args := append(strings.Fields(options), "--font-file", fontPath, "--unicodes", unicodes)
+		var out bytes.Buffer
+		if err := run(args, nil, &out); err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+		if got := strings.TrimSpace(out.String()); expected != "*" && got != expected {
+			t.Fatalf("%s: expected\n%s\ngot\n%s", line, expected, got)
+		}
+	}
+}
+
+func TestShape(t *testing.T) {
+	dir := "harfbuzz_reference/in-house"
+	disabled := map[string]bool{
+		"collections.tests":   true, // invalid face index
+		"language-tags.tests": true, // see the harfbuzz package
+		"macos.tests":         true, // requires system fonts
+		"vertical.tests":      true, // requires FreeType font funcs
+	}
+	files, err := testdata.Files.ReadDir(filepath.Join(dir, "tests"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if !disabled[file.Name()] {
+			runTestFile(t, dir, file.Name())
+		}
+	}
+}
+
+func TestShapeFormat(t *testing.T) {
+	dir := "harfbuzz_reference/in-house/fonts"
+	writeFont := func(name string) string {
+		font, err := testdata.Files.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fontPath := filepath.Join(t.TempDir(), name)
+		if err = os.WriteFile(fontPath, font, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return fontPath
+	}
+	fontPath := writeFont("15dfc433a135a658b9f4b1a861b5cdd9658ccbb9.ttf")
+	kernPath := writeFont("e39391c77a6321c2ac7a2d644de0396470cd4bfe.ttf") // with a kern table
+
+	for _, test := range []struct {
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{[]string{fontPath, "12"}, "", "[one=0+1090|two=1+1090]\n"},
+		{[]string{"--no-glyph-names", "--ned", fontPath, "12"}, "", "[2|3@1090,0]\n"},
+		{[]string{fontPath, "--text", "1\n2"}, "", "[one=0+1090]\n[two=0+1090]\n"},
+		{[]string{"--text-file", "-", fontPath}, "1\n2\n", "[one=0+1090]\n[two=0+1090]\n"},
+		{[]string{"--glyphs", kernPath, "a=0|c=1|d=2"}, "", "[a=0+626|c=1+564|d=2@-15,0+657]\n"},
+		{[]string{"--glyphs", "--features", "-kern", kernPath, "[a=0|c=1|d=2]"}, "", "[a=0+626|c=1+579|d=2+672]\n"},
+		{[]string{"--show-flags", kernPath, "acd"}, "", "[a=0+626|c=1+564|d=2@-15,0+657#1]\n"},
+		{[]string{"--unsafe-to-concat", "--show-flags", kernPath, "acd"}, "", "[a=0+626#2|c=1+564#2|d=2@-15,0+657#3]\n"},
+		{[]string{"--output-format", "json", "--unicodes", "U+0031", fontPath}, "", `[{"g":"one","cl":0,"dx":0,"dy":0,"ax":1090,"ay":0}]` + "\n"},
+	} {
+		var out bytes.Buffer
+		if err := run(test.args, strings.NewReader(test.stdin), &out); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != test.expected {
+			t.Fatalf("%v: expected %q, got %q", test.args, test.expected, got)
+		}
+	}
+
+	var trace bytes.Buffer
+	if err := run([]string{"--trace", fontPath, "12"}, nil, &trace); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(trace.String(), "trace: start table GSUB\tbuffer: [one=0+0|two=1+0]\n") ||
+		!strings.HasSuffix(trace.String(), "\n[one=0+1090|two=1+1090]\n") {
+		t.Fatalf("unexpected trace %s", trace.String())
+	}
+
+	for _, args := range [][]string{
+		{},
+		{fontPath},
+		{fontPath, "12", "extra"},
+		{"--shapers", "invalid", fontPath, "12"},
+		{"--face-index", "2", fontPath, "12"},
+		{"--glyphs", fontPath, "[one=0|"},
+	} {
+		if err := run(args, nil, new(bytes.Buffer)); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
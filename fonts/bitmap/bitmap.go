@@ -587,9 +587,8 @@ func (f *Font) XHeightPDF() int {
 	panic("not implemented")
 }
 
-// Subset removes all data from the font except the one needed for the given
-// code points.
-func (f *Font) Subset(codepoints []fonts.GID) error {
+// Subset returns a font containing only the given glyphs.
+func (f *Font) Subset(gids []fonts.GID) (*fonts.SubsetResult, error) {
 	panic("not implemented")
 }
 
@@ -70,10 +70,30 @@ type FaceMetadata interface {
 }
 
 // Subsetter implements the Subset() method to create a subset of the font which
-// contains only the code for the codepoints. The returned byte slice can be
-// used in PDF.
+// contains only the given glyphs.
+// Subset does not modify its receiver, so that a font may be subsetted
+// several times, possibly concurrently.
 type Subsetter interface {
-	Subset(codepoints []GID) error
+	Subset(gids []GID) (*SubsetResult, error)
+	PDFEmbedder
+}
+
+// SubsetResult is the output of Subsetter.Subset.
+type SubsetResult struct {
+	// Font is the subsetted font, ready to be written in a PDF file.
+	Font PDFEmbedder
+
+	// GIDMap maps the glyphs of the original font to the glyphs of the subset,
+	// and may be used to build a /CIDToGIDMap.
+	GIDMap map[GID]GID
+
+	// ToUnicode maps the glyphs of the subset to the text they represent,
+	// when known.
+	ToUnicode map[GID][]rune
+}
+
+// PDFEmbedder provides the data needed to embed a font in a PDF file.
+type PDFEmbedder interface {
 	WriteSubset(w io.Writer) error
 	WidthsPDF() string
 	CMapPDF() string
@@ -330,7 +350,7 @@ type FaceRenderer interface {
 }
 
 // GlyphData describe how to draw a glyph.
-// It is either an GlyphOutline, GlyphSVG or GlyphBitmap.
+// It is either an GlyphOutline, GlyphSVG, GlyphBitmap or GlyphColor.
 type GlyphData interface {
 	isGlyphData()
 }
@@ -338,6 +358,7 @@ type GlyphData interface {
 func (GlyphOutline) isGlyphData() {}
 func (GlyphSVG) isGlyphData()     {}
 func (GlyphBitmap) isGlyphData()  {}
+func (GlyphColor) isGlyphData()   {}
 
 // GlyphOutline exposes the path to draw for
 // vector glyph.
@@ -0,0 +1,186 @@
+package fonts
+
+// GlyphColor is a color glyph, described by a graph of paint
+// operations, as found in OpenType COLR table.
+// Both layered glyphs (COLR version 0) and paint graphs (COLR version 1)
+// are exposed with this type, with variations already applied.
+//
+// Colors are given as indices into the palettes of the font,
+// which must be fetched from the font itself.
+type GlyphColor struct {
+	// Paint is the root of the paint graph
+	Paint Paint
+
+	// ClipBox, if not nil, is the region (in font units) outside of which
+	// the glyph is not drawn.
+	ClipBox *ClipBox
+
+	// Outline is the regular glyph description, which may be used
+	// by renderers not supporting color glyphs. It is empty if the
+	// font does not provide one.
+	Outline GlyphOutline
+}
+
+// ClipBox is a rectangle, expressed in font units.
+type ClipBox struct {
+	XMin, YMin, XMax, YMax float32
+}
+
+// PaletteIndexForeground is the special palette index used to
+// select the text foreground color, instead of a palette entry.
+const PaletteIndexForeground = 0xFFFF
+
+// Paint is one node in a color glyph paint graph.
+// It is one of PaintColrLayers, PaintSolid, PaintLinearGradient, PaintRadialGradient,
+// PaintSweepGradient, PaintGlyph, PaintColrGlyph, PaintTransform or PaintComposite.
+type Paint interface {
+	isPaint()
+}
+
+func (PaintColrLayers) isPaint()     {}
+func (PaintSolid) isPaint()          {}
+func (PaintLinearGradient) isPaint() {}
+func (PaintRadialGradient) isPaint() {}
+func (PaintSweepGradient) isPaint()  {}
+func (PaintGlyph) isPaint()          {}
+func (PaintColrGlyph) isPaint()      {}
+func (PaintTransform) isPaint()      {}
+func (PaintComposite) isPaint()      {}
+
+// PaintColrLayers draws each layer, in order, on top of the previous ones.
+type PaintColrLayers []Paint
+
+// PaintSolid fills the drawing area with a solid color.
+type PaintSolid struct {
+	PaletteIndex uint16  // index in the palette, or PaletteIndexForeground
+	Alpha        float32 // in [0, 1], multiplying the alpha of the palette color
+}
+
+// ColorStop is one color of a gradient.
+type ColorStop struct {
+	Offset       float32 // position on the color line
+	PaletteIndex uint16  // index in the palette, or PaletteIndexForeground
+	Alpha        float32 // in [0, 1], multiplying the alpha of the palette color
+}
+
+// Extend specifies how a gradient is drawn
+// outside of its color line.
+type Extend uint8
+
+const (
+	ExtendPad Extend = iota
+	ExtendRepeat
+	ExtendReflect
+)
+
+// ColorLine defines the colors of a gradient.
+type ColorLine struct {
+	Extend Extend
+	Stops  []ColorStop // sorted by offset
+}
+
+// PaintLinearGradient fills the drawing area with a linear gradient,
+// defined by the start point P0, the end point P1, and the rotation point P2.
+type PaintLinearGradient struct {
+	ColorLine
+	X0, Y0, X1, Y1, X2, Y2 float32
+}
+
+// PaintRadialGradient fills the drawing area with a gradient
+// between two circles.
+type PaintRadialGradient struct {
+	ColorLine
+	X0, Y0, R0 float32 // start circle
+	X1, Y1, R1 float32 // end circle
+}
+
+// PaintSweepGradient fills the drawing area with a conic gradient
+// around a center.
+type PaintSweepGradient struct {
+	ColorLine
+	CenterX, CenterY float32
+	// Angles are expressed in counter-clockwise degrees
+	StartAngle, EndAngle float32
+}
+
+// PaintGlyph fills the outline of the glyph `Glyph` with `Paint`.
+type PaintGlyph struct {
+	Paint Paint
+	Glyph GID
+}
+
+// PaintColrGlyph draws the color glyph `Glyph`, that is,
+// the paint graph of another base glyph.
+type PaintColrGlyph struct {
+	Glyph GID
+}
+
+// Transform is an affine transformation, mapping
+// (x, y) to (XX*x + XY*y + DX, YX*x + YY*y + DY).
+type Transform struct {
+	XX, YX, XY, YY, DX, DY float32
+}
+
+// Multiply returns the transformation applying `other`
+// then `t`.
+func (t Transform) Multiply(other Transform) Transform {
+	return Transform{
+		XX: t.XX*other.XX + t.XY*other.YX,
+		YX: t.YX*other.XX + t.YY*other.YX,
+		XY: t.XX*other.XY + t.XY*other.YY,
+		YY: t.YX*other.XY + t.YY*other.YY,
+		DX: t.XX*other.DX + t.XY*other.DY + t.DX,
+		DY: t.YX*other.DX + t.YY*other.DY + t.DY,
+	}
+}
+
+// PaintTransform draws `Paint` with the given transformation applied.
+// The various transformations defined in the COLR table (translations, rotations, scales, skews)
+// are all converted to this type.
+type PaintTransform struct {
+	Paint     Paint
+	Transform Transform
+}
+
+// CompositeMode is a blending mode used to
+// combine two paints.
+type CompositeMode uint8
+
+const (
+	CompositeClear CompositeMode = iota
+	CompositeSrc
+	CompositeDest
+	CompositeSrcOver
+	CompositeDestOver
+	CompositeSrcIn
+	CompositeDestIn
+	CompositeSrcOut
+	CompositeDestOut
+	CompositeSrcAtop
+	CompositeDestAtop
+	CompositeXor
+	CompositePlus
+	CompositeScreen
+	CompositeOverlay
+	CompositeDarken
+	CompositeLighten
+	CompositeColorDodge
+	CompositeColorBurn
+	CompositeHardLight
+	CompositeSoftLight
+	CompositeDifference
+	CompositeExclusion
+	CompositeMultiply
+	CompositeHSLHue
+	CompositeHSLSaturation
+	CompositeHSLColor
+	CompositeHSLLuminosity
+)
+
+// PaintComposite draws `Source` on top of `Backdrop`,
+// blended using `Mode`.
+type PaintComposite struct {
+	Source   Paint
+	Backdrop Paint
+	Mode     CompositeMode
+}
@@ -35,6 +35,11 @@ const (
 	// T1_SPEC.pdf 6.1 Encoding as a limitation of 24.
 	psArgStackSize = 48
 
+	// psArgStackSizeCFF2 is the argument stack size for CFF2 data, which
+	// uses the default "maxstack" value of 513, so that the blend operator
+	// may work on many regions.
+	psArgStackSizeCFF2 = 513
+
 	// Similarly, Appendix B says "Subr nesting, stack limit 10".
 	psCallStackSize = 10
 
@@ -51,8 +56,14 @@ const (
 	Type1Charstring                  // Charstring in Type1 font files
 )
 
+// CFF2 is a flag which may be combined with `TopDict`, `PrivateDict` and
+// `Type2Charstring` to select the CFF2 flavour of the format.
+const CFF2 PsContext = 1 << 8
+
 type ArgStack struct {
-	Vals [psArgStackSize]int32
+	// Vals is allocated by `Machine.Run`, with the size
+	// allowed by the context (larger for CFF2 data).
+	Vals []int32
 	// Effective size currently in use. The first value to
 	// pop is at index Top-1
 	Top int32
@@ -107,7 +118,8 @@ type Machine struct {
 	ArgStack ArgStack
 
 	parseNumberBuf [maxRealNumberStrLen]byte
-	ctx            PsContext
+	ctx            PsContext // without the CFF2 flag
+	cff2           bool
 }
 
 // SkipBytes skips the next `count` bytes from the instructions, and clears the
@@ -141,14 +153,33 @@ const escapeByte = 12
 // `localSubrs` and `globalSubrs` contains the subroutines that may be called in
 // the instructions.
 func (p *Machine) Run(instructions []byte, localSubrs, globalSubrs [][]byte, handler PsOperatorHandler) error {
-	p.ctx = handler.Context()
+	ctx := handler.Context()
+	p.ctx, p.cff2 = ctx&^CFF2, ctx&CFF2 != 0
+	size := psArgStackSize
+	if p.cff2 {
+		size = psArgStackSizeCFF2
+	}
+	if cap(p.ArgStack.Vals) < size {
+		p.ArgStack.Vals = make([]int32, size)
+	}
+	p.ArgStack.Vals = p.ArgStack.Vals[:size]
 	p.instructions = instructions
 	p.localSubrs = localSubrs
 	p.globalSubrs = globalSubrs
 	p.ArgStack.Top = 0
 	p.callStack.top = 0
 
-	for len(p.instructions) > 0 {
+	for {
+		if len(p.instructions) == 0 {
+			// CFF2 subroutines have no return operator: they
+			// end implicitly with their instructions
+			if !p.cff2 || p.callStack.top == 0 {
+				break
+			}
+			p.Return()
+			continue
+		}
+
 		// Push a numeric operand on the stack, if applicable.
 		if hasResult, err := p.parseNumber(); hasResult {
 			if err != nil {
@@ -263,11 +294,17 @@ func (p *Machine) parseNumber() (hasResult bool, err error) {
 			return true, errInvalidCFFTable
 		}
 		number, hasResult = int32(be.Uint32(p.instructions[1:])), true
+		if p.cff2 && p.ctx == Type2Charstring {
+			// 5177.Type2.pdf section 3.2 "Charstring Number Encoding" says that
+			// the value is a 16.16 fixed point number: CFF2 outlines
+			// are loaded with integer coordinates, so round it
+			number = (number + 1<<15) >> 16
+		}
 		p.instructions = p.instructions[5:]
 	}
 
 	if hasResult {
-		if p.ArgStack.Top == psArgStackSize {
+		if int(p.ArgStack.Top) == len(p.ArgStack.Vals) {
 			return true, errInvalidCFFTable
 		}
 		p.ArgStack.Vals[p.ArgStack.Top] = number
@@ -0,0 +1,131 @@
+package fonts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxCMapBlock is the maximum number of entries in a
+// bfchar or bfrange block, as required by the PDF specification.
+const maxCMapBlock = 100
+
+// ToUnicodeCMap returns a /ToUnicode CMap, for a font whose character codes
+// are the 2-byte glyph indices (such as with the Identity-H encoding).
+// `toUnicode` maps glyphs to the text they represent, which may
+// contain several runes, for instance for ligatures. Glyphs with empty text are ignored.
+// Consecutive glyphs mapped to consecutive runes are written as ranges.
+func ToUnicodeCMap(toUnicode map[GID][]rune) string {
+	type entry struct {
+		gid  GID
+		text []uint16 // UTF-16 encoded
+	}
+	entries := make([]entry, 0, len(toUnicode))
+	for gid, runes := range toUnicode {
+		if len(runes) != 0 {
+			entries = append(entries, entry{gid, utf16.Encode(runes)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].gid < entries[j].gid })
+
+	// follows returns true if `e` may extend a range ending with `prev`:
+	// only the last byte of the glyph and of the text may be incremented
+	follows := func(prev, e entry) bool {
+		if e.gid != prev.gid+1 || e.gid>>8 != prev.gid>>8 || len(e.text) != len(prev.text) {
+			return false
+		}
+		last := len(e.text) - 1
+		for i := 0; i < last; i++ {
+			if e.text[i] != prev.text[i] {
+				return false
+			}
+		}
+		return e.text[last] == prev.text[last]+1 && e.text[last]>>8 == prev.text[last]>>8
+	}
+
+	type bfrange struct {
+		start, end GID
+		text       []uint16
+	}
+	var (
+		chars  []entry
+		ranges []bfrange
+	)
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && follows(entries[j-1], entries[j]) {
+			j++
+		}
+		if j-i == 1 {
+			chars = append(chars, entries[i])
+		} else {
+			ranges = append(ranges, bfrange{entries[i].gid, entries[j-1].gid, entries[i].text})
+		}
+		i = j
+	}
+
+	writeText := func(b *strings.Builder, text []uint16) {
+		b.WriteByte('<')
+		for _, u := range text {
+			fmt.Fprintf(b, "%04X", u)
+		}
+		b.WriteByte('>')
+	}
+
+	var b strings.Builder
+	b.WriteString(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo << /Registry (Adobe)/Ordering (UCS)/Supplement 0>> def
+/CMapName /Adobe-Identity-UCS def /CMapType 2 def
+1 begincodespacerange
+<0000><FFFF>
+endcodespacerange
+`)
+	for start := 0; start < len(chars); start += maxCMapBlock {
+		block := chars[start:]
+		if len(block) > maxCMapBlock {
+			block = block[:maxCMapBlock]
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(block))
+		for _, e := range block {
+			fmt.Fprintf(&b, "<%04X>", e.gid)
+			writeText(&b, e.text)
+			b.WriteByte('\n')
+		}
+		b.WriteString("endbfchar\n")
+	}
+	for start := 0; start < len(ranges); start += maxCMapBlock {
+		block := ranges[start:]
+		if len(block) > maxCMapBlock {
+			block = block[:maxCMapBlock]
+		}
+		fmt.Fprintf(&b, "%d beginbfrange\n", len(block))
+		for _, r := range block {
+			fmt.Fprintf(&b, "<%04X><%04X>", r.start, r.end)
+			writeText(&b, r.text)
+			b.WriteByte('\n')
+		}
+		b.WriteString("endbfrange\n")
+	}
+	b.WriteString(`endcmap CMapName currentdict /CMap defineresource pop end end`)
+	return b.String()
+}
+
+// ToUnicodeCMap returns a /ToUnicode CMap for the subset, where `text` maps glyphs of
+// the original font to the text they represent, as computed when shaping.
+// The glyphs not in `text` use the mapping from the ToUnicode field.
+// See the package level ToUnicodeCMap function for more details.
+func (r *SubsetResult) ToUnicodeCMap(text map[GID][]rune) string {
+	toUnicode := make(map[GID][]rune, len(r.GIDMap))
+	for gid, runes := range r.ToUnicode {
+		toUnicode[gid] = runes
+	}
+	for gid, runes := range text {
+		if newGID, ok := r.GIDMap[gid]; ok && len(runes) != 0 {
+			toUnicode[newGID] = runes
+		}
+	}
+	return ToUnicodeCMap(toUnicode)
+}
@@ -0,0 +1,42 @@
+package fonts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToUnicodeCMap(t *testing.T) {
+	toUnicode := map[GID][]rune{
+		1: {'f', 'f', 'i'}, // ligature
+		2: {'é'},
+		3: {0x1F600}, // outside the BMP
+		4: nil,       // ignored
+	}
+	// a range crossing the 0x100 boundary is split
+	for gid := GID(0xF0); gid < 0x110; gid++ {
+		toUnicode[gid] = []rune{'A' + rune(gid-0xF0)}
+	}
+	// not consecutive: written as several bfchar blocks
+	for i := GID(0); i < 250; i++ {
+		toUnicode[0x1000+i] = []rune{'a' + 2*rune(i)}
+	}
+
+	cmap := ToUnicodeCMap(toUnicode)
+	for _, exp := range []string{
+		"<0001><006600660069>\n",
+		"<0002><00E9>\n",
+		"<0003><D83DDE00>\n",
+		"<00F0><00FF><0041>\n",
+		"<0100><010F><0051>\n",
+		"100 beginbfchar\n",
+		"53 beginbfchar\n", // 253 = 100 + 100 + 53
+		"2 beginbfrange\n",
+	} {
+		if !strings.Contains(cmap, exp) {
+			t.Fatalf("expected %q in CMap:\n%s", exp, cmap)
+		}
+	}
+	if strings.Contains(cmap, "<0004>") {
+		t.Fatal("unexpected mapping for empty text")
+	}
+}
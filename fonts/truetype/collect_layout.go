@@ -0,0 +1,304 @@
+package truetype
+
+// This file implements the collection of the glyphs involved in
+// the lookups of the advanced layout tables (GSUB, GPOS), used
+// to introspect the features of a font.
+
+// LookupGlyphs stores the glyphs which may be involved when applying
+// a set of lookups, as returned by the CollectGlyphs methods.
+// As for Harfbuzz, the sets are a superset of the glyphs actually
+// matched: the context of contextual lookups is not checked, and
+// the class 0 of class definitions is ignored.
+type LookupGlyphs struct {
+	Before map[GID]bool // glyphs which may be matched before the input sequence (backtrack)
+	Input  map[GID]bool // glyphs which may be matched in the input sequence
+	After  map[GID]bool // glyphs which may be matched after the input sequence (lookahead)
+	Output map[GID]bool // glyphs which may be produced by substitutions (GSUB only)
+}
+
+func newLookupGlyphs() LookupGlyphs {
+	return LookupGlyphs{
+		Before: make(map[GID]bool),
+		Input:  make(map[GID]bool),
+		After:  make(map[GID]bool),
+		Output: make(map[GID]bool),
+	}
+}
+
+// CollectGlyphs returns the glyphs involved in the given lookups, including
+// the glyphs produced by their nested lookups. Invalid indices are ignored.
+func (t *TableGSUB) CollectGlyphs(lookupIndices []uint16) LookupGlyphs {
+	c := glyphCollector{out: newLookupGlyphs(), visited: make(map[uint16]bool)}
+	c.lookup = func(index uint16) {
+		if int(index) >= len(t.Lookups) {
+			return
+		}
+		for _, subtable := range t.Lookups[index].Subtables {
+			c.gsubSubtable(subtable)
+		}
+	}
+	for _, index := range lookupIndices {
+		c.lookup(index)
+	}
+	return c.out
+}
+
+// CollectGlyphs returns the glyphs involved in the given lookups.
+// Invalid indices are ignored, and the returned Output set is always empty.
+func (t *TableGPOS) CollectGlyphs(lookupIndices []uint16) LookupGlyphs {
+	c := glyphCollector{out: newLookupGlyphs()}
+	for _, index := range lookupIndices {
+		if int(index) >= len(t.Lookups) {
+			continue
+		}
+		for _, subtable := range t.Lookups[index].Subtables {
+			c.gposSubtable(subtable)
+		}
+	}
+	return c.out
+}
+
+type glyphCollector struct {
+	out LookupGlyphs
+	// lookup collects the given lookup; it is nil for GPOS, whose
+	// nested lookups never produce glyphs
+	lookup  func(index uint16)
+	visited map[uint16]bool // nested lookups already collected
+}
+
+// nested collects the output of the nested lookups.
+func (c *glyphCollector) nested(lookups []SequenceLookup) {
+	if c.lookup == nil {
+		return
+	}
+	for _, l := range lookups {
+		if c.visited[l.LookupIndex] {
+			continue
+		}
+		c.visited[l.LookupIndex] = true
+		// only the glyphs produced are relevant
+		saved := c.out
+		c.out = LookupGlyphs{
+			Before: make(map[GID]bool),
+			Input:  make(map[GID]bool),
+			After:  make(map[GID]bool),
+			Output: saved.Output,
+		}
+		c.lookup(l.LookupIndex)
+		c.out = saved
+	}
+}
+
+func addGlyphs(set map[GID]bool, glyphs []GID) {
+	for _, g := range glyphs {
+		set[g] = true
+	}
+}
+
+func addGlyphIDs(set map[GID]bool, glyphs []uint16) {
+	for _, g := range glyphs {
+		set[GID(g)] = true
+	}
+}
+
+func addCoverage(set map[GID]bool, cov Coverage) {
+	addGlyphs(set, coverageGlyphs(cov))
+}
+
+// addClasses adds the glyphs of `class` with one of the given class values.
+// The class 0 is ignored.
+func addClasses(set map[GID]bool, class Class, values []uint16) {
+	if class == nil || len(values) == 0 {
+		return
+	}
+	wanted := make(map[uint32]bool, len(values))
+	for _, v := range values {
+		if v != 0 {
+			wanted[uint32(v)] = true
+		}
+	}
+	forEachClassGlyph(class, func(g GID, class uint32) {
+		if wanted[class] {
+			set[g] = true
+		}
+	})
+}
+
+// addAllClasses adds the glyphs of `class` with a non zero class value.
+func addAllClasses(set map[GID]bool, class Class) {
+	forEachClassGlyph(class, func(g GID, class uint32) {
+		if class != 0 {
+			set[g] = true
+		}
+	})
+}
+
+// forEachClassGlyph calls `fn` for each glyph explicitly listed in `class`.
+func forEachClassGlyph(class Class, fn func(g GID, class uint32)) {
+	switch class := class.(type) {
+	case classFormat1:
+		for i, c := range class.classIDs {
+			fn(class.startGlyph+GID(i), c)
+		}
+	case classFormat2:
+		for _, r := range class {
+			for g := uint32(r.start); g <= uint32(r.end); g++ {
+				fn(GID(g), r.targetClassID)
+			}
+		}
+	}
+}
+
+func (c *glyphCollector) context1(cov Coverage, data LookupContext1) {
+	addCoverage(c.out.Input, cov)
+	for _, rules := range data {
+		for _, rule := range rules {
+			addGlyphIDs(c.out.Input, rule.Input)
+			c.nested(rule.Lookups)
+		}
+	}
+}
+
+func (c *glyphCollector) context2(cov Coverage, data LookupContext2) {
+	addCoverage(c.out.Input, cov)
+	for _, rules := range data.SequenceSets {
+		for _, rule := range rules {
+			addClasses(c.out.Input, data.Class, rule.Input)
+			c.nested(rule.Lookups)
+		}
+	}
+}
+
+func (c *glyphCollector) context3(data LookupContext3) {
+	for _, cov := range data.Coverages {
+		addCoverage(c.out.Input, cov)
+	}
+	c.nested(data.SequenceLookups)
+}
+
+func (c *glyphCollector) chainedContext1(cov Coverage, data LookupChainedContext1) {
+	addCoverage(c.out.Input, cov)
+	for _, rules := range data {
+		for _, rule := range rules {
+			addGlyphIDs(c.out.Before, rule.Backtrack)
+			addGlyphIDs(c.out.Input, rule.Input)
+			addGlyphIDs(c.out.After, rule.Lookahead)
+			c.nested(rule.Lookups)
+		}
+	}
+}
+
+func (c *glyphCollector) chainedContext2(cov Coverage, data LookupChainedContext2) {
+	addCoverage(c.out.Input, cov)
+	for _, rules := range data.SequenceSets {
+		for _, rule := range rules {
+			addClasses(c.out.Before, data.BacktrackClass, rule.Backtrack)
+			addClasses(c.out.Input, data.InputClass, rule.Input)
+			addClasses(c.out.After, data.LookaheadClass, rule.Lookahead)
+			c.nested(rule.Lookups)
+		}
+	}
+}
+
+func (c *glyphCollector) chainedContext3(data LookupChainedContext3) {
+	for _, cov := range data.Backtrack {
+		addCoverage(c.out.Before, cov)
+	}
+	for _, cov := range data.Input {
+		addCoverage(c.out.Input, cov)
+	}
+	for _, cov := range data.Lookahead {
+		addCoverage(c.out.After, cov)
+	}
+	c.nested(data.SequenceLookups)
+}
+
+func (c *glyphCollector) gsubSubtable(st GSUBSubtable) {
+	switch data := st.Data.(type) {
+	case GSUBSingle1:
+		for _, g := range coverageGlyphs(st.Coverage) {
+			c.out.Input[g] = true
+			c.out.Output[GID(uint16(int(g)+int(data)))] = true
+		}
+	case GSUBSingle2:
+		addCoverage(c.out.Input, st.Coverage)
+		addGlyphs(c.out.Output, data)
+	case GSUBMultiple1:
+		addCoverage(c.out.Input, st.Coverage)
+		for _, seq := range data {
+			addGlyphs(c.out.Output, seq)
+		}
+	case GSUBAlternate1:
+		addCoverage(c.out.Input, st.Coverage)
+		for _, set := range data {
+			addGlyphs(c.out.Output, set)
+		}
+	case GSUBLigature1:
+		addCoverage(c.out.Input, st.Coverage)
+		for _, set := range data {
+			for _, lig := range set {
+				addGlyphIDs(c.out.Input, lig.Components)
+				c.out.Output[lig.Glyph] = true
+			}
+		}
+	case GSUBContext1:
+		c.context1(st.Coverage, LookupContext1(data))
+	case GSUBContext2:
+		c.context2(st.Coverage, LookupContext2(data))
+	case GSUBContext3:
+		c.context3(LookupContext3(data))
+	case GSUBChainedContext1:
+		c.chainedContext1(st.Coverage, LookupChainedContext1(data))
+	case GSUBChainedContext2:
+		c.chainedContext2(st.Coverage, LookupChainedContext2(data))
+	case GSUBChainedContext3:
+		c.chainedContext3(LookupChainedContext3(data))
+	case GSUBReverseChainedContext1:
+		for _, cov := range data.Backtrack {
+			addCoverage(c.out.Before, cov)
+		}
+		addCoverage(c.out.Input, st.Coverage)
+		for _, cov := range data.Lookahead {
+			addCoverage(c.out.After, cov)
+		}
+		addGlyphs(c.out.Output, data.Substitutes)
+	}
+}
+
+func (c *glyphCollector) gposSubtable(st GPOSSubtable) {
+	switch data := st.Data.(type) {
+	case GPOSPair1:
+		addCoverage(c.out.Input, st.Coverage)
+		for _, set := range data.Values {
+			for _, record := range set {
+				c.out.Input[record.SecondGlyph] = true
+			}
+		}
+	case GPOSPair2:
+		addCoverage(c.out.Input, st.Coverage)
+		addAllClasses(c.out.Input, data.Second)
+	case GPOSMarkToBase1:
+		addCoverage(c.out.Input, st.Coverage)
+		addCoverage(c.out.Input, data.BaseCoverage)
+	case GPOSMarkToLigature1:
+		addCoverage(c.out.Input, st.Coverage)
+		addCoverage(c.out.Input, data.LigatureCoverage)
+	case GPOSMarkToMark1:
+		addCoverage(c.out.Input, st.Coverage)
+		addCoverage(c.out.Input, data.Mark2Coverage)
+	case GPOSContext1:
+		c.context1(st.Coverage, LookupContext1(data))
+	case GPOSContext2:
+		c.context2(st.Coverage, LookupContext2(data))
+	case GPOSContext3:
+		c.context3(LookupContext3(data))
+	case GPOSChainedContext1:
+		c.chainedContext1(st.Coverage, LookupChainedContext1(data))
+	case GPOSChainedContext2:
+		c.chainedContext2(st.Coverage, LookupChainedContext2(data))
+	case GPOSChainedContext3:
+		c.chainedContext3(LookupChainedContext3(data))
+	default: // single and cursive
+		addCoverage(c.out.Input, st.Coverage)
+	}
+}
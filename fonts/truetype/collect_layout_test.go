@@ -0,0 +1,67 @@
+package truetype
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/truetype"
+)
+
+func TestCollectGlyphs(t *testing.T) {
+	filename := "ToyGPOSCursive.ttf"
+	file, err := testdata.Files.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open %q: %s\n", filename, err)
+	}
+
+	font, err := NewFontParser(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Parse(%q) err = %q, want nil", filename, err)
+	}
+
+	gsub, err := font.GSUBTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type sets struct{ before, input, after, output []GID }
+	for _, test := range []struct {
+		feature string
+		exp     sets
+	}{
+		{"medi", sets{nil, []GID{1, 4}, nil, []GID{5, 6, 9}}},
+		// chained contexts, with a nested single substitution (lookup 6)
+		{"calt", sets{[]GID{6}, []GID{6, 7, 9}, []GID{6, 8, 9}, []GID{10}}},
+	} {
+		index, ok := gsub.FindFeatureIndex(MustNewTag(test.feature))
+		if !ok {
+			t.Fatalf("missing feature %s", test.feature)
+		}
+		glyphs := gsub.CollectGlyphs(gsub.Features[index].LookupIndices)
+		got := sets{sortedGlyphs(glyphs.Before), sortedGlyphs(glyphs.Input), sortedGlyphs(glyphs.After), sortedGlyphs(glyphs.Output)}
+		for i, pair := range [][2][]GID{{got.before, test.exp.before}, {got.input, test.exp.input}, {got.after, test.exp.after}, {got.output, test.exp.output}} {
+			if len(pair[0]) == 0 && len(pair[1]) == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(pair[0], pair[1]) {
+				t.Errorf("feature %s, set %d: expected %v, got %v", test.feature, i, pair[1], pair[0])
+			}
+		}
+	}
+
+	// invalid lookups are ignored
+	if glyphs := gsub.CollectGlyphs([]uint16{1000}); len(glyphs.Input) != 0 {
+		t.Fatalf("unexpected glyphs %v", glyphs.Input)
+	}
+
+	gpos, err := font.GPOSTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, _ := gpos.FindFeatureIndex(MustNewTag("curs"))
+	glyphs := gpos.CollectGlyphs(gpos.Features[index].LookupIndices)
+	if len(glyphs.Input) != 4 || len(glyphs.Output) != 0 {
+		t.Fatalf("unexpected glyphs %v", glyphs)
+	}
+}
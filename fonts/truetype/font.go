@@ -55,8 +55,14 @@ type Font struct {
 	hhea, vhea *TableHVhea
 	vorg       *tableVorg // optional
 	cff        *type1c.Font
-	post       TablePost // optional
-	svg        tableSVG  // optional
+	cff2       *type1c.CFF2 // optional
+	post       TablePost    // optional
+	svg        tableSVG     // optional
+	colr       tableCOLR    // optional
+
+	// CPAL stores the color palettes used by
+	// color glyphs, and is optional.
+	CPAL TableCPAL
 
 	// Optional, only present in variable fonts
 
@@ -98,20 +104,23 @@ type Font struct {
 	// HasHint is true if the font has a prep table.
 	HasHint bool
 
-	// A six letter string for PDF inclusion. Empty until Subset() is called.
+	// A six letter string for PDF inclusion. Empty except for fonts returned by Subset().
 	SubsetID string
 
-	// all codepoints in the subset
+	// all glyphs in the subset, using the new glyph indices
 	subsetCodepoints []GID
 
-	// store the glyph offsets when writing the glyf table
-	glyphOffsets []uint32
+	// text of the glyphs in the subset
+	toUnicode map[GID][]rune
 
 	// The prep table
 	prep []byte
 
 	// The cvt table
 	cvt []byte
+
+	// The fpgm table
+	fpgm []byte
 }
 
 // LayoutTables exposes advanced layout tables.
@@ -0,0 +1,463 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// This file implements the instancing of variable fonts, that is the
+// creation of a static font for given variation coordinates.
+
+var (
+	tagHorizontalClippingAscent  = MustNewTag("hcla")
+	tagHorizontalClippingDescent = MustNewTag("hcld")
+	tagHorizontalCaretRise       = MustNewTag("hcrs")
+	tagHorizontalCaretRun        = MustNewTag("hcrn")
+	tagHorizontalCaretOffset     = MustNewTag("hcof")
+	tagVerticalCaretRise         = MustNewTag("vcrs")
+	tagVerticalCaretRun          = MustNewTag("vcrn")
+	tagVerticalCaretOffset       = MustNewTag("vcof")
+	tagSubscriptXSize            = MustNewTag("sbxs")
+
+	tagAxisWeight = MustNewTag("wght")
+	tagAxisWidth  = MustNewTag("wdth")
+	tagAxisSlant  = MustNewTag("slnt")
+)
+
+// round16 rounds half up, as commonly done by font tools.
+func round16(v float32) int16 {
+	return int16(math.Floor(float64(v) + 0.5))
+}
+
+// Instance returns a static font, with the variations applied at the given
+// coordinates, expressed in design units. The axis not specified in `variations`
+// use their default value.
+// The glyph outlines ('gvar', including the inferred deltas), the glyph metrics
+// ('HVAR', 'VVAR'), the font wide metrics ('MVAR') and the advanced layout tables
+// (feature variations and device tables) are instanced, and the variation tables
+// are dropped. The 'OS/2' weight and width classes and the 'post' italic angle
+// are also updated from the 'wght', 'wdth' and 'slnt' axis.
+// The 'cvar' table is not supported, so that the hinting uses the default control values.
+//
+// The receiver is not modified. The returned font may be subsetted, or written with WriteFont.
+// Fonts with CFF2 outlines are not supported.
+func (font *Font) Instance(variations []Variation) (*Font, error) {
+	if len(font.fvar.Axis) == 0 {
+		return nil, errors.New("font is not variable")
+	}
+	if font.cff2 != nil {
+		return nil, errors.New("instancing CFF2 outlines is not supported")
+	}
+
+	designCoords := font.fvar.GetDesignCoordsDefault(variations)
+	coords := font.NormalizeVariations(designCoords)
+
+	// varFont is used to fetch the values with variations applied
+	varFont := *font
+	varFont.varCoords = coords
+
+	inst := *font
+	inst.varCoords = nil
+	inst.fvar, inst.avar, inst.gvar, inst.mvar = TableFvar{}, nil, tableGvar{}, TableMvar{}
+	inst.hvar, inst.vvar = nil, nil
+
+	inst.Hmtx = make(TableHVmtx, len(font.Hmtx))
+	for i := range inst.Hmtx {
+		gid := GID(i)
+		inst.Hmtx[i] = Metric{Advance: round16(varFont.HorizontalAdvance(gid)), SideBearing: varFont.getHorizontalSideBearing(gid)}
+	}
+	if font.vmtx != nil {
+		inst.vmtx = make(TableHVmtx, len(font.vmtx))
+		for i := range inst.vmtx {
+			gid := GID(i)
+			inst.vmtx[i] = Metric{Advance: round16(-varFont.VerticalAdvance(gid)), SideBearing: varFont.getVerticalSideBearing(gid)}
+		}
+	}
+	if font.hhea != nil {
+		hhea := *font.hhea
+		inst.hhea = &hhea
+	}
+	if font.vhea != nil {
+		vhea := *font.vhea
+		inst.vhea = &vhea
+	}
+	if font.OS2 != nil {
+		os2 := *font.OS2
+		inst.OS2 = &os2
+	}
+
+	if len(font.Glyf) != 0 {
+		inst.instanceGlyf(&varFont)
+	}
+	inst.applyMvar(font.mvar, coords)
+	inst.applyAxisValues(font.fvar, designCoords)
+
+	gidMap := make(map[GID]GID, font.NumGlyphs)
+	glyphs := make([]GID, font.NumGlyphs)
+	for i := range glyphs {
+		glyphs[i] = GID(i)
+		gidMap[GID(i)] = GID(i)
+	}
+	s := layoutSubsetter{glyphs: glyphs, gidMap: gidMap, coords: coords, varStore: font.layoutTables.GDEF.VariationStore}
+	inst.layoutTables.GSUB = s.gsub(font.layoutTables.GSUB)
+	inst.layoutTables.GPOS = s.gpos(font.layoutTables.GPOS)
+	inst.layoutTables.GDEF = s.gdef(font.layoutTables.GDEF)
+
+	return &inst, nil
+}
+
+// instanceGlyf applies the variations of `varFont` to the
+// glyph outlines and updates the side bearings and the bounding boxes.
+func (inst *Font) instanceGlyf(varFont *Font) {
+	inst.Glyf = make(TableGlyf, len(varFont.Glyf))
+	// the phantom points of the variable glyphs, used to
+	// position the outlines as in the variable font
+	phantoms := make([][phantomCount]contourPoint, len(varFont.Glyf))
+	for i, glyph := range varFont.Glyf {
+		gid := GID(i)
+
+		var varPoints []contourPoint
+		varFont.getPointsForGlyph(gid, 1, &varPoints) // depth 1 : do not shift by the side bearing
+		if L := len(varPoints); L >= phantomCount {
+			copy(phantoms[i][:], varPoints[L-phantomCount:])
+		}
+
+		switch data := glyph.data.(type) {
+		case simpleGlyphData:
+			points := varFont.getGlyphOwnPoints(gid)
+			newData := simpleGlyphData{
+				endPtsOfContours: data.endPtsOfContours,
+				instructions:     data.instructions,
+				points:           make([]glyphContourPoint, len(data.points)),
+			}
+			for j, p := range data.points {
+				newData.points[j] = glyphContourPoint{
+					flag: p.flag & (flagOnCurve | overlapSimple),
+					x:    round16(points[j].X),
+					y:    round16(points[j].Y),
+				}
+			}
+			glyph.data = newData
+		case compositeGlyphData:
+			// the deltas of the pseudo points translate the components
+			points := varFont.getGlyphOwnPoints(gid)
+			newData := compositeGlyphData{
+				glyphs:       append([]compositeGlyphPart(nil), data.glyphs...),
+				instructions: data.instructions,
+			}
+			for j := range newData.glyphs {
+				part := &newData.glyphs[j]
+				if part.isAnchored() {
+					continue
+				}
+				dx, dy := part.argsAsTranslation()
+				part.flags |= arg1And2AreWords
+				part.arg1 = uint16(dx + round16(points[j].X))
+				part.arg2 = uint16(dy + round16(points[j].Y))
+			}
+			glyph.data = newData
+		}
+		inst.Glyf[i] = glyph
+	}
+
+	// the bounding boxes depend on the components, so
+	// they are computed once all the glyphs are updated
+	var (
+		hasBounds                               bool
+		advanceMax                              uint16
+		minLeftSideBearing, minRightSideBearing int16
+		maxExtent                               int16
+		xMin, yMin, xMax, yMax                  int16
+	)
+	for i := range inst.Glyf {
+		glyph := &inst.Glyf[i]
+		hasOutline := glyph.updateBounds(inst, GID(i))
+
+		switch data := glyph.data.(type) {
+		case simpleGlyphData:
+			glyph.rawdata = data.encode(glyph.encodeHeader(int16(len(data.endPtsOfContours))))
+		case compositeGlyphData:
+			glyph.rawdata = data.encode(glyph.encodeHeader(-1))
+		}
+
+		if i >= len(inst.Hmtx) {
+			continue
+		}
+		if uint16(inst.Hmtx[i].Advance) > advanceMax {
+			advanceMax = uint16(inst.Hmtx[i].Advance)
+		}
+		if !hasOutline {
+			inst.Hmtx[i].SideBearing = 0
+			if i < len(inst.vmtx) {
+				inst.vmtx[i].SideBearing = 0
+			}
+			continue
+		}
+
+		// with this side bearing, the outline is shifted
+		// by the (varied) left phantom point, as in the variable font
+		inst.Hmtx[i].SideBearing = glyph.Xmin - round16(phantoms[i][phantomLeft].X)
+		if i < len(inst.vmtx) {
+			inst.vmtx[i].SideBearing = round16(phantoms[i][phantomTop].Y) - glyph.Ymax
+		}
+		metric := inst.Hmtx[i]
+		rsb := metric.Advance - metric.SideBearing - (glyph.Xmax - glyph.Xmin)
+		extent := metric.SideBearing + (glyph.Xmax - glyph.Xmin)
+		if !hasBounds {
+			minLeftSideBearing, minRightSideBearing, maxExtent = metric.SideBearing, rsb, extent
+			xMin, yMin, xMax, yMax = glyph.Xmin, glyph.Ymin, glyph.Xmax, glyph.Ymax
+			hasBounds = true
+			continue
+		}
+		minLeftSideBearing = min16(minLeftSideBearing, metric.SideBearing)
+		minRightSideBearing = min16(minRightSideBearing, rsb)
+		maxExtent = max16(maxExtent, extent)
+		xMin, yMin = min16(xMin, glyph.Xmin), min16(yMin, glyph.Ymin)
+		xMax, yMax = max16(xMax, glyph.Xmax), max16(yMax, glyph.Ymax)
+	}
+
+	if inst.hhea != nil {
+		inst.hhea.AdvanceMax = advanceMax
+		inst.hhea.MinFirstSideBearing, inst.hhea.MinSecondSideBearing = minLeftSideBearing, minRightSideBearing
+		inst.hhea.MaxExtent = maxExtent
+	}
+	inst.Head.XMin, inst.Head.YMin, inst.Head.XMax, inst.Head.YMax = xMin, yMin, xMax, yMax
+	// the glyph lengths may be odd
+	inst.Head.indexToLocFormat = 1
+}
+
+// updateBounds sets the bounding box of the glyph, from its
+// contour points, which are resolved for composite glyphs.
+// It returns false for empty glyphs.
+func (g *GlyphData) updateBounds(font *Font, gid GID) bool {
+	var points []contourPoint
+	font.getPointsForGlyph(gid, 1, &points) // depth 1 : do not shift by the side bearing
+	if len(points) <= phantomCount {
+		g.Xmin, g.Ymin, g.Xmax, g.Ymax = 0, 0, 0, 0
+		return false
+	}
+	ext := extentsFromPoints(points)
+	g.Xmin = int16(math.Floor(float64(ext.XBearing)))
+	g.Ymax = int16(math.Ceil(float64(ext.YBearing)))
+	g.Xmax = int16(math.Ceil(float64(ext.XBearing + ext.Width)))
+	g.Ymin = int16(math.Floor(float64(ext.YBearing + ext.Height)))
+	return true
+}
+
+// encodeHeader returns the glyph header, with the current bounding box.
+func (g GlyphData) encodeHeader(numberOfContours int16) []byte {
+	out := make([]byte, 10)
+	binary.BigEndian.PutUint16(out, uint16(numberOfContours))
+	binary.BigEndian.PutUint16(out[2:], uint16(g.Xmin))
+	binary.BigEndian.PutUint16(out[4:], uint16(g.Ymin))
+	binary.BigEndian.PutUint16(out[6:], uint16(g.Xmax))
+	binary.BigEndian.PutUint16(out[8:], uint16(g.Ymax))
+	return out
+}
+
+// encode appends the glyph data to `out`, compressing the
+// coordinates and the flags.
+func (sg simpleGlyphData) encode(out []byte) []byte {
+	const repeatFlag = 0x08
+
+	for _, end := range sg.endPtsOfContours {
+		out = binary.BigEndian.AppendUint16(out, end)
+	}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(sg.instructions)))
+	out = append(out, sg.instructions...)
+
+	var (
+		flags, xs, ys []byte
+		prevX, prevY  int16
+	)
+	// encodeCoordinate updates the flag and appends the coordinate delta `v`
+	encodeCoordinate := func(flag *byte, data []byte, v int16, shortFlag, sameFlag uint8) []byte {
+		switch {
+		case v == 0:
+			*flag |= sameFlag
+		case -0xFF <= v && v <= 0xFF:
+			*flag |= shortFlag
+			if v > 0 {
+				*flag |= sameFlag
+			} else {
+				v = -v
+			}
+			data = append(data, byte(v))
+		default:
+			data = binary.BigEndian.AppendUint16(data, uint16(v))
+		}
+		return data
+	}
+	for _, p := range sg.points {
+		flag := p.flag & (flagOnCurve | overlapSimple)
+		xs = encodeCoordinate(&flag, xs, p.x-prevX, xShortVector, xIsSameOrPositiveXShortVector)
+		ys = encodeCoordinate(&flag, ys, p.y-prevY, yShortVector, yIsSameOrPositiveYShortVector)
+		prevX, prevY = p.x, p.y
+
+		// use the repeat count when possible
+		if L := len(flags); L >= 2 && flags[L-2] == flag|repeatFlag && flags[L-1] < 0xFF {
+			flags[L-1]++
+		} else if L >= 1 && flags[L-1] == flag {
+			flags[L-1] |= repeatFlag
+			flags = append(flags, 1)
+		} else {
+			flags = append(flags, flag)
+		}
+	}
+
+	out = append(out, flags...)
+	out = append(out, xs...)
+	out = append(out, ys...)
+	return out
+}
+
+// encode appends the glyph data to `out`, using the
+// smallest size for the arguments.
+func (cg compositeGlyphData) encode(out []byte) []byte {
+	const (
+		weHaveAScale       = 1 << 3
+		moreComponents     = 1 << 5
+		weHaveAnXAndYScale = 1 << 6
+		weHaveATwoByTwo    = 1 << 7
+		weHaveInstructions = 1 << 8
+	)
+	f2dot14 := func(v float32) uint16 { return uint16(int16(math.Round(float64(v) * (1 << 14)))) }
+	for i, part := range cg.glyphs {
+		flags := part.flags &^ (arg1And2AreWords | moreComponents | weHaveInstructions)
+		if i != len(cg.glyphs)-1 {
+			flags |= moreComponents
+		} else if len(cg.instructions) != 0 {
+			flags |= weHaveInstructions
+		}
+
+		var arg1, arg2 uint16
+		isWord := false
+		if part.isAnchored() {
+			p1, p2 := part.argsAsIndices()
+			arg1, arg2 = uint16(p1), uint16(p2)
+			isWord = p1 > 0xFF || p2 > 0xFF
+		} else {
+			dx, dy := part.argsAsTranslation()
+			arg1, arg2 = uint16(dx), uint16(dy)
+			isWord = dx < -128 || dx > 127 || dy < -128 || dy > 127
+		}
+		if isWord {
+			flags |= arg1And2AreWords
+		}
+
+		out = binary.BigEndian.AppendUint16(out, flags)
+		out = binary.BigEndian.AppendUint16(out, uint16(part.glyphIndex))
+		if isWord {
+			out = binary.BigEndian.AppendUint16(out, arg1)
+			out = binary.BigEndian.AppendUint16(out, arg2)
+		} else {
+			out = append(out, byte(arg1), byte(arg2))
+		}
+
+		if flags&weHaveAScale != 0 {
+			out = binary.BigEndian.AppendUint16(out, f2dot14(part.scale[0]))
+		} else if flags&weHaveAnXAndYScale != 0 {
+			out = binary.BigEndian.AppendUint16(out, f2dot14(part.scale[0]))
+			out = binary.BigEndian.AppendUint16(out, f2dot14(part.scale[3]))
+		} else if flags&weHaveATwoByTwo != 0 {
+			for _, v := range part.scale {
+				out = binary.BigEndian.AppendUint16(out, f2dot14(v))
+			}
+		}
+	}
+	if len(cg.instructions) != 0 {
+		out = binary.BigEndian.AppendUint16(out, uint16(len(cg.instructions)))
+		out = append(out, cg.instructions...)
+	}
+	return out
+}
+
+// applyMvar applies the variations of the font wide metrics,
+// on the tables already copied.
+func (inst *Font) applyMvar(mvar TableMvar, coords []float32) {
+	if len(mvar.Values) == 0 {
+		return
+	}
+	delta := func(tag Tag) int16 { return round16(mvar.getVar(tag, coords)) }
+	deltaU := func(v uint16, tag Tag) uint16 { return uint16(int32(v) + int32(delta(tag))) }
+
+	if os2 := inst.OS2; os2 != nil {
+		os2.STypoAscender += delta(metricsTagHorizontalAscender)
+		os2.STypoDescender += delta(metricsTagHorizontalDescender)
+		os2.STypoLineGap += delta(metricsTagHorizontalLineGap)
+		os2.UsWinAscent = deltaU(os2.UsWinAscent, tagHorizontalClippingAscent)
+		os2.UsWinDescent = deltaU(os2.UsWinDescent, tagHorizontalClippingDescent)
+		os2.SxHeigh += delta(tagXHeight)
+		os2.SCapHeight += delta(tagCapHeight)
+		os2.YSubscriptXSize += delta(tagSubscriptXSize)
+		os2.YSubscriptYSize += delta(tagSubscriptYSize)
+		os2.YSubscriptXOffset += delta(tagSubscriptXOffset)
+		os2.YSubscriptYOffset += delta(tagSubscriptYOffset)
+		os2.YSuperscriptXSize += delta(tagSuperscriptXSize)
+		os2.YSuperscriptYSize += delta(tagSuperscriptYSize)
+		os2.YSuperscriptXOffset += delta(tagSuperscriptXOffset)
+		os2.YSuperscriptYOffset += delta(tagSuperscriptYOffset)
+		os2.YStrikeoutSize += delta(tagStrikeoutSize)
+		os2.YStrikeoutPosition += delta(tagStrikeoutOffset)
+	}
+	// as in getPositionCommon, the 'hhea' metrics use the same deltas
+	// as the typographic metrics
+	if hhea := inst.hhea; hhea != nil {
+		hhea.Ascent += delta(metricsTagHorizontalAscender)
+		hhea.Descent += delta(metricsTagHorizontalDescender)
+		hhea.LineGap += delta(metricsTagHorizontalLineGap)
+		hhea.CaretSlopeRise += delta(tagHorizontalCaretRise)
+		hhea.CaretSlopeRun += delta(tagHorizontalCaretRun)
+		hhea.CaretOffset += delta(tagHorizontalCaretOffset)
+	}
+	if vhea := inst.vhea; vhea != nil {
+		vhea.Ascent += delta(metricsTagVerticalAscender)
+		vhea.Descent += delta(metricsTagVerticalDescender)
+		vhea.LineGap += delta(metricsTagVerticalLineGap)
+		vhea.CaretSlopeRise += delta(tagVerticalCaretRise)
+		vhea.CaretSlopeRun += delta(tagVerticalCaretRun)
+		vhea.CaretOffset += delta(tagVerticalCaretOffset)
+	}
+	inst.post.UnderlinePosition += delta(tagUnderlineOffset)
+	inst.post.UnderlineThickness += delta(tagUnderlineSize)
+}
+
+// applyAxisValues updates the style fields described
+// by the registered axis, on the tables already copied.
+func (inst *Font) applyAxisValues(fvar TableFvar, designCoords []float32) {
+	for i, axis := range fvar.Axis {
+		value := designCoords[i]
+		// clamp as in normalizeCoordinates
+		if value > axis.Maximum {
+			value = axis.Maximum
+		} else if value < axis.Minimum {
+			value = axis.Minimum
+		}
+		switch axis.Tag {
+		case tagAxisWeight:
+			if inst.OS2 != nil {
+				inst.OS2.USWeightClass = uint16(math.Max(1, math.Min(1000, math.Round(float64(value)))))
+			}
+		case tagAxisWidth:
+			if inst.OS2 != nil {
+				inst.OS2.USWidthClass = widthClass(value)
+			}
+		case tagAxisSlant:
+			inst.post.ItalicAngle = float64(value)
+		}
+	}
+}
+
+// widthClass returns the 'OS/2' width class closest to
+// the 'wdth' axis value `width`, expressed in percent.
+func widthClass(width float32) uint16 {
+	percents := [...]float32{50, 62.5, 75, 87.5, 100, 112.5, 125, 150, 200}
+	class := 0
+	for i, p := range percents {
+		if math.Abs(float64(width-p)) < math.Abs(float64(width-percents[class])) {
+			class = i
+		}
+	}
+	return uint16(class + 1)
+}
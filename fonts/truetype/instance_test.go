@@ -0,0 +1,119 @@
+package truetype
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestInstance(t *testing.T) {
+	wght, wdth, slnt := MustNewTag("wght"), MustNewTag("wdth"), MustNewTag("slnt")
+	for _, test := range []struct {
+		filename   string
+		variations []Variation
+		weight     uint16
+	}{
+		{"SelawikVar.ttf", []Variation{{wght, 700}}, 700},                             // HVAR
+		{"Mada-VF.ttf", []Variation{{wght, 900}}, 900},                                // phantom points, MVAR
+		{"Estedad-VF.ttf", []Variation{{wght, 300}, {wdth, 150}}, 300},                // composites
+		{"Commissioner-VF.ttf", []Variation{{wght, 650}, {slnt, -12}}, 650},           // feature variations, GDEF variations
+		{"SourceSansVariable-Roman-nohvar-41,C1.ttf", []Variation{{wght, 2000}}, 900}, // clamped
+	} {
+		font := loadFont(t, test.filename)
+		inst, err := font.Instance(test.variations)
+		if err != nil {
+			t.Fatal(test.filename, err)
+		}
+		if len(inst.Variations().Axis) != 0 || len(font.Variations().Axis) == 0 {
+			t.Fatalf("%s: expected a static instance", test.filename)
+		}
+		if inst.OS2.USWeightClass != test.weight {
+			t.Fatalf("%s: expected weight %d, got %d", test.filename, test.weight, inst.OS2.USWeightClass)
+		}
+
+		SetVariations(font, test.variations)
+		for i := 0; i < font.NumGlyphs; i++ {
+			gid := GID(i)
+			if exp, got := float32(math.Round(float64(font.HorizontalAdvance(gid)))), inst.HorizontalAdvance(gid); exp != got {
+				t.Fatalf("%s: glyph %d: expected advance %g, got %g", test.filename, gid, exp, got)
+			}
+			exp, _ := font.GlyphExtents(gid, 0, 0)
+			got, _ := inst.GlyphExtents(gid, 0, 0)
+			if exp.Width == 0 { // the empty glyphs have no meaningful bearings
+				continue
+			}
+			// allow for rounding errors, which are larger for scaled components
+			if math.Abs(float64(exp.XBearing-got.XBearing)) > 1.5 || math.Abs(float64(exp.YBearing-got.YBearing)) > 1.5 ||
+				math.Abs(float64(exp.Width-got.Width)) > 2 || math.Abs(float64(exp.Height-got.Height)) > 2 {
+				t.Fatalf("%s: glyph %d: expected extents %v, got %v", test.filename, gid, exp, got)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err = inst.WriteFont(&buf); err != nil {
+			t.Fatal(test.filename, err)
+		}
+		back, err := Parse(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(test.filename, err)
+		}
+		if len(back.Variations().Axis) != 0 {
+			t.Fatalf("%s: unexpected variations", test.filename)
+		}
+		for i := 0; i < font.NumGlyphs; i++ {
+			gid := GID(i)
+			exp, _ := inst.GlyphExtents(gid, 0, 0)
+			got, _ := back.GlyphExtents(gid, 0, 0)
+			if exp != got || inst.HorizontalAdvance(gid) != back.HorizontalAdvance(gid) {
+				t.Fatalf("%s: glyph %d: metrics not preserved", test.filename, gid)
+			}
+		}
+
+		// the instance may be subsetted, for instance for PDF embedding
+		sub, err := inst.Subset([]GID{1, 2, 3})
+		if err != nil {
+			t.Fatal(test.filename, err)
+		}
+		if err = sub.Font.(*Font).WriteSubset(&buf); err != nil {
+			t.Fatal(test.filename, err)
+		}
+	}
+}
+
+func TestInstanceFeatureVariations(t *testing.T) {
+	font := loadFont(t, "Commissioner-VF.ttf")
+	rvrn := MustNewTag("rvrn")
+	for _, test := range []struct {
+		weight       float32
+		hasVariation bool
+	}{
+		{100, false},
+		{500, false},
+		{700, true},
+	} {
+		inst, err := font.Instance([]Variation{{MustNewTag("wght"), test.weight}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		gsub := inst.LayoutTables().GSUB
+		if len(gsub.FeatureVariations) != 0 {
+			t.Fatal("unexpected feature variations")
+		}
+		_, hasVariation := gsub.FindFeatureIndex(rvrn)
+		if hasVariation != test.hasVariation {
+			t.Fatalf("weight %g: expected rvrn feature %v, got %v", test.weight, test.hasVariation, hasVariation)
+		}
+	}
+}
+
+func TestInstanceInvalid(t *testing.T) {
+	for _, filename := range []string{
+		"DejaVuSerif.ttf", // not variable
+		"TestCFF2VF.otf",  // not supported
+	} {
+		font := loadFont(t, filename)
+		if _, err := font.Instance(nil); err == nil {
+			t.Fatalf("%s: expected error", filename)
+		}
+	}
+}
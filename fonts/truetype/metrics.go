@@ -194,15 +194,11 @@ const (
 	phantomCount
 )
 
-// use the `glyf` table to fetch the contour points,
+// getGlyphOwnPoints returns the points of the glyph itself (that is, one pseudo point
+// by component for composite glyphs), followed by the phantom points,
 // applying variation if needed.
-// for composite, recursively calls itself; allPoints includes phantom points and will be at least of length 4
-func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contourPoint /* OUT */) {
-	// adapted from harfbuzz/src/hb-ot-glyf-table.hh
-
-	if currentDepth > maxCompositeNesting || int(gid) >= len(f.Glyf) {
-		return
-	}
+// `gid` must be a valid index in the `glyf` table.
+func (f *Font) getGlyphOwnPoints(gid GID) []contourPoint {
 	g := f.Glyf[gid]
 
 	var points []contourPoint
@@ -228,6 +224,22 @@ func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contour
 	if f.isVar() {
 		f.gvar.applyDeltasToPoints(gid, f.varCoords, points)
 	}
+	return points
+}
+
+// use the `glyf` table to fetch the contour points,
+// applying variation if needed.
+// for composite, recursively calls itself; allPoints includes phantom points and will be at least of length 4
+func (f *Font) getPointsForGlyph(gid GID, currentDepth int, allPoints *[]contourPoint /* OUT */) {
+	// adapted from harfbuzz/src/hb-ot-glyf-table.hh
+
+	if currentDepth > maxCompositeNesting || int(gid) >= len(f.Glyf) {
+		return
+	}
+	g := f.Glyf[gid]
+
+	points := f.getGlyphOwnPoints(gid)
+	phantoms := points[len(points)-phantomCount:]
 
 	switch data := g.data.(type) {
 	case simpleGlyphData:
@@ -353,6 +365,9 @@ func (f *Font) HorizontalAdvance(gid GID) float32 {
 	if f.hvar != nil {
 		return float32(advance) + f.hvar.getAdvanceVar(gid, f.varCoords)
 	}
+	if f.cff2 != nil { // no phantom points to vary
+		return float32(advance)
+	}
 	return f.getGlyphAdvanceVar(gid, false)
 }
 
@@ -370,6 +385,9 @@ func (f *Font) VerticalAdvance(gid GID) float32 {
 	if f.vvar != nil {
 		return -float32(advance) - f.vvar.getAdvanceVar(gid, f.varCoords)
 	}
+	if f.cff2 != nil { // no phantom points to vary
+		return -float32(advance)
+	}
 	return -f.getGlyphAdvanceVar(gid, true)
 }
 
@@ -391,6 +409,9 @@ func (f *Font) getHorizontalSideBearing(glyph GID) int16 {
 	if f.hvar != nil {
 		return sideBearing + int16(f.hvar.getSideBearingVar(glyph, f.varCoords))
 	}
+	if f.cff2 != nil { // no phantom points to vary
+		return sideBearing
+	}
 	return f.getGlyphSideBearingVar(glyph, false)
 }
 
@@ -404,6 +425,9 @@ func (f *Font) getVerticalSideBearing(glyph GID) int16 {
 	if f.vvar != nil {
 		return sideBearing + int16(f.vvar.getSideBearingVar(glyph, f.varCoords))
 	}
+	if f.cff2 != nil { // no phantom points to vary
+		return sideBearing
+	}
 	return f.getGlyphSideBearingVar(glyph, true)
 }
 
@@ -500,8 +524,16 @@ func (f *Font) getExtentsFromCff1(glyph GID) (fonts.GlyphExtents, bool) {
 	return bounds.ToExtents(), true
 }
 
-// func (f *fontMetrics) getExtentsFromCff2(glyph , coords []float32) (fonts.GlyphExtents, bool) {
-// }
+func (f *Font) getExtentsFromCff2(glyph GID) (fonts.GlyphExtents, bool) {
+	if f.cff2 == nil {
+		return fonts.GlyphExtents{}, false
+	}
+	_, bounds, err := f.cff2.LoadGlyph(glyph, f.varCoords)
+	if err != nil {
+		return fonts.GlyphExtents{}, false
+	}
+	return bounds.ToExtents(), true
+}
 
 func (f *Font) GlyphExtents(glyph GID, xPpem, yPpem uint16) (fonts.GlyphExtents, bool) {
 	out, ok := f.getExtentsFromSbix(glyph, xPpem, yPpem)
@@ -516,6 +548,10 @@ func (f *Font) GlyphExtents(glyph GID, xPpem, yPpem uint16) (fonts.GlyphExtents,
 	if ok {
 		return out, ok
 	}
+	out, ok = f.getExtentsFromCff2(glyph)
+	if ok {
+		return out, ok
+	}
 	out, ok = f.getExtentsFromCBDT(glyph, xPpem, yPpem)
 	return out, ok
 }
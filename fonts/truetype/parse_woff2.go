@@ -0,0 +1,1046 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/andybalholm/brotli"
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// WOFF2 support, as defined in https://www.w3.org/TR/WOFF2/
+//
+// Contrary to WOFF, the tables of a WOFF2 file are stored in one
+// Brotli stream and some of them (glyf, loca and hmtx) may be transformed,
+// so that they can't be accessed lazily.
+// Thus, the font is decoded as a whole, and the resulting SFNT (or TTC)
+// file is used as the resource for the usual parsers.
+
+var errInvalidWOFF2 = errors.New("invalid WOFF2 font")
+
+const (
+	woff2HeaderSize = 48
+
+	// security implementation limit on the size of the decompressed font
+	woff2MaxDecompressedSize = 1 << 28
+)
+
+type woff2Header struct {
+	Signature           Tag
+	Flavor              Tag
+	Length              uint32
+	NumTables           uint16
+	Reserved            uint16
+	TotalSfntSize       uint32
+	TotalCompressedSize uint32
+	MajorVersion        uint16
+	MinorVersion        uint16
+	MetaOffset          uint32
+	MetaLength          uint32
+	MetaOrigLength      uint32
+	PrivOffset          uint32
+	PrivLength          uint32
+}
+
+func parseWOFF2Header(data []byte) (woff2Header, error) {
+	var header woff2Header
+	if len(data) < woff2HeaderSize {
+		return header, errors.New("invalid WOFF2 header (EOF)")
+	}
+	header.Signature = newTag(data[0:4])
+	header.Flavor = newTag(data[4:8])
+	header.Length = binary.BigEndian.Uint32(data[8:12])
+	header.NumTables = binary.BigEndian.Uint16(data[12:14])
+	header.Reserved = binary.BigEndian.Uint16(data[14:16])
+	header.TotalSfntSize = binary.BigEndian.Uint32(data[16:20])
+	header.TotalCompressedSize = binary.BigEndian.Uint32(data[20:24])
+	header.MajorVersion = binary.BigEndian.Uint16(data[24:26])
+	header.MinorVersion = binary.BigEndian.Uint16(data[26:28])
+	header.MetaOffset = binary.BigEndian.Uint32(data[28:32])
+	header.MetaLength = binary.BigEndian.Uint32(data[32:36])
+	header.MetaOrigLength = binary.BigEndian.Uint32(data[36:40])
+	header.PrivOffset = binary.BigEndian.Uint32(data[40:44])
+	header.PrivLength = binary.BigEndian.Uint32(data[44:48])
+	return header, nil
+}
+
+// woff2KnownTags maps the 6 lower bits of a table entry flag
+// to the table tag. The value 63 means that the tag is explicitly stored.
+var woff2KnownTags = [63]Tag{
+	MustNewTag("cmap"), MustNewTag("head"), MustNewTag("hhea"), MustNewTag("hmtx"),
+	MustNewTag("maxp"), MustNewTag("name"), MustNewTag("OS/2"), MustNewTag("post"),
+	MustNewTag("cvt "), MustNewTag("fpgm"), MustNewTag("glyf"), MustNewTag("loca"),
+	MustNewTag("prep"), MustNewTag("CFF "), MustNewTag("VORG"), MustNewTag("EBDT"),
+	MustNewTag("EBLC"), MustNewTag("gasp"), MustNewTag("hdmx"), MustNewTag("kern"),
+	MustNewTag("LTSH"), MustNewTag("PCLT"), MustNewTag("VDMX"), MustNewTag("vhea"),
+	MustNewTag("vmtx"), MustNewTag("BASE"), MustNewTag("GDEF"), MustNewTag("GPOS"),
+	MustNewTag("GSUB"), MustNewTag("EBSC"), MustNewTag("JSTF"), MustNewTag("MATH"),
+	MustNewTag("CBDT"), MustNewTag("CBLC"), MustNewTag("COLR"), MustNewTag("CPAL"),
+	MustNewTag("SVG "), MustNewTag("sbix"), MustNewTag("acnt"), MustNewTag("avar"),
+	MustNewTag("bdat"), MustNewTag("bloc"), MustNewTag("bsln"), MustNewTag("cvar"),
+	MustNewTag("fdsc"), MustNewTag("feat"), MustNewTag("fmtx"), MustNewTag("fvar"),
+	MustNewTag("gvar"), MustNewTag("hsty"), MustNewTag("just"), MustNewTag("lcar"),
+	MustNewTag("mort"), MustNewTag("morx"), MustNewTag("opbd"), MustNewTag("prop"),
+	MustNewTag("trak"), MustNewTag("Zapf"), MustNewTag("Silf"), MustNewTag("Glat"),
+	MustNewTag("Gloc"), MustNewTag("Feat"), MustNewTag("Sill"),
+}
+
+type woff2Entry struct {
+	Tag              Tag
+	transformVersion uint8
+	OrigLength       uint32
+	TransformLength  uint32 // only valid if isTransformed() is true
+
+	data []byte // decompressed (possibly transformed) data
+}
+
+// isTransformed returns true if the table data is not stored as it is.
+// Note that the null transform for glyf and loca is version 3.
+func (entry woff2Entry) isTransformed() bool {
+	if entry.Tag == tagGlyf || entry.Tag == tagLoca {
+		return entry.transformVersion != 3
+	}
+	return entry.transformVersion != 0
+}
+
+// storedLength returns the length of the table data in the decompressed stream.
+func (entry woff2Entry) storedLength() uint32 {
+	if entry.isTransformed() {
+		return entry.TransformLength
+	}
+	return entry.OrigLength
+}
+
+// woff2Reader is a cursor over a byte slice, used
+// to read the variable length encodings of WOFF2.
+type woff2Reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *woff2Reader) u8() (uint8, error) {
+	if r.pos+1 > len(r.data) {
+		return 0, errInvalidWOFF2
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return v, nil
+}
+
+func (r *woff2Reader) u16() (uint16, error) {
+	if r.pos+2 > len(r.data) {
+		return 0, errInvalidWOFF2
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v, nil
+}
+
+func (r *woff2Reader) u32() (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errInvalidWOFF2
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *woff2Reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errInvalidWOFF2
+	}
+	v := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return v, nil
+}
+
+// base128 reads an UIntBase128 value
+func (r *woff2Reader) base128() (uint32, error) {
+	var accum uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.u8()
+		if err != nil {
+			return 0, err
+		}
+		// leading zeros are invalid
+		if i == 0 && b == 0x80 {
+			return 0, errors.New("invalid WOFF2 UIntBase128 (leading zeros)")
+		}
+		// if any of the top seven bits are set then we're about to overflow
+		if accum&0xFE000000 != 0 {
+			return 0, errors.New("invalid WOFF2 UIntBase128 (overflow)")
+		}
+		accum = accum<<7 | uint32(b&0x7F)
+		// spin until the most significant bit of data byte is false
+		if b&0x80 == 0 {
+			return accum, nil
+		}
+	}
+	// UIntBase128 sequence exceeds 5 bytes
+	return 0, errors.New("invalid WOFF2 UIntBase128 (too long)")
+}
+
+// read255UInt16 reads a 255UInt16 value
+func (r *woff2Reader) read255UInt16() (uint16, error) {
+	const (
+		oneMoreByteCode1 = 255
+		oneMoreByteCode2 = 254
+		wordCode         = 253
+		lowestUCode      = 253
+	)
+	code, err := r.u8()
+	if err != nil {
+		return 0, err
+	}
+	switch code {
+	case wordCode:
+		return r.u16()
+	case oneMoreByteCode1:
+		v, err := r.u8()
+		return uint16(v) + lowestUCode, err
+	case oneMoreByteCode2:
+		v, err := r.u8()
+		return uint16(v) + lowestUCode*2, err
+	default:
+		return uint16(code), nil
+	}
+}
+
+func (r *woff2Reader) readTableDirectory(numTables int) ([]woff2Entry, error) {
+	entries := make([]woff2Entry, numTables)
+	for i := range entries {
+		flags, err := r.u8()
+		if err != nil {
+			return nil, err
+		}
+		entry := &entries[i]
+		if tagIndex := flags & 0x3F; tagIndex == 63 {
+			tag, err := r.u32()
+			if err != nil {
+				return nil, err
+			}
+			entry.Tag = Tag(tag)
+		} else {
+			entry.Tag = woff2KnownTags[tagIndex]
+		}
+		entry.transformVersion = flags >> 6
+
+		entry.OrigLength, err = r.base128()
+		if err != nil {
+			return nil, err
+		}
+		if entry.isTransformed() {
+			if entry.Tag != tagGlyf && entry.Tag != tagLoca && entry.Tag != tagHmtx {
+				return nil, fmt.Errorf("unsupported WOFF2 transform for table %s", entry.Tag)
+			}
+			entry.TransformLength, err = r.base128()
+			if err != nil {
+				return nil, err
+			}
+			if entry.Tag == tagLoca && entry.TransformLength != 0 {
+				return nil, errors.New("invalid WOFF2 transformed loca table (non zero length)")
+			}
+		}
+	}
+	return entries, nil
+}
+
+// woff2Font is one font of a (possibly single font) WOFF2 collection,
+// storing the indices of its tables into the directory
+type woff2Font struct {
+	flavor Tag
+	tables []int
+}
+
+func (r *woff2Reader) readCollectionDirectory(numTables int) ([]woff2Font, error) {
+	version, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+	if version != 0x00010000 && version != 0x00020000 {
+		return nil, fmt.Errorf("unsupported WOFF2 collection version %x", version)
+	}
+	numFonts, err := r.read255UInt16()
+	if err != nil {
+		return nil, err
+	}
+	if numFonts == 0 {
+		return nil, errors.New("empty font collection")
+	}
+	if numFonts > maxNumFonts {
+		return nil, fmt.Errorf("number of fonts (%d) in collection exceed implementation limit (%d)",
+			numFonts, maxNumFonts)
+	}
+	out := make([]woff2Font, numFonts)
+	for i := range out {
+		nb, err := r.read255UInt16()
+		if err != nil {
+			return nil, err
+		}
+		flavor, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		out[i].flavor = Tag(flavor)
+		out[i].tables = make([]int, nb)
+		for j := range out[i].tables {
+			index, err := r.read255UInt16()
+			if err != nil {
+				return nil, err
+			}
+			if int(index) >= numTables {
+				return nil, fmt.Errorf("invalid WOFF2 collection table index %d", index)
+			}
+			out[i].tables[j] = int(index)
+		}
+	}
+	return out, nil
+}
+
+// decodeWOFF2Resource decodes the WOFF2 `file` and returns
+// an in-memory SFNT (or TTC) resource.
+func decodeWOFF2Resource(file fonts.Resource) (fonts.Resource, error) {
+	data, err := decodeWOFF2(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// decodeWOFF2 reads the whole WOFF2 file and returns the equivalent
+// SFNT file, or a TTC file for WOFF2 collections.
+func decodeWOFF2(file fonts.Resource) ([]byte, error) {
+	var headerBuf [woff2HeaderSize]byte
+	if _, err := file.ReadAt(headerBuf[:], 0); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 header: %s", err)
+	}
+	header, err := parseWOFF2Header(headerBuf[:])
+	if err != nil {
+		return nil, err
+	}
+	if header.Signature != SignatureWOFF2 {
+		return nil, errInvalidWOFF2
+	}
+	if header.NumTables == 0 {
+		return nil, errors.New("invalid WOFF2 font (no tables)")
+	}
+	if header.Length < woff2HeaderSize || header.Length > woff2MaxDecompressedSize {
+		return nil, fmt.Errorf("invalid WOFF2 file length %d", header.Length)
+	}
+
+	data := make([]byte, header.Length)
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 file: %s", err)
+	}
+
+	r := woff2Reader{data: data, pos: woff2HeaderSize}
+	entries, err := r.readTableDirectory(int(header.NumTables))
+	if err != nil {
+		return nil, err
+	}
+
+	var fontsDir []woff2Font
+	if header.Flavor == ttcTag {
+		fontsDir, err = r.readCollectionDirectory(len(entries))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fontsDir = []woff2Font{{flavor: header.Flavor, tables: make([]int, len(entries))}}
+		for i := range entries {
+			fontsDir[0].tables[i] = i
+		}
+	}
+
+	compressed, err := r.bytes(int(header.TotalCompressedSize))
+	if err != nil {
+		return nil, errors.New("invalid WOFF2 compressed data (EOF)")
+	}
+
+	var totalLength uint64
+	for _, entry := range entries {
+		totalLength += uint64(entry.storedLength())
+	}
+	if totalLength > woff2MaxDecompressedSize {
+		return nil, fmt.Errorf("WOFF2 decompressed size (%d) exceed implementation limit", totalLength)
+	}
+
+	decompressed := make([]byte, totalLength)
+	br := brotli.NewReader(bytes.NewReader(compressed))
+	if _, err = io.ReadFull(br, decompressed); err != nil {
+		return nil, fmt.Errorf("invalid WOFF2 compressed data: %s", err)
+	}
+	var tmp [1]byte
+	if n, _ := br.Read(tmp[:]); n != 0 {
+		return nil, errors.New("invalid WOFF2 compressed data (too long)")
+	}
+
+	var offset uint32
+	for i := range entries {
+		L := entries[i].storedLength()
+		entries[i].data = decompressed[offset : offset+L]
+		offset += L
+	}
+
+	for _, font := range fontsDir {
+		if err := reconstructWOFF2Tables(entries, font.tables); err != nil {
+			return nil, err
+		}
+	}
+
+	if header.Flavor == ttcTag {
+		return writeTTC(entries, fontsDir), nil
+	}
+	return writeSFNT(header.Flavor, entries, fontsDir[0].tables), nil
+}
+
+// reconstructWOFF2Tables undoes the glyf, loca and hmtx transforms
+// for the given font, updating `entries` in place.
+func reconstructWOFF2Tables(entries []woff2Entry, tables []int) error {
+	var glyf, loca, hmtx, hhea, maxp *woff2Entry
+	for _, index := range tables {
+		entry := &entries[index]
+		switch entry.Tag {
+		case tagGlyf:
+			glyf = entry
+		case tagLoca:
+			loca = entry
+		case tagHmtx:
+			hmtx = entry
+		case tagHhea:
+			hhea = entry
+		case tagMaxp:
+			maxp = entry
+		}
+	}
+
+	if (glyf == nil) != (loca == nil) {
+		return errors.New("invalid WOFF2 font: glyf and loca tables must be both present or absent")
+	}
+
+	var xMins []int16
+	if glyf != nil {
+		if glyf.isTransformed() != loca.isTransformed() {
+			return errors.New("invalid WOFF2 font: glyf and loca tables must be both transformed or not")
+		}
+		// in a collection, the tables may already have been reconstructed for a previous font
+		if glyf.isTransformed() {
+			if glyf.transformVersion != 0 {
+				return fmt.Errorf("unsupported WOFF2 glyf transform %d", glyf.transformVersion)
+			}
+			glyfData, locaData, mins, err := reconstructGlyfLoca(glyf.data)
+			if err != nil {
+				return err
+			}
+			if uint32(len(locaData)) != loca.OrigLength {
+				return errors.New("invalid WOFF2 transformed loca table (length mismatch)")
+			}
+			glyf.data, glyf.transformVersion = glyfData, 3
+			loca.data, loca.transformVersion = locaData, 3
+			xMins = mins
+		}
+	}
+
+	if hmtx != nil && hmtx.isTransformed() {
+		if hmtx.transformVersion != 1 {
+			return fmt.Errorf("unsupported WOFF2 hmtx transform %d", hmtx.transformVersion)
+		}
+		if glyf == nil || hhea == nil || maxp == nil {
+			return errors.New("invalid WOFF2 transformed hmtx table (missing tables)")
+		}
+		if xMins == nil { // already transformed (collection) glyf table
+			var err error
+			xMins, err = glyphsXMin(glyf.data, loca.data, maxp.data)
+			if err != nil {
+				return err
+			}
+		}
+		hheaTable, err := parseTableHVhea(hhea.data)
+		if err != nil {
+			return err
+		}
+		hmtx.data, err = reconstructHmtx(hmtx.data, xMins, int(hheaTable.numOfLongMetrics))
+		if err != nil {
+			return err
+		}
+		hmtx.transformVersion = 0
+		if uint32(len(hmtx.data)) != hmtx.OrigLength {
+			return errors.New("invalid WOFF2 transformed hmtx table (length mismatch)")
+		}
+	}
+
+	return nil
+}
+
+// glyphsXMin returns the xMin value of each glyph, using
+// the (untransformed) glyf and loca tables.
+func glyphsXMin(glyf, loca, maxp []byte) ([]int16, error) {
+	maxpTable, err := parseTableMaxp(maxp)
+	if err != nil {
+		return nil, err
+	}
+	numGlyphs := int(maxpTable.NumGlyphs)
+	offsets, err := parseTableLoca(loca, numGlyphs, len(loca) >= 4*(numGlyphs+1))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int16, numGlyphs)
+	for i := range out {
+		start, end := offsets[i], offsets[i+1]
+		if start == end {
+			continue
+		}
+		if start > end || int(end) > len(glyf) || end-start < 10 {
+			return nil, errors.New("invalid 'glyf' table (EOF)")
+		}
+		out[i] = int16(binary.BigEndian.Uint16(glyf[start+2:]))
+	}
+	return out, nil
+}
+
+// glyf transform
+
+const (
+	woff2FlagOverlapSimpleBitmap = 1 << 0
+
+	glyfFlagRepeat = 0x08
+)
+
+type woff2Point struct {
+	x, y    int32
+	onCurve bool
+}
+
+// reconstructGlyfLoca decodes the transformed glyf table, returning the
+// glyf and loca tables, and the xMin value of each glyph.
+func reconstructGlyfLoca(data []byte) (glyf, loca []byte, xMins []int16, err error) {
+	r := woff2Reader{data: data}
+	if _, err = r.u16(); err != nil { // reserved
+		return nil, nil, nil, err
+	}
+	optionFlags, _ := r.u16()
+	numGlyphs16, _ := r.u16()
+	indexFormat, _ := r.u16()
+	var sizes [7]uint32
+	for i := range sizes {
+		sizes[i], err = r.u32()
+		if err != nil {
+			return nil, nil, nil, errors.New("invalid WOFF2 transformed glyf header (EOF)")
+		}
+	}
+	numGlyphs := int(numGlyphs16)
+
+	// split the streams
+	var streams [7]woff2Reader
+	for i, size := range sizes {
+		streams[i].data, err = r.bytes(int(size))
+		if err != nil {
+			return nil, nil, nil, errors.New("invalid WOFF2 transformed glyf streams (EOF)")
+		}
+	}
+	nContourStream, nPointsStream, flagStream, glyphStream := &streams[0], &streams[1], &streams[2], &streams[3]
+	compositeStream, bboxStream, instructionStream := &streams[4], &streams[5], &streams[6]
+
+	var overlapBitmap []byte
+	if optionFlags&woff2FlagOverlapSimpleBitmap != 0 {
+		overlapBitmap, err = r.bytes((numGlyphs + 7) >> 3)
+		if err != nil {
+			return nil, nil, nil, errors.New("invalid WOFF2 transformed glyf overlap bitmap (EOF)")
+		}
+	}
+
+	bboxBitmap, err := bboxStream.bytes(((numGlyphs + 31) >> 5) << 2)
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid WOFF2 transformed glyf bbox bitmap (EOF)")
+	}
+
+	locaValues := make([]uint32, numGlyphs+1)
+	xMins = make([]int16, numGlyphs)
+	var (
+		out    []byte
+		points []woff2Point
+	)
+	for i := 0; i < numGlyphs; i++ {
+		nContours, err := nContourStream.u16()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		hasBbox := bboxBitmap[i>>3]&(0x80>>(i&7)) != 0
+		start := len(out)
+		locaValues[i] = uint32(start)
+
+		switch numberOfContours := int16(nContours); {
+		case numberOfContours == 0: // empty glyph
+			if hasBbox {
+				return nil, nil, nil, errors.New("invalid WOFF2 empty glyph with bounding box")
+			}
+		case numberOfContours == -1: // composite glyph
+			if !hasBbox {
+				return nil, nil, nil, errors.New("invalid WOFF2 composite glyph without bounding box")
+			}
+			bbox, err := bboxStream.bytes(8)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			composite, haveInstructions, err := readWOFF2CompositeGlyph(compositeStream)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			out = append(out, 0xFF, 0xFF)
+			out = append(out, bbox...)
+			out = append(out, composite...)
+			if haveInstructions {
+				out, err = appendWOFF2Instructions(out, glyphStream, instructionStream)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+			}
+		case numberOfContours > 0: // simple glyph
+			endPoints := make([]uint16, numberOfContours)
+			totalPoints := 0
+			for c := range endPoints {
+				nPoints, err := nPointsStream.read255UInt16()
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				totalPoints += int(nPoints)
+				if totalPoints > 0xFFFF+1 {
+					return nil, nil, nil, errors.New("invalid WOFF2 simple glyph (too many points)")
+				}
+				endPoints[c] = uint16(totalPoints - 1)
+			}
+			flags, err := flagStream.bytes(totalPoints)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			points, err = decodeWOFF2Triplets(points[:0], flags, glyphStream)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			out = append(out, byte(nContours>>8), byte(nContours))
+			if hasBbox {
+				bbox, err := bboxStream.bytes(8)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				out = append(out, bbox...)
+			} else {
+				out = appendWOFF2Bbox(out, points)
+			}
+			for _, end := range endPoints {
+				out = append(out, byte(end>>8), byte(end))
+			}
+			out, err = appendWOFF2Instructions(out, glyphStream, instructionStream)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hasOverlap := overlapBitmap != nil && overlapBitmap[i>>3]&(0x80>>(i&7)) != 0
+			out = appendGlyfPoints(out, points, hasOverlap)
+		default:
+			return nil, nil, nil, fmt.Errorf("invalid WOFF2 number of contours %d", numberOfContours)
+		}
+
+		if len(out) > start {
+			xMins[i] = int16(binary.BigEndian.Uint16(out[start+2:]))
+		}
+		// pad to 4 bytes
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	locaValues[numGlyphs] = uint32(len(out))
+
+	switch indexFormat {
+	case 0:
+		if len(out) > 0x1FFFE {
+			return nil, nil, nil, errors.New("invalid WOFF2 glyf table (too long for short loca)")
+		}
+		loca = make([]byte, 2*len(locaValues))
+		for i, v := range locaValues {
+			binary.BigEndian.PutUint16(loca[2*i:], uint16(v>>1))
+		}
+	case 1:
+		loca = make([]byte, 4*len(locaValues))
+		for i, v := range locaValues {
+			binary.BigEndian.PutUint32(loca[4*i:], v)
+		}
+	default:
+		return nil, nil, nil, fmt.Errorf("invalid WOFF2 loca index format %d", indexFormat)
+	}
+
+	return out, loca, xMins, nil
+}
+
+// readWOFF2CompositeGlyph returns the composite glyph data (without header and instructions)
+func readWOFF2CompositeGlyph(stream *woff2Reader) ([]byte, bool, error) {
+	const (
+		weHaveAScale       = 0x0008
+		moreComponents     = 0x0020
+		weHaveAnXAndYScale = 0x0040
+		weHaveATwoByTwo    = 0x0080
+		weHaveInstructions = 0x0100
+	)
+	start := stream.pos
+	var haveInstructions bool
+	for {
+		flags, err := stream.u16()
+		if err != nil {
+			return nil, false, err
+		}
+		haveInstructions = haveInstructions || flags&weHaveInstructions != 0
+		argSize := 2 // glyph index
+		if flags&arg1And2AreWords != 0 {
+			argSize += 4
+		} else {
+			argSize += 2
+		}
+		switch {
+		case flags&weHaveAScale != 0:
+			argSize += 2
+		case flags&weHaveAnXAndYScale != 0:
+			argSize += 4
+		case flags&weHaveATwoByTwo != 0:
+			argSize += 8
+		}
+		if _, err := stream.bytes(argSize); err != nil {
+			return nil, false, err
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return stream.data[start:stream.pos], haveInstructions, nil
+}
+
+// appendWOFF2Instructions reads the instruction length from the glyph stream
+// and appends the length and the instructions to `out`
+func appendWOFF2Instructions(out []byte, glyphStream, instructionStream *woff2Reader) ([]byte, error) {
+	L, err := glyphStream.read255UInt16()
+	if err != nil {
+		return nil, err
+	}
+	instructions, err := instructionStream.bytes(int(L))
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, byte(L>>8), byte(L))
+	return append(out, instructions...), nil
+}
+
+func woff2WithSign(flag byte, baseval int32) int32 {
+	// Precondition: 0 <= baseval < 65536 (to avoid integer overflow)
+	if flag&1 != 0 {
+		return baseval
+	}
+	return -baseval
+}
+
+// decodeWOFF2Triplets decodes the points coordinates, appending to `points`
+func decodeWOFF2Triplets(points []woff2Point, flags []byte, glyphStream *woff2Reader) ([]woff2Point, error) {
+	var x, y int32
+	for _, flag := range flags {
+		onCurve := flag>>7 == 0
+		flag &= 0x7f
+		var nDataBytes int
+		switch {
+		case flag < 84:
+			nDataBytes = 1
+		case flag < 120:
+			nDataBytes = 2
+		case flag < 124:
+			nDataBytes = 3
+		default:
+			nDataBytes = 4
+		}
+		in, err := glyphStream.bytes(nDataBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		var dx, dy int32
+		switch {
+		case flag < 10:
+			dx = 0
+			dy = woff2WithSign(flag, int32(flag&14)<<7+int32(in[0]))
+		case flag < 20:
+			dx = woff2WithSign(flag, int32((flag-10)&14)<<7+int32(in[0]))
+			dy = 0
+		case flag < 84:
+			b0 := int32(flag - 20)
+			b1 := int32(in[0])
+			dx = woff2WithSign(flag, 1+(b0&0x30)+(b1>>4))
+			dy = woff2WithSign(flag>>1, 1+((b0&0x0c)<<2)+(b1&0x0f))
+		case flag < 120:
+			b0 := int32(flag - 84)
+			dx = woff2WithSign(flag, 1+((b0/12)<<8)+int32(in[0]))
+			dy = woff2WithSign(flag>>1, 1+(((b0%12)>>2)<<8)+int32(in[1]))
+		case flag < 124:
+			b2 := int32(in[1])
+			dx = woff2WithSign(flag, int32(in[0])<<4+b2>>4)
+			dy = woff2WithSign(flag>>1, (b2&0x0f)<<8+int32(in[2]))
+		default:
+			dx = woff2WithSign(flag, int32(in[0])<<8+int32(in[1]))
+			dy = woff2WithSign(flag>>1, int32(in[2])<<8+int32(in[3]))
+		}
+		x += dx
+		y += dy
+		points = append(points, woff2Point{x: x, y: y, onCurve: onCurve})
+	}
+	return points, nil
+}
+
+// appendWOFF2Bbox computes the bounding box of the points
+func appendWOFF2Bbox(out []byte, points []woff2Point) []byte {
+	var xMin, yMin, xMax, yMax int32
+	if len(points) != 0 {
+		xMin, yMin, xMax, yMax = points[0].x, points[0].y, points[0].x, points[0].y
+	}
+	for _, p := range points {
+		xMin, xMax = min32(xMin, p.x), max32(xMax, p.x)
+		yMin, yMax = min32(yMin, p.y), max32(yMax, p.y)
+	}
+	return append(out, byte(xMin>>8), byte(xMin), byte(yMin>>8), byte(yMin),
+		byte(xMax>>8), byte(xMax), byte(yMax>>8), byte(yMax))
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// appendGlyfPoints encodes the flags and the coordinates of a simple glyph,
+// using the most compact form.
+func appendGlyfPoints(out []byte, points []woff2Point, hasOverlap bool) []byte {
+	var (
+		lastX, lastY int32
+		lastFlag     = -1
+		repeatCount  int
+		xBytes       []byte
+		yBytes       []byte
+	)
+	for i, p := range points {
+		var flag byte
+		if p.onCurve {
+			flag = flagOnCurve
+		}
+		if hasOverlap && i == 0 {
+			flag |= overlapSimple
+		}
+		dx, dy := p.x-lastX, p.y-lastY
+		switch {
+		case dx == 0:
+			flag |= xIsSameOrPositiveXShortVector
+		case -256 < dx && dx < 256:
+			flag |= xShortVector
+			if dx > 0 {
+				flag |= xIsSameOrPositiveXShortVector
+			} else {
+				dx = -dx
+			}
+			xBytes = append(xBytes, byte(dx))
+		default:
+			xBytes = append(xBytes, byte(dx>>8), byte(dx))
+		}
+		switch {
+		case dy == 0:
+			flag |= yIsSameOrPositiveYShortVector
+		case -256 < dy && dy < 256:
+			flag |= yShortVector
+			if dy > 0 {
+				flag |= yIsSameOrPositiveYShortVector
+			} else {
+				dy = -dy
+			}
+			yBytes = append(yBytes, byte(dy))
+		default:
+			yBytes = append(yBytes, byte(dy>>8), byte(dy))
+		}
+
+		if int(flag) == lastFlag && repeatCount != 255 {
+			out[len(out)-1] |= glyfFlagRepeat
+			repeatCount++
+		} else {
+			if repeatCount != 0 {
+				out = append(out, byte(repeatCount))
+			}
+			out = append(out, flag)
+			repeatCount = 0
+		}
+		lastX, lastY = p.x, p.y
+		lastFlag = int(flag)
+	}
+	if repeatCount != 0 {
+		out = append(out, byte(repeatCount))
+	}
+	out = append(out, xBytes...)
+	return append(out, yBytes...)
+}
+
+// hmtx transform
+
+// reconstructHmtx decodes the transformed hmtx table.
+func reconstructHmtx(data []byte, xMins []int16, numHMetrics int) ([]byte, error) {
+	const (
+		proportionalLsbAbsent = 1 << 0
+		monospacedLsbAbsent   = 1 << 1
+	)
+	numGlyphs := len(xMins)
+	if numHMetrics < 1 || numHMetrics > numGlyphs {
+		return nil, errors.New("invalid WOFF2 transformed hmtx table (number of metrics)")
+	}
+	r := woff2Reader{data: data}
+	flags, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	if flags&^(proportionalLsbAbsent|monospacedLsbAbsent) != 0 || flags == 0 {
+		return nil, fmt.Errorf("invalid WOFF2 transformed hmtx flags %d", flags)
+	}
+
+	advances, err := r.bytes(2 * numHMetrics)
+	if err != nil {
+		return nil, err
+	}
+	lsbs := make([]int16, numGlyphs)
+	for i := range lsbs {
+		isProportional := i < numHMetrics
+		if isProportional && flags&proportionalLsbAbsent != 0 || !isProportional && flags&monospacedLsbAbsent != 0 {
+			lsbs[i] = xMins[i]
+			continue
+		}
+		v, err := r.u16()
+		if err != nil {
+			return nil, err
+		}
+		lsbs[i] = int16(v)
+	}
+
+	out := make([]byte, 0, 4*numHMetrics+2*(numGlyphs-numHMetrics))
+	for i, lsb := range lsbs {
+		if i < numHMetrics {
+			out = append(out, advances[2*i], advances[2*i+1])
+		}
+		out = append(out, byte(lsb>>8), byte(lsb))
+	}
+	return out, nil
+}
+
+// SFNT output
+
+// sfntHeaderValues returns the searchRange, entrySelector and rangeShift
+// fields of the table directory
+func sfntHeaderValues(numTables int) (searchRange, entrySelector, rangeShift uint16) {
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange = (1 << entrySelector) * 16
+	rangeShift = uint16(numTables)*16 - searchRange
+	return
+}
+
+// appendTableDirectory appends the offset table and the table records of one font,
+// sorted by tag. `offsets` are the offsets of the table data in the output file.
+func appendTableDirectory(out []byte, flavor Tag, entries []woff2Entry, tables []int, offsets []uint32) []byte {
+	sorted := append([]int(nil), tables...)
+	sort.Slice(sorted, func(i, j int) bool { return entries[sorted[i]].Tag < entries[sorted[j]].Tag })
+
+	searchRange, entrySelector, rangeShift := sfntHeaderValues(len(sorted))
+	out = binary.BigEndian.AppendUint32(out, uint32(flavor))
+	out = binary.BigEndian.AppendUint16(out, uint16(len(sorted)))
+	out = binary.BigEndian.AppendUint16(out, searchRange)
+	out = binary.BigEndian.AppendUint16(out, entrySelector)
+	out = binary.BigEndian.AppendUint16(out, rangeShift)
+	for _, index := range sorted {
+		entry := entries[index]
+		out = binary.BigEndian.AppendUint32(out, uint32(entry.Tag))
+		out = binary.BigEndian.AppendUint32(out, calcChecksum(entry.data))
+		out = binary.BigEndian.AppendUint32(out, offsets[index])
+		out = binary.BigEndian.AppendUint32(out, uint32(len(entry.data)))
+	}
+	return out
+}
+
+// appendTablesData appends the data of every table, padded to 4 bytes,
+// and returns the offset of each table.
+func appendTablesData(out []byte, entries []woff2Entry) ([]byte, []uint32) {
+	offsets := make([]uint32, len(entries))
+	for i := range entries {
+		offsets[i] = uint32(len(out))
+		out = append(out, entries[i].data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out, offsets
+}
+
+// clearCheckSumAdjustment sets the checkSumAdjustment field of the head
+// table to zero, as required to compute the checksums
+func clearCheckSumAdjustment(entries []woff2Entry) {
+	for i, entry := range entries {
+		if entry.Tag == tagHead && len(entry.data) >= 12 {
+			data := append([]byte(nil), entry.data...) // do not modify the decompressed stream
+			binary.BigEndian.PutUint32(data[8:], 0)
+			entries[i].data = data
+		}
+	}
+}
+
+func writeSFNT(flavor Tag, entries []woff2Entry, tables []int) []byte {
+	clearCheckSumAdjustment(entries)
+
+	directoryLength := otfHeaderLength + directoryEntryLength*len(tables)
+	out, offsets := appendTablesData(make([]byte, directoryLength), entries)
+	directory := appendTableDirectory(nil, flavor, entries, tables, offsets)
+	copy(out, directory)
+
+	for i, entry := range entries {
+		if entry.Tag == tagHead && len(entry.data) >= 12 {
+			binary.BigEndian.PutUint32(out[offsets[i]+8:], 0xB1B0AFBA-calcChecksum(out))
+		}
+	}
+	return out
+}
+
+func writeTTC(entries []woff2Entry, fontsDir []woff2Font) []byte {
+	clearCheckSumAdjustment(entries)
+
+	// TTC header (version 1, without DSIG)
+	headerLength := 12 + 4*len(fontsDir)
+	fontOffsets := make([]uint32, len(fontsDir))
+	directoriesLength := 0
+	for i, font := range fontsDir {
+		fontOffsets[i] = uint32(headerLength + directoriesLength)
+		directoriesLength += otfHeaderLength + directoryEntryLength*len(font.tables)
+	}
+
+	out, offsets := appendTablesData(make([]byte, headerLength+directoriesLength), entries)
+
+	header := binary.BigEndian.AppendUint32(nil, uint32(ttcTag))
+	header = binary.BigEndian.AppendUint32(header, 0x00010000)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(fontsDir)))
+	for _, offset := range fontOffsets {
+		header = binary.BigEndian.AppendUint32(header, offset)
+	}
+	copy(out, header)
+
+	for i, font := range fontsDir {
+		directory := appendTableDirectory(nil, font.flavor, entries, font.tables, offsets)
+		copy(out[fontOffsets[i]:], directory)
+	}
+	return out
+}
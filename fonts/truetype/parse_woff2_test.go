@@ -0,0 +1,703 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	testdata "github.com/benoitkugler/textlayout-testdata/truetype"
+)
+
+// the following implements a (simplified) WOFF2 encoder,
+// used to build WOFF2 fixtures from the TTF/OTF test files
+
+type woff2TestTable struct {
+	offset    uint32 // in the original file
+	tag       Tag
+	data      []byte
+	transform []byte // nil for the null transform
+}
+
+func woff2AppendBase128(out []byte, v uint32) []byte {
+	var tmp [5]byte
+	n := 0
+	for {
+		tmp[4-n] = byte(v & 0x7F)
+		if n != 0 {
+			tmp[4-n] |= 0x80
+		}
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	return append(out, tmp[5-n:]...)
+}
+
+func woff2Append255UInt16(out []byte, v uint16) []byte {
+	switch {
+	case v < 253:
+		return append(out, byte(v))
+	case v < 506:
+		return append(out, 255, byte(v-253))
+	case v < 762:
+		return append(out, 254, byte(v-506))
+	default:
+		return append(out, 253, byte(v>>8), byte(v))
+	}
+}
+
+func woff2AppendTriplet(flags, glyphs []byte, onCurve bool, x, y int) ([]byte, []byte) {
+	absX, absY := x, y
+	if absX < 0 {
+		absX = -absX
+	}
+	if absY < 0 {
+		absY = -absY
+	}
+	var onCurveBit, xSignBit, ySignBit int
+	if !onCurve {
+		onCurveBit = 128
+	}
+	if x >= 0 {
+		xSignBit = 1
+	}
+	if y >= 0 {
+		ySignBit = 1
+	}
+	xySignBits := xSignBit + 2*ySignBit
+
+	switch {
+	case x == 0 && absY < 1280:
+		flags = append(flags, byte(onCurveBit+((absY&0xf00)>>7)+ySignBit))
+		glyphs = append(glyphs, byte(absY))
+	case y == 0 && absX < 1280:
+		flags = append(flags, byte(onCurveBit+10+((absX&0xf00)>>7)+xSignBit))
+		glyphs = append(glyphs, byte(absX))
+	case absX < 65 && absY < 65:
+		flags = append(flags, byte(onCurveBit+20+((absX-1)&0x30)+(((absY-1)&0x30)>>2)+xySignBits))
+		glyphs = append(glyphs, byte((((absX-1)&0xf)<<4)|((absY-1)&0xf)))
+	case absX < 769 && absY < 769:
+		flags = append(flags, byte(onCurveBit+84+12*(((absX-1)&0x300)>>8)+(((absY-1)&0x300)>>6)+xySignBits))
+		glyphs = append(glyphs, byte(absX-1), byte(absY-1))
+	case absX < 4096 && absY < 4096:
+		flags = append(flags, byte(onCurveBit+120+xySignBits))
+		glyphs = append(glyphs, byte(absX>>4), byte((absX&0xf)<<4|absY>>8), byte(absY))
+	default:
+		flags = append(flags, byte(onCurveBit+124+xySignBits))
+		glyphs = append(glyphs, byte(absX>>8), byte(absX), byte(absY>>8), byte(absY))
+	}
+	return flags, glyphs
+}
+
+// woff2TransformGlyf returns the transformed glyf table
+func woff2TransformGlyf(t *testing.T, glyfData, locaData []byte, numGlyphs int, indexFormat int16) []byte {
+	t.Helper()
+
+	loca, err := parseTableLoca(locaData, numGlyphs, indexFormat == 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nContours, nPoints, flags, glyphs, composites, bboxes, instructions []byte
+	bboxBitmap := make([]byte, ((numGlyphs+31)>>5)<<2)
+	for i := 0; i < numGlyphs; i++ {
+		if loca[i] == loca[i+1] {
+			nContours = append(nContours, 0, 0)
+			continue
+		}
+		glyph, err := parseGlyphData(glyfData, loca[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := glyfData[loca[i]:loca[i+1]]
+		switch data := glyph.data.(type) {
+		case simpleGlyphData:
+			nContours = append(nContours, raw[:2]...)
+			var lastEnd int
+			for c, end := range data.endPtsOfContours {
+				if c == 0 {
+					nPoints = woff2Append255UInt16(nPoints, end+1)
+				} else {
+					nPoints = woff2Append255UInt16(nPoints, end-uint16(lastEnd))
+				}
+				lastEnd = int(end)
+			}
+			var lastX, lastY int
+			xMin, yMin, xMax, yMax := 0, 0, 0, 0
+			for j, p := range data.points {
+				flags, glyphs = woff2AppendTriplet(flags, glyphs, p.flag&flagOnCurve != 0, int(p.x)-lastX, int(p.y)-lastY)
+				lastX, lastY = int(p.x), int(p.y)
+				if j == 0 {
+					xMin, yMin, xMax, yMax = lastX, lastY, lastX, lastY
+				}
+				xMin, xMax = min(xMin, lastX), max(xMax, lastX)
+				yMin, yMax = min(yMin, lastY), max(yMax, lastY)
+			}
+			glyphs = woff2Append255UInt16(glyphs, uint16(len(data.instructions)))
+			instructions = append(instructions, data.instructions...)
+			// only store the bounding box if it can't be computed
+			if xMin != int(glyph.Xmin) || yMin != int(glyph.Ymin) || xMax != int(glyph.Xmax) || yMax != int(glyph.Ymax) {
+				bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+				bboxes = append(bboxes, raw[2:10]...)
+			}
+		case compositeGlyphData:
+			nContours = append(nContours, 0xFF, 0xFF)
+			bboxBitmap[i>>3] |= 0x80 >> (i & 7)
+			bboxes = append(bboxes, raw[2:10]...)
+			r := woff2Reader{data: raw[10:]}
+			composite, haveInstructions, err := readWOFF2CompositeGlyph(&r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			composites = append(composites, composite...)
+			if haveInstructions {
+				glyphs = woff2Append255UInt16(glyphs, uint16(len(data.instructions)))
+				instructions = append(instructions, data.instructions...)
+			}
+		}
+	}
+
+	bboxes = append(bboxBitmap, bboxes...)
+	var out []byte
+	out = binary.BigEndian.AppendUint16(out, 0)
+	out = binary.BigEndian.AppendUint16(out, 0)
+	out = binary.BigEndian.AppendUint16(out, uint16(numGlyphs))
+	out = binary.BigEndian.AppendUint16(out, uint16(indexFormat))
+	for _, stream := range [][]byte{nContours, nPoints, flags, glyphs, composites, bboxes, instructions} {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(stream)))
+	}
+	for _, stream := range [][]byte{nContours, nPoints, flags, glyphs, composites, bboxes, instructions} {
+		out = append(out, stream...)
+	}
+	return out
+}
+
+// woff2TransformHmtx returns the transformed hmtx table, or nil if the
+// transform can't be applied
+func woff2TransformHmtx(font *Font) []byte {
+	numHMetrics := int(font.hhea.numOfLongMetrics)
+	for i, metric := range font.Hmtx {
+		if metric.SideBearing != font.Glyf[i].Xmin {
+			return nil
+		}
+	}
+	out := []byte{3}
+	for _, metric := range font.Hmtx[:numHMetrics] {
+		out = binary.BigEndian.AppendUint16(out, uint16(metric.Advance))
+	}
+	return out
+}
+
+// encodeWOFF2 encodes the font(s) in `file` as WOFF2, optionnaly applying
+// the glyf, loca and hmtx transforms.
+// Tables are stored in the order they appear in `file`.
+func encodeWOFF2(t *testing.T, file []byte, transform bool) []byte {
+	t.Helper()
+
+	parsers, err := NewFontParsers(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// collect the tables, shared by offset
+	type fontTables struct {
+		flavor  Tag
+		offsets []uint32
+	}
+	var (
+		fontsTables []fontTables
+		sections    = map[uint32]Tag{}
+	)
+	for _, pr := range parsers {
+		var ft fontTables
+		ft.flavor = pr.Type
+		for tag, section := range pr.tables {
+			sections[section.offset] = tag
+			ft.offsets = append(ft.offsets, section.offset)
+		}
+		fontsTables = append(fontsTables, ft)
+	}
+	var allOffsets []uint32
+	for offset := range sections {
+		allOffsets = append(allOffsets, offset)
+	}
+	sort.Slice(allOffsets, func(i, j int) bool { return allOffsets[i] < allOffsets[j] })
+
+	var tables []woff2TestTable
+	for _, offset := range allOffsets {
+		tag := sections[offset]
+		var pr *FontParser
+		for _, p := range parsers {
+			if p.tables[tag].offset == offset {
+				pr = p
+			}
+		}
+		data, err := pr.GetRawTable(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table := woff2TestTable{offset: offset, tag: tag, data: data}
+		if transform && tag == tagGlyf {
+			font, err := pr.loadTables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.transform = woff2TransformGlyf(t, data, mustRawTable(t, pr, tagLoca), font.NumGlyphs, font.Head.indexToLocFormat)
+		} else if transform && tag == tagLoca {
+			table.transform = []byte{}
+		} else if transform && tag == tagHmtx && pr.HasTable(tagGlyf) {
+			font, err := pr.loadTables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.transform = woff2TransformHmtx(font)
+		}
+		tables = append(tables, table)
+	}
+	// the loca table must follow the glyf table
+	for i := range tables {
+		if tables[i].tag != tagLoca {
+			continue
+		}
+		loca := tables[i]
+		tables = append(tables[:i], tables[i+1:]...)
+		for j := range tables {
+			if tables[j].tag == tagGlyf {
+				tables = append(tables[:j+1], append([]woff2TestTable{loca}, tables[j+1:]...)...)
+				break
+			}
+		}
+		break
+	}
+	indices := map[uint32]int{}
+	for i, table := range tables {
+		indices[table.offset] = i
+	}
+
+	var directory, stream []byte
+	for _, table := range tables {
+		tagIndex := 63
+		for i, known := range woff2KnownTags {
+			if known == table.tag {
+				tagIndex = i
+			}
+		}
+		var transformVersion byte
+		if table.tag == tagGlyf || table.tag == tagLoca {
+			transformVersion = 3
+		}
+		if table.transform != nil {
+			transformVersion ^= 3 // 0 for glyf and loca
+			if table.tag == tagHmtx {
+				transformVersion = 1
+			}
+		}
+		directory = append(directory, transformVersion<<6|byte(tagIndex))
+		if tagIndex == 63 {
+			directory = binary.BigEndian.AppendUint32(directory, uint32(table.tag))
+		}
+		directory = woff2AppendBase128(directory, uint32(len(table.data)))
+		if table.transform != nil {
+			directory = woff2AppendBase128(directory, uint32(len(table.transform)))
+			stream = append(stream, table.transform...)
+		} else {
+			stream = append(stream, table.data...)
+		}
+	}
+
+	flavor := fontsTables[0].flavor
+	if len(parsers) > 1 || file[0] == 't' { // collection
+		flavor = ttcTag
+		directory = binary.BigEndian.AppendUint32(directory, 0x00010000)
+		directory = woff2Append255UInt16(directory, uint16(len(fontsTables)))
+		for _, ft := range fontsTables {
+			directory = woff2Append255UInt16(directory, uint16(len(ft.offsets)))
+			directory = binary.BigEndian.AppendUint32(directory, uint32(ft.flavor))
+			for _, offset := range ft.offsets {
+				directory = woff2Append255UInt16(directory, uint16(indices[offset]))
+			}
+		}
+	}
+
+	var compressed bytes.Buffer
+	w := brotli.NewWriter(&compressed)
+	w.Write(stream)
+	w.Close()
+
+	out := make([]byte, woff2HeaderSize)
+	out = append(out, directory...)
+	out = append(out, compressed.Bytes()...)
+	for len(out)%4 != 0 {
+		out = append(out, 0)
+	}
+	binary.BigEndian.PutUint32(out[0:], uint32(SignatureWOFF2))
+	binary.BigEndian.PutUint32(out[4:], uint32(flavor))
+	binary.BigEndian.PutUint32(out[8:], uint32(len(out)))
+	binary.BigEndian.PutUint16(out[12:], uint16(len(tables)))
+	binary.BigEndian.PutUint32(out[16:], uint32(len(file)))
+	binary.BigEndian.PutUint32(out[20:], uint32(compressed.Len()))
+	return out
+}
+
+func mustRawTable(t *testing.T, pr *FontParser, tag Tag) []byte {
+	t.Helper()
+	data, err := pr.GetRawTable(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// compareTables checks that every table of `got` is identical to the one of `expected`,
+// except for the head checkSumAdjustment and the tables listed in `skip`
+func compareTables(t *testing.T, expected, got *FontParser, skip ...Tag) {
+	t.Helper()
+
+	if len(expected.tables) != len(got.tables) {
+		t.Fatalf("expected %d tables, got %d", len(expected.tables), len(got.tables))
+	}
+	if expected.Type != got.Type {
+		t.Fatalf("expected flavor %s, got %s", expected.Type, got.Type)
+	}
+tables:
+	for tag := range expected.tables {
+		for _, s := range skip {
+			if s == tag {
+				continue tables
+			}
+		}
+		exp, got := mustRawTable(t, expected, tag), mustRawTable(t, got, tag)
+		if tag == tagHead {
+			exp, got = append([]byte(nil), exp...), append([]byte(nil), got...)
+			binary.BigEndian.PutUint32(exp[8:], 0)
+			binary.BigEndian.PutUint32(got[8:], 0)
+		}
+		if !bytes.Equal(exp, got) {
+			t.Errorf("table %s: decoded data differs from the original", tag)
+		}
+	}
+}
+
+func TestWOFF2NullTransform(t *testing.T) {
+	// these files use the canonical layout (sorted table records,
+	// padded tables and valid checksums), so that decoding
+	// must give back the exact same file
+	for _, filename := range []string{
+		"Raleway-v4020-Regular.otf",
+		"CFFTest.otf",
+		"ToyCMAP14.otf",
+		"ToyCBLC1.ttf",
+	} {
+		file, err := testdata.Files.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ttf, err := decodeWOFF2(bytes.NewReader(encodeWOFF2(t, file, false)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ttf, file) {
+			t.Errorf("%s: decoded font differs from the original", filename)
+		}
+	}
+
+	// for the other files, the tables content are preserved
+	for _, filename := range []string{
+		"Roboto-BoldItalic.ttf",
+		"SelawikVar.ttf",
+		"DejaVuSerif.ttf",
+	} {
+		file, err := testdata.Files.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ttf, err := decodeWOFF2(bytes.NewReader(encodeWOFF2(t, file, false)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checksum := calcChecksum(ttf); checksum != 0xB1B0AFBA {
+			t.Errorf("%s: invalid font checksum %x", filename, checksum)
+		}
+		expected, err := NewFontParser(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewFontParser(bytes.NewReader(ttf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareTables(t, expected, got)
+	}
+}
+
+func TestWOFF2Transform(t *testing.T) {
+	for _, filename := range []string{
+		"Roboto-BoldItalic.ttf",
+		"SelawikVar.ttf",
+		"Castoro-Regular.ttf",
+		"DejaVuSerif.ttf",
+		"NotoSansArabic.ttf",
+		"Raleway-v4020-Regular.otf", // no glyf table
+	} {
+		file, err := testdata.Files.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		woff2 := encodeWOFF2(t, file, true)
+
+		expected, err := NewFontParser(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewFontParser(bytes.NewReader(woff2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the transform does not preserve the encoding of the glyphs points,
+		// so that glyf and loca are compared after parsing
+		compareTables(t, expected, got, tagGlyf, tagLoca)
+		compareGlyphs(t, filename, expected, got)
+	}
+}
+
+// compareGlyphs checks that the glyphs of `expected` and `got` have the same
+// points and instructions, regardless of their encoding
+func compareGlyphs(t *testing.T, filename string, expected, got *FontParser) {
+	t.Helper()
+
+	expectedFont, err := expected.loadTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotFont, err := got.loadTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expectedFont.Glyf) != len(gotFont.Glyf) {
+		t.Fatalf("%s: expected %d glyphs, got %d", filename, len(expectedFont.Glyf), len(gotFont.Glyf))
+	}
+	for i, exp := range expectedFont.Glyf {
+		got := gotFont.Glyf[i]
+		exp.rawdata, got.rawdata = nil, nil
+		// only the on curve bit is meaningful
+		for _, glyph := range []GlyphData{exp, got} {
+			if data, ok := glyph.data.(simpleGlyphData); ok {
+				for j := range data.points {
+					data.points[j].flag &= flagOnCurve
+				}
+			}
+		}
+		if !reflect.DeepEqual(exp, got) {
+			t.Errorf("%s: glyph %d: expected %v, got %v", filename, i, exp, got)
+		}
+	}
+}
+
+func TestWOFF2Reference(t *testing.T) {
+	// fontawesome-webfont.woff2 is distributed with Font Awesome 4.7.0 (SIL OFL 1.1),
+	// and has been built from fontawesome-webfont.ttf by the reference encoder
+	// (github.com/google/woff2), with transformed glyf and loca tables
+	file, err := os.ReadFile("testdata/fontawesome-webfont.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	woff2, err := os.ReadFile("testdata/fontawesome-webfont.woff2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewFontParser(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewFontParser(bytes.NewReader(woff2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareTables(t, expected, got, tagHead, tagGlyf, tagLoca)
+	compareGlyphs(t, "fontawesome-webfont.woff2", expected, got)
+
+	// the encoder flags the font data as transformed (bit 11),
+	// and changes the checkSumAdjustment accordingly
+	expHead := append([]byte(nil), mustRawTable(t, expected, tagHead)...)
+	gotHead := append([]byte(nil), mustRawTable(t, got, tagHead)...)
+	if flags := binary.BigEndian.Uint16(gotHead[16:]); flags&(1<<11) == 0 {
+		t.Fatalf("unexpected head flags %b", flags)
+	}
+	gotHead[16] &^= 1 << 3
+	binary.BigEndian.PutUint32(expHead[8:], 0)
+	binary.BigEndian.PutUint32(gotHead[8:], 0)
+	if !bytes.Equal(expHead, gotHead) {
+		t.Error("table head: decoded data differs from the original")
+	}
+
+	font, err := Parse(bytes.NewReader(woff2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := font.PostscriptName(); name != "FontAwesome" {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
+
+func TestWOFF2Collection(t *testing.T) {
+	for _, filename := range []string{
+		"ToyTTC.ttc",
+		"Bangla Sangam MN.ttc",
+	} {
+		file, err := testdata.Files.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := NewFontParsers(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, transform := range []bool{false, true} {
+			woff2 := encodeWOFF2(t, file, transform)
+			got, err := NewFontParsers(bytes.NewReader(woff2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(expected) {
+				t.Fatalf("%s: expected %d fonts, got %d", filename, len(expected), len(got))
+			}
+			for i := range expected {
+				compareTables(t, expected[i], got[i], tagGlyf, tagLoca)
+			}
+
+			fonts, err := Load(bytes.NewReader(woff2))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fonts) != len(expected) {
+				t.Fatalf("%s: expected %d fonts, got %d", filename, len(expected), len(fonts))
+			}
+
+			// collections are not supported by Parse
+			if _, err = Parse(bytes.NewReader(woff2)); err == nil {
+				t.Fatal("expected error for collection")
+			}
+		}
+	}
+}
+
+func TestWOFF2Loaders(t *testing.T) {
+	file, err := testdata.Files.ReadFile("Roboto-BoldItalic.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	woff2 := encodeWOFF2(t, file, true)
+
+	font, err := Parse(bytes.NewReader(woff2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font.Type != TypeTrueType {
+		t.Fatalf("unexpected font type %s", font.Type)
+	}
+	if name := font.PostscriptName(); name != "Roboto-BoldItalic" {
+		t.Fatalf("unexpected name %s", name)
+	}
+
+	fonts, err := Load(bytes.NewReader(woff2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fonts) != 1 {
+		t.Fatal("expected one font")
+	}
+
+	descriptors, err := ScanFont(bytes.NewReader(woff2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptors) != 1 {
+		t.Fatal("expected one font")
+	}
+	if family := descriptors[0].Family(); family != "Roboto" {
+		t.Fatalf("unexpected family %s", family)
+	}
+}
+
+func TestWOFF2Invalid(t *testing.T) {
+	file, err := testdata.Files.ReadFile("Roboto-BoldItalic.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	woff2 := encodeWOFF2(t, file, true)
+
+	// truncated files
+	for _, length := range []int{0, 4, 20, woff2HeaderSize, woff2HeaderSize + 10, len(woff2) / 2, len(woff2) - 1} {
+		if _, err := Parse(bytes.NewReader(woff2[:length])); err == nil {
+			t.Errorf("expected error for length %d", length)
+		}
+	}
+
+	// corrupted files should not panic
+	for i := 0; i < 2000; i++ {
+		corrupted := append([]byte(nil), woff2...)
+		pos := woff2HeaderSize + i%(len(corrupted)-woff2HeaderSize)
+		corrupted[pos] ^= byte(i) | 1
+		Parse(bytes.NewReader(corrupted))
+	}
+
+	// invalid header values
+	for _, modify := range []func(data []byte){
+		func(data []byte) { binary.BigEndian.PutUint16(data[12:], 0) },                 // no tables
+		func(data []byte) { binary.BigEndian.PutUint32(data[8:], 1<<30) },              // too long
+		func(data []byte) { binary.BigEndian.PutUint32(data[20:], uint32(len(data))) }, // compressed size
+	} {
+		corrupted := append([]byte(nil), woff2...)
+		modify(corrupted)
+		if _, err := Parse(bytes.NewReader(corrupted)); err == nil {
+			t.Error("expected error for invalid header")
+		}
+	}
+}
+
+func TestWOFF2VariableEncodings(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		expected uint32
+		valid    bool
+	}{
+		{[]byte{0x3F}, 63, true},
+		{[]byte{0x81, 0x00}, 128, true},
+		{[]byte{0x8F, 0xFF, 0xFF, 0xFF, 0x7F}, 0xFFFFFFFF, true},
+		{[]byte{0x80, 0x01}, 0, false},                   // leading zeros
+		{[]byte{0x90, 0x80, 0x80, 0x80, 0x00}, 0, false}, // overflow
+		{[]byte{0x81, 0x81, 0x81, 0x81, 0x81, 0x01}, 0, false},
+		{[]byte{0x81}, 0, false}, // EOF
+	} {
+		r := woff2Reader{data: test.data}
+		got, err := r.base128()
+		if (err == nil) != test.valid {
+			t.Errorf("%v: unexpected error %v", test.data, err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %d, got %d", test.data, test.expected, got)
+		}
+		if test.valid {
+			if enc := woff2AppendBase128(nil, test.expected); !bytes.Equal(enc, test.data) {
+				t.Errorf("%d: expected %v, got %v", test.expected, test.data, enc)
+			}
+		}
+	}
+
+	for _, v := range []uint16{0, 252, 253, 505, 506, 761, 762, 0xFFFF} {
+		r := woff2Reader{data: woff2Append255UInt16(nil, v)}
+		got, err := r.read255UInt16()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("expected %d, got %d", v, got)
+		}
+	}
+}
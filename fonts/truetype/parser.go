@@ -57,6 +57,14 @@ func NewFontParsers(file fonts.Resource) ([]*FontParser, error) {
 	switch magic {
 	case SignatureWOFF, TypeTrueType, TypeOpenType, TypePostScript1, TypeAppleTrueType:
 		pr, err = parseOneFont(file, 0, false)
+	case SignatureWOFF2:
+		// the font is decoded as a whole, and may be a collection
+		var decoded fonts.Resource
+		decoded, err = decodeWOFF2Resource(file)
+		if err != nil {
+			return nil, err
+		}
+		return NewFontParsers(decoded)
 	case ttcTag:
 		offsets, err = parseTTCHeader(file)
 	case dfontResourceDataOffset:
@@ -213,6 +221,15 @@ func (pr *FontParser) cffTable(numGlyphs int) (*type1c.Font, error) {
 	return out, nil
 }
 
+func (pr *FontParser) cff2Table(numGlyphs int, fvar TableFvar) (*type1c.CFF2, error) {
+	buf, err := pr.GetRawTable(tagCFF2)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTableCFF2(buf, numGlyphs, len(fvar.Axis))
+}
+
 func (pr *FontParser) sbixTable(numGlyphs int) (tableSbix, error) {
 	buf, err := pr.GetRawTable(tagSbix)
 	if err != nil {
@@ -406,6 +423,14 @@ func (pr *FontParser) cvtTable() ([]byte, error) {
 	return nil, nil
 }
 
+func (pr *FontParser) fpgmTable() ([]byte, error) {
+	s, found := pr.tables[tagFpgm]
+	if found {
+		return pr.findTableBuffer(s)
+	}
+	return nil, nil
+}
+
 func (pr *FontParser) maxpTable() (TableMaxp, error) {
 	s, found := pr.tables[tagMaxp]
 	if !found {
@@ -444,6 +469,27 @@ func (pr *FontParser) PostTable(numGlyphs int) (TablePost, error) {
 	return parseTablePost(buf, uint16(numGlyphs))
 }
 
+// colrTable returns the color glyphs table, whose variation store
+// (COLR version 1) is parsed using the axes of `fvar`.
+func (pr *FontParser) colrTable(fvar TableFvar) (tableCOLR, error) {
+	buf, err := pr.GetRawTable(tagCOLR)
+	if err != nil {
+		return tableCOLR{}, err
+	}
+
+	return parseTableCOLR(buf, len(fvar.Axis))
+}
+
+// CPALTable returns the color palettes of the font.
+func (pr *FontParser) CPALTable() (TableCPAL, error) {
+	buf, err := pr.GetRawTable(tagCPAL)
+	if err != nil {
+		return TableCPAL{}, err
+	}
+
+	return parseTableCPAL(buf)
+}
+
 // svgTable returns the Post table names
 func (pr *FontParser) svgTable() (tableSVG, error) {
 	buf, err := pr.GetRawTable(tagSVG)
@@ -718,6 +764,17 @@ func parseOneFont(file fonts.Resource, offset uint32, relativeOffset bool) (pars
 	switch magic {
 	case SignatureWOFF:
 		parser, err = parseWOFF(file, offset, relativeOffset)
+	case SignatureWOFF2:
+		if offset != 0 { // WOFF2 files are not allowed in collections
+			return nil, errUnsupportedFormat
+		}
+		var decoded fonts.Resource
+		decoded, err = decodeWOFF2Resource(file)
+		if err != nil {
+			return nil, err
+		}
+		// as for TTC files, WOFF2 collections are not supported here
+		parser, err = parseOneFont(decoded, 0, false)
 	case TypeTrueType, TypeOpenType, TypePostScript1, TypeAppleTrueType:
 		parser, err = parseOTF(file, offset, relativeOffset)
 	default:
@@ -763,6 +820,11 @@ func (pr *FontParser) loadTables() (*Font, error) {
 		return nil, err
 	}
 
+	out.fpgm, err = pr.fpgmTable()
+	if err != nil {
+		return nil, err
+	}
+
 	out.NumGlyphs = int(out.Maxp.NumGlyphs)
 
 	cmaps, err := pr.CmapTable()
@@ -796,8 +858,11 @@ func (pr *FontParser) loadTables() (*Font, error) {
 
 	out.sbix, _ = pr.sbixTable(out.NumGlyphs)
 	out.cff, _ = pr.cffTable(out.NumGlyphs)
+	out.cff2, _ = pr.cff2Table(out.NumGlyphs, out.fvar)
 	out.post, _ = pr.PostTable(out.NumGlyphs)
 	out.svg, _ = pr.svgTable()
+	out.colr, _ = pr.colrTable(out.fvar)
+	out.CPAL, _ = pr.CPALTable()
 
 	out.hhea, _ = pr.HheaTable()
 	out.vhea, _ = pr.VheaTable()
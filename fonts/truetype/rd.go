@@ -60,13 +60,18 @@ func (colorBitmap bitmapTable) glyphData(gid GID, xPpem, yPpem uint16) (fonts.Gl
 	return out, nil
 }
 
-// look for data in 'glyf' and 'cff' tables
+// look for data in 'glyf', 'CFF ' and 'CFF2' tables
 func (f *Font) outlineGlyphData(gid GID) (fonts.GlyphOutline, bool) {
 	out, err := f.glyphDataFromCFF1(gid)
 	if err == nil {
 		return out, true
 	}
 
+	out, err = f.glyphDataFromCFF2(gid)
+	if err == nil {
+		return out, true
+	}
+
 	out, err = f.glyphDataFromGlyf(gid)
 	if err == nil {
 		return out, true
@@ -78,7 +83,17 @@ func (f *Font) outlineGlyphData(gid GID) (fonts.GlyphOutline, bool) {
 func (f *Font) GlyphData(gid GID, xPpem, yPpem uint16) fonts.GlyphData {
 	var out fonts.GlyphData
 
-	// try every table
+	// try every table, starting with vector color glyphs
+	var coords []float32
+	if f.isVar() {
+		coords = f.varCoords
+	}
+	if color, err := f.colr.glyphData(gid, coords); err == nil {
+		// the base glyph usually has a regular description too
+		color.Outline, _ = f.outlineGlyphData(gid)
+		return color
+	}
+
 	out, err := f.sbix.glyphData(gid, xPpem, yPpem)
 	if err == nil {
 		return out
@@ -151,3 +151,15 @@ func (f *Font) glyphDataFromCFF1(glyph GID) (fonts.GlyphOutline, error) {
 	}
 	return fonts.GlyphOutline{Segments: segments}, nil
 }
+
+// apply variation when needed
+func (f *Font) glyphDataFromCFF2(glyph GID) (fonts.GlyphOutline, error) {
+	if f.cff2 == nil {
+		return fonts.GlyphOutline{}, errors.New("no CFF2 table")
+	}
+	segments, _, err := f.cff2.LoadGlyph(glyph, f.varCoords)
+	if err != nil {
+		return fonts.GlyphOutline{}, err
+	}
+	return fonts.GlyphOutline{Segments: segments}, nil
+}
@@ -1,7 +1,6 @@
 package truetype
 
 import (
-	"bytes"
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
@@ -24,6 +23,9 @@ func binarywrite(w io.Writer, data interface{}) error {
 
 func (fnt *Font) getAdditionalCodepoints(codepoint GID) []GID {
 	var additionalCodepoints []GID
+	if int(codepoint) >= len(fnt.Glyf) {
+		return nil
+	}
 	cp := fnt.Glyf[codepoint]
 	additionalCodepoints = append(additionalCodepoints, codepoint)
 	switch t := cp.data.(type) {
@@ -41,61 +43,249 @@ func (fnt *Font) getAdditionalCodepoints(codepoint GID) []GID {
 	return additionalCodepoints
 }
 
-// subsetTrueType removes all data from the font file that is not necessary to
-// render the given code points.
-func (fnt *Font) subsetTrueType(codepoints []GID) error {
-	var additionalCodepoints []GID
-	for _, gid := range codepoints {
-		cp := fnt.Glyf[gid]
-		switch t := cp.data.(type) {
-		case compositeGlyphData:
-			for _, g := range t.glyphs {
-				additionalCodepoints = append(additionalCodepoints, fnt.getAdditionalCodepoints(g.glyphIndex)...)
+// SubsetOptions controls the behavior of SubsetWithOptions.
+type SubsetOptions struct {
+	// RetainGIDs keeps the glyph indices of the original font, the glyphs
+	// not in the subset being replaced by empty ones.
+	// By default, the glyphs are renumbered compactly.
+	RetainGIDs bool
+
+	// KeepLayout extends the subset to the glyphs reachable through the GSUB
+	// substitutions, and keeps the GSUB, GPOS and GDEF tables, pruned and renumbered
+	// for the subset, so that the output of WriteFont may still be shaped.
+	// It is typically used for web or editable documents, whereas the default
+	// only retains what is needed to draw the glyphs, as required for PDF embedding.
+	// The AAT and 'kern' tables are never kept.
+	KeepLayout bool
+}
+
+// SubsetResult is the output of Subset.
+type SubsetResult = fonts.SubsetResult
+
+// Subset returns a font containing only the data needed for the given glyphs,
+// which are renumbered compactly. See SubsetWithOptions for more details.
+func (fnt *Font) Subset(gids []GID) (*SubsetResult, error) {
+	return fnt.SubsetWithOptions(gids, SubsetOptions{})
+}
+
+// SubsetWithOptions returns a font containing only the data needed for the given glyphs.
+// The .notdef glyph and the components of composite glyphs are always included.
+// The receiver is not modified, so that it is safe to subset a font several times,
+// possibly concurrently.
+// The returned SubsetResult.Font is a *Font, whose PDF methods and WriteSubset
+// describe the subset.
+func (fnt *Font) SubsetWithOptions(gids []GID, opts SubsetOptions) (*SubsetResult, error) {
+	glyphs := []GID{0}
+	for _, gid := range gids {
+		if int(gid) >= fnt.NumGlyphs {
+			return nil, fmt.Errorf("invalid glyph index %d", gid)
+		}
+		glyphs = append(glyphs, gid)
+	}
+	if opts.KeepLayout {
+		glyphs = fnt.closeOverLayout(glyphs)
+	}
+	glyphs, err := fnt.closeOverComponents(glyphs)
+	if err != nil {
+		return nil, err
+	}
+
+	gidMap := make(map[GID]GID, len(glyphs))
+	for i, gid := range glyphs {
+		if opts.RetainGIDs {
+			gidMap[gid] = gid
+		} else {
+			gidMap[gid] = GID(i)
+		}
+	}
+
+	sub := *fnt
+	numGlyphs := len(glyphs)
+	if opts.RetainGIDs {
+		numGlyphs = int(glyphs[len(glyphs)-1]) + 1
+	}
+	sub.NumGlyphs = numGlyphs
+	sub.subsetCodepoints = make([]GID, len(glyphs))
+	for i, gid := range glyphs {
+		sub.subsetCodepoints[i] = gidMap[gid]
+	}
+
+	// the glyphs not used in the subset are replaced by an empty glyph
+	sub.Hmtx = make(TableHVmtx, numGlyphs)
+	for _, gid := range glyphs {
+		if int(gid) < len(fnt.Hmtx) {
+			sub.Hmtx[gidMap[gid]] = fnt.Hmtx[gid]
+		}
+	}
+	if fnt.hhea != nil {
+		hhea := *fnt.hhea
+		hhea.NumberOfHMetrics = uint16(numGlyphs)
+		sub.hhea = &hhea
+	}
+
+	if fnt.cff != nil {
+		cff, err := fnt.cff.Subset(glyphs, opts.RetainGIDs)
+		if err != nil {
+			return nil, err
+		}
+		sub.cff = cff
+	} else {
+		sub.Glyf = make(TableGlyf, numGlyphs)
+		for _, gid := range glyphs {
+			if int(gid) >= len(fnt.Glyf) {
+				continue
 			}
+			glyph := fnt.Glyf[gid]
+			if _, isComposite := glyph.data.(compositeGlyphData); isComposite && !opts.RetainGIDs {
+				glyph.rawdata = remapCompositeGlyph(glyph.rawdata, gidMap)
+			}
+			sub.Glyf[gidMap[gid]] = glyph
 		}
+		sub.Head.indexToLocFormat = 1
 	}
-	codepoints = append(codepoints, additionalCodepoints...)
-	fonts.RemoveDuplicates(codepoints)
 
-	maxCP := codepoints[len(codepoints)-1] + 1
-	// the codepoints not used in the subset (or used from one of these glyphs)
-	// are replaced by an empty glyph.
-	glyphs := make([]GlyphData, maxCP)
+	// the cmap is rewritten with the new glyph indices
+	cmap := make(fonts.CmapSimple)
+	sub.toUnicode = make(map[GID][]rune)
+	if fnt.cmap != nil {
+		iter := fnt.cmap.Iter()
+		for iter.Next() {
+			r, gid := iter.Char()
+			newGID, ok := gidMap[gid]
+			if !ok {
+				continue
+			}
+			cmap[r] = newGID
+			// use the smallest rune for reproducible output
+			if runes := sub.toUnicode[newGID]; len(runes) == 0 || r < runes[0] {
+				sub.toUnicode[newGID] = []rune{r}
+			}
+		}
+	}
+	sub.cmap, sub.cmapVar = cmap, nil
+	if fnt.OS2 != nil {
+		os2 := *fnt.OS2
+		os2.USFirstCharIndex, os2.USLastCharIndex = 0xFFFF, 0
+		for r := range cmap {
+			if r > 0xFFFF { // the fields are limited to the BMP
+				r = 0xFFFF
+			}
+			if r < rune(os2.USFirstCharIndex) {
+				os2.USFirstCharIndex = uint16(r)
+			}
+			if r > rune(os2.USLastCharIndex) {
+				os2.USLastCharIndex = uint16(r)
+			}
+		}
+		sub.OS2 = &os2
+	}
 
-	emptyGlyph := GlyphData{}
-	for i, c := GID(0), 0; i < maxCP; i++ {
-		if i == codepoints[c] {
-			glyphs[i] = fnt.Glyf[i]
-			c++
+	if fnt.vhea != nil && fnt.vmtx != nil {
+		sub.vmtx = make(TableHVmtx, numGlyphs)
+		for _, gid := range glyphs {
+			if int(gid) < len(fnt.vmtx) {
+				sub.vmtx[gidMap[gid]] = fnt.vmtx[gid]
+			}
+		}
+		vhea := *fnt.vhea
+		vhea.NumberOfHMetrics = uint16(numGlyphs)
+		sub.vhea = &vhea
+	}
+
+	if fnt.post.Names != nil {
+		names := make([]string, numGlyphs)
+		for _, gid := range glyphs {
+			names[gidMap[gid]] = fnt.post.Names.GlyphName(gid)
+		}
+		sub.post.Names = newPostNamesFormat20(names)
+	}
+
+	// the layout tables refer to glyph indices, so they are
+	// either subsetted or dropped
+	sub.layoutTables = LayoutTables{}
+	if opts.KeepLayout {
+		s := layoutSubsetter{glyphs: glyphs, gidMap: gidMap}
+		sub.layoutTables.GSUB = s.gsub(fnt.layoutTables.GSUB)
+		sub.layoutTables.GPOS = s.gpos(fnt.layoutTables.GPOS)
+		sub.layoutTables.GDEF = s.gdef(fnt.layoutTables.GDEF)
+	}
+
+	sub.SubsetID = getCharTag(append([]GID(nil), glyphs...))
+
+	return &SubsetResult{Font: &sub, GIDMap: gidMap, ToUnicode: sub.toUnicode}, nil
+}
+
+// closeOverLayout returns `glyphs` extended with the glyphs
+// which may be produced by the GSUB substitutions.
+func (fnt *Font) closeOverLayout(glyphs []GID) []GID {
+	if len(fnt.layoutTables.GSUB.Lookups) == 0 {
+		return glyphs
+	}
+	set := make(map[GID]bool, len(glyphs))
+	for _, gid := range glyphs {
+		set[gid] = true
+	}
+	fnt.layoutTables.GSUB.closeOverGlyphs(set)
+	return sortedGlyphs(set)
+}
+
+// closeOverComponents returns `glyphs` extended with the components
+// of composite glyphs (or accented CFF glyphs), sorted and without duplicates.
+func (fnt *Font) closeOverComponents(glyphs []GID) ([]GID, error) {
+	var out []GID
+	for _, gid := range glyphs {
+		if fnt.cff != nil {
+			components, err := fnt.cff.SeacComponents(gid)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, gid)
+			out = append(out, components...)
+		} else if int(gid) < len(fnt.Glyf) {
+			out = append(out, fnt.getAdditionalCodepoints(gid)...)
 		} else {
-			fnt.Hmtx[i].Advance = 0
-			fnt.Hmtx[i].SideBearing = 0
-			glyphs[i] = emptyGlyph
+			out = append(out, gid)
 		}
 	}
-	fnt.Glyf = glyphs
-	fnt.Hmtx = fnt.Hmtx[:maxCP]
-	fnt.NumGlyphs = int(maxCP)
-	fnt.Head.indexToLocFormat = 1
-	fnt.hhea.NumberOfHMetrics = uint16(maxCP)
-	fnt.subsetCodepoints = codepoints
-	return nil
+	return fonts.RemoveDuplicates(out), nil
 }
 
-func (fnt *Font) subsetCFF(codepoints []GID) error {
-	fnt.subsetCodepoints = codepoints
-	fnt.cff.Subset(codepoints)
-	return nil
+// remapCompositeGlyph returns a copy of the composite glyph `rawdata`, where
+// the index of each component is updated using `gidMap`.
+func remapCompositeGlyph(rawdata []byte, gidMap map[GID]GID) []byte {
+	const (
+		weHaveAScale       = 1 << 3
+		moreComponents     = 1 << 5
+		weHaveAnXAndYScale = 1 << 6
+		weHaveATwoByTwo    = 1 << 7
+	)
+	out := append([]byte(nil), rawdata...)
+	// skip the glyph header
+	for pos, flags := 10, uint16(moreComponents); flags&moreComponents != 0 && pos+4 <= len(out); {
+		flags = binary.BigEndian.Uint16(out[pos:])
+		gid := GID(binary.BigEndian.Uint16(out[pos+2:]))
+		binary.BigEndian.PutUint16(out[pos+2:], uint16(gidMap[gid]))
+		pos += 4
+		if flags&arg1And2AreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		if flags&weHaveAScale != 0 {
+			pos += 2
+		} else if flags&weHaveAnXAndYScale != 0 {
+			pos += 4
+		} else if flags&weHaveATwoByTwo != 0 {
+			pos += 8
+		}
+	}
+	return out
 }
 
 // WidthsPDF returns a width entry suitable for embedding in a PDF file.
 func (fnt *Font) WidthsPDF() string {
-	scale := 1.0
-	if fnt.Type != TypeOpenType {
-		scale = float64(fnt.upem) / 1000.0
-	}
 	getWd := func(cp GID) string {
-		return strconv.FormatFloat(float64(fnt.Hmtx[cp].Advance)/scale, 'f', -1, 64)
+		return strconv.Itoa(fnt.toPDF(int(fnt.Hmtx[cp].Advance)))
 	}
 
 	var b strings.Builder
@@ -119,42 +309,12 @@ func (fnt *Font) WidthsPDF() string {
 	return b.String()
 }
 
-// CMapPDF returns a CMap string to be used in a PDF file
+// CMapPDF returns a /ToUnicode CMap to be used in a PDF file.
+// The text of each glyph is found by inverting the cmap, so that ligatures
+// and alternates are not mapped: see fonts.SubsetResult.ToUnicodeCMap to use
+// the text of a shaped buffer instead.
 func (fnt *Font) CMapPDF() string {
-	var numGlyphs int
-
-	if fnt.cff != nil {
-		// numGlyphs = len(fnt..Font[0].CharStrings)
-	} else {
-		numGlyphs = fnt.NumGlyphs
-	}
-
-	cm, _ := fnt.Cmap()
-	iter := cm.Iter()
-	toUni := make(map[GID]rune)
-	for iter.Next() {
-		a, b := iter.Char()
-		toUni[b] = a
-	}
-
-	var b strings.Builder
-	b.WriteString(`/CIDInit /ProcSet findresource begin
-12 dict begin
-begincmap
-/CIDSystemInfo << /Registry (Adobe)/Ordering (UCS)/Supplement 0>> def
-/CMapName /Adobe-Identity-UCS def /CMapType 2 def
-1 begincodespacerange
-`)
-	fmt.Fprintf(&b, "<0001><%04X>\n", numGlyphs)
-	b.WriteString("endcodespacerange\n")
-	fmt.Fprintf(&b, "%d beginbfchar\n", len(fnt.subsetCodepoints))
-	for _, cp := range fnt.subsetCodepoints {
-
-		fmt.Fprintf(&b, "<%04X><%04X>\n", cp, toUni[cp])
-	}
-	b.WriteString(`endbfchar
-endcmap CMapName currentdict /CMap defineresource pop end end`)
-	return b.String()
+	return fonts.ToUnicodeCMap(fnt.toUnicode)
 }
 
 // NamePDF returns the PDF name of the font file
@@ -162,30 +322,100 @@ func (fnt *Font) NamePDF() string {
 	return fmt.Sprintf("/%s-%s", fnt.SubsetID, fnt.PostscriptName())
 }
 
+// toPDF converts `v` from font units to the glyph space
+// of PDF files, where 1000 units are used per em.
+func (fnt *Font) toPDF(v int) int {
+	if fnt.upem == 0 || fnt.upem == 1000 {
+		return v
+	}
+	return int(math.Round(float64(v) * 1000 / float64(fnt.upem)))
+}
+
 // AscenderPDF returns the /Ascent value for the PDF file
 func (fnt *Font) AscenderPDF() int {
-	return int(fnt.hhea.Ascent)
+	return fnt.toPDF(int(fnt.hhea.Ascent))
 }
 
 // DescenderPDF returns the /Descent value for the PDF file
 func (fnt *Font) DescenderPDF() int {
-	return int(fnt.hhea.Descent)
+	return fnt.toPDF(int(fnt.hhea.Descent))
 }
 
 // CapHeightPDF returns the /CapHeight value for the PDF file
 func (fnt *Font) CapHeightPDF() int {
-	ch := int(fnt.OS2.SCapHeight)
-	return ch
+	if fnt.OS2 == nil {
+		return fnt.AscenderPDF()
+	}
+	return fnt.toPDF(int(fnt.OS2.SCapHeight))
 }
 
-// BoundingBoxPDF returns the /FontBBox value for the PDF file
+// BoundingBoxPDF returns the /FontBBox value for the PDF file,
+// which is the bounding box of all the glyphs, as found in the 'head' table.
 func (fnt *Font) BoundingBoxPDF() string {
-	return fmt.Sprintf("[%d %d %d %d]", 0, fnt.hhea.Descent, 1000, fnt.hhea.Ascent)
+	return fmt.Sprintf("[%d %d %d %d]", fnt.toPDF(int(fnt.Head.XMin)), fnt.toPDF(int(fnt.Head.YMin)),
+		fnt.toPDF(int(fnt.Head.XMax)), fnt.toPDF(int(fnt.Head.YMax)))
 }
 
-// FlagsPDF returns the /Flags value for the PDF file
+// flags of the PDF font descriptor
+const (
+	pdfFixedPitch  = 1 << 0
+	pdfSerif       = 1 << 1
+	pdfSymbolic    = 1 << 2
+	pdfScript      = 1 << 3
+	pdfNonsymbolic = 1 << 5
+	pdfItalic      = 1 << 6
+)
+
+// FlagsPDF returns the /Flags value for the PDF file.
+// It is derived from the 'post' table, the font class and Panose
+// classification of the 'OS/2' table, the italic angle and the kind of cmap.
 func (fnt *Font) FlagsPDF() int {
-	return 4
+	var (
+		flags       int
+		familyClass = -1 // unknown
+		panose      [10]byte
+		italic      = fnt.post.ItalicAngle != 0 || fnt.Head.MacStyle&2 != 0
+	)
+	if fnt.OS2 != nil {
+		familyClass = int(fnt.OS2.SFamilyClass >> 8)
+		panose = fnt.OS2.Panose
+		italic = italic || fnt.OS2.FsSelection&1 != 0
+	}
+	const (
+		panoseLatinText        = 2
+		panoseLatinHandWritten = 3
+		panoseLatinSymbol      = 5
+		panoseMonospaced       = 9 // proportion
+		panoseNormalSans       = 11
+		familyClassSansSerif   = 8
+		familyClassScript      = 10
+		familyClassSymbolic    = 12
+	)
+
+	if fnt.post.IsFixedPitch || (panose[0] == panoseLatinText && panose[3] == panoseMonospaced) {
+		flags |= pdfFixedPitch
+	}
+	switch familyClass {
+	case 1, 2, 3, 4, 5, 7: // oldstyle, transitional, modern, clarendon, slab and freeform serifs
+		flags |= pdfSerif
+	case familyClassScript:
+		flags |= pdfScript
+	case -1, 0: // no classification: use Panose instead
+		if panose[0] == panoseLatinText && panose[1] >= 2 && panose[1] < panoseNormalSans {
+			flags |= pdfSerif
+		} else if panose[0] == panoseLatinHandWritten {
+			flags |= pdfScript
+		}
+	}
+	if fnt.cmapEncoding == fonts.EncSymbol || familyClass == familyClassSymbolic || panose[0] == panoseLatinSymbol {
+		flags |= pdfSymbolic
+	} else {
+		flags |= pdfNonsymbolic
+	}
+	if italic {
+		flags |= pdfItalic
+	}
+	return flags
 }
 
 // ItalicAnglePDF returns the /ItalicAngle value for the PDF file
@@ -193,26 +423,31 @@ func (fnt *Font) ItalicAnglePDF() int {
 	return int(fnt.post.ItalicAngle)
 }
 
-// StemVPDF returns the /StemV value for the PDF file
+// StemVPDF returns the /StemV value for the PDF file.
+// It is taken from the /StdVW entry of the CFF Private dict if present,
+// or estimated from the weight class of the font.
 func (fnt *Font) StemVPDF() int {
-	return 0
+	if fnt.cff != nil {
+		if stdVW := fnt.cff.StdVW(); stdVW != 0 {
+			return fnt.toPDF(stdVW)
+		}
+	}
+	weight := 400.
+	if fnt.OS2 != nil && fnt.OS2.USWeightClass != 0 {
+		weight = float64(fnt.OS2.USWeightClass)
+	} else if fnt.Head.MacStyle&1 != 0 { // bold
+		weight = 700
+	}
+	// a common heuristic, giving 88 for regular fonts and 166 for bold fonts
+	return int(math.Round(50 + (weight/65)*(weight/65)))
 }
 
 // XHeightPDF returns the /XHeight value for the PDF file
 func (fnt *Font) XHeightPDF() int {
-	xh := int(fnt.OS2.SxHeigh)
-	return xh
-}
-
-// Subset removes all data from the font except the one needed for the given
-// code points.
-func (fnt *Font) Subset(codepoints []GID) error {
-	fnt.SubsetID = getCharTag(codepoints)
-	if fnt.cff == nil {
-		err := fnt.subsetTrueType(codepoints)
-		return err
+	if fnt.OS2 == nil {
+		return 0
 	}
-	return fnt.subsetCFF(codepoints)
+	return fnt.toPDF(int(fnt.OS2.SxHeigh))
 }
 
 type tableOffsetLength struct {
@@ -269,23 +504,29 @@ func (fnt *Font) writeHead(w io.Writer) error {
 }
 
 func (fnt *Font) writeGlyf(w io.Writer) error {
-	glyphOffsets := []uint32{}
+	for i := 0; i < fnt.NumGlyphs; i++ {
+		if _, err := w.Write(fnt.Glyf[i].rawdata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// glyphOffsets returns the offsets of the glyphs written by writeGlyf
+func (fnt *Font) glyphOffsets() []uint32 {
+	glyphOffsets := make([]uint32, 0, fnt.NumGlyphs+1)
 	c := uint32(0)
 	for i := 0; i < fnt.NumGlyphs; i++ {
-		g := fnt.Glyf[i]
 		glyphOffsets = append(glyphOffsets, c)
-		w.Write(g.rawdata)
-		c += uint32(len(g.rawdata))
+		c += uint32(len(fnt.Glyf[i].rawdata))
 	}
-	glyphOffsets = append(glyphOffsets, c)
-	fnt.glyphOffsets = glyphOffsets
-	return nil
+	return append(glyphOffsets, c)
 }
 
-func (fnt *Font) writeHHea(w io.Writer) error {
-	tbl := fnt.hhea
-	binarywrite(w, uint16(1))
-	binarywrite(w, uint16(0))
+// writeHVhea writes the 'hhea' or 'vhea' table `tbl`, whose metrics
+// are written for all the glyphs.
+func (fnt *Font) writeHVhea(w io.Writer, tbl *TableHVhea, version uint32) error {
+	binarywrite(w, version)
 
 	var reserved int16
 
@@ -307,10 +548,15 @@ func (fnt *Font) writeHHea(w io.Writer) error {
 	binarywrite(w, reserved)
 
 	binarywrite(w, tbl.MetricDataFormat)
-	binarywrite(w, tbl.NumberOfHMetrics)
+	binarywrite(w, uint16(fnt.NumGlyphs))
 	return nil
 }
 
+func (fnt *Font) writeFpgm(w io.Writer) error {
+	_, err := w.Write(fnt.fpgm)
+	return err
+}
+
 func (fnt *Font) writeCvt(w io.Writer) error {
 	_, err := w.Write(fnt.cvt)
 	return err
@@ -354,12 +600,12 @@ func (fnt *Font) writeLoca(w io.Writer) error {
 	switch version {
 	case 0:
 		var offset uint16
-		for _, off := range fnt.glyphOffsets {
+		for _, off := range fnt.glyphOffsets() {
 			offset = uint16(off / 2)
 			binarywrite(w, offset)
 		}
 	case 1:
-		for _, off := range fnt.glyphOffsets {
+		for _, off := range fnt.glyphOffsets() {
 			binarywrite(w, off)
 		}
 	}
@@ -367,9 +613,10 @@ func (fnt *Font) writeLoca(w io.Writer) error {
 	return nil
 }
 
-func (fnt *Font) writeHmtx(w io.Writer) error {
+// writeHVmtx writes the 'hmtx' or 'vmtx' table `tbl`, with
+// one long metric per glyph.
+func (fnt *Font) writeHVmtx(w io.Writer, tbl TableHVmtx) error {
 	var err error
-	tbl := fnt.Hmtx
 	l := GID(fnt.NumGlyphs)
 	for i := GID(0); i < l; i++ {
 		if err = binarywrite(w, uint16(tbl[i].Advance)); err != nil {
@@ -387,28 +634,40 @@ func (fnt *Font) writeHmtx(w io.Writer) error {
 func (fnt *Font) writeTable(w io.Writer, t Tag) error {
 	var err error
 	switch t {
-	// case "CFF ":
-	// 	err = tt.CFF.WriteCFFData(w)
+	case tagCFF:
+		err = fnt.cff.WriteSubset(w)
 	case tagLoca:
 		err = fnt.writeLoca(w)
 	case tagHhea:
-		err = fnt.writeHHea(w)
+		err = fnt.writeHVhea(w, fnt.hhea, 0x00010000)
+	case tagVhea:
+		err = fnt.writeHVhea(w, fnt.vhea, 0x00011000)
 	case tagHead:
 		err = fnt.writeHead(w)
 	case tagMaxp:
 		err = fnt.writeMaxp(w)
 	case tagHmtx:
-		err = fnt.writeHmtx(w)
+		err = fnt.writeHVmtx(w, fnt.Hmtx)
+	case tagVmtx:
+		err = fnt.writeHVmtx(w, fnt.vmtx)
 	case tagCvt:
 		err = fnt.writeCvt(w)
 	case tagPrep:
 		err = fnt.writePrep(w)
+	case tagFpgm:
+		err = fnt.writeFpgm(w)
 	case tagGlyf:
 		err = fnt.writeGlyf(w)
-	// case tagPost:
-	// 	err = fnt.writePost(w)
-	// case tagOS2:
-	// 	err = fnt.writeOs2(w)
+	case tagCmap:
+		err = fnt.writeCmap(w)
+	case tagPost:
+		err = fnt.writePost(w)
+	case tagName:
+		err = fnt.writeName(w)
+	case tagOS2:
+		err = fnt.writeOS2(w)
+	case TagGsub, TagGpos, TagGdef:
+		err = fnt.writeLayoutTable(w, t)
 	default:
 		// fmt.Printf("    skip write table %s\n", tbl)
 	}
@@ -418,12 +677,18 @@ func (fnt *Font) writeTable(w io.Writer, t Tag) error {
 	return nil
 }
 
+// calcChecksum returns the OpenType checksum of `data`,
+// which is padded with zeros if its length is not a multiple of 4.
 func calcChecksum(data []byte) uint32 {
 	sum := uint32(0)
 	c := 0
-	for c < len(data) {
-		sum += uint32(data[c])<<3 + uint32(data[c+1])<<2 + uint32(data[c+2])<<1 + uint32(data[c+3])
-		c += 4
+	for ; c+4 <= len(data); c += 4 {
+		sum += binary.BigEndian.Uint32(data[c:])
+	}
+	if c < len(data) {
+		var last [4]byte
+		copy(last[:], data[c:])
+		sum += binary.BigEndian.Uint32(last[:])
 	}
 	return sum
 }
@@ -433,92 +698,14 @@ func (fnt *Font) WriteSubset(w io.Writer) error {
 	if fnt.cff != nil {
 		return fnt.cff.WriteSubset(w)
 	}
-	var err error
-	var fontfile bytes.Buffer
-	fnt.Head.checkSumAdjustment = 0
-
-	tablesForPDF := []tableOffsetLength{}
-
 	// put only those tables in PDF which are present in the font file
-	for _, tblname := range []Tag{tagCvt, tagGlyf, tagHead, tagHhea, tagHmtx, tagLoca, tagMaxp, tagPrep} {
-		if _, ok := fnt.knowTables[tblname]; ok {
-			tbl := tableOffsetLength{}
-			tbl.tag = tblname
-			tablesForPDF = append(tablesForPDF, tbl)
+	var tags []Tag
+	for _, tag := range []Tag{tagCvt, tagGlyf, tagHead, tagHhea, tagHmtx, tagLoca, tagMaxp, tagPrep} {
+		if _, ok := fnt.knowTables[tag]; ok {
+			tags = append(tags, tag)
 		}
 	}
-
-	// tables start at 12 (header) + table toc
-	tableOffset := uint32(12 + 16*len(tablesForPDF))
-	var newTables []tableOffsetLength
-	for _, tbl := range tablesForPDF {
-		var tableData bytes.Buffer
-		err = fnt.writeTable(&tableData, tbl.tag)
-		if err != nil {
-			return err
-		}
-		l := tableData.Len()
-		nt := tableOffsetLength{
-			length: uint32(l),
-			tag:    tbl.tag,
-			offset: tableOffset,
-		}
-
-		switch l & 3 {
-		case 0:
-			// ok, no alignment
-		case 1:
-			binarywrite(&tableData, uint16(0))
-			binarywrite(&tableData, uint8(0))
-			l += 3
-		case 2:
-			binarywrite(&tableData, uint16(0))
-			l += 2
-		case 3:
-			binarywrite(&tableData, uint8(0))
-			l++
-		}
-		nt.tabledata = tableData.Bytes()
-		tableOffset += uint32(len(nt.tabledata))
-		nt.checksum = calcChecksum(nt.tabledata)
-		newTables = append(newTables, nt)
-	}
-
-	binarywrite(&fontfile, fnt.Type)
-	cTablesRead := float64(len(newTables))
-	searchRange := (math.Pow(2, math.Floor(math.Log2(cTablesRead))) * 16)
-	entrySelector := math.Floor(math.Log2(cTablesRead))
-	rangeShift := (cTablesRead * 16.0) - searchRange
-
-	binarywrite(&fontfile, uint16(cTablesRead))
-	binarywrite(&fontfile, uint16(searchRange))
-	binarywrite(&fontfile, uint16(entrySelector))
-	binarywrite(&fontfile, uint16(rangeShift))
-
-	checksumAdjustmentOffset := 0
-	for _, tbl := range newTables {
-		binarywrite(&fontfile, []byte(tbl.tag.String()))
-		binarywrite(&fontfile, tbl.checksum)
-		binarywrite(&fontfile, tbl.offset)
-		binarywrite(&fontfile, tbl.length)
-		if tbl.tag == tagHead {
-			checksumAdjustmentOffset = int(tbl.offset) + 8
-		}
-	}
-
-	for _, tbl := range newTables {
-		binarywrite(&fontfile, tbl.tabledata)
-	}
-
-	b := fontfile.Bytes()
-	checksumFontFile := calcChecksum(b)
-	if checksumAdjustmentOffset > 0 {
-		// only if we write the head table
-		binary.BigEndian.PutUint32(b[checksumAdjustmentOffset:], checksumFontFile-0xB1B0AFBA)
-	}
-	w.Write(b)
-
-	return nil
+	return fnt.writeSfnt(w, fnt.Type, tags)
 }
 
 // getCharTag returns a string of length 6 based on the characters in code point
@@ -0,0 +1,1030 @@
+package truetype
+
+import (
+	"math"
+	"sort"
+)
+
+// This file implements the subsetting of the advanced layout tables (GSUB, GPOS, GDEF):
+// the glyph closure through GSUB, and the pruning and renumbering of the lookups.
+
+// closeOverGlyphs adds to `glyphs` all the glyphs which may be produced
+// by the substitutions reachable from the features of the table,
+// iterating until a fixed point is reached.
+// The context of contextual lookups is not checked, so that the closure
+// may include more glyphs than strictly needed.
+func (t *TableGSUB) closeOverGlyphs(glyphs map[GID]bool) {
+	t.closeOverLookups(t.reachableLookups(), glyphs)
+}
+
+// SubstituteClosure adds to `glyphs` all the glyphs which may be produced
+// by the given lookups (and their nested lookups) when applied to `glyphs`,
+// iterating until a fixed point is reached. Invalid lookup indices are ignored.
+// As for subsetting, the context of contextual lookups is not checked, so that the closure
+// may include more glyphs than strictly needed.
+func (t *TableGSUB) SubstituteClosure(lookupIndices []uint16, glyphs map[GID]bool) {
+	t.closeOverLookups(t.lookupsReachableFrom(lookupIndices), glyphs)
+}
+
+// closeOverLookups implements the closure for the lookups
+// flagged in `reached`.
+func (t *TableGSUB) closeOverLookups(reached []bool, glyphs map[GID]bool) {
+	for {
+		var added []GID
+		// use a sorted snapshot, since `glyphs` is updated during the iteration
+		current := sortedGlyphs(glyphs)
+		for i, lookup := range t.Lookups {
+			if !reached[i] {
+				continue
+			}
+			for _, subtable := range lookup.Subtables {
+				added = subtable.closeOverGlyphs(current, glyphs, added)
+			}
+		}
+
+		changed := false
+		for _, g := range added {
+			if !glyphs[g] {
+				glyphs[g] = true
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// closeOverGlyphs appends to `added` the glyphs produced by the subtable
+// when applied to `current`, whose elements are also in `glyphs`.
+func (st GSUBSubtable) closeOverGlyphs(current []GID, glyphs map[GID]bool, added []GID) []GID {
+	for _, g := range current {
+		index, ok := st.Coverage.Index(g)
+		if !ok {
+			continue
+		}
+		switch data := st.Data.(type) {
+		case GSUBSingle1:
+			added = append(added, GID(uint16(int(g)+int(data))))
+		case GSUBSingle2:
+			if index < len(data) {
+				added = append(added, data[index])
+			}
+		case GSUBMultiple1:
+			if index < len(data) {
+				added = append(added, data[index]...)
+			}
+		case GSUBAlternate1:
+			if index < len(data) {
+				added = append(added, data[index]...)
+			}
+		case GSUBLigature1:
+			if index >= len(data) {
+				continue
+			}
+			for _, lig := range data[index] {
+				if allGlyphsIn(lig.Components, glyphs) {
+					added = append(added, lig.Glyph)
+				}
+			}
+		case GSUBReverseChainedContext1:
+			if index < len(data.Substitutes) {
+				added = append(added, data.Substitutes[index])
+			}
+		}
+	}
+	return added
+}
+
+func allGlyphsIn(components []uint16, glyphs map[GID]bool) bool {
+	for _, c := range components {
+		if !glyphs[GID(c)] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedGlyphs(glyphs map[GID]bool) []GID {
+	out := make([]GID, 0, len(glyphs))
+	for g := range glyphs {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// reachableLookups returns, for each lookup, true if it is used by
+// one of the features of the table, directly or as a nested lookup.
+func (t *TableGSUB) reachableLookups() []bool {
+	return t.lookupsReachableFrom(t.TableLayout.featuresLookups())
+}
+
+// lookupsReachableFrom returns, for each lookup, true if it is one of `roots`
+// or a lookup nested in them.
+func (t *TableGSUB) lookupsReachableFrom(roots []uint16) []bool {
+	nested := func(i int) []SequenceLookup {
+		var out []SequenceLookup
+		for _, subtable := range t.Lookups[i].Subtables {
+			out = append(out, nestedLookups(subtable.Data)...)
+		}
+		return out
+	}
+	return reachableLookups(roots, len(t.Lookups), nested)
+}
+
+// reachableLookups returns, for each lookup, true if it is used by
+// one of the features of the table, directly or as a nested lookup.
+func (t *TableGPOS) reachableLookups() []bool {
+	nested := func(i int) []SequenceLookup {
+		var out []SequenceLookup
+		for _, subtable := range t.Lookups[i].Subtables {
+			out = append(out, nestedLookups(subtable.Data)...)
+		}
+		return out
+	}
+	return reachableLookups(t.TableLayout.featuresLookups(), len(t.Lookups), nested)
+}
+
+// featuresLookups returns the lookups used by the features of the table,
+// possibly with duplicates.
+func (t *TableLayout) featuresLookups() []uint16 {
+	var out []uint16
+	for _, feature := range t.Features {
+		out = append(out, feature.LookupIndices...)
+	}
+	return out
+}
+
+func reachableLookups(roots []uint16, lookupCount int, nested func(int) []SequenceLookup) []bool {
+	reached := make([]bool, lookupCount)
+	var visit func(index uint16)
+	visit = func(index uint16) {
+		if int(index) >= lookupCount || reached[index] {
+			return
+		}
+		reached[index] = true
+		for _, l := range nested(int(index)) {
+			visit(l.LookupIndex)
+		}
+	}
+	for _, index := range roots {
+		visit(index)
+	}
+	return reached
+}
+
+// nestedLookups returns the lookups referenced by a contextual subtable,
+// or nil for the other kinds of subtables.
+func nestedLookups(data interface{}) []SequenceLookup {
+	var out []SequenceLookup
+	addRules := func(rules []SequenceRule) {
+		for _, rule := range rules {
+			out = append(out, rule.Lookups...)
+		}
+	}
+	addChainedRules := func(rules []ChainedSequenceRule) {
+		for _, rule := range rules {
+			out = append(out, rule.Lookups...)
+		}
+	}
+	switch data := data.(type) {
+	case GSUBContext1:
+		for _, rules := range data {
+			addRules(rules)
+		}
+	case GPOSContext1:
+		for _, rules := range data {
+			addRules(rules)
+		}
+	case GSUBContext2:
+		for _, rules := range data.SequenceSets {
+			addRules(rules)
+		}
+	case GPOSContext2:
+		for _, rules := range data.SequenceSets {
+			addRules(rules)
+		}
+	case GSUBContext3:
+		out = data.SequenceLookups
+	case GPOSContext3:
+		out = data.SequenceLookups
+	case GSUBChainedContext1:
+		for _, rules := range data {
+			addChainedRules(rules)
+		}
+	case GPOSChainedContext1:
+		for _, rules := range data {
+			addChainedRules(rules)
+		}
+	case GSUBChainedContext2:
+		for _, rules := range data.SequenceSets {
+			addChainedRules(rules)
+		}
+	case GPOSChainedContext2:
+		for _, rules := range data.SequenceSets {
+			addChainedRules(rules)
+		}
+	case GSUBChainedContext3:
+		out = data.SequenceLookups
+	case GPOSChainedContext3:
+		out = data.SequenceLookups
+	}
+	return out
+}
+
+// layoutSubsetter prunes and renumbers the glyphs used in the layout tables.
+type layoutSubsetter struct {
+	glyphs []GID       // the glyphs kept, sorted by original index
+	gidMap map[GID]GID // original index -> new index
+	lookup map[int]int // original lookup index -> new index, for the table being subsetted
+
+	// when instancing a variable font, the variation deltas
+	// of the device tables and the feature variations matching `coords` are applied
+	coords   []float32
+	varStore VariationStore
+}
+
+func (s *layoutSubsetter) glyph(g GID) (GID, bool) {
+	newGID, ok := s.gidMap[g]
+	return newGID, ok
+}
+
+// intersects returns true if at least one glyph covered by `cov` is kept.
+func (s *layoutSubsetter) intersects(cov Coverage) bool {
+	if cov == nil {
+		return false
+	}
+	if list, ok := cov.(CoverageList); ok && len(list) < len(s.glyphs) {
+		for _, g := range list {
+			if _, ok := s.gidMap[g]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	for _, g := range s.glyphs {
+		if _, ok := cov.Index(g); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *layoutSubsetter) intersectsAll(covs ...[]Coverage) bool {
+	for _, list := range covs {
+		for _, cov := range list {
+			if !s.intersects(cov) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// coverage returns the kept glyphs of `cov`, with their new index,
+// and, for each of them, its index in the original coverage.
+func (s *layoutSubsetter) coverage(cov Coverage) (CoverageList, []int) {
+	var (
+		out     CoverageList
+		indices []int
+	)
+	for _, g := range s.glyphs {
+		if index, ok := cov.Index(g); ok {
+			out = append(out, s.gidMap[g])
+			indices = append(indices, index)
+		}
+	}
+	return out, indices
+}
+
+func (s *layoutSubsetter) coverages(covs []Coverage) []Coverage {
+	out := make([]Coverage, len(covs))
+	for i, cov := range covs {
+		out[i], _ = s.coverage(cov)
+	}
+	return out
+}
+
+// glyphList remaps the glyphs in `list`, returning false
+// if one of them is not kept.
+func (s *layoutSubsetter) glyphList(list []uint16) ([]uint16, bool) {
+	out := make([]uint16, len(list))
+	for i, g := range list {
+		newGID, ok := s.gidMap[GID(g)]
+		if !ok {
+			return nil, false
+		}
+		out[i] = uint16(newGID)
+	}
+	return out, true
+}
+
+// class returns the class definitions for the kept glyphs, or nil
+// if `class` is nil.
+func (s *layoutSubsetter) class(class Class) Class {
+	if class == nil {
+		return nil
+	}
+	out, _ := s.compactClass(class, false)
+	return out
+}
+
+// compactClass returns the class definitions for the kept glyphs.
+// If `renumber` is true, the classes are also renumbered compactly, and
+// the original class is returned for each new class. The class 0 is never renumbered.
+func (s *layoutSubsetter) compactClass(class Class, renumber bool) (classFormat2, []uint32) {
+	type glyphClass struct {
+		gid   GID
+		class uint32
+	}
+	var (
+		glyphs  []glyphClass
+		classes = map[uint32]uint32{0: 0}
+	)
+	for _, g := range s.glyphs {
+		if c, ok := class.ClassID(g); ok && c != 0 {
+			glyphs = append(glyphs, glyphClass{s.gidMap[g], c})
+			classes[c] = c
+		}
+	}
+
+	var oldClasses []uint32
+	if renumber {
+		oldClasses = make([]uint32, 0, len(classes))
+		for c := range classes {
+			oldClasses = append(oldClasses, c)
+		}
+		sort.Slice(oldClasses, func(i, j int) bool { return oldClasses[i] < oldClasses[j] })
+		for newClass, c := range oldClasses {
+			classes[c] = uint32(newClass)
+		}
+	}
+
+	var out classFormat2
+	for _, gc := range glyphs {
+		newClass := classes[gc.class]
+		if L := len(out); L != 0 && out[L-1].end+1 == gid(gc.gid) && out[L-1].targetClassID == newClass {
+			out[L-1].end++
+			continue
+		}
+		out = append(out, classRangeRecord{start: gid(gc.gid), end: gid(gc.gid), targetClassID: newClass})
+	}
+	return out, oldClasses
+}
+
+// device drops the device tables pointing to variation data,
+// which is not kept in the subset.
+func (s *layoutSubsetter) device(dev DeviceTable) DeviceTable {
+	if _, isVariation := dev.(DeviceVariation); isVariation {
+		return nil
+	}
+	return dev
+}
+
+// delta returns the rounded variation delta for `dev`,
+// or 0 if not instancing.
+func (s *layoutSubsetter) delta(dev DeviceTable) int16 {
+	if index, isVariation := dev.(DeviceVariation); isVariation && s.coords != nil {
+		return int16(math.Round(float64(s.varStore.GetDelta(VariationStoreIndex(index), s.coords))))
+	}
+	return 0
+}
+
+// valueFormat adds the values which may be set from variation deltas.
+func (s *layoutSubsetter) valueFormat(format GPOSValueFormat) GPOSValueFormat {
+	if s.coords == nil {
+		return format
+	}
+	if format&XPlaDevice != 0 {
+		format |= XPlacement
+	}
+	if format&YPlaDevice != 0 {
+		format |= YPlacement
+	}
+	if format&XAdvDevice != 0 {
+		format |= XAdvance
+	}
+	if format&YAdvDevice != 0 {
+		format |= YAdvance
+	}
+	return format
+}
+
+func (s *layoutSubsetter) valueRecord(v GPOSValueRecord) GPOSValueRecord {
+	v.XPlacement += s.delta(v.XPlaDevice)
+	v.YPlacement += s.delta(v.YPlaDevice)
+	v.XAdvance += s.delta(v.XAdvDevice)
+	v.YAdvance += s.delta(v.YAdvDevice)
+	v.XPlaDevice = s.device(v.XPlaDevice)
+	v.YPlaDevice = s.device(v.YPlaDevice)
+	v.XAdvDevice = s.device(v.XAdvDevice)
+	v.YAdvDevice = s.device(v.YAdvDevice)
+	return v
+}
+
+func (s *layoutSubsetter) anchor(anchor GPOSAnchor) GPOSAnchor {
+	if a, ok := anchor.(GPOSAnchorFormat3); ok {
+		a.X += s.delta(a.XDevice)
+		a.Y += s.delta(a.YDevice)
+		a.XDevice = s.device(a.XDevice)
+		a.YDevice = s.device(a.YDevice)
+		return a
+	}
+	return anchor
+}
+
+func (s *layoutSubsetter) anchors(anchors []GPOSAnchor) []GPOSAnchor {
+	out := make([]GPOSAnchor, len(anchors))
+	for i, a := range anchors {
+		out[i] = s.anchor(a)
+	}
+	return out
+}
+
+// sequenceLookups remaps the nested lookups, dropping the ones not kept.
+func (s *layoutSubsetter) sequenceLookups(lookups []SequenceLookup) []SequenceLookup {
+	var out []SequenceLookup
+	for _, l := range lookups {
+		if index, ok := s.lookup[int(l.LookupIndex)]; ok {
+			out = append(out, SequenceLookup{InputIndex: l.InputIndex, LookupIndex: uint16(index)})
+		}
+	}
+	return out
+}
+
+// sequenceRules subsets the rules of a contextual subtable.
+// If `byGlyph` is false, the rules are expressed with classes,
+// which are not modified.
+func (s *layoutSubsetter) sequenceRules(rules []SequenceRule, byGlyph bool) []SequenceRule {
+	var out []SequenceRule
+	for _, rule := range rules {
+		input := rule.Input
+		if byGlyph {
+			var ok bool
+			if input, ok = s.glyphList(rule.Input); !ok {
+				continue
+			}
+		}
+		out = append(out, SequenceRule{Input: input, Lookups: s.sequenceLookups(rule.Lookups)})
+	}
+	return out
+}
+
+func (s *layoutSubsetter) chainedSequenceRules(rules []ChainedSequenceRule, byGlyph bool) []ChainedSequenceRule {
+	var out []ChainedSequenceRule
+	for _, rule := range rules {
+		input, backtrack, lookahead := rule.Input, rule.Backtrack, rule.Lookahead
+		if byGlyph {
+			var ok1, ok2, ok3 bool
+			input, ok1 = s.glyphList(rule.Input)
+			backtrack, ok2 = s.glyphList(rule.Backtrack)
+			lookahead, ok3 = s.glyphList(rule.Lookahead)
+			if !(ok1 && ok2 && ok3) {
+				continue
+			}
+		}
+		out = append(out, ChainedSequenceRule{
+			SequenceRule: SequenceRule{Input: input, Lookups: s.sequenceLookups(rule.Lookups)},
+			Backtrack:    backtrack,
+			Lookahead:    lookahead,
+		})
+	}
+	return out
+}
+
+func (s *layoutSubsetter) context1(cov Coverage, data LookupContext1) (Coverage, LookupContext1) {
+	newCov, indices := s.coverage(cov)
+	out := make(LookupContext1, len(indices))
+	for i, index := range indices {
+		if index < len(data) {
+			out[i] = s.sequenceRules(data[index], true)
+		}
+	}
+	return newCov, out
+}
+
+func (s *layoutSubsetter) context2(cov Coverage, data LookupContext2) (Coverage, LookupContext2) {
+	newCov, _ := s.coverage(cov)
+	out := LookupContext2{
+		Class:        s.class(data.Class),
+		SequenceSets: make([][]SequenceRule, len(data.SequenceSets)),
+	}
+	for i, rules := range data.SequenceSets {
+		out.SequenceSets[i] = s.sequenceRules(rules, false)
+	}
+	return newCov, out
+}
+
+func (s *layoutSubsetter) context3(data LookupContext3) (Coverage, LookupContext3) {
+	out := LookupContext3{
+		Coverages:       s.coverages(data.Coverages),
+		SequenceLookups: s.sequenceLookups(data.SequenceLookups),
+	}
+	return out.Coverages[0], out
+}
+
+func (s *layoutSubsetter) chainedContext1(cov Coverage, data LookupChainedContext1) (Coverage, LookupChainedContext1) {
+	newCov, indices := s.coverage(cov)
+	out := make(LookupChainedContext1, len(indices))
+	for i, index := range indices {
+		if index < len(data) {
+			out[i] = s.chainedSequenceRules(data[index], true)
+		}
+	}
+	return newCov, out
+}
+
+func (s *layoutSubsetter) chainedContext2(cov Coverage, data LookupChainedContext2) (Coverage, LookupChainedContext2) {
+	newCov, _ := s.coverage(cov)
+	out := LookupChainedContext2{
+		BacktrackClass: s.class(data.BacktrackClass),
+		InputClass:     s.class(data.InputClass),
+		LookaheadClass: s.class(data.LookaheadClass),
+		SequenceSets:   make([][]ChainedSequenceRule, len(data.SequenceSets)),
+	}
+	for i, rules := range data.SequenceSets {
+		out.SequenceSets[i] = s.chainedSequenceRules(rules, false)
+	}
+	return newCov, out
+}
+
+func (s *layoutSubsetter) chainedContext3(data LookupChainedContext3) (Coverage, LookupChainedContext3) {
+	out := LookupChainedContext3{
+		Backtrack:       s.coverages(data.Backtrack),
+		Input:           s.coverages(data.Input),
+		Lookahead:       s.coverages(data.Lookahead),
+		SequenceLookups: s.sequenceLookups(data.SequenceLookups),
+	}
+	return out.Input[0], out
+}
+
+// keepGSUBSubtable returns true if the subtable may apply to the glyphs of the subset.
+func (s *layoutSubsetter) keepGSUBSubtable(st GSUBSubtable) bool {
+	switch data := st.Data.(type) {
+	case GSUBContext3:
+		return len(data.Coverages) != 0 && s.intersectsAll(data.Coverages)
+	case GSUBChainedContext3:
+		return len(data.Input) != 0 && s.intersectsAll(data.Backtrack, data.Input, data.Lookahead)
+	case GSUBReverseChainedContext1:
+		return s.intersects(st.Coverage) && s.intersectsAll(data.Backtrack, data.Lookahead)
+	default:
+		return s.intersects(st.Coverage)
+	}
+}
+
+func (s *layoutSubsetter) gsubSubtable(st GSUBSubtable) GSUBSubtable {
+	var out GSUBSubtable
+	switch data := st.Data.(type) {
+	case GSUBSingle1, GSUBSingle2:
+		// the delta of format 1 is not preserved by the renumbering,
+		// so that both formats are converted to format 2
+		var (
+			cov  CoverageList
+			subs GSUBSingle2
+		)
+		for _, g := range s.glyphs {
+			index, ok := st.Coverage.Index(g)
+			if !ok {
+				continue
+			}
+			var target GID
+			if delta, isDelta := data.(GSUBSingle1); isDelta {
+				target = GID(uint16(int(g) + int(delta)))
+			} else if list := data.(GSUBSingle2); index < len(list) {
+				target = list[index]
+			} else {
+				continue
+			}
+			if newTarget, ok := s.glyph(target); ok {
+				cov = append(cov, s.gidMap[g])
+				subs = append(subs, newTarget)
+			}
+		}
+		out.Coverage, out.Data = cov, subs
+	case GSUBMultiple1:
+		var (
+			cov  CoverageList
+			seqs GSUBMultiple1
+		)
+		for _, g := range s.glyphs {
+			index, ok := st.Coverage.Index(g)
+			if !ok || index >= len(data) {
+				continue
+			}
+			if seq, ok := s.glyphSlice(data[index]); ok {
+				cov = append(cov, s.gidMap[g])
+				seqs = append(seqs, seq)
+			}
+		}
+		out.Coverage, out.Data = cov, seqs
+	case GSUBAlternate1:
+		cov, indices := s.coverage(st.Coverage)
+		alts := make(GSUBAlternate1, len(indices))
+		for i, index := range indices {
+			if index >= len(data) {
+				continue
+			}
+			for _, g := range data[index] {
+				if newGID, ok := s.glyph(g); ok {
+					alts[i] = append(alts[i], newGID)
+				}
+			}
+		}
+		out.Coverage, out.Data = cov, alts
+	case GSUBLigature1:
+		cov, indices := s.coverage(st.Coverage)
+		ligs := make(GSUBLigature1, len(indices))
+		for i, index := range indices {
+			if index >= len(data) {
+				continue
+			}
+			for _, lig := range data[index] {
+				glyph, ok1 := s.glyph(lig.Glyph)
+				components, ok2 := s.glyphList(lig.Components)
+				if ok1 && ok2 {
+					ligs[i] = append(ligs[i], LigatureGlyph{Glyph: glyph, Components: components})
+				}
+			}
+		}
+		out.Coverage, out.Data = cov, ligs
+	case GSUBContext1:
+		cov, ctx := s.context1(st.Coverage, LookupContext1(data))
+		out.Coverage, out.Data = cov, GSUBContext1(ctx)
+	case GSUBContext2:
+		cov, ctx := s.context2(st.Coverage, LookupContext2(data))
+		out.Coverage, out.Data = cov, GSUBContext2(ctx)
+	case GSUBContext3:
+		cov, ctx := s.context3(LookupContext3(data))
+		out.Coverage, out.Data = cov, GSUBContext3(ctx)
+	case GSUBChainedContext1:
+		cov, ctx := s.chainedContext1(st.Coverage, LookupChainedContext1(data))
+		out.Coverage, out.Data = cov, GSUBChainedContext1(ctx)
+	case GSUBChainedContext2:
+		cov, ctx := s.chainedContext2(st.Coverage, LookupChainedContext2(data))
+		out.Coverage, out.Data = cov, GSUBChainedContext2(ctx)
+	case GSUBChainedContext3:
+		cov, ctx := s.chainedContext3(LookupChainedContext3(data))
+		out.Coverage, out.Data = cov, GSUBChainedContext3(ctx)
+	case GSUBReverseChainedContext1:
+		var (
+			cov CoverageList
+			rev = GSUBReverseChainedContext1{
+				Backtrack: s.coverages(data.Backtrack),
+				Lookahead: s.coverages(data.Lookahead),
+			}
+		)
+		for _, g := range s.glyphs {
+			index, ok := st.Coverage.Index(g)
+			if !ok || index >= len(data.Substitutes) {
+				continue
+			}
+			if newTarget, ok := s.glyph(data.Substitutes[index]); ok {
+				cov = append(cov, s.gidMap[g])
+				rev.Substitutes = append(rev.Substitutes, newTarget)
+			}
+		}
+		out.Coverage, out.Data = cov, rev
+	}
+	return out
+}
+
+// glyphSlice is the same as glyphList, for a slice of GID.
+func (s *layoutSubsetter) glyphSlice(list []GID) ([]GID, bool) {
+	out := make([]GID, len(list))
+	for i, g := range list {
+		newGID, ok := s.gidMap[g]
+		if !ok {
+			return nil, false
+		}
+		out[i] = newGID
+	}
+	return out, true
+}
+
+// keepGPOSSubtable returns true if the subtable may apply to the glyphs of the subset.
+func (s *layoutSubsetter) keepGPOSSubtable(st GPOSSubtable) bool {
+	switch data := st.Data.(type) {
+	case GPOSContext3:
+		return len(data.Coverages) != 0 && s.intersectsAll(data.Coverages)
+	case GPOSChainedContext3:
+		return len(data.Input) != 0 && s.intersectsAll(data.Backtrack, data.Input, data.Lookahead)
+	case GPOSMarkToBase1:
+		return s.intersects(st.Coverage) && s.intersects(data.BaseCoverage)
+	case GPOSMarkToLigature1:
+		return s.intersects(st.Coverage) && s.intersects(data.LigatureCoverage)
+	case GPOSMarkToMark1:
+		return s.intersects(st.Coverage) && s.intersects(data.Mark2Coverage)
+	default:
+		return s.intersects(st.Coverage)
+	}
+}
+
+func (s *layoutSubsetter) gposSubtable(st GPOSSubtable) GPOSSubtable {
+	var out GPOSSubtable
+	switch data := st.Data.(type) {
+	case GPOSSingle1:
+		out.Coverage, _ = s.coverage(st.Coverage)
+		out.Data = GPOSSingle1{Format: s.valueFormat(data.Format), Value: s.valueRecord(data.Value)}
+	case GPOSSingle2:
+		cov, indices := s.coverage(st.Coverage)
+		single := GPOSSingle2{Format: s.valueFormat(data.Format), Values: make([]GPOSValueRecord, len(indices))}
+		for i, index := range indices {
+			if index < len(data.Values) {
+				single.Values[i] = s.valueRecord(data.Values[index])
+			}
+		}
+		out.Coverage, out.Data = cov, single
+	case GPOSPair1:
+		cov, indices := s.coverage(st.Coverage)
+		pair := GPOSPair1{Formats: [2]GPOSValueFormat{s.valueFormat(data.Formats[0]), s.valueFormat(data.Formats[1])}, Values: make([]GPOSPairSet, len(indices))}
+		for i, index := range indices {
+			if index >= len(data.Values) {
+				continue
+			}
+			for _, record := range data.Values[index] {
+				if second, ok := s.glyph(record.SecondGlyph); ok {
+					pair.Values[i] = append(pair.Values[i], GPOSPairValueRecord{
+						SecondGlyph: second,
+						Pos:         [2]GPOSValueRecord{s.valueRecord(record.Pos[0]), s.valueRecord(record.Pos[1])},
+					})
+				}
+			}
+		}
+		out.Coverage, out.Data = cov, pair
+	case GPOSPair2:
+		cov, _ := s.coverage(st.Coverage)
+		// the number of classes must match the size of the matrix,
+		// so that they are renumbered
+		first, firstClasses := s.compactClass(data.First, true)
+		second, secondClasses := s.compactClass(data.Second, true)
+		pair := GPOSPair2{Formats: [2]GPOSValueFormat{s.valueFormat(data.Formats[0]), s.valueFormat(data.Formats[1])}, First: first, Second: second}
+		pair.Values = make([][][2]GPOSValueRecord, len(firstClasses))
+		for i, c1 := range firstClasses {
+			pair.Values[i] = make([][2]GPOSValueRecord, len(secondClasses))
+			for j, c2 := range secondClasses {
+				if int(c1) < len(data.Values) && int(c2) < len(data.Values[c1]) {
+					v := data.Values[c1][c2]
+					pair.Values[i][j] = [2]GPOSValueRecord{s.valueRecord(v[0]), s.valueRecord(v[1])}
+				}
+			}
+		}
+		out.Coverage, out.Data = cov, pair
+	case GPOSCursive1:
+		cov, indices := s.coverage(st.Coverage)
+		cursive := make(GPOSCursive1, len(indices))
+		for i, index := range indices {
+			if index < len(data) {
+				cursive[i] = [2]GPOSAnchor{s.anchor(data[index][0]), s.anchor(data[index][1])}
+			}
+		}
+		out.Coverage, out.Data = cov, cursive
+	case GPOSMarkToBase1:
+		cov, marks := s.marks(st.Coverage, data.Marks)
+		baseCov, bases := s.baseAnchors(data.BaseCoverage, data.Bases)
+		out.Coverage, out.Data = cov, GPOSMarkToBase1{Marks: marks, BaseCoverage: baseCov, Bases: bases}
+	case GPOSMarkToLigature1:
+		cov, marks := s.marks(st.Coverage, data.Marks)
+		ligCov, indices := s.coverage(data.LigatureCoverage)
+		ligs := make([][][]GPOSAnchor, len(indices))
+		for i, index := range indices {
+			if index >= len(data.Ligatures) {
+				continue
+			}
+			ligs[i] = make([][]GPOSAnchor, len(data.Ligatures[index]))
+			for j, component := range data.Ligatures[index] {
+				ligs[i][j] = s.anchors(component)
+			}
+		}
+		out.Coverage, out.Data = cov, GPOSMarkToLigature1{Marks: marks, LigatureCoverage: ligCov, Ligatures: ligs}
+	case GPOSMarkToMark1:
+		cov, marks := s.marks(st.Coverage, data.Marks1)
+		mark2Cov, marks2 := s.baseAnchors(data.Mark2Coverage, data.Marks2)
+		out.Coverage, out.Data = cov, GPOSMarkToMark1{Marks1: marks, Mark2Coverage: mark2Cov, Marks2: marks2}
+	case GPOSContext1:
+		cov, ctx := s.context1(st.Coverage, LookupContext1(data))
+		out.Coverage, out.Data = cov, GPOSContext1(ctx)
+	case GPOSContext2:
+		cov, ctx := s.context2(st.Coverage, LookupContext2(data))
+		out.Coverage, out.Data = cov, GPOSContext2(ctx)
+	case GPOSContext3:
+		cov, ctx := s.context3(LookupContext3(data))
+		out.Coverage, out.Data = cov, GPOSContext3(ctx)
+	case GPOSChainedContext1:
+		cov, ctx := s.chainedContext1(st.Coverage, LookupChainedContext1(data))
+		out.Coverage, out.Data = cov, GPOSChainedContext1(ctx)
+	case GPOSChainedContext2:
+		cov, ctx := s.chainedContext2(st.Coverage, LookupChainedContext2(data))
+		out.Coverage, out.Data = cov, GPOSChainedContext2(ctx)
+	case GPOSChainedContext3:
+		cov, ctx := s.chainedContext3(LookupChainedContext3(data))
+		out.Coverage, out.Data = cov, GPOSChainedContext3(ctx)
+	}
+	return out
+}
+
+func (s *layoutSubsetter) marks(cov Coverage, marks []GPOSMark) (CoverageList, []GPOSMark) {
+	var (
+		newCov CoverageList
+		out    []GPOSMark
+	)
+	for _, g := range s.glyphs {
+		if index, ok := cov.Index(g); ok && index < len(marks) {
+			newCov = append(newCov, s.gidMap[g])
+			out = append(out, GPOSMark{ClassValue: marks[index].ClassValue, Anchor: s.anchor(marks[index].Anchor)})
+		}
+	}
+	return newCov, out
+}
+
+func (s *layoutSubsetter) baseAnchors(cov Coverage, bases [][]GPOSAnchor) (CoverageList, [][]GPOSAnchor) {
+	var (
+		newCov CoverageList
+		out    [][]GPOSAnchor
+	)
+	for _, g := range s.glyphs {
+		if index, ok := cov.Index(g); ok && index < len(bases) {
+			newCov = append(newCov, s.gidMap[g])
+			out = append(out, s.anchors(bases[index]))
+		}
+	}
+	return newCov, out
+}
+
+// applyFeatureVariations returns `t` where the features are replaced
+// by the feature variation matching the instance coordinates, if any.
+func (s *layoutSubsetter) applyFeatureVariations(t TableLayout) TableLayout {
+	if s.coords == nil {
+		return t
+	}
+	index := t.FindVariationIndex(s.coords)
+	if index == -1 {
+		return t
+	}
+	t.Features = append([]FeatureRecord(nil), t.Features...)
+	for _, subs := range t.FeatureVariations[index].FeatureSubstitutions {
+		if int(subs.FeatureIndex) < len(t.Features) {
+			t.Features[subs.FeatureIndex].Feature = subs.AlternateFeature
+		}
+	}
+	return t
+}
+
+// layout remaps the lookups used by the features, dropping
+// the features which become empty. The feature variations are not kept.
+func (s *layoutSubsetter) layout(t TableLayout) TableLayout {
+	var (
+		out        TableLayout
+		featureMap = make(map[uint16]uint16)
+	)
+	for i, feature := range t.Features {
+		var indices []uint16
+		for _, index := range feature.LookupIndices {
+			if newIndex, ok := s.lookup[int(index)]; ok {
+				indices = append(indices, uint16(newIndex))
+			}
+		}
+		if len(indices) == 0 {
+			continue
+		}
+		featureMap[uint16(i)] = uint16(len(out.Features))
+		out.Features = append(out.Features, FeatureRecord{Tag: feature.Tag, Feature: Feature{LookupIndices: indices}})
+	}
+
+	langSys := func(lang LangSys) LangSys {
+		out := LangSys{Tag: lang.Tag, RequiredFeatureIndex: 0xFFFF}
+		if index, ok := featureMap[lang.RequiredFeatureIndex]; ok {
+			out.RequiredFeatureIndex = index
+		}
+		for _, index := range lang.Features {
+			if newIndex, ok := featureMap[index]; ok {
+				out.Features = append(out.Features, newIndex)
+			}
+		}
+		return out
+	}
+	out.Scripts = make([]Script, len(t.Scripts))
+	for i, script := range t.Scripts {
+		out.Scripts[i] = Script{Tag: script.Tag, Languages: make([]LangSys, len(script.Languages))}
+		if script.DefaultLanguage != nil {
+			def := langSys(*script.DefaultLanguage)
+			out.Scripts[i].DefaultLanguage = &def
+		}
+		for j, lang := range script.Languages {
+			out.Scripts[i].Languages[j] = langSys(lang)
+		}
+	}
+	return out
+}
+
+func (s *layoutSubsetter) gsub(t TableGSUB) TableGSUB {
+	if len(t.Lookups) == 0 {
+		return TableGSUB{}
+	}
+	t.TableLayout = s.applyFeatureVariations(t.TableLayout)
+	reached := t.reachableLookups()
+
+	// the lookups are first selected, so that nested lookups
+	// may be remapped when subsetting the subtables
+	s.lookup = make(map[int]int)
+	for i, lookup := range t.Lookups {
+		if !reached[i] {
+			continue
+		}
+		for _, subtable := range lookup.Subtables {
+			if s.keepGSUBSubtable(subtable) {
+				s.lookup[i] = len(s.lookup)
+				break
+			}
+		}
+	}
+
+	out := TableGSUB{Lookups: make([]LookupGSUB, len(s.lookup))}
+	for i, lookup := range t.Lookups {
+		newIndex, ok := s.lookup[i]
+		if !ok {
+			continue
+		}
+		newLookup := LookupGSUB{Type: lookup.Type, LookupOptions: lookup.LookupOptions}
+		for _, subtable := range lookup.Subtables {
+			if s.keepGSUBSubtable(subtable) {
+				newLookup.Subtables = append(newLookup.Subtables, s.gsubSubtable(subtable))
+			}
+		}
+		out.Lookups[newIndex] = newLookup
+	}
+	out.TableLayout = s.layout(t.TableLayout)
+	return out
+}
+
+func (s *layoutSubsetter) gpos(t TableGPOS) TableGPOS {
+	if len(t.Lookups) == 0 {
+		return TableGPOS{}
+	}
+	t.TableLayout = s.applyFeatureVariations(t.TableLayout)
+	reached := t.reachableLookups()
+
+	s.lookup = make(map[int]int)
+	for i, lookup := range t.Lookups {
+		if !reached[i] {
+			continue
+		}
+		for _, subtable := range lookup.Subtables {
+			if s.keepGPOSSubtable(subtable) {
+				s.lookup[i] = len(s.lookup)
+				break
+			}
+		}
+	}
+
+	out := TableGPOS{Lookups: make([]LookupGPOS, len(s.lookup))}
+	for i, lookup := range t.Lookups {
+		newIndex, ok := s.lookup[i]
+		if !ok {
+			continue
+		}
+		newLookup := LookupGPOS{Type: lookup.Type, LookupOptions: lookup.LookupOptions}
+		for _, subtable := range lookup.Subtables {
+			if s.keepGPOSSubtable(subtable) {
+				newLookup.Subtables = append(newLookup.Subtables, s.gposSubtable(subtable))
+			}
+		}
+		out.Lookups[newIndex] = newLookup
+	}
+	out.TableLayout = s.layout(t.TableLayout)
+	return out
+}
+
+// gdef subsets the glyph definitions. The mark glyph sets are kept
+// (possibly empty) so that the lookups referencing them remain valid.
+// The variation store is not kept.
+func (s *layoutSubsetter) gdef(t TableGDEF) TableGDEF {
+	out := TableGDEF{
+		Class:      s.class(t.Class),
+		MarkAttach: s.class(t.MarkAttach),
+	}
+	if t.MarkGlyphSet != nil {
+		out.MarkGlyphSet = s.coverages(t.MarkGlyphSet)
+	}
+	if cov := t.LigatureCaretList.Coverage; cov != nil {
+		newCov, indices := s.coverage(cov)
+		out.LigatureCaretList.Coverage = newCov
+		out.LigatureCaretList.LigCarets = make([][]CaretValue, len(indices))
+		for i, index := range indices {
+			if index >= len(t.LigatureCaretList.LigCarets) {
+				continue
+			}
+			carets := make([]CaretValue, len(t.LigatureCaretList.LigCarets[index]))
+			for j, caret := range t.LigatureCaretList.LigCarets[index] {
+				if c, ok := caret.(CaretValueFormat3); ok {
+					c.Coordinate += s.delta(c.Device)
+					c.Device = s.device(c.Device)
+					caret = c
+				}
+				carets[j] = caret
+			}
+			out.LigatureCaretList.LigCarets[i] = carets
+		}
+	}
+	return out
+}
@@ -0,0 +1,349 @@
+package truetype
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSubset(t *testing.T) {
+	for _, filename := range []string{
+		"DejaVuSerif.ttf",
+		"Castoro-Regular.ttf",
+		"CFFTest.otf",
+	} {
+		font := loadFont(t, filename)
+		numGlyphsSource, glyf := font.NumGlyphs, len(font.Glyf)
+
+		gids := []GID{3, 1}
+		if font.NumGlyphs > 200 {
+			gids = append(gids, 126, 200, GID(font.NumGlyphs-1))
+		}
+		for _, opts := range []SubsetOptions{{}, {RetainGIDs: true}} {
+			res, err := font.SubsetWithOptions(gids, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Font.(*Font).SubsetID) != 6 {
+				t.Fatal("expected subset tag")
+			}
+			var buf bytes.Buffer
+			if err = res.Font.WriteSubset(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if font.cff != nil {
+				continue // the CFF table is written alone
+			}
+
+			pr, err := NewFontParser(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			head, err := pr.loadHeadTable()
+			if err != nil {
+				t.Fatal(err)
+			}
+			numGlyphs, err := pr.NumGlyphs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !opts.RetainGIDs && numGlyphs != len(res.GIDMap) {
+				t.Fatalf("expected %d glyphs, got %d", len(res.GIDMap), numGlyphs)
+			}
+			glyphs, err := pr.GlyfTable(numGlyphs, head.indexToLocFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hmtx, err := pr.HmtxTable(numGlyphs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for gid, newGID := range res.GIDMap {
+				if opts.RetainGIDs && newGID != gid {
+					t.Fatalf("expected glyph index %d to be retained, got %d", gid, newGID)
+				}
+				if hmtx[newGID] != font.Hmtx[gid] {
+					t.Fatalf("glyph %d: expected metrics %v, got %v", gid, font.Hmtx[gid], hmtx[newGID])
+				}
+				exp, got := font.Glyf[gid].data, glyphs[newGID].data
+				if composite, ok := exp.(compositeGlyphData); ok {
+					composite.glyphs = append([]compositeGlyphPart(nil), composite.glyphs...)
+					for i, part := range composite.glyphs {
+						composite.glyphs[i].glyphIndex = res.GIDMap[part.glyphIndex]
+					}
+					exp = composite
+				}
+				if !reflect.DeepEqual(exp, got) {
+					t.Fatalf("glyph %d: expected %v, got %v", gid, exp, got)
+				}
+			}
+		}
+
+		// the source font is not modified
+		if font.NumGlyphs != numGlyphsSource || len(font.Glyf) != glyf || font.SubsetID != "" {
+			t.Fatal("source font modified by subsetting")
+		}
+	}
+}
+
+func TestSubsetToUnicode(t *testing.T) {
+	font := loadFont(t, "DejaVuSerif.ttf")
+	gidA, _ := font.NominalGlyph('A')
+	gidEacute, _ := font.NominalGlyph('é')
+
+	res, err := font.Subset([]GID{gidEacute, gidA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runes := res.ToUnicode[res.GIDMap[gidA]]; !reflect.DeepEqual(runes, []rune{'A'}) {
+		t.Fatalf("unexpected text for 'A': %v", runes)
+	}
+	if runes := res.ToUnicode[res.GIDMap[gidEacute]]; !reflect.DeepEqual(runes, []rune{'é'}) {
+		t.Fatalf("unexpected text for 'é': %v", runes)
+	}
+	// composite glyphs are renumbered
+	for oldGID, newGID := range res.GIDMap {
+		if int(newGID) >= len(res.GIDMap) {
+			t.Fatalf("glyph %d not renumbered (%d)", oldGID, newGID)
+		}
+	}
+}
+
+func TestSubsetConcurrent(t *testing.T) {
+	for _, filename := range []string{"DejaVuSerif.ttf", "CFFTest.otf"} {
+		font := loadFont(t, filename)
+
+		var (
+			wg      sync.WaitGroup
+			outputs [8][]byte
+		)
+		for i := range outputs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := font.Subset([]GID{1, 2, 3})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var buf bytes.Buffer
+				if err := res.Font.WriteSubset(&buf); err != nil {
+					t.Error(err)
+					return
+				}
+				outputs[i] = buf.Bytes()
+			}(i)
+		}
+		wg.Wait()
+
+		for _, output := range outputs[1:] {
+			if !bytes.Equal(output, outputs[0]) {
+				t.Fatal("subsets should be identical")
+			}
+		}
+	}
+}
+
+// findLigature returns the ligature formed by `first` and `second`, in the
+// GSUB table of the font.
+func findLigature(font *Font, first, second GID) (GID, bool) {
+	for _, lookup := range font.layoutTables.GSUB.Lookups {
+		for _, st := range lookup.Subtables {
+			ligatures, ok := st.Data.(GSUBLigature1)
+			if !ok {
+				continue
+			}
+			index, ok := st.Coverage.Index(first)
+			if !ok {
+				continue
+			}
+			for _, lig := range ligatures[index] {
+				if len(lig.Components) == 1 && GID(lig.Components[0]) == second {
+					return lig.Glyph, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestSubsetKeepLayout(t *testing.T) {
+	font := loadFont(t, "DejaVuSerif.ttf")
+	gidF, _ := font.NominalGlyph('f')
+	gidI, _ := font.NominalGlyph('i')
+	gidLig, ok := findLigature(font, gidF, gidI)
+	if !ok {
+		t.Fatal("missing 'fi' ligature")
+	}
+
+	for _, opts := range []SubsetOptions{{}, {KeepLayout: true}} {
+		res, err := font.SubsetWithOptions([]GID{gidF, gidI}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, hasLig := res.GIDMap[gidLig]
+		if hasLig != opts.KeepLayout {
+			t.Fatalf("unexpected closure %v for options %v", res.GIDMap, opts)
+		}
+		if !opts.KeepLayout {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err = res.Font.(*Font).WriteFont(&buf); err != nil {
+			t.Fatal(err)
+		}
+		sub, err := Parse(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.NumGlyphs != len(res.GIDMap) {
+			t.Fatalf("expected %d glyphs, got %d", len(res.GIDMap), sub.NumGlyphs)
+		}
+		if gid, _ := sub.NominalGlyph('f'); gid != res.GIDMap[gidF] {
+			t.Fatalf("unexpected glyph for 'f': %d", gid)
+		}
+		if lig, _ := findLigature(sub, res.GIDMap[gidF], res.GIDMap[gidI]); lig != res.GIDMap[gidLig] {
+			t.Fatalf("expected ligature %d, got %d", res.GIDMap[gidLig], lig)
+		}
+		if len(sub.layoutTables.GPOS.Lookups) == 0 || sub.layoutTables.GDEF.Class == nil {
+			t.Fatal("expected GPOS and GDEF tables")
+		}
+		for gid, newGID := range res.GIDMap {
+			if sub.Hmtx[newGID] != font.Hmtx[gid] {
+				t.Fatalf("glyph %d: expected metrics %v, got %v", gid, font.Hmtx[gid], sub.Hmtx[newGID])
+			}
+			if name, exp := sub.GlyphName(newGID), font.GlyphName(gid); name != exp {
+				t.Fatalf("glyph %d: expected name %s, got %s", gid, exp, name)
+			}
+		}
+	}
+}
+
+func TestWriteFont(t *testing.T) {
+	for _, filename := range []string{
+		"DejaVuSerif.ttf",
+		"Roboto-BoldItalic.ttf",
+		"CFFTest.otf",
+		"Raleway-v4020-Regular.otf", // with local subroutines
+	} {
+		font := loadFont(t, filename)
+		var runes []rune
+		iter := font.cmap.Iter()
+		for iter.Next() && len(runes) < 50 {
+			r, _ := iter.Char()
+			runes = append(runes, r)
+		}
+		var gids []GID
+		for _, r := range runes {
+			gid, _ := font.NominalGlyph(r)
+			gids = append(gids, gid)
+		}
+
+		res, err := font.SubsetWithOptions(gids, SubsetOptions{KeepLayout: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = res.Font.(*Font).WriteFont(&buf); err != nil {
+			t.Fatal(filename, err)
+		}
+		sub, err := Parse(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(filename, err)
+		}
+		for i, r := range runes {
+			if gid, _ := sub.NominalGlyph(r); gid != res.GIDMap[gids[i]] {
+				t.Fatalf("%s: unexpected glyph for %q: %d", filename, r, gid)
+			}
+			exp := font.GlyphData(gids[i], 0, 0)
+			got := sub.GlyphData(res.GIDMap[gids[i]], 0, 0)
+			if !reflect.DeepEqual(exp, got) {
+				t.Fatalf("%s: glyph %d: expected %v, got %v", filename, gids[i], exp, got)
+			}
+		}
+		if sub.OS2 == nil || sub.OS2.Version != font.OS2.Version {
+			t.Fatalf("%s: unexpected OS/2 table", filename)
+		}
+		if sub.PostscriptName() != font.PostscriptName() {
+			t.Fatalf("%s: unexpected name %s", filename, sub.PostscriptName())
+		}
+	}
+}
+
+func TestDescriptorPDF(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		flags    int
+		stemV    int
+	}{
+		{"DejaVuSerif.ttf", pdfSerif | pdfNonsymbolic, 88},
+		{"Roboto-BoldItalic.ttf", pdfNonsymbolic | pdfItalic, 166},
+		{"CFFTest.otf", pdfNonsymbolic | pdfItalic, 200},                  // StdVW
+		{"Raleway-v4020-Regular.otf", pdfNonsymbolic, 68},                 // StdVW
+		{"AccanthisADFStdNo2-Regular.otf", pdfSerif | pdfNonsymbolic, 75}, // StdVW
+		{"ToyCMAP12.otf", pdfSerif | pdfNonsymbolic, 57},                  // CFF with 1500 units per em
+	} {
+		font := loadFont(t, test.filename)
+		res, err := font.Subset([]GID{1, 2, 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub := res.Font.(*Font)
+
+		flags := sub.FlagsPDF()
+		if flags != test.flags {
+			t.Fatalf("%s: expected flags %b, got %b", test.filename, test.flags, flags)
+		}
+		// PDF/A requires exactly one of Symbolic and Nonsymbolic
+		if (flags&pdfSymbolic != 0) == (flags&pdfNonsymbolic != 0) {
+			t.Fatalf("%s: invalid flags %b", test.filename, flags)
+		}
+		if stemV := sub.StemVPDF(); stemV != test.stemV {
+			t.Fatalf("%s: expected StemV %d, got %d", test.filename, test.stemV, stemV)
+		}
+
+		scale := func(v int16) int { return int(math.Round(float64(v) * 1000 / float64(font.Upem()))) }
+		bbox := fmt.Sprintf("[%d %d %d %d]", scale(font.Head.XMin), scale(font.Head.YMin), scale(font.Head.XMax), scale(font.Head.YMax))
+		if got := sub.BoundingBoxPDF(); got != bbox {
+			t.Fatalf("%s: expected bounding box %s, got %s", test.filename, bbox, got)
+		}
+
+		widths := parseWidthsPDF(t, sub.WidthsPDF())
+		for gid, newGID := range res.GIDMap {
+			if exp := strconv.Itoa(scale(font.Hmtx[gid].Advance)); widths[int(newGID)] != exp {
+				t.Fatalf("%s: glyph %d: expected width %s, got %s", test.filename, gid, exp, widths[int(newGID)])
+			}
+		}
+
+		// the code space covers all the glyphs of the subset
+		if cmap := sub.CMapPDF(); !strings.Contains(cmap, "<0000><FFFF>") {
+			t.Fatalf("%s: expected 2-byte code space in\n%s", test.filename, cmap)
+		}
+	}
+}
+
+// parseWidthsPDF returns the widths of a /W array, by glyph index (or CID)
+func parseWidthsPDF(t *testing.T, w string) map[int]string {
+	t.Helper()
+	out := make(map[int]string)
+	for _, entry := range strings.Split(strings.Trim(w, "[]"), "]") {
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, "[")
+		start, err := strconv.Atoi(fields[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, width := range strings.Fields(fields[1]) {
+			out[start+i] = width
+		}
+	}
+	return out
+}
@@ -29,6 +29,8 @@ var (
 	TagSilf = MustNewTag("Silf")
 	// tagPrep
 	tagPrep = MustNewTag("prep")
+	// tagFpgm represents the 'fpgm' table, the Font Program
+	tagFpgm = MustNewTag("fpgm")
 
 	tagCmap = MustNewTag("cmap")
 	tagKern = MustNewTag("kern")
@@ -47,6 +49,7 @@ var (
 	tagBloc = MustNewTag("bloc")
 	tagBdat = MustNewTag("bdat")
 	tagCOLR = MustNewTag("COLR")
+	tagCPAL = MustNewTag("CPAL")
 	tagFvar = MustNewTag("fvar")
 	tagAvar = MustNewTag("avar")
 	tagGvar = MustNewTag("gvar")
@@ -75,10 +78,10 @@ var (
 	// SignatureWOFF is the magic number at the start of a WOFF file.
 	SignatureWOFF = MustNewTag("wOFF")
 
-	ttcTag = MustNewTag("ttcf")
+	// SignatureWOFF2 is the magic number at the start of a WOFF2 file.
+	SignatureWOFF2 = MustNewTag("wOF2")
 
-	// // SignatureWOFF2 is the magic number at the start of a WOFF2 file.
-	// SignatureWOFF2 = MustNewTag("wOF2")
+	ttcTag = MustNewTag("ttcf")
 )
 
 // dfontResourceDataOffset is the assumed value of a dfont file's resource data
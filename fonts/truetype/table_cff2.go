@@ -0,0 +1,33 @@
+package truetype
+
+import (
+	type1c "github.com/boxesandglue/textlayout/fonts/type1C"
+)
+
+// parseTableCFF2 parses the 'CFF2' table, whose variation store
+// is parsed here since it is shared with the other OpenType tables.
+func parseTableCFF2(data []byte, numGlyphs int, axisCount int) (*type1c.CFF2, error) {
+	return type1c.ParseCFF2(data, numGlyphs, func(vstore []byte) (type1c.VariationStore, error) {
+		store, err := parseVariationStore(vstore, 0, axisCount)
+		if err != nil {
+			return nil, err
+		}
+		return cff2VariationStore{store}, nil
+	})
+}
+
+// cff2VariationStore implements type1c.VariationStore
+type cff2VariationStore struct {
+	store VariationStore
+}
+
+func (vs cff2VariationStore) RegionsCount(vsIndex uint16) (int, bool) {
+	if int(vsIndex) >= len(vs.store.Datas) {
+		return 0, false
+	}
+	return len(vs.store.Datas[vsIndex].RegionIndexes), true
+}
+
+func (vs cff2VariationStore) RegionScalars(vsIndex uint16, coords []float32, dst []float32) []float32 {
+	return vs.store.regionScalars(vsIndex, coords, dst)
+}
@@ -0,0 +1,86 @@
+package truetype
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+func TestCFF2Extents(t *testing.T) {
+	font := loadFont(t, "TestCFF2VF.otf")
+
+	type extents struct {
+		glyph GID
+		ext   fonts.GlyphExtents
+	}
+	for _, test := range []struct {
+		weight  float32 // design coordinates, 0 for no variations
+		extents []extents
+	}{
+		{0, []extents{
+			{1, fonts.GlyphExtents{XBearing: 31, YBearing: 656, Width: 538, Height: -656}},
+			{3, fonts.GlyphExtents{XBearing: 85, YBearing: 751, Width: 430, Height: -864}},
+		}},
+		{400, []extents{ // default instance
+			{1, fonts.GlyphExtents{XBearing: 31, YBearing: 656, Width: 538, Height: -656}},
+			{3, fonts.GlyphExtents{XBearing: 85, YBearing: 751, Width: 430, Height: -864}},
+		}},
+		{200, []extents{
+			{1, fonts.GlyphExtents{XBearing: 50, YBearing: 660, Width: 500, Height: -660}},
+			{3, fonts.GlyphExtents{XBearing: 102, YBearing: 752, Width: 400, Height: -864}},
+		}},
+		{900, []extents{
+			{1, fonts.GlyphExtents{XBearing: 0, YBearing: 650, Width: 600, Height: -650}},
+			{3, fonts.GlyphExtents{XBearing: 56, YBearing: 750, Width: 482, Height: -866}},
+		}},
+	} {
+		var coords []float32
+		if test.weight != 0 {
+			coords = font.NormalizeVariations([]float32{test.weight})
+		}
+		font.SetVarCoordinates(coords)
+
+		for _, exp := range test.extents {
+			got, ok := font.GlyphExtents(exp.glyph, 0, 0)
+			if !ok {
+				t.Fatalf("missing extents for glyph %d", exp.glyph)
+			}
+			if got != exp.ext {
+				t.Errorf("weight %f, glyph %d: expected %v, got %v", test.weight, exp.glyph, exp.ext, got)
+			}
+			// CFF2 fonts without HVAR keep their default advances
+			if adv := font.HorizontalAdvance(exp.glyph); adv != 600 {
+				t.Errorf("unexpected advance %f", adv)
+			}
+		}
+	}
+}
+
+func TestCFF2Outlines(t *testing.T) {
+	font := loadFont(t, "TestCFF2VF.otf")
+
+	for _, weight := range []float32{200, 400, 650, 900} {
+		font.SetVarCoordinates(font.NormalizeVariations([]float32{weight}))
+		for gid := GID(0); gid < GID(font.NumGlyphs); gid++ {
+			data, ok := font.GlyphData(gid, 0, 0).(fonts.GlyphOutline)
+			if !ok {
+				t.Fatalf("missing outline for glyph %d", gid)
+			}
+			if len(data.Segments) == 0 || data.Segments[0].Op != fonts.SegmentOpMoveTo {
+				t.Fatalf("invalid outline for glyph %d: %v", gid, data.Segments)
+			}
+
+			// every contour must be closed
+			start := data.Segments[0].Args[0]
+			for i, seg := range data.Segments[1:] {
+				if seg.Op != fonts.SegmentOpMoveTo {
+					continue
+				}
+				if last := data.Segments[i].ArgsSlice(); last[len(last)-1] != start {
+					t.Fatalf("unclosed contour in glyph %d", gid)
+				}
+				start = seg.Args[0]
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package truetype
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/harfbuzz"
+	"github.com/boxesandglue/textlayout/fonts"
+	type1c "github.com/boxesandglue/textlayout/fonts/type1C"
+)
+
+func TestCIDKeyedCFF(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		subset   []GID
+	}{
+		{"harfbuzz_reference/text-rendering-tests/fonts/FDArrayTest257.otf", []GID{30, 66, 97, 98, 235}},
+		{"harfbuzz_reference/text-rendering-tests/fonts/FDArrayTest65535.otf", []GID{1, 30, 66, 65534}},
+		{"harfbuzz_reference/in-house/fonts/6991b13ce889466be6de3f66e891de2bc0f117ee.ttf", []GID{2, 5}},
+	} {
+		file, err := testdata.Files.ReadFile(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		font, err := Parse(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if font.cff == nil || !font.cff.IsCIDFont() {
+			t.Fatalf("expected CID-keyed CFF font in %s", test.filename)
+		}
+
+		for gid := GID(0); gid < GID(font.NumGlyphs); gid++ {
+			if _, _, err := font.cff.LoadGlyph(gid); err != nil {
+				t.Fatalf("glyph %d: %s", gid, err)
+			}
+		}
+
+		expected := make(map[GID]interface{})
+		for _, gid := range append(test.subset, 0) {
+			expected[gid] = font.GlyphData(gid, 0, 0)
+		}
+
+		for _, retainGIDs := range []bool{true, false} {
+			cff, err := font.cff.Subset(test.subset, retainGIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err = cff.WriteSubset(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			subset, err := type1c.Parse(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !subset.IsCIDFont() {
+				t.Fatal("expected CID-keyed subset")
+			}
+			exp := len(test.subset) + 1
+			if retainGIDs {
+				exp = int(test.subset[len(test.subset)-1]) + 1
+			}
+			if subset.NumGlyphs() != exp {
+				t.Fatalf("expected %d glyphs, got %d", exp, subset.NumGlyphs())
+			}
+			for newGID, gid := range append([]GID{0}, test.subset...) {
+				if retainGIDs {
+					newGID = int(gid)
+				}
+				segments, _, err := subset.LoadGlyph(GID(newGID))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := (fonts.GlyphOutline{Segments: segments}); !reflect.DeepEqual(expected[gid], got) {
+					t.Fatalf("glyph %d: expected %v, got %v", gid, expected[gid], got)
+				}
+			}
+		}
+
+		// the source font is not modified
+		for gid, exp := range expected {
+			if got := font.GlyphData(gid, 0, 0); !reflect.DeepEqual(exp, got) {
+				t.Fatalf("glyph %d modified by subsetting", gid)
+			}
+		}
+	}
+}
+
+func TestCIDKeyedSubsetWidths(t *testing.T) {
+	file, err := testdata.Files.ReadFile("harfbuzz_reference/text-rendering-tests/fonts/FDArrayTest257.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := Parse(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gids := []GID{30, 66, 97, 98, 235}
+	res, err := font.Subset(gids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := res.Font.(*Font)
+	var buf bytes.Buffer
+	if err = sub.WriteFont(&buf); err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the /W entries are keyed by CID
+	widths := parseWidthsPDF(t, sub.WidthsPDF())
+	for _, gid := range append(gids, 0) {
+		cid, ok := embedded.cff.CID(res.GIDMap[gid])
+		if !ok {
+			t.Fatalf("missing CID for glyph %d", gid)
+		}
+		if exp := strconv.Itoa(font.toPDF(int(font.Hmtx[gid].Advance))); widths[int(cid)] != exp {
+			t.Fatalf("glyph %d (CID %d): expected width %s, got %s", gid, cid, exp, widths[int(cid)])
+		}
+	}
+}
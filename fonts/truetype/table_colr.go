@@ -0,0 +1,547 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// tableCOLR stores the color glyphs definitions.
+// Since paint graphs may be large, they are only
+// resolved when requested, from the raw table data.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/colr
+type tableCOLR struct {
+	data []byte // the whole table
+
+	// version 0
+	baseGlyphs []colrBaseGlyph // sorted by glyph
+	layers     []colrLayer
+
+	// version 1
+	baseGlyphPaints []colrBaseGlyphPaint // sorted by glyph
+	layerPaints     []uint32             // offsets from the start of the table
+	clips           []colrClip           // sorted by glyph
+	varIndexMap     deltaSetMapping      // optional
+	store           VariationStore       // optional
+}
+
+type colrBaseGlyph struct {
+	glyph                 GID
+	firstLayer, numLayers uint16 // valid index in layers
+}
+
+type colrLayer struct {
+	glyph        GID
+	paletteIndex uint16
+}
+
+type colrBaseGlyphPaint struct {
+	glyph GID
+	paint uint32 // offset from the start of the table
+}
+
+type colrClip struct {
+	start, end GID    // inclusive range
+	box        uint32 // offset from the start of the table
+}
+
+func parseTableCOLR(data []byte, axisCount int) (out tableCOLR, err error) {
+	if len(data) < 14 {
+		return out, errors.New("invalid 'COLR' table (EOF)")
+	}
+	out.data = data
+	version := binary.BigEndian.Uint16(data)
+	numBaseGlyphs := int(binary.BigEndian.Uint16(data[2:]))
+	baseGlyphsOffset := int(binary.BigEndian.Uint32(data[4:]))
+	layersOffset := int(binary.BigEndian.Uint32(data[8:]))
+	numLayers := int(binary.BigEndian.Uint16(data[12:]))
+
+	if numBaseGlyphs != 0 {
+		if len(data) < baseGlyphsOffset+6*numBaseGlyphs {
+			return out, errors.New("invalid 'COLR' table base glyph records (EOF)")
+		}
+		out.baseGlyphs = make([]colrBaseGlyph, numBaseGlyphs)
+		for i := range out.baseGlyphs {
+			record := data[baseGlyphsOffset+6*i:]
+			out.baseGlyphs[i] = colrBaseGlyph{
+				glyph:      GID(binary.BigEndian.Uint16(record)),
+				firstLayer: binary.BigEndian.Uint16(record[2:]),
+				numLayers:  binary.BigEndian.Uint16(record[4:]),
+			}
+			if int(out.baseGlyphs[i].firstLayer)+int(out.baseGlyphs[i].numLayers) > numLayers {
+				return out, errors.New("invalid 'COLR' table base glyph record")
+			}
+		}
+	}
+	if numLayers != 0 {
+		if len(data) < layersOffset+4*numLayers {
+			return out, errors.New("invalid 'COLR' table layer records (EOF)")
+		}
+		out.layers = make([]colrLayer, numLayers)
+		for i := range out.layers {
+			record := data[layersOffset+4*i:]
+			out.layers[i] = colrLayer{
+				glyph:        GID(binary.BigEndian.Uint16(record)),
+				paletteIndex: binary.BigEndian.Uint16(record[2:]),
+			}
+		}
+	}
+
+	if version == 0 {
+		return out, nil
+	}
+
+	if len(data) < 34 {
+		return out, errors.New("invalid 'COLR' table (EOF)")
+	}
+	baseGlyphListOffset := binary.BigEndian.Uint32(data[14:])
+	layerListOffset := binary.BigEndian.Uint32(data[18:])
+	clipListOffset := binary.BigEndian.Uint32(data[22:])
+	varIndexMapOffset := binary.BigEndian.Uint32(data[26:])
+	storeOffset := binary.BigEndian.Uint32(data[30:])
+
+	if baseGlyphListOffset != 0 {
+		out.baseGlyphPaints, err = parseCOLRBaseGlyphList(data, baseGlyphListOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if layerListOffset != 0 {
+		out.layerPaints, err = parseCOLRLayerList(data, layerListOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if clipListOffset != 0 {
+		out.clips, err = parseCOLRClipList(data, clipListOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if varIndexMapOffset != 0 {
+		out.varIndexMap, err = parseDeltaSetMapping(data, varIndexMapOffset)
+		if err != nil {
+			return out, err
+		}
+	}
+	if storeOffset != 0 {
+		out.store, err = parseVariationStore(data, storeOffset, axisCount)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+func parseCOLRBaseGlyphList(data []byte, offset uint32) ([]colrBaseGlyphPaint, error) {
+	if len(data) < int(offset)+4 {
+		return nil, errors.New("invalid 'COLR' table base glyph list (EOF)")
+	}
+	list := data[offset:]
+	count := int(binary.BigEndian.Uint32(list))
+	if len(list) < 4+6*count {
+		return nil, errors.New("invalid 'COLR' table base glyph list (EOF)")
+	}
+	out := make([]colrBaseGlyphPaint, count)
+	for i := range out {
+		record := list[4+6*i:]
+		out[i].glyph = GID(binary.BigEndian.Uint16(record))
+		out[i].paint = offset + binary.BigEndian.Uint32(record[2:])
+	}
+	return out, nil
+}
+
+func parseCOLRLayerList(data []byte, offset uint32) ([]uint32, error) {
+	if len(data) < int(offset)+4 {
+		return nil, errors.New("invalid 'COLR' table layer list (EOF)")
+	}
+	list := data[offset:]
+	count := int(binary.BigEndian.Uint32(list))
+	if len(list) < 4+4*count {
+		return nil, errors.New("invalid 'COLR' table layer list (EOF)")
+	}
+	out := parseUint32s(list[4:], count)
+	for i := range out {
+		out[i] += offset
+	}
+	return out, nil
+}
+
+func parseCOLRClipList(data []byte, offset uint32) ([]colrClip, error) {
+	if len(data) < int(offset)+5 {
+		return nil, errors.New("invalid 'COLR' table clip list (EOF)")
+	}
+	list := data[offset:]
+	// format is ignored
+	count := int(binary.BigEndian.Uint32(list[1:]))
+	if len(list) < 5+7*count {
+		return nil, errors.New("invalid 'COLR' table clip list (EOF)")
+	}
+	out := make([]colrClip, count)
+	for i := range out {
+		record := list[5+7*i:]
+		out[i].start = GID(binary.BigEndian.Uint16(record))
+		out[i].end = GID(binary.BigEndian.Uint16(record[2:]))
+		out[i].box = offset + uint32(parseUint24(record[4:]))
+		if out[i].end < out[i].start {
+			return nil, errors.New("invalid 'COLR' table clip record")
+		}
+	}
+	return out, nil
+}
+
+var errNoColorGlyph = errors.New("glyph not found in 'COLR' table")
+
+// glyphData returns the paint graph of `glyph`, with variations defined by
+// the normalized `coords` applied (which may be empty).
+// Version 1 definitions have priority over the version 0 ones.
+func (colr *tableCOLR) glyphData(glyph GID, coords []float32) (fonts.GlyphColor, error) {
+	records := colr.baseGlyphPaints
+	if i := sort.Search(len(records), func(i int) bool { return records[i].glyph >= glyph }); i < len(records) && records[i].glyph == glyph {
+		r := paintResolver{colr: colr, coords: coords}
+		paint, err := r.resolve(records[i].paint)
+		if err != nil {
+			return fonts.GlyphColor{}, err
+		}
+		out := fonts.GlyphColor{Paint: paint}
+
+		clips := colr.clips
+		if i := sort.Search(len(clips), func(i int) bool { return clips[i].end >= glyph }); i < len(clips) && clips[i].start <= glyph {
+			box, err := r.clipBox(clips[i].box)
+			if err != nil {
+				return fonts.GlyphColor{}, err
+			}
+			out.ClipBox = &box
+		}
+		return out, nil
+	}
+
+	bases := colr.baseGlyphs
+	if i := sort.Search(len(bases), func(i int) bool { return bases[i].glyph >= glyph }); i < len(bases) && bases[i].glyph == glyph {
+		base := bases[i]
+		layers := colr.layers[base.firstLayer : base.firstLayer+base.numLayers] // checked during parsing
+		out := make(fonts.PaintColrLayers, len(layers))
+		for j, layer := range layers {
+			out[j] = fonts.PaintGlyph{
+				Glyph: layer.glyph,
+				Paint: fonts.PaintSolid{PaletteIndex: layer.paletteIndex, Alpha: 1},
+			}
+		}
+		return fonts.GlyphColor{Paint: out}, nil
+	}
+
+	return fonts.GlyphColor{}, errNoColorGlyph
+}
+
+const (
+	// maximum depth of a paint graph
+	colrMaxNesting = 64
+	// maximum number of paints in a glyph graph, to
+	// protect against (malicious) exponential graphs
+	colrMaxPaints = 1 << 16
+
+	noVariationIndex = 0xFFFFFFFF
+)
+
+// paintSizes is the minimum size of each paint format, including the format byte
+var paintSizes = [...]int{
+	1: 6, 2: 5, 3: 9, 4: 16, 5: 20, 6: 16, 7: 20, 8: 12, 9: 16, 10: 6,
+	11: 3, 12: 7, 13: 7, 14: 8, 15: 12, 16: 8, 17: 12, 18: 12, 19: 16, 20: 6,
+	21: 10, 22: 10, 23: 14, 24: 6, 25: 10, 26: 10, 27: 14, 28: 8, 29: 12, 30: 12,
+	31: 16, 32: 8,
+}
+
+// paintResolver builds the paint graph for one glyph,
+// applying variations
+type paintResolver struct {
+	colr   *tableCOLR
+	coords []float32
+	depth  int // current nesting level
+	count  int // total number of paints resolved
+}
+
+// delta returns the variation delta for the field at `varIndexBase + i`
+func (r *paintResolver) delta(varIndexBase, i uint32) float32 {
+	if len(r.coords) == 0 || varIndexBase == noVariationIndex {
+		return 0
+	}
+	index := varIndexBase + i
+	var storeIndex VariationStoreIndex
+	if m := r.colr.varIndexMap; len(m) != 0 {
+		// the last entry is used for indices out of range
+		if int(index) >= len(m) {
+			index = uint32(len(m) - 1)
+		}
+		storeIndex = m[index]
+	} else {
+		storeIndex = VariationStoreIndex{DeltaSetOuter: uint16(index >> 16), DeltaSetInner: uint16(index)}
+	}
+	return r.colr.store.GetDelta(storeIndex, r.coords)
+}
+
+func (r *paintResolver) clipBox(offset uint32) (fonts.ClipBox, error) {
+	data := r.colr.data
+	if len(data) < int(offset)+9 {
+		return fonts.ClipBox{}, errors.New("invalid 'COLR' table clip box (EOF)")
+	}
+	data = data[offset:]
+	varIndexBase := uint32(noVariationIndex)
+	if format := data[0]; format == 2 {
+		if len(data) < 13 {
+			return fonts.ClipBox{}, errors.New("invalid 'COLR' table clip box (EOF)")
+		}
+		varIndexBase = binary.BigEndian.Uint32(data[9:])
+	}
+	return fonts.ClipBox{
+		XMin: float32(int16(binary.BigEndian.Uint16(data[1:]))) + r.delta(varIndexBase, 0),
+		YMin: float32(int16(binary.BigEndian.Uint16(data[3:]))) + r.delta(varIndexBase, 1),
+		XMax: float32(int16(binary.BigEndian.Uint16(data[5:]))) + r.delta(varIndexBase, 2),
+		YMax: float32(int16(binary.BigEndian.Uint16(data[7:]))) + r.delta(varIndexBase, 3),
+	}, nil
+}
+
+// resolve parses the paint at `offset` (from the start of the table),
+// recursing into its children
+func (r *paintResolver) resolve(offset uint32) (fonts.Paint, error) {
+	if r.depth >= colrMaxNesting || r.count >= colrMaxPaints {
+		return nil, errors.New("invalid 'COLR' table: paint graph is too large")
+	}
+	r.depth++
+	r.count++
+	defer func() { r.depth-- }()
+
+	data := r.colr.data
+	if len(data) < int(offset)+1 {
+		return nil, errors.New("invalid 'COLR' table paint (EOF)")
+	}
+	data = data[offset:]
+	format := int(data[0])
+	if format == 0 || format >= len(paintSizes) {
+		return nil, fmt.Errorf("invalid 'COLR' table paint format %d", format)
+	}
+	size := paintSizes[format]
+	if len(data) < size {
+		return nil, fmt.Errorf("invalid 'COLR' table paint format %d (EOF)", format)
+	}
+
+	// variable paints (odd formats, except PaintColrGlyph and PaintVarTransform)
+	// store their variation index after the static fields
+	varIndexBase := uint32(noVariationIndex)
+	if format%2 == 1 && 3 <= format && format <= 31 && format != 11 && format != 13 {
+		varIndexBase = binary.BigEndian.Uint32(data[size-4:])
+	}
+	fword := func(pos int, field uint32) float32 {
+		return float32(int16(binary.BigEndian.Uint16(data[pos:]))) + r.delta(varIndexBase, field)
+	}
+	f2dot14 := func(pos int, field uint32) float32 {
+		return fixed214ToFloat(binary.BigEndian.Uint16(data[pos:])) + r.delta(varIndexBase, field)/(1<<14)
+	}
+	// sub tables offsets are relative to the paint
+	child := func(pos int) uint32 { return offset + uint32(parseUint24(data[pos:])) }
+
+	switch format {
+	case 1: // PaintColrLayers
+		numLayers := uint32(data[1])
+		firstLayer := binary.BigEndian.Uint32(data[2:])
+		if uint64(firstLayer)+uint64(numLayers) > uint64(len(r.colr.layerPaints)) {
+			return nil, errors.New("invalid 'COLR' table layers index")
+		}
+		out := make(fonts.PaintColrLayers, numLayers)
+		for i, layerOffset := range r.colr.layerPaints[firstLayer : firstLayer+numLayers] {
+			var err error
+			out[i], err = r.resolve(layerOffset)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case 2, 3: // PaintSolid, PaintVarSolid
+		return fonts.PaintSolid{
+			PaletteIndex: binary.BigEndian.Uint16(data[1:]),
+			Alpha:        f2dot14(3, 0),
+		}, nil
+	case 4, 5: // PaintLinearGradient, PaintVarLinearGradient
+		colorLine, err := r.colorLine(child(1), format == 5)
+		if err != nil {
+			return nil, err
+		}
+		return fonts.PaintLinearGradient{
+			ColorLine: colorLine,
+			X0:        fword(4, 0), Y0: fword(6, 1),
+			X1: fword(8, 2), Y1: fword(10, 3),
+			X2: fword(12, 4), Y2: fword(14, 5),
+		}, nil
+	case 6, 7: // PaintRadialGradient, PaintVarRadialGradient
+		colorLine, err := r.colorLine(child(1), format == 7)
+		if err != nil {
+			return nil, err
+		}
+		// radii are unsigned
+		ufword := func(pos int, field uint32) float32 {
+			return float32(binary.BigEndian.Uint16(data[pos:])) + r.delta(varIndexBase, field)
+		}
+		return fonts.PaintRadialGradient{
+			ColorLine: colorLine,
+			X0:        fword(4, 0), Y0: fword(6, 1), R0: ufword(8, 2),
+			X1: fword(10, 3), Y1: fword(12, 4), R1: ufword(14, 5),
+		}, nil
+	case 8, 9: // PaintSweepGradient, PaintVarSweepGradient
+		colorLine, err := r.colorLine(child(1), format == 9)
+		if err != nil {
+			return nil, err
+		}
+		// angles are biased by 1 (half a turn)
+		return fonts.PaintSweepGradient{
+			ColorLine: colorLine,
+			CenterX:   fword(4, 0), CenterY: fword(6, 1),
+			StartAngle: (f2dot14(8, 2) + 1) * 180,
+			EndAngle:   (f2dot14(10, 3) + 1) * 180,
+		}, nil
+	case 10: // PaintGlyph
+		paint, err := r.resolve(child(1))
+		if err != nil {
+			return nil, err
+		}
+		return fonts.PaintGlyph{Paint: paint, Glyph: GID(binary.BigEndian.Uint16(data[4:]))}, nil
+	case 11: // PaintColrGlyph
+		return fonts.PaintColrGlyph{Glyph: GID(binary.BigEndian.Uint16(data[1:]))}, nil
+	case 32: // PaintComposite
+		mode := data[4]
+		if mode > uint8(fonts.CompositeHSLLuminosity) {
+			return nil, fmt.Errorf("invalid 'COLR' table composite mode %d", mode)
+		}
+		source, err := r.resolve(child(1))
+		if err != nil {
+			return nil, err
+		}
+		backdrop, err := r.resolve(child(5))
+		if err != nil {
+			return nil, err
+		}
+		return fonts.PaintComposite{Source: source, Backdrop: backdrop, Mode: fonts.CompositeMode(mode)}, nil
+	}
+
+	// transformations : 12 <= format <= 31
+	var transform fonts.Transform
+	switch format {
+	case 12, 13: // PaintTransform, PaintVarTransform
+		var err error
+		transform, err = r.affine(child(4), format == 13)
+		if err != nil {
+			return nil, err
+		}
+	case 14, 15: // PaintTranslate, PaintVarTranslate
+		transform = translation(fword(4, 0), fword(6, 1))
+	case 16, 17: // PaintScale, PaintVarScale
+		transform = fonts.Transform{XX: f2dot14(4, 0), YY: f2dot14(6, 1)}
+	case 18, 19: // PaintScaleAroundCenter, PaintVarScaleAroundCenter
+		transform = aroundCenter(fonts.Transform{XX: f2dot14(4, 0), YY: f2dot14(6, 1)}, fword(8, 2), fword(10, 3))
+	case 20, 21: // PaintScaleUniform, PaintVarScaleUniform
+		scale := f2dot14(4, 0)
+		transform = fonts.Transform{XX: scale, YY: scale}
+	case 22, 23: // PaintScaleUniformAroundCenter, PaintVarScaleUniformAroundCenter
+		scale := f2dot14(4, 0)
+		transform = aroundCenter(fonts.Transform{XX: scale, YY: scale}, fword(6, 1), fword(8, 2))
+	case 24, 25: // PaintRotate, PaintVarRotate
+		transform = rotation(f2dot14(4, 0))
+	case 26, 27: // PaintRotateAroundCenter, PaintVarRotateAroundCenter
+		transform = aroundCenter(rotation(f2dot14(4, 0)), fword(6, 1), fword(8, 2))
+	case 28, 29: // PaintSkew, PaintVarSkew
+		transform = skew(f2dot14(4, 0), f2dot14(6, 1))
+	case 30, 31: // PaintSkewAroundCenter, PaintVarSkewAroundCenter
+		transform = aroundCenter(skew(f2dot14(4, 0), f2dot14(6, 1)), fword(8, 2), fword(10, 3))
+	}
+	paint, err := r.resolve(child(1))
+	if err != nil {
+		return nil, err
+	}
+	return fonts.PaintTransform{Paint: paint, Transform: transform}, nil
+}
+
+// colorLine parses a ColorLine or a VarColorLine
+func (r *paintResolver) colorLine(offset uint32, isVar bool) (fonts.ColorLine, error) {
+	data := r.colr.data
+	if len(data) < int(offset)+3 {
+		return fonts.ColorLine{}, errors.New("invalid 'COLR' table color line (EOF)")
+	}
+	data = data[offset:]
+	extend := fonts.Extend(data[0])
+	if extend > fonts.ExtendReflect { // unknown values are treated as pad
+		extend = fonts.ExtendPad
+	}
+	numStops := int(binary.BigEndian.Uint16(data[1:]))
+	stopSize := 6
+	if isVar {
+		stopSize = 10
+	}
+	if len(data) < 3+numStops*stopSize {
+		return fonts.ColorLine{}, errors.New("invalid 'COLR' table color line (EOF)")
+	}
+	out := fonts.ColorLine{Extend: extend, Stops: make([]fonts.ColorStop, numStops)}
+	for i := range out.Stops {
+		stop := data[3+i*stopSize:]
+		varIndexBase := uint32(noVariationIndex)
+		if isVar {
+			varIndexBase = binary.BigEndian.Uint32(stop[6:])
+		}
+		out.Stops[i] = fonts.ColorStop{
+			Offset:       fixed214ToFloat(binary.BigEndian.Uint16(stop)) + r.delta(varIndexBase, 0)/(1<<14),
+			PaletteIndex: binary.BigEndian.Uint16(stop[2:]),
+			Alpha:        fixed214ToFloat(binary.BigEndian.Uint16(stop[4:])) + r.delta(varIndexBase, 1)/(1<<14),
+		}
+	}
+	sort.SliceStable(out.Stops, func(i, j int) bool { return out.Stops[i].Offset < out.Stops[j].Offset })
+	return out, nil
+}
+
+// affine parses an Affine2x3 or a VarAffine2x3
+func (r *paintResolver) affine(offset uint32, isVar bool) (fonts.Transform, error) {
+	size := 24
+	if isVar {
+		size = 28
+	}
+	data := r.colr.data
+	if len(data) < int(offset)+size {
+		return fonts.Transform{}, errors.New("invalid 'COLR' table affine transformation (EOF)")
+	}
+	data = data[offset:]
+	varIndexBase := uint32(noVariationIndex)
+	if isVar {
+		varIndexBase = binary.BigEndian.Uint32(data[24:])
+	}
+	var values [6]float32 // xx, yx, xy, yy, dx, dy
+	for i := range values {
+		values[i] = fixed1616ToFloat(binary.BigEndian.Uint32(data[4*i:])) + r.delta(varIndexBase, uint32(i))/(1<<16)
+	}
+	return fonts.Transform{XX: values[0], YX: values[1], XY: values[2], YY: values[3], DX: values[4], DY: values[5]}, nil
+}
+
+func translation(dx, dy float32) fonts.Transform {
+	return fonts.Transform{XX: 1, YY: 1, DX: dx, DY: dy}
+}
+
+// angle is in half turns
+func rotation(angle float32) fonts.Transform {
+	s, c := math.Sincos(float64(angle) * math.Pi)
+	return fonts.Transform{XX: float32(c), YX: float32(s), XY: -float32(s), YY: float32(c)}
+}
+
+// angles are in half turns; a positive x skew is clockwise
+func skew(xAngle, yAngle float32) fonts.Transform {
+	return fonts.Transform{
+		XX: 1, YY: 1,
+		XY: -float32(math.Tan(float64(xAngle) * math.Pi)),
+		YX: float32(math.Tan(float64(yAngle) * math.Pi)),
+	}
+}
+
+// aroundCenter returns the transformation applying `t` with (centerX, centerY)
+// as origin
+func aroundCenter(t fonts.Transform, centerX, centerY float32) fonts.Transform {
+	return translation(centerX, centerY).Multiply(t.Multiply(translation(-centerX, -centerY)))
+}
@@ -0,0 +1,363 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/harfbuzz"
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+func TestCOLRv0(t *testing.T) {
+	f, err := testdata.Files.ReadFile("harfbuzz_reference/in-house/fonts/53374c7ca3657be37efde7ed02ae34229a56ae1f.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := Parse(bytes.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if L := len(font.CPAL.Palettes); L != 2 {
+		t.Fatalf("expected 2 palettes, got %d", L)
+	}
+	palette := font.CPAL.Palettes[0]
+	if L := len(palette.Colors); L != 69 {
+		t.Fatalf("expected 69 colors, got %d", L)
+	}
+	if c := palette.Colors[7]; c != (color.NRGBA{R: 0xff, A: 0xff}) {
+		t.Fatalf("unexpected color %v", c)
+	}
+	if c := palette.Colors[14]; c != (color.NRGBA{R: 0xff, G: 0xcc, A: 0xff}) {
+		t.Fatalf("unexpected color %v", c)
+	}
+
+	data, ok := font.GlyphData(8, 0, 0).(fonts.GlyphColor)
+	if !ok {
+		t.Fatalf("expected color glyph, got %T", font.GlyphData(8, 0, 0))
+	}
+	expected := fonts.GlyphColor{Paint: fonts.PaintColrLayers{
+		fonts.PaintGlyph{Glyph: 9, Paint: fonts.PaintSolid{PaletteIndex: 0, Alpha: 1}},
+		fonts.PaintGlyph{Glyph: 10, Paint: fonts.PaintSolid{PaletteIndex: 7, Alpha: 1}},
+		fonts.PaintGlyph{Glyph: 11, Paint: fonts.PaintSolid{PaletteIndex: 14, Alpha: 1}},
+	}}
+	// the base glyph also has a regular outline
+	if outline, _ := font.outlineGlyphData(8); len(data.Outline.Segments) == 0 || !reflect.DeepEqual(data.Outline, outline) {
+		t.Fatalf("unexpected fallback outline %v", data.Outline)
+	}
+	expected.Outline = data.Outline
+	if !reflect.DeepEqual(data, expected) {
+		t.Fatalf("expected %v, got %v", expected, data)
+	}
+
+	// layers are regular glyphs
+	if _, ok := font.GlyphData(9, 0, 0).(fonts.GlyphOutline); !ok {
+		t.Fatalf("expected outline glyph, got %T", font.GlyphData(9, 0, 0))
+	}
+}
+
+// colrBuilder is a minimal helper to write COLR tables.
+type colrBuilder struct {
+	data []byte
+}
+
+func (b *colrBuilder) pos() uint32 { return uint32(len(b.data)) }
+
+func (b *colrBuilder) u8(v uint8) { b.data = append(b.data, v) }
+
+func (b *colrBuilder) u16(v uint16) { b.data = append(b.data, byte(v>>8), byte(v)) }
+
+func (b *colrBuilder) u24(v uint32) { b.data = append(b.data, byte(v>>16), byte(v>>8), byte(v)) }
+
+func (b *colrBuilder) u32(v uint32) { b.data = binary.BigEndian.AppendUint32(b.data, v) }
+
+// patch24 writes at `at` the offset of `target` relative to `base`
+func (b *colrBuilder) patch24(at, base, target uint32) {
+	v := target - base
+	b.data[at], b.data[at+1], b.data[at+2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+func (b *colrBuilder) patch32(at, base, target uint32) {
+	binary.BigEndian.PutUint32(b.data[at:], target-base)
+}
+
+// buildCOLRv1 returns a table defining glyph 1 and glyph 4,
+// with one variation axis.
+func buildCOLRv1(t *testing.T) []byte {
+	var b colrBuilder
+	// header
+	b.u16(1)
+	b.u16(0)
+	b.u32(0)
+	b.u32(0)
+	b.u16(0)
+	b.u32(0) // base glyph list, patched at 14
+	b.u32(0) // layer list, patched at 18
+	b.u32(0) // clip list, patched at 22
+	b.u32(0) // no var index map
+	b.u32(0) // store, patched at 30
+
+	baseGlyphList := b.pos()
+	b.patch32(14, 0, baseGlyphList)
+	b.u32(2)
+	b.u16(1)
+	b.u32(0) // patched at baseGlyphList+6
+	b.u16(4)
+	b.u32(0) // patched at baseGlyphList+12
+
+	layerList := b.pos()
+	b.patch32(18, 0, layerList)
+	b.u32(2)
+	b.u32(0) // patched at layerList+4
+	b.u32(0) // patched at layerList+8
+
+	clipList := b.pos()
+	b.patch32(22, 0, clipList)
+	b.u8(1)
+	b.u32(1)
+	b.u16(1)
+	b.u16(1)
+	b.u24(0) // patched at clipList+9
+	clipBox := b.pos()
+	b.patch24(clipList+9, clipList, clipBox)
+	b.u8(2)
+	b.u16(0)
+	b.u16(0)
+	b.u16(100)
+	b.u16(200)
+	b.u32(0) // varIndexBase
+
+	store := b.pos()
+	b.patch32(30, 0, store)
+	b.u16(1)
+	b.u32(12) // region list
+	b.u16(1)
+	b.u32(22) // item variation data
+	// region list
+	b.u16(1)
+	b.u16(1)
+	b.u16(0)
+	b.u16(1 << 14)
+	b.u16(1 << 14)
+	// item variation data
+	deltas := []int16{10, -10, 20, 0, 1 << 12, 5, -5}
+	b.u16(uint16(len(deltas)))
+	b.u16(1) // short deltas
+	b.u16(1)
+	b.u16(0)
+	for _, d := range deltas {
+		b.u16(uint16(d))
+	}
+
+	// glyph 1
+	paint1 := b.pos()
+	b.patch32(baseGlyphList+6, baseGlyphList, paint1)
+	b.u8(1) // PaintColrLayers
+	b.u8(2)
+	b.u32(0)
+
+	paintGlyph2 := b.pos()
+	b.patch32(layerList+4, layerList, paintGlyph2)
+	b.u8(10) // PaintGlyph
+	b.u24(0) // patched
+	b.u16(2)
+	paintSolid := b.pos()
+	b.patch24(paintGlyph2+1, paintGlyph2, paintSolid)
+	b.u8(3) // PaintVarSolid
+	b.u16(3)
+	b.u16(1 << 13) // 0.5
+	b.u32(4)
+
+	paintGlyph3 := b.pos()
+	b.patch32(layerList+8, layerList, paintGlyph3)
+	b.u8(10) // PaintGlyph
+	b.u24(0) // patched
+	b.u16(3)
+	paintRotate := b.pos()
+	b.patch24(paintGlyph3+1, paintGlyph3, paintRotate)
+	b.u8(26) // PaintRotateAroundCenter
+	b.u24(0) // patched
+	b.u16(1 << 13)
+	b.u16(100)
+	b.u16(200)
+	paintLinear := b.pos()
+	b.patch24(paintRotate+1, paintRotate, paintLinear)
+	b.u8(4)  // PaintLinearGradient
+	b.u24(0) // patched with the color line
+	for _, v := range []uint16{0, 0, 100, 0, 0, 100} {
+		b.u16(v)
+	}
+
+	// glyph 4
+	paint4 := b.pos()
+	b.patch32(baseGlyphList+12, baseGlyphList, paint4)
+	b.u8(32) // PaintComposite
+	b.u24(0) // patched
+	b.u8(uint8(fonts.CompositeSrcOver))
+	b.u24(0) // patched
+	paintColrGlyph := b.pos()
+	b.patch24(paint4+1, paint4, paintColrGlyph)
+	b.u8(11)
+	b.u16(1)
+	paintTranslate := b.pos()
+	b.patch24(paint4+5, paint4, paintTranslate)
+	b.u8(15) // PaintVarTranslate
+	b.u24(0) // patched
+	b.u16(10)
+	b.u16(20)
+	b.u32(5)
+	paintSweep := b.pos()
+	b.patch24(paintTranslate+1, paintTranslate, paintSweep)
+	b.u8(8)  // PaintSweepGradient
+	b.u24(0) // patched with the color line
+	b.u16(0)
+	b.u16(0)
+	b.u16(0xC000) // -1
+	b.u16(0)
+
+	colorLine := b.pos()
+	b.patch24(paintLinear+1, paintLinear, colorLine)
+	b.patch24(paintSweep+1, paintSweep, colorLine)
+	b.u8(1)
+	b.u16(2)
+	b.u16(1 << 14)
+	b.u16(1)
+	b.u16(1 << 14)
+	b.u16(0)
+	b.u16(0)
+	b.u16(1 << 14)
+
+	return b.data
+}
+
+func assertTransform(t *testing.T, exp, got fonts.Transform) {
+	t.Helper()
+	e := [6]float32{exp.XX, exp.YX, exp.XY, exp.YY, exp.DX, exp.DY}
+	g := [6]float32{got.XX, got.YX, got.XY, got.YY, got.DX, got.DY}
+	for i := range e {
+		if math.Abs(float64(e[i]-g[i])) > 1e-4 {
+			t.Fatalf("expected transform %v, got %v", exp, got)
+		}
+	}
+}
+
+func TestCOLRv1(t *testing.T) {
+	colr, err := parseTableCOLR(buildCOLRv1(t), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		coords []float32
+		alpha  float32
+		clip   fonts.ClipBox
+		dx, dy float32
+	}{
+		{nil, 0.5, fonts.ClipBox{XMin: 0, YMin: 0, XMax: 100, YMax: 200}, 10, 20},
+		{[]float32{0.5}, 0.625, fonts.ClipBox{XMin: 5, YMin: -5, XMax: 110, YMax: 200}, 12.5, 17.5},
+		{[]float32{1}, 0.75, fonts.ClipBox{XMin: 10, YMin: -10, XMax: 120, YMax: 200}, 15, 15},
+	} {
+		glyph1, err := colr.glyphData(1, test.coords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if glyph1.ClipBox == nil || *glyph1.ClipBox != test.clip {
+			t.Fatalf("expected clip box %v, got %v", test.clip, glyph1.ClipBox)
+		}
+		layers, ok := glyph1.Paint.(fonts.PaintColrLayers)
+		if !ok || len(layers) != 2 {
+			t.Fatalf("unexpected paint %v", glyph1.Paint)
+		}
+		solid := fonts.PaintGlyph{Glyph: 2, Paint: fonts.PaintSolid{PaletteIndex: 3, Alpha: test.alpha}}
+		if layers[0] != solid {
+			t.Fatalf("expected %v, got %v", solid, layers[0])
+		}
+
+		paintGlyph := layers[1].(fonts.PaintGlyph)
+		rotate := paintGlyph.Paint.(fonts.PaintTransform)
+		// rotation of 90° around (100, 200)
+		assertTransform(t, fonts.Transform{XX: 0, YX: 1, XY: -1, YY: 0, DX: 300, DY: 100}, rotate.Transform)
+		expectedLinear := fonts.PaintLinearGradient{
+			ColorLine: fonts.ColorLine{Extend: fonts.ExtendRepeat, Stops: []fonts.ColorStop{
+				{Offset: 0, PaletteIndex: 0, Alpha: 1},
+				{Offset: 1, PaletteIndex: 1, Alpha: 1},
+			}},
+			X1: 100, Y2: 100,
+		}
+		if !reflect.DeepEqual(rotate.Paint, expectedLinear) {
+			t.Fatalf("expected %v, got %v", expectedLinear, rotate.Paint)
+		}
+
+		glyph4, err := colr.glyphData(4, test.coords)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if glyph4.ClipBox != nil {
+			t.Fatal("unexpected clip box")
+		}
+		expectedComposite := fonts.PaintComposite{
+			Source: fonts.PaintColrGlyph{Glyph: 1},
+			Backdrop: fonts.PaintTransform{
+				Transform: fonts.Transform{XX: 1, YY: 1, DX: test.dx, DY: test.dy},
+				Paint: fonts.PaintSweepGradient{
+					ColorLine: expectedLinear.ColorLine,
+					EndAngle:  180,
+				},
+			},
+			Mode: fonts.CompositeSrcOver,
+		}
+		if !reflect.DeepEqual(glyph4.Paint, expectedComposite) {
+			t.Fatalf("expected %v, got %v", expectedComposite, glyph4.Paint)
+		}
+	}
+
+	if _, err := colr.glyphData(2, nil); err == nil {
+		t.Fatal("expected error for non color glyph")
+	}
+}
+
+func TestCOLRInvalid(t *testing.T) {
+	data := buildCOLRv1(t)
+	for i := range data {
+		colr, err := parseTableCOLR(data[:i], 1)
+		if err != nil {
+			continue
+		}
+		// check for panics
+		colr.glyphData(1, []float32{0.5})
+		colr.glyphData(4, []float32{0.5})
+	}
+
+	// a paint referencing itself
+	var b colrBuilder
+	b.data = make([]byte, 34)
+	b.patch32(14, 0, b.pos())
+	b.u32(1)
+	b.u16(1)
+	b.u32(10)
+	b.u8(10) // PaintGlyph
+	b.u24(0) // itself
+	b.u16(1)
+	colr, err := parseTableCOLR(b.data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := colr.glyphData(1, nil); err == nil {
+		t.Fatal("expected error for cyclic paint graph")
+	}
+
+	// CPAL
+	cpal := []byte{0, 0, 0, 2, 0, 1, 0, 2, 0, 0, 0, 14, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8}
+	if _, err := parseTableCPAL(cpal); err != nil {
+		t.Fatal(err)
+	}
+	for i := range cpal {
+		if _, err := parseTableCPAL(cpal[:i]); err == nil {
+			t.Fatalf("expected error for truncated table (length %d)", i)
+		}
+	}
+}
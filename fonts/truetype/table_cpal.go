@@ -0,0 +1,120 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+)
+
+// PaletteType is a set of flags describing the intended usage of a palette.
+type PaletteType uint32
+
+const (
+	// PaletteUsableWithLightBackground indicates that the palette
+	// is appropriate to use when displaying the font on a light background such as white.
+	PaletteUsableWithLightBackground PaletteType = 1 << iota
+	// PaletteUsableWithDarkBackground indicates that the palette
+	// is appropriate to use when displaying the font on a dark background such as black.
+	PaletteUsableWithDarkBackground
+)
+
+// Palette is a set of colors, referenced by index
+// in color glyphs.
+type Palette struct {
+	Colors []color.NRGBA
+	Type   PaletteType
+	Label  NameID // 0xFFFF if not provided
+}
+
+// TableCPAL stores the color palettes of the font.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/cpal
+type TableCPAL struct {
+	Palettes []Palette // each palette has the same number of entries
+	// EntryLabels are the names of each palette entry,
+	// or 0xFFFF if not provided. It is empty if not
+	// provided in the font.
+	EntryLabels []NameID
+}
+
+func parseTableCPAL(data []byte) (out TableCPAL, err error) {
+	if len(data) < 12 {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	version := binary.BigEndian.Uint16(data)
+	numPaletteEntries := int(binary.BigEndian.Uint16(data[2:]))
+	numPalettes := int(binary.BigEndian.Uint16(data[4:]))
+	numColorRecords := int(binary.BigEndian.Uint16(data[6:]))
+	colorRecordsOffset := int(binary.BigEndian.Uint32(data[8:]))
+
+	colorRecordIndices, err := parseUint16s(data[12:], numPalettes)
+	if err != nil {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+
+	if len(data) < colorRecordsOffset+4*numColorRecords {
+		return out, errors.New("invalid 'CPAL' table color records (EOF)")
+	}
+	records := data[colorRecordsOffset : colorRecordsOffset+4*numColorRecords]
+	out.Palettes = make([]Palette, numPalettes)
+	for i, index := range colorRecordIndices {
+		if int(index)+numPaletteEntries > numColorRecords {
+			return out, errors.New("invalid 'CPAL' table color record index")
+		}
+		colors := make([]color.NRGBA, numPaletteEntries)
+		for j := range colors {
+			record := records[4*(int(index)+j):]
+			// stored as BGRA
+			colors[j] = color.NRGBA{B: record[0], G: record[1], R: record[2], A: record[3]}
+		}
+		out.Palettes[i] = Palette{Colors: colors, Label: 0xFFFF}
+	}
+
+	if version == 0 {
+		return out, nil
+	}
+
+	headerEnd := 12 + 2*numPalettes
+	if len(data) < headerEnd+12 {
+		return out, errors.New("invalid 'CPAL' table (EOF)")
+	}
+	typesOffset := int(binary.BigEndian.Uint32(data[headerEnd:]))
+	labelsOffset := int(binary.BigEndian.Uint32(data[headerEnd+4:]))
+	entryLabelsOffset := int(binary.BigEndian.Uint32(data[headerEnd+8:]))
+
+	if typesOffset != 0 {
+		if len(data) < typesOffset+4*numPalettes {
+			return out, errors.New("invalid 'CPAL' table palette types (EOF)")
+		}
+		types := parseUint32s(data[typesOffset:], numPalettes)
+		for i, t := range types {
+			out.Palettes[i].Type = PaletteType(t)
+		}
+	}
+	if labelsOffset != 0 {
+		if len(data) < labelsOffset {
+			return out, errors.New("invalid 'CPAL' table palette labels (EOF)")
+		}
+		labels, err := parseUint16s(data[labelsOffset:], numPalettes)
+		if err != nil {
+			return out, errors.New("invalid 'CPAL' table palette labels (EOF)")
+		}
+		for i, label := range labels {
+			out.Palettes[i].Label = NameID(label)
+		}
+	}
+	if entryLabelsOffset != 0 {
+		if len(data) < entryLabelsOffset {
+			return out, errors.New("invalid 'CPAL' table palette entry labels (EOF)")
+		}
+		labels, err := parseUint16s(data[entryLabelsOffset:], numPaletteEntries)
+		if err != nil {
+			return out, errors.New("invalid 'CPAL' table palette entry labels (EOF)")
+		}
+		out.EntryLabels = make([]NameID, numPaletteEntries)
+		for i, label := range labels {
+			out.EntryLabels[i] = NameID(label)
+		}
+	}
+
+	return out, nil
+}
@@ -87,6 +87,35 @@ func (t *TableLayout) FindFeatureIndex(featureTag Tag) (uint16, bool) {
 	return 0, false
 }
 
+// ScriptTags returns the tags of the scripts defined in the table.
+func (t *TableLayout) ScriptTags() []Tag {
+	out := make([]Tag, len(t.Scripts))
+	for i, s := range t.Scripts {
+		out[i] = s.Tag
+	}
+	return out
+}
+
+// FeatureLookups returns the lookups of the feature at `featureIndex`, with
+// the substitution defined by the feature variation at `variationsIndex` applied.
+// A negative `variationsIndex` (as returned by FindVariationIndex) selects the default feature.
+// It returns nil for an invalid `featureIndex`.
+func (t *TableLayout) FeatureLookups(featureIndex uint16, variationsIndex int) []uint16 {
+	if int(featureIndex) >= len(t.Features) {
+		return nil
+	}
+
+	if variationsIndex >= 0 && variationsIndex < len(t.FeatureVariations) {
+		for _, sub := range t.FeatureVariations[variationsIndex].FeatureSubstitutions {
+			if sub.FeatureIndex == featureIndex {
+				return sub.AlternateFeature.LookupIndices
+			}
+		}
+	}
+	// the features not substituted are kept
+	return t.Features[featureIndex].LookupIndices
+}
+
 // Script represents a single script (i.e "latn" (Latin), "cyrl" (Cyrillic), etc).
 type Script struct {
 	DefaultLanguage *LangSys
@@ -113,6 +142,16 @@ func (t Script) FindLanguage(language Tag) int {
 	return -1
 }
 
+// LanguageTags returns the tags of the language systems defined for the script,
+// not including the default one.
+func (t Script) LanguageTags() []Tag {
+	out := make([]Tag, len(t.Languages))
+	for i, l := range t.Languages {
+		out[i] = l.Tag
+	}
+	return out
+}
+
 // GetLangSys return the language at `index`. It `index` is out of range (for example with 0xFFFF),
 // it returns `DefaultLanguage` (which may be empty)
 func (t Script) GetLangSys(index uint16) LangSys {
@@ -120,7 +159,7 @@ func (t Script) GetLangSys(index uint16) LangSys {
 		if t.DefaultLanguage != nil {
 			return *t.DefaultLanguage
 		}
-		return LangSys{}
+		return LangSys{RequiredFeatureIndex: 0xFFFF}
 	}
 	return t.Languages[index]
 }
@@ -133,8 +172,48 @@ type FeatureRecord struct {
 
 // Feature represents a glyph substitution or glyph positioning features.
 type Feature struct {
+	// Params is the optional FeatureParams table, one of FeatureParamsSize,
+	// FeatureParamsStylisticSet or FeatureParamsCharacterVariants.
+	// It is nil if absent, invalid, or not defined for the feature tag.
+	Params        FeatureParams
 	LookupIndices []uint16
-	paramsOffet   uint16
+}
+
+// FeatureParams provides additional information about
+// features such as 'size', 'ssXX' and 'cvXX'.
+type FeatureParams interface {
+	isFeatureParams()
+}
+
+func (FeatureParamsSize) isFeatureParams()              {}
+func (FeatureParamsStylisticSet) isFeatureParams()      {}
+func (FeatureParamsCharacterVariants) isFeatureParams() {}
+
+// FeatureParamsSize stores the parameters of the 'size' feature.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/features_pt#size
+type FeatureParamsSize struct {
+	DesignSize      uint16 // in decipoints
+	SubfamilyID     uint16 // identifies the font family member, 0 if not in a family
+	SubfamilyNameID NameID // localized name for the subfamily
+	RangeStart      uint16 // small end of the recommended usage range (exclusive), in decipoints
+	RangeEnd        uint16 // large end of the recommended usage range (inclusive), in decipoints
+}
+
+// FeatureParamsStylisticSet stores the parameters of the 'ss01' to 'ss20' features.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/features_pt#ssxx
+type FeatureParamsStylisticSet struct {
+	UINameID NameID // user-interface string for the set
+}
+
+// FeatureParamsCharacterVariants stores the parameters of the 'cv01' to 'cv99' features.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/features_ae#cv01-cv99
+type FeatureParamsCharacterVariants struct {
+	FeatUILabelNameID       NameID // user-interface label for the feature (may be 0)
+	FeatUITooltipTextNameID NameID // user-interface tooltip text (may be 0)
+	SampleTextNameID        NameID // sample text illustrating the effect (may be 0)
+	NumNamedParameters      uint16 // number of named parameters (may be 0)
+	FirstParamUILabelNameID NameID // first user-interface label for the named parameters (may be 0)
+	Characters              []rune // characters for which the feature provides glyph variants (may be empty)
 }
 
 type LookupOptions struct {
@@ -208,6 +287,22 @@ type LangSys struct {
 	Tag                  Tag
 }
 
+// FeatureIndices returns the indices of all the features of the language system,
+// starting with the required feature, if any.
+func (l LangSys) FeatureIndices() []uint16 {
+	if l.RequiredFeatureIndex == 0xFFFF {
+		return l.Features
+	}
+	out := make([]uint16, 0, len(l.Features)+1)
+	out = append(out, l.RequiredFeatureIndex)
+	for _, index := range l.Features {
+		if index != l.RequiredFeatureIndex {
+			out = append(out, index)
+		}
+	}
+	return out
+}
+
 // parseLangSys parses a single Language System table. b expected to be the beginning of Script table.
 // See https://www.microsoft.com/typography/otspec/chapter2.htm#langSysTbl
 func (t *TableLayout) parseLangSys(b []byte, record langSysRecord) (LangSys, error) {
@@ -335,9 +430,11 @@ func (t *TableLayout) parseScriptList(buf []byte) error {
 	return nil
 }
 
-// parseFeature parses a single Feature table. b expected to be the beginning of the feature
+// parseFeature parses a single Feature table. b expected to be the beginning of the feature.
+// `tag` selects the kind of FeatureParams, and `list`, if not nil, is the beginning of the
+// FeatureList, used to recover from a common mistake in 'size' features.
 // See https://www.microsoft.com/typography/otspec/chapter2.htm#featTbl
-func parseFeature(b []byte) (Feature, error) {
+func parseFeature(b []byte, tag Tag, list []byte) (Feature, error) {
 	r := bytes.NewReader(b)
 
 	var feature struct {
@@ -353,7 +450,84 @@ func parseFeature(b []byte) (Feature, error) {
 		return Feature{}, fmt.Errorf("reading featureTable: %s", err)
 	}
 
-	return Feature{paramsOffet: feature.FeatureParams, LookupIndices: lookupIndices}, nil
+	out := Feature{LookupIndices: lookupIndices}
+	if offset := int(feature.FeatureParams); offset != 0 {
+		out.Params = parseFeatureParams(b, offset, tag)
+		// Some early versions of Adobe tools calculated the offset of the
+		// FeatureParams subtable from the beginning of the FeatureList table,
+		// instead of from the beginning of the Feature table: try this as well.
+		if out.Params == nil && tag == tagSize && list != nil {
+			out.Params = parseFeatureParams(list, offset, tag)
+		}
+	}
+	return out, nil
+}
+
+var tagSize = MustNewTag("size")
+
+// parseFeatureParams returns nil for invalid or unsupported parameters.
+func parseFeatureParams(b []byte, offset int, tag Tag) FeatureParams {
+	if offset >= len(b) {
+		return nil
+	}
+	b = b[offset:]
+	switch {
+	case tag == tagSize:
+		if len(b) < 10 {
+			return nil
+		}
+		out := FeatureParamsSize{
+			DesignSize:      binary.BigEndian.Uint16(b),
+			SubfamilyID:     binary.BigEndian.Uint16(b[2:]),
+			SubfamilyNameID: NameID(binary.BigEndian.Uint16(b[4:])),
+			RangeStart:      binary.BigEndian.Uint16(b[6:]),
+			RangeEnd:        binary.BigEndian.Uint16(b[8:]),
+		}
+		if !out.isValid() {
+			return nil
+		}
+		return out
+	case tag>>16 == 's'<<8|'s': // ssXX
+		if len(b) < 4 {
+			return nil
+		}
+		return FeatureParamsStylisticSet{UINameID: NameID(binary.BigEndian.Uint16(b[2:]))}
+	case tag>>16 == 'c'<<8|'v': // cvXX
+		if len(b) < 14 {
+			return nil
+		}
+		out := FeatureParamsCharacterVariants{
+			FeatUILabelNameID:       NameID(binary.BigEndian.Uint16(b[2:])),
+			FeatUITooltipTextNameID: NameID(binary.BigEndian.Uint16(b[4:])),
+			SampleTextNameID:        NameID(binary.BigEndian.Uint16(b[6:])),
+			NumNamedParameters:      binary.BigEndian.Uint16(b[8:]),
+			FirstParamUILabelNameID: NameID(binary.BigEndian.Uint16(b[10:])),
+		}
+		count := int(binary.BigEndian.Uint16(b[12:]))
+		if len(b) < 14+3*count {
+			return nil
+		}
+		out.Characters = make([]rune, count)
+		for i := range out.Characters {
+			out.Characters[i] = parseUint24(b[14+3*i:])
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// isValid applies the same heuristic as Harfbuzz to reject
+// the 'size' parameters which are obviously wrong.
+func (p FeatureParamsSize) isValid() bool {
+	if p.DesignSize == 0 {
+		return false
+	}
+	if p.SubfamilyID == 0 && p.SubfamilyNameID == 0 && p.RangeStart == 0 && p.RangeEnd == 0 {
+		return true
+	}
+	return p.DesignSize >= p.RangeStart && p.DesignSize <= p.RangeEnd &&
+		p.SubfamilyNameID >= 256 && p.SubfamilyNameID <= 32767
 }
 
 // parseFeatureList parses the FeatureList.
@@ -382,7 +556,7 @@ func (t *TableLayout) parseFeatureList(buf []byte) error {
 		if len(b) < int(record.Offset) {
 			return io.ErrUnexpectedEOF
 		}
-		feature, err := parseFeature(b[record.Offset:])
+		feature, err := parseFeature(b[record.Offset:], record.Tag, b)
 		if err != nil {
 			return err
 		}
@@ -530,7 +704,7 @@ func (t *TableLayout) parseFeatureVariationList(buf []byte) (err error) {
 			return err
 		}
 
-		t.FeatureVariations[i].FeatureSubstitutions, err = parseFeatureSubstitution(b[record.FeatureTableSubstitutionOffset:])
+		t.FeatureVariations[i].FeatureSubstitutions, err = parseFeatureSubstitution(b[record.FeatureTableSubstitutionOffset:], t.Features)
 		if err != nil {
 			return err
 		}
@@ -605,8 +779,9 @@ type FeatureSubstitution struct {
 	FeatureIndex     uint16 // The feature table index to match.
 }
 
-// buf is as the begining of the table
-func parseFeatureSubstitution(buf []byte) ([]FeatureSubstitution, error) {
+// buf is as the begining of the table; `features` is used to
+// fetch the tags of the substituted features.
+func parseFeatureSubstitution(buf []byte, features []FeatureRecord) ([]FeatureSubstitution, error) {
 	if len(buf) < 6 {
 		return nil, io.ErrUnexpectedEOF
 	}
@@ -621,8 +796,12 @@ func parseFeatureSubstitution(buf []byte) ([]FeatureSubstitution, error) {
 		if len(buf) < int(alternateFeatureOffset) {
 			return nil, io.ErrUnexpectedEOF
 		}
+		var tag Tag
+		if int(out[i].FeatureIndex) < len(features) {
+			tag = features[out[i].FeatureIndex].Tag
+		}
 		var err error
-		out[i].AlternateFeature, err = parseFeature(buf[alternateFeatureOffset:])
+		out[i].AlternateFeature, err = parseFeature(buf[alternateFeatureOffset:], tag, nil)
 		if err != nil {
 			return nil, err
 		}
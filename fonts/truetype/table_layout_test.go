@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -122,3 +123,104 @@ func TestFeatureVariations(t *testing.T) {
 	}
 	fmt.Println(gdef.Class)
 }
+
+func TestFeatureParamsSize(t *testing.T) {
+	filename := "TestCFF2VF.otf"
+	file, err := testdata.Files.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open %q: %s\n", filename, err)
+	}
+
+	font, err := NewFontParser(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Parse(%q) err = %q, want nil", filename, err)
+	}
+
+	gpos, err := font.GPOSTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, ok := gpos.FindFeatureIndex(MustNewTag("size"))
+	if !ok {
+		t.Fatal("missing size feature")
+	}
+	if exp, got := (FeatureParamsSize{DesignSize: 100}), gpos.Features[index].Params; got != exp {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestParseFeatureParams(t *testing.T) {
+	cv := []byte{
+		0, 0, // format
+		1, 0, // FeatUILabelNameID
+		1, 1, // FeatUITooltipTextNameID
+		0, 0, // SampleTextNameID
+		0, 2, // NumNamedParameters
+		1, 2, // FirstParamUILabelNameID
+		0, 2, // charCount
+		0, 0, 'a', 0x01, 0xF6, 0x00, // characters
+	}
+	params := parseFeatureParams(cv, 0, MustNewTag("cv01"))
+	exp := FeatureParamsCharacterVariants{
+		FeatUILabelNameID: 256, FeatUITooltipTextNameID: 257,
+		NumNamedParameters: 2, FirstParamUILabelNameID: 258,
+		Characters: []rune{'a', 0x1F600},
+	}
+	if !reflect.DeepEqual(params, exp) {
+		t.Fatalf("expected %v, got %v", exp, params)
+	}
+	if params := parseFeatureParams(cv[:15], 0, MustNewTag("cv01")); params != nil {
+		t.Fatalf("expected nil params for truncated characters, got %v", params)
+	}
+
+	ss := []byte{0, 0, 1, 3}
+	if params := parseFeatureParams(ss, 0, MustNewTag("ss02")); params != (FeatureParamsStylisticSet{UINameID: 259}) {
+		t.Fatalf("unexpected params %v", params)
+	}
+	if params := parseFeatureParams(ss, 0, MustNewTag("liga")); params != nil {
+		t.Fatalf("unexpected params %v", params)
+	}
+
+	// design size out of the range
+	size := []byte{0, 100, 0, 1, 1, 0, 0, 10, 0, 50}
+	if params := parseFeatureParams(size, 0, tagSize); params != nil {
+		t.Fatalf("unexpected params %v", params)
+	}
+	size[9] = 200
+	if params := parseFeatureParams(size, 0, tagSize); params != (FeatureParamsSize{100, 1, 256, 10, 200}) {
+		t.Fatalf("unexpected params %v", params)
+	}
+}
+
+func TestParseFeatureSizeOffset(t *testing.T) {
+	// a FeatureList with one 'size' feature whose params offset
+	// is wrongly relative to the FeatureList
+	list := []byte{
+		0, 1, // featureCount
+		's', 'i', 'z', 'e', 0, 8, // featureRecord
+		0, 14, 0, 0, // feature: featureParamsOffset, lookupIndexCount
+		0, 0, // padding
+		0, 100, 0, 0, 0, 0, 0, 0, 0, 0, // size params
+	}
+	feature, err := parseFeature(list[8:], tagSize, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feature.Params != (FeatureParamsSize{DesignSize: 100}) {
+		t.Fatalf("unexpected params %v", feature.Params)
+	}
+}
+
+func TestLangSysFeatureIndices(t *testing.T) {
+	ls := LangSys{Features: []uint16{1, 2, 3}, RequiredFeatureIndex: 2}
+	if got := ls.FeatureIndices(); !reflect.DeepEqual(got, []uint16{2, 1, 3}) {
+		t.Fatalf("unexpected indices %v", got)
+	}
+	ls.RequiredFeatureIndex = 0xFFFF
+	if got := ls.FeatureIndices(); !reflect.DeepEqual(got, []uint16{1, 2, 3}) {
+		t.Fatalf("unexpected indices %v", got)
+	}
+	if got := (Script{}).GetLangSys(0xFFFF).FeatureIndices(); len(got) != 0 {
+		t.Fatalf("unexpected indices %v", got)
+	}
+}
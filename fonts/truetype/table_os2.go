@@ -75,9 +75,9 @@ func parseTableOS2(buf []byte) (*TableOS2, error) {
 	switch version {
 	case 0:
 		dst = &out.TableOS2Version0
-	case 1, 2, 3:
+	case 1:
 		dst = &out.TableOS2Version1
-	case 4:
+	case 2, 3, 4:
 		dst = &out.TableOS2Version4
 	case 5:
 		dst = &out
@@ -0,0 +1,27 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseOS2(t *testing.T) {
+	// versions 2 and 3 have the same layout as version 4
+	for _, version := range []uint16{2, 3, 4} {
+		var table TableOS2Version4
+		table.Version = version
+		table.SCapHeight = 700
+		var b bytes.Buffer
+		if err := binary.Write(&b, binary.BigEndian, table); err != nil {
+			t.Fatal(err)
+		}
+		out, err := parseTableOS2(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.SCapHeight != 700 {
+			t.Fatalf("version %d: expected cap height 700, got %d", version, out.SCapHeight)
+		}
+	}
+}
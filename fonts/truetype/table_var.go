@@ -187,6 +187,24 @@ func (store VariationStore) GetDelta(index VariationStoreIndex, coords []float32
 	return delta
 }
 
+// regionScalars appends to `out` the scalars of the regions referenced by
+// the item variation data at `index` (which must be valid), for the given normalized coordinates.
+// Missing coordinates are treated as default (zero) values.
+func (store VariationStore) regionScalars(index uint16, coords []float32, out []float32) []float32 {
+	for _, regionIndex := range store.Datas[index].RegionIndexes {
+		v := float32(1)
+		for axis, region := range store.Regions[regionIndex] {
+			var coord float32
+			if axis < len(coords) {
+				coord = coords[axis]
+			}
+			v *= region.evaluate(coord)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
 func parseVariationStore(data []byte, offset uint32, axisCount int) (out VariationStore, err error) {
 	if len(data) < int(offset)+8 {
 		return out, errors.New("invalid item variation store (EOF)")
@@ -431,12 +449,24 @@ func parseDeltaSetMapping(data []byte, offset uint32) (deltaSetMapping, error) {
 	if len(data) < int(offset)+4 {
 		return nil, errors.New("invalid delta-set mapping (EOF)")
 	}
-	format := binary.BigEndian.Uint16(data[offset:])
-	count := int(binary.BigEndian.Uint16(data[offset+2:]))
-	data = data[offset+4:]
+	format, entryFormat := data[offset], data[offset+1]
+	var count int
+	switch format {
+	case 0:
+		count = int(binary.BigEndian.Uint16(data[offset+2:]))
+		data = data[offset+4:]
+	case 1: // 32-bit count
+		if len(data) < int(offset)+6 {
+			return nil, errors.New("invalid delta-set mapping (EOF)")
+		}
+		count = int(binary.BigEndian.Uint32(data[offset+2:]))
+		data = data[offset+6:]
+	default:
+		return nil, fmt.Errorf("invalid delta-set mapping format %d", format)
+	}
 
-	entrySize := int((format&0x0030)>>4 + 1)
-	innerBitSize := format&0x0F + 1
+	entrySize := int((entryFormat&0x30)>>4 + 1)
+	innerBitSize := entryFormat&0x0F + 1
 	if entrySize > 4 || len(data) < entrySize*count {
 		return nil, errors.New("invalid delta-set mapping (EOF)")
 	}
@@ -0,0 +1,464 @@
+package truetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// WriteFont writes a complete OpenType file, with the outlines, metrics, character map,
+// names and advanced layout tables (GSUB, GPOS, GDEF) of the font.
+// It is typically used on a subset built with the KeepLayout option, for
+// web or editable output. The variation tables and the bitmaps are not written
+// (see Instance to write a variable font), and fonts with CFF2 outlines are not supported.
+func (fnt *Font) WriteFont(w io.Writer) error {
+	if fnt.cff == nil && fnt.cff2 != nil {
+		return errors.New("writing CFF2 outlines is not supported")
+	}
+	if fnt.cff == nil && len(fnt.Glyf) < fnt.NumGlyphs {
+		return errors.New("missing glyph outlines")
+	}
+	if fnt.hhea == nil || len(fnt.Hmtx) < fnt.NumGlyphs {
+		return errors.New("missing horizontal metrics")
+	}
+
+	version := TypeTrueType
+	tags := []Tag{tagHead, tagHhea, tagMaxp, tagHmtx, tagCmap, tagPost}
+	if fnt.Names != nil {
+		tags = append(tags, tagName)
+	}
+	if fnt.OS2 != nil {
+		tags = append(tags, tagOS2)
+	}
+	if fnt.vhea != nil && len(fnt.vmtx) >= fnt.NumGlyphs {
+		tags = append(tags, tagVhea, tagVmtx)
+	}
+	if fnt.cff != nil {
+		version = TypeOpenType
+		tags = append(tags, tagCFF)
+	} else {
+		tags = append(tags, tagGlyf, tagLoca)
+		if len(fnt.cvt) != 0 {
+			tags = append(tags, tagCvt)
+		}
+		if len(fnt.fpgm) != 0 {
+			tags = append(tags, tagFpgm)
+		}
+		if len(fnt.prep) != 0 {
+			tags = append(tags, tagPrep)
+		}
+	}
+	if len(fnt.layoutTables.GSUB.Lookups) != 0 {
+		tags = append(tags, TagGsub)
+	}
+	if len(fnt.layoutTables.GPOS.Lookups) != 0 {
+		tags = append(tags, TagGpos)
+	}
+	if gdef := fnt.layoutTables.GDEF; gdef.Class != nil || gdef.MarkAttach != nil ||
+		gdef.MarkGlyphSet != nil || gdef.LigatureCaretList.Coverage != nil {
+		tags = append(tags, TagGdef)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	return fnt.writeSfnt(w, version, tags)
+}
+
+// writeSfnt writes an OpenType file with the given tables, which are serialized by writeTable.
+func (fnt *Font) writeSfnt(w io.Writer, version Tag, tags []Tag) error {
+	var fontfile bytes.Buffer
+
+	// tables start at 12 (header) + table toc
+	tableOffset := uint32(12 + 16*len(tags))
+	var newTables []tableOffsetLength
+	for _, tag := range tags {
+		var tableData bytes.Buffer
+		if err := fnt.writeTable(&tableData, tag); err != nil {
+			return err
+		}
+		l := tableData.Len()
+		nt := tableOffsetLength{
+			length: uint32(l),
+			tag:    tag,
+			offset: tableOffset,
+		}
+		// align on 4 bytes
+		if pad := (4 - l&3) & 3; pad != 0 {
+			tableData.Write(make([]byte, pad))
+		}
+		nt.tabledata = tableData.Bytes()
+		tableOffset += uint32(len(nt.tabledata))
+		nt.checksum = calcChecksum(nt.tabledata)
+		newTables = append(newTables, nt)
+	}
+
+	binarywrite(&fontfile, version)
+	cTablesRead := float64(len(newTables))
+	searchRange := (math.Pow(2, math.Floor(math.Log2(cTablesRead))) * 16)
+	entrySelector := math.Floor(math.Log2(cTablesRead))
+	rangeShift := (cTablesRead * 16.0) - searchRange
+
+	binarywrite(&fontfile, uint16(cTablesRead))
+	binarywrite(&fontfile, uint16(searchRange))
+	binarywrite(&fontfile, uint16(entrySelector))
+	binarywrite(&fontfile, uint16(rangeShift))
+
+	checksumAdjustmentOffset := 0
+	for _, tbl := range newTables {
+		binarywrite(&fontfile, []byte(tbl.tag.String()))
+		binarywrite(&fontfile, tbl.checksum)
+		binarywrite(&fontfile, tbl.offset)
+		binarywrite(&fontfile, tbl.length)
+		if tbl.tag == tagHead {
+			checksumAdjustmentOffset = int(tbl.offset) + 8
+		}
+	}
+
+	for _, tbl := range newTables {
+		binarywrite(&fontfile, tbl.tabledata)
+	}
+
+	b := fontfile.Bytes()
+	checksumFontFile := calcChecksum(b)
+	if checksumAdjustmentOffset > 0 {
+		// only if we write the head table
+		binary.BigEndian.PutUint32(b[checksumAdjustmentOffset:], checksumFontFile-0xB1B0AFBA)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// cmapEntry is a rune mapped by a cmap subtable.
+type cmapEntry struct {
+	r   rune
+	gid GID
+}
+
+// cmapEntries returns the runes mapped by the font cmap,
+// sorted by rune.
+func (fnt *Font) cmapEntries() []cmapEntry {
+	var out []cmapEntry
+	if fnt.cmap == nil {
+		return nil
+	}
+	iter := fnt.cmap.Iter()
+	for iter.Next() {
+		r, gid := iter.Char()
+		out = append(out, cmapEntry{r, gid})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].r < out[j].r })
+	return out
+}
+
+// writeCmap writes a cmap table with a format 4 subtable for the BMP, and
+// a format 12 subtable if some runes are outside the BMP or if the format 4 subtable
+// is too large. Fonts whose cmap is not Unicode are written with a Windows symbol subtable.
+func (fnt *Font) writeCmap(w io.Writer) error {
+	entries := fnt.cmapEntries()
+
+	var bmp []cmapEntry
+	for _, e := range entries {
+		if e.r < 0xFFFF {
+			bmp = append(bmp, e)
+		}
+	}
+	format4 := cmapFormat4(bmp)
+	var format12 []byte
+	if fnt.cmapEncoding != fonts.EncUnicode {
+		if format4 == nil {
+			return errors.New("symbol cmap too large")
+		}
+	} else if len(bmp) != len(entries) || format4 == nil {
+		format12 = cmapFormat12(entries)
+	}
+
+	type encodingRecord struct {
+		platform PlatformID
+		encoding PlatformEncodingID
+		subtable []byte
+	}
+	var records []encodingRecord
+	if fnt.cmapEncoding != fonts.EncUnicode {
+		records = append(records, encodingRecord{PlatformMicrosoft, PEMicrosoftSymbolCs, format4})
+	} else {
+		if format4 != nil {
+			records = append(records, encodingRecord{PlatformUnicode, PEUnicodeBMP, format4})
+		}
+		if format12 != nil {
+			records = append(records, encodingRecord{PlatformUnicode, PEUnicodeFull, format12})
+		}
+		if format4 != nil {
+			records = append(records, encodingRecord{PlatformMicrosoft, PEMicrosoftUnicodeCs, format4})
+		}
+		if format12 != nil {
+			records = append(records, encodingRecord{PlatformMicrosoft, PEMicrosoftUcs4, format12})
+		}
+	}
+
+	binarywrite(w, uint16(0)) // version
+	binarywrite(w, uint16(len(records)))
+	offset4 := uint32(4 + 8*len(records))
+	offset12 := offset4 + uint32(len(format4))
+	for _, record := range records {
+		binarywrite(w, record.platform)
+		binarywrite(w, record.encoding)
+		if bytes.Equal(record.subtable, format4) {
+			binarywrite(w, offset4)
+		} else {
+			binarywrite(w, offset12)
+		}
+	}
+	if _, err := w.Write(format4); err != nil {
+		return err
+	}
+	_, err := w.Write(format12)
+	return err
+}
+
+// cmapFormat4 returns a format 4 subtable for the given entries, sorted by rune,
+// or nil if the subtable is too large.
+func cmapFormat4(entries []cmapEntry) []byte {
+	type segment struct {
+		start, end rune
+		glyphs     []GID
+	}
+	var segments []segment
+	for _, e := range entries {
+		if L := len(segments); L != 0 && segments[L-1].end+1 == e.r {
+			segments[L-1].end = e.r
+			segments[L-1].glyphs = append(segments[L-1].glyphs, e.gid)
+			continue
+		}
+		segments = append(segments, segment{start: e.r, end: e.r, glyphs: []GID{e.gid}})
+	}
+	// the last segment is required
+	segments = append(segments, segment{start: 0xFFFF, end: 0xFFFF, glyphs: []GID{0}})
+
+	segCount := len(segments)
+	var (
+		idDeltas, idRangeOffsets, glyphIDArray []uint16
+	)
+	for i, seg := range segments {
+		delta := seg.glyphs[0] - GID(seg.start)
+		useDelta := true
+		for j, g := range seg.glyphs {
+			if g-GID(seg.start)-GID(j) != delta {
+				useDelta = false
+				break
+			}
+		}
+		if useDelta {
+			idDeltas = append(idDeltas, uint16(delta))
+			idRangeOffsets = append(idRangeOffsets, 0)
+			continue
+		}
+		// the offset is relative to the idRangeOffset entry
+		idDeltas = append(idDeltas, 0)
+		idRangeOffsets = append(idRangeOffsets, uint16(2*(segCount-i+len(glyphIDArray))))
+		for _, g := range seg.glyphs {
+			glyphIDArray = append(glyphIDArray, uint16(g))
+		}
+	}
+
+	length := 16 + 8*segCount + 2*len(glyphIDArray)
+	if length > 0xFFFF {
+		return nil
+	}
+	entrySelector := int(math.Floor(math.Log2(float64(segCount))))
+	searchRange := 2 << entrySelector
+
+	var buf bytes.Buffer
+	for _, v := range []uint16{4, uint16(length), 0, uint16(2 * segCount), uint16(searchRange),
+		uint16(entrySelector), uint16(2*segCount - searchRange)} {
+		binarywrite(&buf, v)
+	}
+	for _, seg := range segments {
+		binarywrite(&buf, uint16(seg.end))
+	}
+	binarywrite(&buf, uint16(0)) // reserved
+	for _, seg := range segments {
+		binarywrite(&buf, uint16(seg.start))
+	}
+	binarywrite(&buf, idDeltas)
+	binarywrite(&buf, idRangeOffsets)
+	binarywrite(&buf, glyphIDArray)
+	return buf.Bytes()
+}
+
+// cmapFormat12 returns a format 12 subtable for the given entries, sorted by rune.
+func cmapFormat12(entries []cmapEntry) []byte {
+	type group struct {
+		start, end, startGlyph uint32
+	}
+	var groups []group
+	for _, e := range entries {
+		if L := len(groups); L != 0 && groups[L-1].end+1 == uint32(e.r) &&
+			groups[L-1].startGlyph+groups[L-1].end+1-groups[L-1].start == uint32(e.gid) {
+			groups[L-1].end++
+			continue
+		}
+		groups = append(groups, group{uint32(e.r), uint32(e.r), uint32(e.gid)})
+	}
+
+	var buf bytes.Buffer
+	binarywrite(&buf, uint16(12))
+	binarywrite(&buf, uint16(0)) // reserved
+	binarywrite(&buf, uint32(16+12*len(groups)))
+	binarywrite(&buf, uint32(0)) // language
+	binarywrite(&buf, uint32(len(groups)))
+	for _, g := range groups {
+		binarywrite(&buf, g)
+	}
+	return buf.Bytes()
+}
+
+// writePost writes a version 2 table if the font has glyph names,
+// and a version 3 table otherwise.
+func (fnt *Font) writePost(w io.Writer) error {
+	post := fnt.post
+	version := uint32(0x30000)
+	if post.Names != nil {
+		version = 0x20000
+	}
+	isFixedPitch := uint32(0)
+	if post.IsFixedPitch {
+		isFixedPitch = 1
+	}
+	binarywrite(w, version)
+	binarywrite(w, int32(math.Round(post.ItalicAngle*0x10000)))
+	binarywrite(w, post.UnderlinePosition)
+	binarywrite(w, post.UnderlineThickness)
+	binarywrite(w, isFixedPitch)
+	binarywrite(w, [4]uint32{}) // memory usage
+	if post.Names == nil {
+		return nil
+	}
+
+	names, ok := post.Names.(postNamesFormat20)
+	if !ok || len(names.glyphNameIndexes) != fnt.NumGlyphs {
+		list := make([]string, fnt.NumGlyphs)
+		for gid := range list {
+			list[gid] = post.Names.GlyphName(GID(gid))
+		}
+		names = newPostNamesFormat20(list)
+	}
+	binarywrite(w, uint16(len(names.glyphNameIndexes)))
+	binarywrite(w, names.glyphNameIndexes)
+	for _, name := range names.names {
+		if len(name) > 255 {
+			return fmt.Errorf("glyph name too long: %s", name)
+		}
+		binarywrite(w, uint8(len(name)))
+		binarywrite(w, []byte(name))
+	}
+	return nil
+}
+
+// newPostNamesFormat20 stores the glyph names `names`, indexed by glyph,
+// using the standard Macintosh names when possible.
+// Empty names are replaced by .notdef.
+func newPostNamesFormat20(names []string) postNamesFormat20 {
+	indices := make(map[string]uint16, numBuiltInPostNames)
+	for i, name := range builtInPostNames[:] {
+		indices[name] = uint16(i)
+	}
+	out := postNamesFormat20{glyphNameIndexes: make([]uint16, len(names))}
+	for gid, name := range names {
+		if name == "" {
+			continue // index 0 is .notdef
+		}
+		index, ok := indices[name]
+		if !ok {
+			index = uint16(numBuiltInPostNames + len(out.names))
+			indices[name] = index
+			out.names = append(out.names, name)
+		}
+		out.glyphNameIndexes[gid] = index
+	}
+	return out
+}
+
+// writeName writes a format 0 table, with the records sorted
+// as required by the specification.
+func (fnt *Font) writeName(w io.Writer) error {
+	entries := append(TableName(nil), fnt.Names...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		ei, ej := entries[i], entries[j]
+		if ei.PlatformID != ej.PlatformID {
+			return ei.PlatformID < ej.PlatformID
+		}
+		if ei.EncodingID != ej.EncodingID {
+			return ei.EncodingID < ej.EncodingID
+		}
+		if ei.LanguageID != ej.LanguageID {
+			return ei.LanguageID < ej.LanguageID
+		}
+		return ei.NameID < ej.NameID
+	})
+
+	header := nameHeader{Count: uint16(len(entries)), StringOffset: uint16(6 + 12*len(entries))}
+	binarywrite(w, header)
+	offset := 0
+	for _, e := range entries {
+		if offset+len(e.Value) > 0xFFFF {
+			return errors.New("name table too large")
+		}
+		binarywrite(w, nameRecord{
+			PlatformID: e.PlatformID,
+			EncodingID: e.EncodingID,
+			LanguageID: e.LanguageID,
+			NameID:     e.NameID,
+			Length:     uint16(len(e.Value)),
+			Offset:     uint16(offset),
+		})
+		offset += len(e.Value)
+	}
+	for _, e := range entries {
+		if _, err := w.Write(e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOS2 writes the fields used by the version of the table.
+func (fnt *Font) writeOS2(w io.Writer) error {
+	var data interface{}
+	switch fnt.OS2.Version {
+	case 0:
+		data = fnt.OS2.TableOS2Version0
+	case 1:
+		data = fnt.OS2.TableOS2Version1
+	case 2, 3, 4:
+		data = fnt.OS2.TableOS2Version4
+	default:
+		data = fnt.OS2
+	}
+	return binarywrite(w, data)
+}
+
+// writeLayoutTable writes one of the GSUB, GPOS or GDEF tables.
+func (fnt *Font) writeLayoutTable(w io.Writer, t Tag) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch t {
+	case TagGsub:
+		data, err = fnt.layoutTables.GSUB.writeGSUB()
+	case TagGpos:
+		data, err = fnt.layoutTables.GPOS.writeGPOS()
+	case TagGdef:
+		data, err = fnt.layoutTables.GDEF.writeGDEF()
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s table: %s", t, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,846 @@
+package truetype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// This file implements the serialization of the advanced layout tables (GSUB, GPOS, GDEF).
+
+var errOffsetOverflow = errors.New("offset overflow in layout table")
+
+// tableWriter builds a table made of a header, followed by the sub-tables
+// it references. The sub-tables are only placed when calling bytes(), so that
+// offsets may be reserved at any point in the header.
+type tableWriter struct {
+	errp  *error // shared by a tree of tables
+	data  []byte
+	links []tableLink
+	pos   int // position in the final output
+}
+
+type tableLink struct {
+	child *tableWriter
+	pos   int  // position of the offset in the parent
+	wide  bool // 32-bit offset
+}
+
+func newTableWriter() *tableWriter { return &tableWriter{errp: new(error)} }
+
+// child returns a new table, sharing the error state of `tw`
+func (tw *tableWriter) child() *tableWriter { return &tableWriter{errp: tw.errp} }
+
+func (tw *tableWriter) fail(err error) {
+	if *tw.errp == nil {
+		*tw.errp = err
+	}
+}
+
+func (tw *tableWriter) uint16(v uint16) {
+	tw.data = append(tw.data, byte(v>>8), byte(v))
+}
+
+func (tw *tableWriter) int16(v int16) { tw.uint16(uint16(v)) }
+
+func (tw *tableWriter) uint32(v uint32) {
+	tw.data = append(tw.data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (tw *tableWriter) uint16s(vs []uint16) {
+	for _, v := range vs {
+		tw.uint16(v)
+	}
+}
+
+// count writes the length of an array, which must fit in an uint16
+func (tw *tableWriter) count(n int) {
+	if n > 0xFFFF {
+		tw.fail(fmt.Errorf("too many elements in layout table (%d)", n))
+	}
+	tw.uint16(uint16(n))
+}
+
+// link writes a 16-bit offset to `child`, which may be nil
+// for a NULL offset.
+func (tw *tableWriter) link(child *tableWriter) {
+	if child != nil {
+		tw.links = append(tw.links, tableLink{child: child, pos: len(tw.data)})
+	}
+	tw.uint16(0)
+}
+
+// link32 writes a 32-bit offset to `child`, which may be nil
+// for a NULL offset.
+// The tables referenced by 32-bit offsets are placed after the
+// other ones, so that they don't cause 16-bit offsets to overflow.
+func (tw *tableWriter) link32(child *tableWriter) {
+	if child != nil {
+		tw.links = append(tw.links, tableLink{child: child, pos: len(tw.data), wide: true})
+	}
+	tw.uint32(0)
+}
+
+// bytes places the sub-tables and resolves the offsets.
+// Tables are not shared, so that the output may contain duplicate data.
+func (tw *tableWriter) bytes() ([]byte, error) {
+	var (
+		out      []byte
+		placed   []*tableWriter
+		deferred []*tableWriter
+	)
+	var place func(t *tableWriter)
+	place = func(t *tableWriter) {
+		t.pos = len(out)
+		out = append(out, t.data...)
+		placed = append(placed, t)
+		for _, l := range t.links {
+			if l.wide {
+				deferred = append(deferred, l.child)
+			} else {
+				place(l.child)
+			}
+		}
+	}
+	place(tw)
+	for i := 0; i < len(deferred); i++ { // deferred may grow during the iteration
+		place(deferred[i])
+	}
+
+	for _, t := range placed {
+		for _, l := range t.links {
+			offset := l.child.pos - t.pos
+			if l.wide {
+				binary.BigEndian.PutUint32(out[t.pos+l.pos:], uint32(offset))
+			} else {
+				if offset > 0xFFFF {
+					tw.fail(errOffsetOverflow)
+				}
+				binary.BigEndian.PutUint16(out[t.pos+l.pos:], uint16(offset))
+			}
+		}
+	}
+	return out, *tw.errp
+}
+
+// coverageGlyphs returns the glyphs covered by `cov`, in coverage order.
+func coverageGlyphs(cov Coverage) []GID {
+	switch cov := cov.(type) {
+	case CoverageList:
+		return cov
+	case CoverageRanges:
+		out := make([]GID, cov.Size())
+		for _, r := range cov {
+			for g := r.Start; g <= r.End && g >= r.Start; g++ {
+				if index := r.StartCoverage + int(g-r.Start); index < len(out) {
+					out[index] = g
+				}
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func (tw *tableWriter) coverage(cov Coverage) *tableWriter {
+	out := tw.child()
+	glyphs := coverageGlyphs(cov)
+
+	// choose the smallest format
+	var ranges []CoverageRange
+	for i, g := range glyphs {
+		if L := len(ranges); L != 0 && ranges[L-1].End+1 == g {
+			ranges[L-1].End = g
+			continue
+		}
+		ranges = append(ranges, CoverageRange{Start: g, End: g, StartCoverage: i})
+	}
+	if 6*len(ranges) < 2*len(glyphs) {
+		out.uint16(2)
+		out.count(len(ranges))
+		for _, r := range ranges {
+			out.uint16(uint16(r.Start))
+			out.uint16(uint16(r.End))
+			out.uint16(uint16(r.StartCoverage))
+		}
+	} else {
+		out.uint16(1)
+		out.count(len(glyphs))
+		for _, g := range glyphs {
+			out.uint16(uint16(g))
+		}
+	}
+	return out
+}
+
+// class writes the class definition, which may be nil,
+// and is then written as an empty table.
+func (tw *tableWriter) class(class Class) *tableWriter {
+	out := tw.child()
+	var ranges classFormat2
+	switch class := class.(type) {
+	case nil:
+	case classFormat2:
+		ranges = class
+	case classFormat1:
+		for i, c := range class.classIDs {
+			g := gid(class.startGlyph) + gid(i)
+			if L := len(ranges); L != 0 && ranges[L-1].end+1 == g && ranges[L-1].targetClassID == c {
+				ranges[L-1].end = g
+				continue
+			}
+			if c != 0 {
+				ranges = append(ranges, classRangeRecord{start: g, end: g, targetClassID: c})
+			}
+		}
+	default:
+		tw.fail(fmt.Errorf("unsupported class definition %T", class))
+	}
+	out.uint16(2)
+	out.count(len(ranges))
+	for _, r := range ranges {
+		out.uint16(r.start)
+		out.uint16(r.end)
+		out.uint16(uint16(r.targetClassID))
+	}
+	return out
+}
+
+// device returns nil for a nil device table.
+func (tw *tableWriter) device(dev DeviceTable) *tableWriter {
+	switch dev := dev.(type) {
+	case DeviceHinting:
+		out := tw.child()
+		// select the smallest format able to store the values
+		format, bitsPerValue := uint16(1), 2
+		for _, v := range dev.Values {
+			if v < -8 || v > 7 {
+				format, bitsPerValue = 3, 8
+				break
+			} else if v < -2 || v > 1 {
+				format, bitsPerValue = 2, 4
+			}
+		}
+		out.uint16(dev.StartSize)
+		out.uint16(dev.EndSize)
+		out.uint16(format)
+		perUint16 := 16 / bitsPerValue
+		mask := uint16(1)<<bitsPerValue - 1
+		for i := 0; i < len(dev.Values); i += perUint16 {
+			var packed uint16
+			for j := 0; j < perUint16; j++ {
+				packed <<= bitsPerValue
+				if i+j < len(dev.Values) {
+					packed |= uint16(dev.Values[i+j]) & mask
+				}
+			}
+			out.uint16(packed)
+		}
+		return out
+	case DeviceVariation:
+		out := tw.child()
+		out.uint16(dev.DeltaSetOuter)
+		out.uint16(dev.DeltaSetInner)
+		out.uint16(0x8000)
+		return out
+	default:
+		return nil
+	}
+}
+
+// valueRecord writes the fields selected by `format` in `tw`,
+// the device tables being sub-tables of `tw`.
+func (tw *tableWriter) valueRecord(format GPOSValueFormat, v GPOSValueRecord) {
+	if format&XPlacement != 0 {
+		tw.int16(v.XPlacement)
+	}
+	if format&YPlacement != 0 {
+		tw.int16(v.YPlacement)
+	}
+	if format&XAdvance != 0 {
+		tw.int16(v.XAdvance)
+	}
+	if format&YAdvance != 0 {
+		tw.int16(v.YAdvance)
+	}
+	if format&XPlaDevice != 0 {
+		tw.link(tw.device(v.XPlaDevice))
+	}
+	if format&YPlaDevice != 0 {
+		tw.link(tw.device(v.YPlaDevice))
+	}
+	if format&XAdvDevice != 0 {
+		tw.link(tw.device(v.XAdvDevice))
+	}
+	if format&YAdvDevice != 0 {
+		tw.link(tw.device(v.YAdvDevice))
+	}
+}
+
+// anchor returns nil for a nil anchor.
+func (tw *tableWriter) anchor(anchor GPOSAnchor) *tableWriter {
+	out := tw.child()
+	switch anchor := anchor.(type) {
+	case GPOSAnchorFormat1:
+		out.uint16(1)
+		out.int16(anchor.X)
+		out.int16(anchor.Y)
+	case GPOSAnchorFormat2:
+		out.uint16(2)
+		out.int16(anchor.X)
+		out.int16(anchor.Y)
+		out.uint16(anchor.AnchorPoint)
+	case GPOSAnchorFormat3:
+		out.uint16(3)
+		out.int16(anchor.X)
+		out.int16(anchor.Y)
+		out.link(out.device(anchor.XDevice))
+		out.link(out.device(anchor.YDevice))
+	default:
+		return nil
+	}
+	return out
+}
+
+// layoutTable writes the common header, scripts and features of GSUB and GPOS,
+// the lookups being written by `writeLookup`.
+// The feature params and feature variations are not supported.
+func (tw *tableWriter) layoutTable(t TableLayout, lookupCount int, writeLookup func(lookups *tableWriter, index int) *tableWriter) {
+	tw.uint16(1) // major version
+	tw.uint16(0) // minor version
+
+	scripts := tw.child()
+	scripts.count(len(t.Scripts))
+	for _, script := range t.Scripts {
+		s := scripts.child()
+		s.link(s.langSys(script.DefaultLanguage))
+		s.count(len(script.Languages))
+		for i, lang := range script.Languages {
+			s.uint32(uint32(lang.Tag))
+			s.link(s.langSys(&script.Languages[i]))
+		}
+		scripts.uint32(uint32(script.Tag))
+		scripts.link(s)
+	}
+	tw.link(scripts)
+
+	features := tw.child()
+	features.count(len(t.Features))
+	for _, feature := range t.Features {
+		f := features.child()
+		f.uint16(0) // feature params
+		f.count(len(feature.LookupIndices))
+		f.uint16s(feature.LookupIndices)
+		features.uint32(uint32(feature.Tag))
+		features.link(f)
+	}
+	tw.link(features)
+
+	lookups := tw.child()
+	lookups.count(lookupCount)
+	for i := 0; i < lookupCount; i++ {
+		lookups.link(writeLookup(lookups, i))
+	}
+	tw.link(lookups)
+}
+
+func (tw *tableWriter) langSys(lang *LangSys) *tableWriter {
+	if lang == nil {
+		return nil
+	}
+	out := tw.child()
+	out.uint16(0) // lookup order
+	out.uint16(lang.RequiredFeatureIndex)
+	out.count(len(lang.Features))
+	out.uint16s(lang.Features)
+	return out
+}
+
+// lookupHeader writes the header of a lookup table, whose subtables
+// are given by `subtables`. If `extensionType` is not zero, extension subtables
+// are used to reference the actual subtables.
+func (tw *tableWriter) lookupHeader(kind uint16, options LookupOptions, subtables []*tableWriter, extensionType uint16) {
+	if extensionType != 0 {
+		kind, extensionType = extensionType, kind
+	}
+	tw.uint16(kind)
+	tw.uint16(options.Flag)
+	tw.count(len(subtables))
+	for _, subtable := range subtables {
+		if extensionType != 0 {
+			ext := tw.child()
+			ext.uint16(1) // format
+			ext.uint16(extensionType)
+			ext.link32(subtable)
+			subtable = ext
+		}
+		tw.link(subtable)
+	}
+	if options.Flag&UseMarkFilteringSet != 0 {
+		tw.uint16(options.MarkFilteringSet)
+	}
+}
+
+func (tw *tableWriter) sequenceLookups(lookups []SequenceLookup) {
+	for _, l := range lookups {
+		tw.uint16(l.InputIndex)
+		tw.uint16(l.LookupIndex)
+	}
+}
+
+func (tw *tableWriter) sequenceRuleSet(rules []SequenceRule) *tableWriter {
+	out := tw.child()
+	out.count(len(rules))
+	for _, rule := range rules {
+		r := out.child()
+		r.count(len(rule.Input) + 1)
+		r.count(len(rule.Lookups))
+		r.uint16s(rule.Input)
+		r.sequenceLookups(rule.Lookups)
+		out.link(r)
+	}
+	return out
+}
+
+func (tw *tableWriter) chainedSequenceRuleSet(rules []ChainedSequenceRule) *tableWriter {
+	out := tw.child()
+	out.count(len(rules))
+	for _, rule := range rules {
+		r := out.child()
+		r.count(len(rule.Backtrack))
+		r.uint16s(rule.Backtrack)
+		r.count(len(rule.Input) + 1)
+		r.uint16s(rule.Input)
+		r.count(len(rule.Lookahead))
+		r.uint16s(rule.Lookahead)
+		r.count(len(rule.Lookups))
+		r.sequenceLookups(rule.Lookups)
+		out.link(r)
+	}
+	return out
+}
+
+func (tw *tableWriter) coverages(covs []Coverage) {
+	tw.count(len(covs))
+	for _, cov := range covs {
+		tw.link(tw.coverage(cov))
+	}
+}
+
+// context writes the contextual subtables, shared by GSUB and GPOS,
+// returning false for other kinds of data.
+func (tw *tableWriter) context(cov Coverage, data interface{}) bool {
+	switch data := data.(type) {
+	case GSUBContext1:
+		tw.context1(cov, LookupContext1(data))
+	case GPOSContext1:
+		tw.context1(cov, LookupContext1(data))
+	case GSUBContext2:
+		tw.context2(cov, LookupContext2(data))
+	case GPOSContext2:
+		tw.context2(cov, LookupContext2(data))
+	case GSUBContext3:
+		tw.context3(LookupContext3(data))
+	case GPOSContext3:
+		tw.context3(LookupContext3(data))
+	case GSUBChainedContext1:
+		tw.chainedContext1(cov, LookupChainedContext1(data))
+	case GPOSChainedContext1:
+		tw.chainedContext1(cov, LookupChainedContext1(data))
+	case GSUBChainedContext2:
+		tw.chainedContext2(cov, LookupChainedContext2(data))
+	case GPOSChainedContext2:
+		tw.chainedContext2(cov, LookupChainedContext2(data))
+	case GSUBChainedContext3:
+		tw.chainedContext3(LookupChainedContext3(data))
+	case GPOSChainedContext3:
+		tw.chainedContext3(LookupChainedContext3(data))
+	default:
+		return false
+	}
+	return true
+}
+
+func (tw *tableWriter) context1(cov Coverage, data LookupContext1) {
+	tw.uint16(1)
+	tw.link(tw.coverage(cov))
+	tw.count(len(data))
+	for _, rules := range data {
+		tw.link(tw.sequenceRuleSet(rules))
+	}
+}
+
+func (tw *tableWriter) context2(cov Coverage, data LookupContext2) {
+	tw.uint16(2)
+	tw.link(tw.coverage(cov))
+	tw.link(tw.class(data.Class))
+	tw.count(len(data.SequenceSets))
+	for _, rules := range data.SequenceSets {
+		if len(rules) == 0 {
+			tw.link(nil)
+		} else {
+			tw.link(tw.sequenceRuleSet(rules))
+		}
+	}
+}
+
+func (tw *tableWriter) context3(data LookupContext3) {
+	tw.uint16(3)
+	tw.count(len(data.Coverages))
+	tw.count(len(data.SequenceLookups))
+	for _, cov := range data.Coverages {
+		tw.link(tw.coverage(cov))
+	}
+	tw.sequenceLookups(data.SequenceLookups)
+}
+
+func (tw *tableWriter) chainedContext1(cov Coverage, data LookupChainedContext1) {
+	tw.uint16(1)
+	tw.link(tw.coverage(cov))
+	tw.count(len(data))
+	for _, rules := range data {
+		tw.link(tw.chainedSequenceRuleSet(rules))
+	}
+}
+
+func (tw *tableWriter) chainedContext2(cov Coverage, data LookupChainedContext2) {
+	tw.uint16(2)
+	tw.link(tw.coverage(cov))
+	tw.link(tw.class(data.BacktrackClass))
+	tw.link(tw.class(data.InputClass))
+	tw.link(tw.class(data.LookaheadClass))
+	tw.count(len(data.SequenceSets))
+	for _, rules := range data.SequenceSets {
+		if len(rules) == 0 {
+			tw.link(nil)
+		} else {
+			tw.link(tw.chainedSequenceRuleSet(rules))
+		}
+	}
+}
+
+func (tw *tableWriter) chainedContext3(data LookupChainedContext3) {
+	tw.uint16(3)
+	tw.coverages(data.Backtrack)
+	tw.coverages(data.Input)
+	tw.coverages(data.Lookahead)
+	tw.count(len(data.SequenceLookups))
+	tw.sequenceLookups(data.SequenceLookups)
+}
+
+// glyphArrays writes the common structure of the multiple and alternate substitutions.
+func (tw *tableWriter) glyphArrays(cov Coverage, arrays [][]GID) {
+	tw.uint16(1)
+	tw.link(tw.coverage(cov))
+	tw.count(len(arrays))
+	for _, glyphs := range arrays {
+		seq := tw.child()
+		seq.count(len(glyphs))
+		for _, g := range glyphs {
+			seq.uint16(uint16(g))
+		}
+		tw.link(seq)
+	}
+}
+
+func (tw *tableWriter) gsubSubtable(st GSUBSubtable) *tableWriter {
+	out := tw.child()
+	switch data := st.Data.(type) {
+	case GSUBSingle1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.int16(int16(data))
+	case GSUBSingle2:
+		out.uint16(2)
+		out.link(out.coverage(st.Coverage))
+		out.count(len(data))
+		for _, g := range data {
+			out.uint16(uint16(g))
+		}
+	case GSUBMultiple1:
+		out.glyphArrays(st.Coverage, data)
+	case GSUBAlternate1:
+		out.glyphArrays(st.Coverage, data)
+	case GSUBLigature1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.count(len(data))
+		for _, ligs := range data {
+			set := out.child()
+			set.count(len(ligs))
+			for _, lig := range ligs {
+				l := set.child()
+				l.uint16(uint16(lig.Glyph))
+				l.count(len(lig.Components) + 1)
+				l.uint16s(lig.Components)
+				set.link(l)
+			}
+			out.link(set)
+		}
+	case GSUBReverseChainedContext1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.coverages(data.Backtrack)
+		out.coverages(data.Lookahead)
+		out.count(len(data.Substitutes))
+		for _, g := range data.Substitutes {
+			out.uint16(uint16(g))
+		}
+	default:
+		if !out.context(st.Coverage, data) {
+			tw.fail(fmt.Errorf("unsupported GSUB subtable %T", data))
+		}
+	}
+	return out
+}
+
+// markArray writes a mark array with its anchors.
+func (tw *tableWriter) markArray(marks []GPOSMark) *tableWriter {
+	out := tw.child()
+	out.count(len(marks))
+	for _, mark := range marks {
+		out.uint16(mark.ClassValue)
+		out.link(out.anchor(mark.Anchor))
+	}
+	return out
+}
+
+// anchorMatrix writes the base array of mark-to-base and mark-to-mark subtables,
+// as well as the ligature attach tables.
+func (tw *tableWriter) anchorMatrix(rows [][]GPOSAnchor) *tableWriter {
+	out := tw.child()
+	out.count(len(rows))
+	for _, row := range rows {
+		for _, anchor := range row {
+			out.link(out.anchor(anchor))
+		}
+	}
+	return out
+}
+
+// markClassCount returns the number of mark classes, used
+// to size the anchor matrix
+func markClassCount(marks []GPOSMark, rows [][]GPOSAnchor) int {
+	if len(rows) != 0 {
+		return len(rows[0])
+	}
+	count := 0
+	for _, mark := range marks {
+		if int(mark.ClassValue) >= count {
+			count = int(mark.ClassValue) + 1
+		}
+	}
+	return count
+}
+
+func (tw *tableWriter) gposSubtable(st GPOSSubtable) *tableWriter {
+	out := tw.child()
+	switch data := st.Data.(type) {
+	case GPOSSingle1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.uint16(uint16(data.Format))
+		out.valueRecord(data.Format, data.Value)
+	case GPOSSingle2:
+		out.uint16(2)
+		out.link(out.coverage(st.Coverage))
+		out.uint16(uint16(data.Format))
+		out.count(len(data.Values))
+		for _, v := range data.Values {
+			out.valueRecord(data.Format, v)
+		}
+	case GPOSPair1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.uint16(uint16(data.Formats[0]))
+		out.uint16(uint16(data.Formats[1]))
+		out.count(len(data.Values))
+		for _, set := range data.Values {
+			s := out.child()
+			s.count(len(set))
+			for _, record := range set {
+				s.uint16(uint16(record.SecondGlyph))
+				s.valueRecord(data.Formats[0], record.Pos[0])
+				s.valueRecord(data.Formats[1], record.Pos[1])
+			}
+			out.link(s)
+		}
+	case GPOSPair2:
+		out.uint16(2)
+		out.link(out.coverage(st.Coverage))
+		out.uint16(uint16(data.Formats[0]))
+		out.uint16(uint16(data.Formats[1]))
+		out.link(out.class(data.First))
+		out.link(out.class(data.Second))
+		class2Count := 0
+		if len(data.Values) != 0 {
+			class2Count = len(data.Values[0])
+		}
+		out.count(len(data.Values))
+		out.count(class2Count)
+		for _, row := range data.Values {
+			for _, v := range row {
+				out.valueRecord(data.Formats[0], v[0])
+				out.valueRecord(data.Formats[1], v[1])
+			}
+		}
+	case GPOSCursive1:
+		out.uint16(1)
+		out.link(out.coverage(st.Coverage))
+		out.count(len(data))
+		for _, entryExit := range data {
+			out.link(out.anchor(entryExit[0]))
+			out.link(out.anchor(entryExit[1]))
+		}
+	case GPOSMarkToBase1:
+		out.markAttachment(st.Coverage, data.BaseCoverage, data.Marks, out.anchorMatrix(data.Bases), markClassCount(data.Marks, data.Bases))
+	case GPOSMarkToMark1:
+		out.markAttachment(st.Coverage, data.Mark2Coverage, data.Marks1, out.anchorMatrix(data.Marks2), markClassCount(data.Marks1, data.Marks2))
+	case GPOSMarkToLigature1:
+		classCount := markClassCount(data.Marks, nil)
+		ligs := out.child()
+		ligs.count(len(data.Ligatures))
+		for _, components := range data.Ligatures {
+			ligs.link(ligs.anchorMatrix(components))
+			if len(components) != 0 {
+				classCount = len(components[0])
+			}
+		}
+		out.markAttachment(st.Coverage, data.LigatureCoverage, data.Marks, ligs, classCount)
+	default:
+		if !out.context(st.Coverage, data) {
+			tw.fail(fmt.Errorf("unsupported GPOS subtable %T", data))
+		}
+	}
+	return out
+}
+
+// markAttachment writes the common structure of mark attachment subtables.
+func (tw *tableWriter) markAttachment(markCov, baseCov Coverage, marks []GPOSMark, bases *tableWriter, classCount int) {
+	tw.uint16(1)
+	tw.link(tw.coverage(markCov))
+	tw.link(tw.coverage(baseCov))
+	tw.count(classCount)
+	tw.link(tw.markArray(marks))
+	tw.link(bases)
+}
+
+// writeLayout serializes a GSUB or GPOS table, using extension
+// subtables if needed to avoid offset overflows.
+func writeLayout(write func(tw *tableWriter, extension bool)) ([]byte, error) {
+	tw := newTableWriter()
+	write(tw, false)
+	out, err := tw.bytes()
+	if err != errOffsetOverflow {
+		return out, err
+	}
+	tw = newTableWriter()
+	write(tw, true)
+	return tw.bytes()
+}
+
+// writeGSUB serializes the table.
+func (t *TableGSUB) writeGSUB() ([]byte, error) {
+	return writeLayout(func(tw *tableWriter, extension bool) {
+		var extensionType uint16
+		if extension {
+			extensionType = uint16(gsubExtension)
+		}
+		tw.layoutTable(t.TableLayout, len(t.Lookups), func(lookups *tableWriter, index int) *tableWriter {
+			lookup := t.Lookups[index]
+			out := lookups.child()
+			subtables := make([]*tableWriter, len(lookup.Subtables))
+			for i, st := range lookup.Subtables {
+				subtables[i] = out.gsubSubtable(st)
+			}
+			out.lookupHeader(uint16(lookup.Type), lookup.LookupOptions, subtables, extensionType)
+			return out
+		})
+	})
+}
+
+// writeGPOS serializes the table.
+func (t *TableGPOS) writeGPOS() ([]byte, error) {
+	return writeLayout(func(tw *tableWriter, extension bool) {
+		var extensionType uint16
+		if extension {
+			extensionType = uint16(gposExtension)
+		}
+		tw.layoutTable(t.TableLayout, len(t.Lookups), func(lookups *tableWriter, index int) *tableWriter {
+			lookup := t.Lookups[index]
+			out := lookups.child()
+			subtables := make([]*tableWriter, len(lookup.Subtables))
+			for i, st := range lookup.Subtables {
+				subtables[i] = out.gposSubtable(st)
+			}
+			out.lookupHeader(uint16(lookup.Type), lookup.LookupOptions, subtables, extensionType)
+			return out
+		})
+	})
+}
+
+// writeGDEF serializes the table. The attachment point list and
+// the variation store are not supported.
+func (t *TableGDEF) writeGDEF() ([]byte, error) {
+	tw := newTableWriter()
+	tw.uint16(1) // major version
+	if t.MarkGlyphSet != nil {
+		tw.uint16(2)
+	} else {
+		tw.uint16(0)
+	}
+	if t.Class != nil {
+		tw.link(tw.class(t.Class))
+	} else {
+		tw.link(nil)
+	}
+	tw.link(nil) // attachment point list
+
+	if carets := t.LigatureCaretList; carets.Coverage != nil {
+		list := tw.child()
+		list.link(list.coverage(carets.Coverage))
+		list.count(len(carets.LigCarets))
+		for _, values := range carets.LigCarets {
+			lig := list.child()
+			lig.count(len(values))
+			for _, value := range values {
+				v := lig.child()
+				switch value := value.(type) {
+				case CaretValueFormat1:
+					v.uint16(1)
+					v.int16(int16(value))
+				case CaretValueFormat2:
+					v.uint16(2)
+					v.uint16(uint16(value))
+				case CaretValueFormat3:
+					v.uint16(3)
+					v.int16(value.Coordinate)
+					v.link(v.device(value.Device))
+				}
+				lig.link(v)
+			}
+			list.link(lig)
+		}
+		tw.link(list)
+	} else {
+		tw.link(nil)
+	}
+
+	if t.MarkAttach != nil {
+		tw.link(tw.class(t.MarkAttach))
+	} else {
+		tw.link(nil)
+	}
+
+	if t.MarkGlyphSet != nil {
+		sets := tw.child()
+		sets.uint16(1) // format
+		sets.count(len(t.MarkGlyphSet))
+		for _, cov := range t.MarkGlyphSet {
+			sets.link32(sets.coverage(cov))
+		}
+		tw.link(sets)
+	}
+	return tw.bytes()
+}
@@ -25,6 +25,10 @@ type type1CharstringParser struct {
 
 	cs ps.CharstringReader
 
+	// if not nil, filled with the indices of the
+	// called subroutines
+	usedSubrs map[int32]bool
+
 	inFlex bool // alter the behavior of moveto opcodes
 
 	leftBearing, advance ps.Point
@@ -61,6 +65,9 @@ func (met *type1CharstringParser) Apply(op ps.PsOperator, state *ps.Machine) err
 		case 9: // closepath
 			met.cs.ClosePath()
 		case 10: // callsubr
+			if met.usedSubrs != nil && state.ArgStack.Top > 0 {
+				met.usedSubrs[state.ArgStack.Vals[state.ArgStack.Top-1]] = true
+			}
 			return ps.LocalSubr(state) // do not clear the arg stack
 		case 11: // return
 			return state.Return() // do not clear the arg stack
@@ -171,6 +171,7 @@ func parse(segment1, segment2 []byte) (Font, error) {
 	if err != nil {
 		return Font{}, err
 	}
+	out.program.segment1 = segment1
 	if len(segment2) > 0 {
 		p.parseBinary(segment2, &out)
 	}
@@ -248,6 +249,7 @@ func (p *parser) parseASCII(bytes []byte) (Font, error) {
 		}
 
 		// key/value
+		start := p.lexer.CurrentPosition()
 		keyT, err := p.read(tk.Name)
 		if err != nil {
 			return out, err
@@ -263,6 +265,7 @@ func (p *parser) parseASCII(bytes []byte) (Font, error) {
 			_, err = p.readSimpleDict()
 		case "Encoding":
 			out.Encoding, err = p.readEncoding()
+			out.program.encoding = [2]int{start, p.lexer.CurrentPosition()}
 		default:
 			err = p.readSimpleValue(key, &out)
 		}
@@ -270,6 +273,7 @@ func (p *parser) parseASCII(bytes []byte) (Font, error) {
 			return out, err
 		}
 	}
+	out.program.dictEnd = p.lexer.CurrentPosition()
 
 	if _, err := p.readMaybe(tk.Other, "currentdict"); err != nil {
 		return out, err
@@ -651,6 +655,7 @@ func (p *parser) readProc() ([]tk.Token, error) {
 // Parses the binary portion of a Type 1 font.
 func (p *parser) parseBinary(bytes []byte, font *Font) error {
 	decrypted := decryptSegment(bytes)
+	font.program.private = decrypted
 
 	p.lexer = newLexer(decrypted)
 
@@ -700,6 +705,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 		}
 
 		// key/value
+		start := p.lexer.CurrentPosition()
 		key, err := p.read(tk.Name)
 		if err != nil {
 			return err
@@ -708,6 +714,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 		switch string(key.Value) {
 		case "Subrs":
 			font.subrs, err = p.readSubrs(lenIV)
+			font.program.subrs = [2]int{start, p.lexer.CurrentPosition()}
 		case "OtherSubrs":
 			err = p.readOtherSubrs()
 		case "lenIV":
@@ -717,6 +724,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 			}
 			lenIV, err = vs[0].Int()
 		case "ND":
+			font.program.nd = "ND"
 			if _, err = p.read(tk.StartProc); err != nil {
 				return err
 			}
@@ -737,6 +745,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 				return err
 			}
 		case "NP":
+			font.program.np = "NP"
 			if _, err = p.read(tk.StartProc); err != nil {
 				return err
 			}
@@ -756,6 +765,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 				return err
 			}
 		case "RD":
+			font.program.rd = "RD"
 			// /RD {string currentfile exch readstring pop} bind executeonly def
 			if _, err = p.read(tk.StartProc); err != nil {
 				return err
@@ -778,7 +788,7 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 			if err != nil {
 				return err
 			}
-			err = p.readPrivate(key.Value, vs)
+			err = p.readPrivate(key.Value, vs, font)
 		}
 
 		if err != nil {
@@ -801,15 +811,34 @@ func (p *parser) parseBinary(bytes []byte, font *Font) error {
 	}
 
 	// CharStrings dict
+	start := p.lexer.CurrentPosition()
 	if err = p.readWithName(tk.Name, "CharStrings"); err != nil {
 		return err
 	}
 	font.charstrings, err = p.readCharStrings(lenIV)
+	font.program.charstrings = [2]int{start, p.lexer.CurrentPosition()}
+	font.program.lenIV = lenIV
 	return err
 }
 
 // Extracts values from the /Private dictionary.
-func (p *parser) readPrivate(key []byte, value []tk.Token) error {
+func (p *parser) readPrivate(key []byte, value []tk.Token, font *Font) error {
+	switch string(key) {
+	case "StdVW":
+		stdVW, err := p.arrayToNumbers(value)
+		if err != nil {
+			return err
+		}
+		if len(stdVW) != 0 {
+			font.stdVW = stdVW[0]
+		}
+	case "-|":
+		font.program.rd = "-|"
+	case "|-":
+		font.program.nd = "|-"
+	case "|":
+		font.program.np = "|"
+	}
 	// TODO: complete if needed
 	// 		 switch (key)
 	// 		 {
@@ -1102,6 +1131,29 @@ func decryptSegment(crypted []byte) []byte {
 	return decrypt(hexToBinary(crypted), eexecKey, 4)
 }
 
+// Type 1 Encryption (eexec, charstring), the inverse of `decrypt`.
+// `r` is the key and `n` the number of leading bytes (lenIV) to add
+// to `plain`. Zeros are used instead of random bytes, so that the
+// output is reproducible.
+func encrypt(plain []byte, r uint16, n int) []byte {
+	// lenIV of -1 means no encryption (not documented)
+	if n == -1 {
+		return append([]byte(nil), plain...)
+	}
+	const (
+		c1 uint16 = 52845
+		c2 uint16 = 22719
+	)
+	out := make([]byte, n+len(plain))
+	copy(out[n:], plain)
+	for i, c := range out {
+		c ^= byte(r >> 8)
+		out[i] = c
+		r = (uint16(c)+r)*c1 + c2
+	}
+	return out
+}
+
 // Type 1 Decryption (eexec, charstring).
 // `r` is the key and `n` the number of random bytes (lenIV)
 // the input is modified (the return slice share its storage)
@@ -0,0 +1,544 @@
+package type1
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	tk "github.com/benoitkugler/pstokenizer"
+	"github.com/boxesandglue/textlayout/fonts"
+	"github.com/boxesandglue/textlayout/fonts/glyphsnames"
+	ps "github.com/boxesandglue/textlayout/fonts/psinterpreter"
+	"github.com/boxesandglue/textlayout/fonts/simpleencodings"
+)
+
+var _ fonts.Subsetter = (*Font)(nil)
+
+// fontProgram stores the raw content of the font file,
+// needed to write subsets.
+type fontProgram struct {
+	segment1 []byte // cleartext portion
+	private  []byte // decrypted binary portion
+
+	// positions (start, end) of the /Encoding definition in segment1,
+	// and of the /Subrs and /CharStrings definitions in private
+	encoding, subrs, charstrings [2]int
+
+	// position of the end of the font dict entries in segment1,
+	// where the /Encoding of a subset is added if the font has none
+	dictEnd int
+
+	// names of the procedures defined in the Private dict,
+	// empty if not defined
+	rd, nd, np string
+
+	lenIV int
+}
+
+// subset stores the glyphs selected by Subset
+type subset struct {
+	glyphs []fonts.GID    // sorted, with .notdef and seac components
+	subrs  map[int32]bool // used subroutines
+}
+
+// Subset returns a font containing only the given glyphs, the receiver being left unchanged.
+// The .notdef glyph and the components of accented glyphs (seac) are always included.
+//
+// Since Type1 fonts are embedded as simple fonts, with at most 256 glyphs, the selected glyphs are re-encoded:
+// the Encoding field of the returned *Font is the built-in encoding of the subset, which gives the
+// character codes to use in PDF content streams. When possible, the original codes are preserved.
+// The glyphs of the subset are numbered in ascending order of their original index.
+func (f *Font) Subset(codepoints []fonts.GID) (*fonts.SubsetResult, error) {
+	var (
+		glyphs    = map[fonts.GID]bool{}
+		usedSubrs = map[int32]bool{}
+		queue     = append([]fonts.GID{0}, codepoints...)
+	)
+	for len(queue) != 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		if glyphs[gid] {
+			continue
+		}
+		if int(gid) >= len(f.charstrings) {
+			return nil, fmt.Errorf("invalid glyph index %d", gid)
+		}
+		glyphs[gid] = true
+
+		var (
+			psi    ps.Machine
+			parser = type1CharstringParser{usedSubrs: usedSubrs}
+		)
+		if err := psi.Run(f.charstrings[gid].data, f.subrs, nil, &parser); err != nil {
+			return nil, fmt.Errorf("invalid charstring for glyph %d: %s", gid, err)
+		}
+		if parser.seac != nil { // the base and accent glyphs are required
+			for _, code := range [2]int32{parser.seac.bCode, parser.seac.aCode} {
+				component, err := f.glyphIndexFromStandardCode(code)
+				if err != nil {
+					return nil, err
+				}
+				queue = append(queue, component)
+			}
+		}
+	}
+	// the first four subroutines are reserved for
+	// flex and hint replacement
+	for i := int32(0); i < 4; i++ {
+		usedSubrs[i] = true
+	}
+
+	sub := subset{subrs: usedSubrs}
+	for gid := range glyphs {
+		sub.glyphs = append(sub.glyphs, gid)
+	}
+	sort.Sort(fonts.SortByGID(sub.glyphs))
+	encoded := sub.glyphs[1:] // .notdef is not encoded
+	if len(encoded) > 256 {
+		return nil, fmt.Errorf("too many glyphs for a Type1 subset: %d (max 256)", len(encoded))
+	}
+
+	var (
+		encoding simpleencodings.Encoding
+		pending  []string
+		original map[string]byte
+	)
+	if f.Encoding != nil {
+		original = f.Encoding.NameToByte()
+	}
+	for _, gid := range encoded {
+		name := f.charstrings[gid].name
+		if code, ok := original[name]; ok && encoding[code] == "" {
+			encoding[code] = name
+		} else {
+			pending = append(pending, name)
+		}
+	}
+	code := 0
+	for _, name := range pending {
+		for encoding[code] != "" {
+			code++
+		}
+		encoding[code] = name
+	}
+
+	out := *f
+	out.subset = &sub
+	out.Encoding = &encoding
+	out.SubsetID = subsetTag(sub.glyphs)
+
+	res := fonts.SubsetResult{
+		Font:      &out,
+		GIDMap:    make(map[fonts.GID]fonts.GID, len(sub.glyphs)),
+		ToUnicode: make(map[fonts.GID][]rune),
+	}
+	for newGID, gid := range sub.glyphs {
+		res.GIDMap[gid] = fonts.GID(newGID)
+		if r, ok := glyphsnames.GlyphToRune(f.charstrings[gid].name); ok && gid != 0 {
+			res.ToUnicode[fonts.GID(newGID)] = []rune{r}
+		}
+	}
+	return &res, nil
+}
+
+// subsetTag returns a string of length 6 based on the given glyphs.
+// All returned characters are in the range A-Z.
+func subsetTag(glyphs []fonts.GID) string {
+	data := make([]byte, len(glyphs)*2)
+	for i, r := range glyphs {
+		data[i*2] = byte((r >> 8) & 0xff)
+		data[i*2+1] = byte(r & 0xff)
+	}
+
+	sum := md5.Sum(data)
+	ret := make([]rune, 6)
+	for i := 0; i < 6; i++ {
+		ret[i] = rune(sum[2*i]+sum[2*i+1])/26 + 'A'
+	}
+	return string(ret)
+}
+
+// segments returns the three portions of the font file: the cleartext portion,
+// the binary portion (eexec encrypted) and the fixed-content portion.
+// For fonts returned by Subset, only the selected glyphs are included.
+func (f *Font) segments() (cleartext, private, trailer []byte) {
+	prog := f.program
+
+	cleartext = prog.segment1
+	if f.subset != nil {
+		start, end := prog.encoding[0], prog.encoding[1]
+		if end == 0 { // no /Encoding in the font dict: add one
+			start, end = prog.dictEnd, prog.dictEnd
+		}
+		var b bytes.Buffer
+		b.Write(prog.segment1[:start])
+		b.WriteString("\n/Encoding 256 array\n0 1 255 {1 index exch /.notdef put} for\n")
+		for code, name := range f.Encoding {
+			if name != "" && name != Notdef {
+				fmt.Fprintf(&b, "dup %d /%s put\n", code, name)
+			}
+		}
+		b.WriteString("readonly def")
+		b.Write(prog.segment1[end:])
+		cleartext = b.Bytes()
+	}
+	// the binary portion starts after the end of line following eexec
+	if L := len(cleartext); L != 0 && !tk.IsAsciiWhitespace(cleartext[L-1]) {
+		cleartext = append(cleartext[:L:L], '\n')
+	}
+
+	plain := prog.private
+	// anything after closefile (like the trailer of the .pfa files) is ignored
+	if index := bytes.Index(plain[prog.charstrings[1]:], []byte("closefile")); index != -1 {
+		end := prog.charstrings[1] + index + len("closefile")
+		plain = append(plain[:end:end], '\n')
+	}
+	if f.subset != nil {
+		var b bytes.Buffer
+		subrsStart, subrsEnd := prog.subrs[0], prog.subrs[1]
+		if subrsEnd == 0 { // no subroutines
+			subrsStart, subrsEnd = prog.charstrings[0], prog.charstrings[0]
+		}
+		b.Write(plain[:subrsStart])
+		if subrsEnd != subrsStart {
+			f.writeSubrs(&b)
+		}
+		b.Write(plain[subrsEnd:prog.charstrings[0]])
+		f.writeCharStrings(&b)
+		b.Write(plain[prog.charstrings[1]:])
+		plain = b.Bytes()
+	}
+	private = encrypt(plain, eexecKey, 4)
+
+	// 512 zeros, followed by cleartomark
+	trailer = bytes.Repeat([]byte(strings.Repeat("0", 64)+"\n"), 8)
+	trailer = append(trailer, "cleartomark\n"...)
+
+	return cleartext, private, trailer
+}
+
+func (prog fontProgram) procNames() (rd, nd, np string) {
+	rd, nd, np = prog.rd, prog.nd, prog.np
+	if rd == "" {
+		rd = "RD"
+	}
+	if nd == "" {
+		nd = "noaccess def"
+	}
+	if np == "" {
+		np = "noaccess put"
+	}
+	return rd, nd, np
+}
+
+// writeSubrs writes the /Subrs array, keeping the indices
+// of the used subroutines and replacing the other ones by a
+// simple return.
+func (f *Font) writeSubrs(b *bytes.Buffer) {
+	rd, nd, np := f.program.procNames()
+	length := 0
+	for index := range f.subset.subrs {
+		if int(index) < len(f.subrs) && int(index) >= length {
+			length = int(index) + 1
+		}
+	}
+	fmt.Fprintf(b, "\n/Subrs %d array\n", length)
+	for i := 0; i < length; i++ {
+		data := f.subrs[i]
+		if !f.subset.subrs[int32(i)] || data == nil {
+			data = []byte{11} // return
+		}
+		data = encrypt(data, CHARSTRING_KEY, f.program.lenIV)
+		fmt.Fprintf(b, "dup %d %d %s ", i, len(data), rd)
+		b.Write(data)
+		fmt.Fprintf(b, " %s\n", np)
+	}
+	b.WriteString(nd)
+}
+
+// writeCharStrings writes the /CharStrings dict with the selected glyphs.
+func (f *Font) writeCharStrings(b *bytes.Buffer) {
+	rd, nd, _ := f.program.procNames()
+	fmt.Fprintf(b, "\n/CharStrings %d dict dup begin\n", len(f.subset.glyphs))
+	for _, gid := range f.subset.glyphs {
+		cs := f.charstrings[gid]
+		data := encrypt(cs.data, CHARSTRING_KEY, f.program.lenIV)
+		fmt.Fprintf(b, "/%s %d %s ", cs.name, len(data), rd)
+		b.Write(data)
+		fmt.Fprintf(b, " %s\n", nd)
+	}
+	b.WriteString("end")
+}
+
+// WriteSubset writes the font to w, in the format expected by the /FontFile
+// entry of a PDF font descriptor: the cleartext portion, the binary portion
+// and the fixed-content portion, without the .pfb segment headers.
+// See LengthsPDF for the associated /Length1, /Length2 and /Length3 values.
+// For fonts not returned by Subset, all the glyphs are written.
+func (f *Font) WriteSubset(w io.Writer) error {
+	cleartext, private, trailer := f.segments()
+	for _, segment := range [3][]byte{cleartext, private, trailer} {
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LengthsPDF returns the /Length1, /Length2 and /Length3 values
+// of the font file written by WriteSubset.
+func (f *Font) LengthsPDF() (length1, length2, length3 int) {
+	cleartext, private, trailer := f.segments()
+	return len(cleartext), len(private), len(trailer)
+}
+
+// WritePFB writes the font to w, using the .pfb format.
+// For fonts not returned by Subset, all the glyphs are written.
+func (f *Font) WritePFB(w io.Writer) error {
+	cleartext, private, trailer := f.segments()
+	for i, segment := range [3][]byte{cleartext, private, trailer} {
+		marker := [6]byte{startMarker, asciiMarker}
+		if i == 1 {
+			marker[1] = binaryMarker
+		}
+		binary.LittleEndian.PutUint32(marker[2:], uint32(len(segment)))
+		if _, err := w.Write(marker[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{startMarker, 3}) // end of file
+	return err
+}
+
+// WritePFA writes the font to w, using the .pfa format, where the
+// binary portion is hex encoded.
+// For fonts not returned by Subset, all the glyphs are written.
+func (f *Font) WritePFA(w io.Writer) error {
+	cleartext, private, trailer := f.segments()
+	if _, err := w.Write(cleartext); err != nil {
+		return err
+	}
+	line := make([]byte, 65)
+	for len(private) != 0 {
+		chunk := private
+		if len(chunk) > 32 {
+			chunk = chunk[:32]
+		}
+		private = private[len(chunk):]
+		n := hex.Encode(line, chunk)
+		line[n] = '\n'
+		if _, err := w.Write(line[:n+1]); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(trailer)
+	return err
+}
+
+// toPDF scales `v`, expressed in font units, to the PDF glyph space.
+func (f *Font) toPDF(v Fl) int {
+	return int(math.Round(float64(v) * 1000 / float64(f.Upem())))
+}
+
+// glyphTop returns the maximum height of the glyph `name`,
+// or false if it is not in the font.
+func (f *Font) glyphTop(name string) (int, bool) {
+	for gid, cs := range f.charstrings {
+		if cs.name != name {
+			continue
+		}
+		_, bounds, _, err := f.loadGlyph(fonts.GID(gid), false)
+		if err != nil {
+			return 0, false
+		}
+		return f.toPDF(Fl(bounds.Max.Y)), true
+	}
+	return 0, false
+}
+
+// NamePDF returns the PDF name of the font.
+func (f *Font) NamePDF() string {
+	return fmt.Sprintf("/%s-%s", f.SubsetID, f.PostscriptName())
+}
+
+// WidthsPDF returns a /Widths array suitable for embedding in a PDF file.
+// The array covers the character codes 0 to 255 of the built-in encoding,
+// that is, /FirstChar is 0 and /LastChar is 255.
+func (f *Font) WidthsPDF() string {
+	gids := make(map[string]fonts.GID, len(f.charstrings))
+	for gid, cs := range f.charstrings {
+		gids[cs.name] = fonts.GID(gid)
+	}
+
+	var b strings.Builder
+	b.WriteString("[")
+	for code := 0; code < 256; code++ {
+		if code != 0 {
+			b.WriteString(" ")
+		}
+		width := 0
+		if f.Encoding != nil {
+			name := f.Encoding[code]
+			if met, ok := f.afmMetrics(name); ok {
+				width = met.Width
+			} else if gid, ok := gids[name]; ok && name != "" {
+				width = f.toPDF(f.HorizontalAdvance(gid))
+			}
+		}
+		fmt.Fprintf(&b, "%d", width)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func (f *Font) afmMetrics(name string) (CharMetric, bool) {
+	if f.AFM == nil {
+		return CharMetric{}, false
+	}
+	met, ok := f.AFM.CharMetrics[name]
+	return met, ok
+}
+
+// CMapPDF returns a ToUnicode CMap string to be used in a PDF file,
+// mapping the character codes of the built-in encoding.
+func (f *Font) CMapPDF() string {
+	type mapping struct {
+		code byte
+		r    rune
+	}
+	var mappings []mapping
+	if f.Encoding != nil {
+		for code, name := range f.Encoding {
+			if name == "" || name == Notdef {
+				continue
+			}
+			if r, ok := glyphsnames.GlyphToRune(name); ok {
+				mappings = append(mappings, mapping{byte(code), r})
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CIDSystemInfo << /Registry (Adobe)/Ordering (UCS)/Supplement 0>> def
+/CMapName /Adobe-Identity-UCS def /CMapType 2 def
+1 begincodespacerange
+<00><FF>
+endcodespacerange
+`)
+	// at most 100 entries are allowed in one block
+	for len(mappings) != 0 {
+		block := mappings
+		if len(block) > 100 {
+			block = block[:100]
+		}
+		mappings = mappings[len(block):]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(block))
+		for _, m := range block {
+			fmt.Fprintf(&b, "<%02X><", m.code)
+			for _, u := range utf16.Encode([]rune{m.r}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString(`endcmap CMapName currentdict /CMap defineresource pop end end`)
+	return b.String()
+}
+
+// AscenderPDF returns the /Ascent value for the PDF file
+func (f *Font) AscenderPDF() int {
+	if f.AFM != nil {
+		return int(math.Round(float64(f.AFM.Ascender)))
+	}
+	if len(f.FontBBox) == 4 {
+		return f.toPDF(f.FontBBox[3])
+	}
+	return 0
+}
+
+// DescenderPDF returns the /Descent value for the PDF file
+func (f *Font) DescenderPDF() int {
+	if f.AFM != nil {
+		return int(math.Round(float64(f.AFM.Descender)))
+	}
+	if len(f.FontBBox) == 4 {
+		return f.toPDF(f.FontBBox[1])
+	}
+	return 0
+}
+
+// CapHeightPDF returns the /CapHeight value for the PDF file.
+// Without AFM file, the height of the 'H' glyph is used.
+func (f *Font) CapHeightPDF() int {
+	if f.AFM != nil {
+		return int(math.Round(float64(f.AFM.CapHeight)))
+	}
+	if h, ok := f.glyphTop("H"); ok {
+		return h
+	}
+	return f.AscenderPDF()
+}
+
+// XHeightPDF returns the /XHeight value for the PDF file
+// Without AFM file, the height of the 'x' glyph is used.
+func (f *Font) XHeightPDF() int {
+	if f.AFM != nil {
+		return f.AFM.XHeight
+	}
+	h, _ := f.glyphTop("x")
+	return h
+}
+
+// BoundingBoxPDF returns the /FontBBox value for the PDF file
+func (f *Font) BoundingBoxPDF() string {
+	if len(f.FontBBox) == 4 {
+		return fmt.Sprintf("[%d %d %d %d]", f.toPDF(f.FontBBox[0]), f.toPDF(f.FontBBox[1]),
+			f.toPDF(f.FontBBox[2]), f.toPDF(f.FontBBox[3]))
+	}
+	if f.AFM != nil {
+		return fmt.Sprintf("[%d %d %d %d]", int(f.AFM.Llx), int(f.AFM.Lly), int(f.AFM.Urx), int(f.AFM.Ury))
+	}
+	return "[0 0 0 0]"
+}
+
+// FlagsPDF returns the /Flags value for the PDF file.
+// Type1 fonts are always flagged as symbolic, since
+// their glyphs are accessed through the built-in encoding.
+func (f *Font) FlagsPDF() int {
+	flags := 1 << 2 // symbolic
+	if f.IsFixedPitch {
+		flags |= 1
+	}
+	if f.ItalicAngle != 0 {
+		flags |= 1 << 6
+	}
+	return flags
+}
+
+// ItalicAnglePDF returns the /ItalicAngle value for the PDF file
+func (f *Font) ItalicAnglePDF() int {
+	return f.ItalicAngle
+}
+
+// StemVPDF returns the /StemV value for the PDF file, taken from the AFM
+// file if present, or from the /StdVW entry of the Private dict.
+func (f *Font) StemVPDF() int {
+	if f.AFM != nil {
+		return f.AFM.StdVw
+	}
+	return f.toPDF(f.stdVW)
+}
@@ -0,0 +1,207 @@
+package type1
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/type1"
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+func loadFont(t *testing.T, filename string) *Font {
+	t.Helper()
+	b, err := testdata.Files.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func glyphByName(font *Font, name string) fonts.GID {
+	for gid, cs := range font.charstrings {
+		if cs.name == name {
+			return fonts.GID(gid)
+		}
+	}
+	return 0
+}
+
+func TestSubset(t *testing.T) {
+	for _, filename := range []string{
+		"c0419bt_.pfb",
+		"CalligrapherRegular.pfb",
+		"Z003-MediumItalic.t1",
+	} {
+		ref := loadFont(t, filename)
+
+		var names []string
+		gids := []fonts.GID{3, 10, 20, fonts.GID(len(ref.charstrings) - 1)}
+		for _, gid := range gids {
+			names = append(names, ref.charstrings[gid].name)
+		}
+		res, err := ref.Subset(gids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		font := res.Font.(*Font)
+		if ref.subset != nil || ref.SubsetID != "" {
+			t.Fatal("source font should not be modified")
+		}
+		if len(font.SubsetID) != 6 {
+			t.Fatalf("unexpected subset tag %s", font.SubsetID)
+		}
+
+		var pdf, pfb, pfa bytes.Buffer
+		if err := font.WriteSubset(&pdf); err != nil {
+			t.Fatal(err)
+		}
+		if l1, l2, l3 := font.LengthsPDF(); l1+l2+l3 != pdf.Len() {
+			t.Fatalf("invalid lengths %d %d %d for %d bytes", l1, l2, l3, pdf.Len())
+		}
+		if err := font.WritePFB(&pfb); err != nil {
+			t.Fatal(err)
+		}
+		if err := font.WritePFA(&pfa); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, content := range [][]byte{pdf.Bytes(), pfb.Bytes(), pfa.Bytes()} {
+			sub, err := Parse(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(filename, err)
+			}
+			if L := len(sub.charstrings); L != len(font.subset.glyphs) {
+				t.Fatalf("unexpected number of glyphs %d", L)
+			}
+			if sub.charstrings[0].name != Notdef {
+				t.Fatalf("expected .notdef, got %s", sub.charstrings[0].name)
+			}
+			if !reflect.DeepEqual(sub.Encoding, font.Encoding) {
+				t.Fatal("unexpected encoding")
+			}
+			for i, name := range names {
+				gidRef, gidSub := gids[i], glyphByName(sub, name)
+				if gidSub == 0 || gidSub != res.GIDMap[gidRef] {
+					t.Fatalf("invalid glyph %s in subset", name)
+				}
+				exp, got := ref.GlyphData(gidRef, 0, 0), sub.GlyphData(gidSub, 0, 0)
+				if !reflect.DeepEqual(exp, got) {
+					t.Fatalf("glyph %s: expected %v, got %v", name, exp, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSubsetTooManyGlyphs(t *testing.T) {
+	font := loadFont(t, "c0419bt_.pfb")
+	var gids []fonts.GID
+	for gid := range font.charstrings {
+		gids = append(gids, fonts.GID(gid))
+	}
+	if len(gids) <= 257 {
+		t.Skip("not enough glyphs")
+	}
+	if _, err := font.Subset(gids); err == nil {
+		t.Fatal("expected error for too many glyphs")
+	}
+}
+
+func TestWriteFullFont(t *testing.T) {
+	font := loadFont(t, "CalligrapherRegular.pfb")
+	var b bytes.Buffer
+	if err := font.WritePFB(&b); err != nil {
+		t.Fatal(err)
+	}
+	font2, err := Parse(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(font2.charstrings) != len(font.charstrings) {
+		t.Fatalf("expected %d glyphs, got %d", len(font.charstrings), len(font2.charstrings))
+	}
+}
+
+func TestSubsetWithoutEncoding(t *testing.T) {
+	// build a font without /Encoding from a regular one
+	ref := loadFont(t, "CalligrapherRegular.pfb")
+	start, end := ref.program.encoding[0], ref.program.encoding[1]
+	ref.program.segment1 = append(ref.program.segment1[:start:start], ref.program.segment1[end:]...)
+	ref.program.encoding = [2]int{}
+	var b bytes.Buffer
+	if err := ref.WritePFB(&b); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := Parse(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Encoding != nil {
+		t.Fatal("expected no encoding")
+	}
+
+	res, err := ref.Subset([]fonts.GID{glyphByName(ref, "A"), glyphByName(ref, "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	font := res.Font.(*Font)
+	b.Reset()
+	if err := font.WriteSubset(&b); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := Parse(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the widths and the ToUnicode CMap use the encoding of the embedded font
+	if sub.Encoding == nil || !reflect.DeepEqual(sub.Encoding, font.Encoding) {
+		t.Fatal("unexpected encoding")
+	}
+}
+
+func TestMetricsPDF(t *testing.T) {
+	ref := loadFont(t, "CalligrapherRegular.pfb")
+	res, err := ref.Subset([]fonts.GID{glyphByName(ref, "A"), glyphByName(ref, "b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	font := res.Font.(*Font)
+	if r := res.ToUnicode[res.GIDMap[glyphByName(ref, "A")]]; len(r) != 1 || r[0] != 'A' {
+		t.Fatalf("unexpected ToUnicode %v", res.ToUnicode)
+	}
+	if font.AscenderPDF() <= 0 || font.DescenderPDF() >= 0 {
+		t.Fatalf("unexpected ascender/descender %d %d", font.AscenderPDF(), font.DescenderPDF())
+	}
+	if font.CapHeightPDF() <= 0 {
+		t.Fatal("expected cap height")
+	}
+	codes := font.Encoding.NameToByte()
+	if codes["A"] != 'A' || codes["b"] != 'b' {
+		t.Fatalf("expected original codes to be preserved, got %v", codes)
+	}
+	if cmap := font.CMapPDF(); !bytes.Contains([]byte(cmap), []byte("<41><0041>")) {
+		t.Fatalf("unexpected cmap %s", cmap)
+	}
+
+	f, err := testdata.Files.Open("Times-Bold.afm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	afm, err := ParseAFMFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font.AFM = &afm
+	if font.StemVPDF() != afm.StdVw || font.CapHeightPDF() != int(afm.CapHeight) {
+		t.Fatal("expected metrics from the AFM file")
+	}
+	if font.WidthsPDF() == "" {
+		t.Fatal("expected widths")
+	}
+}
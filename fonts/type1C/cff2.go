@@ -0,0 +1,581 @@
+package type1c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/boxesandglue/textlayout/fonts"
+	ps "github.com/boxesandglue/textlayout/fonts/psinterpreter"
+)
+
+// CFF2 stores the glyph outlines defined in a 'CFF2' table,
+// which may be varied using the variation store.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/cff2
+type CFF2 struct {
+	charstrings [][]byte
+	globalSubrs [][]byte
+	fonts       []cff2PrivateDict // from the FDArray, with length >= 1
+	fdSelect    cff2FDSelect      // nil when there is only one font dict
+	vstore      VariationStore    // nil when the font has no variations
+}
+
+// VariationStore provides the item variation store used by the
+// blend and vsindex operators.
+// It is implemented by the OpenType parser, which owns the variation
+// tables of the font.
+type VariationStore interface {
+	// RegionsCount returns the number of regions referenced by the
+	// item variation data `vsIndex`, or false if there is no such data.
+	RegionsCount(vsIndex uint16) (int, bool)
+	// RegionScalars appends to `dst` the scalars of the regions referenced by the
+	// (valid) item variation data `vsIndex`, for the normalized `coords`.
+	RegionScalars(vsIndex uint16, coords []float32, dst []float32) []float32
+}
+
+// cff2PrivateDict stores the fields of a Private DICT
+// required to interpret a charstring.
+type cff2PrivateDict struct {
+	subrs   [][]byte
+	vsIndex uint16 // default item variation data
+}
+
+// ParseCFF2 parses the 'CFF2' table `data`, for a font with `numGlyphs` glyphs.
+// `parseVStore` is called with the table data starting at the variation
+// store, if any.
+func ParseCFF2(data []byte, numGlyphs int, parseVStore func(vstore []byte) (VariationStore, error)) (*CFF2, error) {
+	if len(data) < 5 {
+		return nil, errors.New("invalid 'CFF2' table (EOF)")
+	}
+	if major := data[0]; major != 2 {
+		return nil, fmt.Errorf("unsupported 'CFF2' table version %d", major)
+	}
+	headerSize := int(data[2])
+	topDictLength := int(binary.BigEndian.Uint16(data[3:]))
+	if len(data) < headerSize+topDictLength {
+		return nil, errors.New("invalid 'CFF2' table (EOF)")
+	}
+
+	var (
+		psi     ps.Machine
+		topDict cff2TopDict
+		out     CFF2
+		err     error
+	)
+	if err = psi.Run(data[headerSize:headerSize+topDictLength], nil, nil, &topDict); err != nil {
+		return nil, fmt.Errorf("invalid 'CFF2' table top dict: %s", err)
+	}
+
+	// the Global Subr INDEX follows the top dict
+	out.globalSubrs, _, err = parseCFF2Index(data, uint32(headerSize+topDictLength))
+	if err != nil {
+		return nil, err
+	}
+
+	if topDict.charstrings == 0 || topDict.fdArray == 0 {
+		return nil, errors.New("invalid 'CFF2' table: missing CharStrings or FDArray")
+	}
+	out.charstrings, _, err = parseCFF2Index(data, topDict.charstrings)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.charstrings) != numGlyphs {
+		return nil, fmt.Errorf("invalid number of glyphs in CFF2 table (%d != %d)", len(out.charstrings), numGlyphs)
+	}
+
+	if topDict.vstore != 0 {
+		// the variation store is preceded by its length
+		if len(data) < int(topDict.vstore)+2 {
+			return nil, errors.New("invalid 'CFF2' table variation store (EOF)")
+		}
+		out.vstore, err = parseVStore(data[topDict.vstore+2:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fontDicts, _, err := parseCFF2Index(data, topDict.fdArray)
+	if err != nil {
+		return nil, err
+	}
+	if len(fontDicts) == 0 {
+		return nil, errors.New("invalid 'CFF2' table: empty FDArray")
+	}
+	out.fonts = make([]cff2PrivateDict, len(fontDicts))
+	for i, fontDict := range fontDicts {
+		out.fonts[i], err = out.parsePrivateDict(data, fontDict)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if topDict.fdSelect != 0 {
+		out.fdSelect, err = parseCFF2FDSelect(data, topDict.fdSelect, numGlyphs)
+		if err != nil {
+			return nil, err
+		}
+		if err = out.fdSelect.sanitize(len(out.fonts)); err != nil {
+			return nil, err
+		}
+	} else if len(out.fonts) != 1 {
+		return nil, errors.New("invalid 'CFF2' table: missing FDSelect")
+	}
+
+	return &out, nil
+}
+
+// parsePrivateDict parses the Private DICT referenced by `fontDict`,
+// as well as its local subroutines.
+// The variation store must have been parsed.
+func (cff2 *CFF2) parsePrivateDict(data, fontDict []byte) (out cff2PrivateDict, err error) {
+	var (
+		psi  ps.Machine
+		font cff2FontDict
+	)
+	if err = psi.Run(fontDict, nil, nil, &font); err != nil {
+		return out, fmt.Errorf("invalid 'CFF2' table font dict: %s", err)
+	}
+	start, end := int(font.privateOffset), int(font.privateOffset)+int(font.privateSize)
+	if start < 0 || end < start || len(data) < end {
+		return out, errors.New("invalid 'CFF2' table private dict (EOF)")
+	}
+
+	private := cff2PrivateDictHandler{vstore: cff2.vstore}
+	if err = psi.Run(data[start:end], nil, nil, &private); err != nil {
+		return out, fmt.Errorf("invalid 'CFF2' table private dict: %s", err)
+	}
+	out.vsIndex = private.vsIndex
+	if private.subrs != 0 {
+		// the Subrs offset is relative to the start of the Private DICT
+		if private.subrs < 0 {
+			return out, fmt.Errorf("invalid 'CFF2' table Subrs offset %d", private.subrs)
+		}
+		out.subrs, _, err = parseCFF2Index(data, uint32(start)+uint32(private.subrs))
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// parseCFF2Index parses the INDEX at `offset`, returning
+// its items and the offset following the INDEX data.
+// Note that, contrary to CFF, the count is stored on 32 bits.
+func parseCFF2Index(data []byte, offset uint32) ([][]byte, uint32, error) {
+	if len(data) < int(offset)+4 {
+		return nil, 0, errors.New("invalid CFF2 INDEX (EOF)")
+	}
+	data = data[offset:]
+	count := int(binary.BigEndian.Uint32(data))
+	if count == 0 { // empty INDEX has no offSize
+		return nil, offset + 4, nil
+	}
+	if len(data) < 5 {
+		return nil, 0, errors.New("invalid CFF2 INDEX (EOF)")
+	}
+	offSize := int(data[4])
+	if offSize < 1 || offSize > 4 {
+		return nil, 0, fmt.Errorf("invalid CFF2 INDEX offset size: %d", offSize)
+	}
+	headerSize := 5 + (count+1)*offSize
+	if len(data) < headerSize {
+		return nil, 0, errors.New("invalid CFF2 INDEX (EOF)")
+	}
+	readOffset := func(i int) int {
+		var v int
+		for _, b := range data[5+i*offSize : 5+(i+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		// offsets start at 1
+		return headerSize - 1 + v
+	}
+
+	out := make([][]byte, count)
+	start := readOffset(0)
+	for i := range out {
+		end := readOffset(i + 1)
+		if start < headerSize || end < start || len(data) < end {
+			return nil, 0, fmt.Errorf("invalid CFF2 INDEX offsets: [%d, %d]", start, end)
+		}
+		out[i] = data[start:end]
+		start = end
+	}
+	return out, offset + uint32(start), nil
+}
+
+// cff2FDSelect maps glyphs to font dicts.
+type cff2FDSelect interface {
+	fontDictIndex(glyph fonts.GID) (uint16, error)
+	// sanitize checks that every font dict index
+	// is smaller than `numFonts`
+	sanitize(numFonts int) error
+}
+
+func parseCFF2FDSelect(data []byte, offset uint32, numGlyphs int) (cff2FDSelect, error) {
+	if len(data) < int(offset)+1 {
+		return nil, errors.New("invalid CFF2 FDSelect (EOF)")
+	}
+	data = data[offset:]
+	switch format := data[0]; format {
+	case 0:
+		if len(data) < 1+numGlyphs {
+			return nil, errors.New("invalid CFF2 FDSelect format 0 (EOF)")
+		}
+		return fdSelect0(data[1 : 1+numGlyphs]), nil
+	case 3:
+		if len(data) < 3 {
+			return nil, errors.New("invalid CFF2 FDSelect format 3 (EOF)")
+		}
+		nRanges := int(binary.BigEndian.Uint16(data[1:]))
+		if len(data) < 3+3*nRanges+2 {
+			return nil, errors.New("invalid CFF2 FDSelect format 3 (EOF)")
+		}
+		out := fdSelect3{ranges: make([]fdSelectRange, nRanges)}
+		for i := range out.ranges {
+			out.ranges[i].first = uint32(binary.BigEndian.Uint16(data[3+3*i:]))
+			out.ranges[i].fd = uint16(data[3+3*i+2])
+		}
+		out.sentinel = uint32(binary.BigEndian.Uint16(data[3+3*nRanges:]))
+		return out, nil
+	case 4:
+		if len(data) < 5 {
+			return nil, errors.New("invalid CFF2 FDSelect format 4 (EOF)")
+		}
+		nRanges := int(binary.BigEndian.Uint32(data[1:]))
+		if len(data) < 5+6*nRanges+4 {
+			return nil, errors.New("invalid CFF2 FDSelect format 4 (EOF)")
+		}
+		out := fdSelect3{ranges: make([]fdSelectRange, nRanges)}
+		for i := range out.ranges {
+			out.ranges[i].first = binary.BigEndian.Uint32(data[5+6*i:])
+			out.ranges[i].fd = binary.BigEndian.Uint16(data[5+6*i+4:])
+		}
+		out.sentinel = binary.BigEndian.Uint32(data[5+6*nRanges:])
+		return out, nil
+	default:
+		return nil, fmt.Errorf("invalid CFF2 FDSelect format %d", format)
+	}
+}
+
+// fdSelect0 stores one font dict index per glyph
+type fdSelect0 []byte
+
+func (fd fdSelect0) fontDictIndex(glyph fonts.GID) (uint16, error) {
+	if int(glyph) >= len(fd) {
+		return 0, fmt.Errorf("invalid glyph index %d in FDSelect", glyph)
+	}
+	return uint16(fd[glyph]), nil
+}
+
+func (fd fdSelect0) sanitize(numFonts int) error {
+	for _, index := range fd {
+		if int(index) >= numFonts {
+			return fmt.Errorf("invalid font dict index %d in FDSelect", index)
+		}
+	}
+	return nil
+}
+
+type fdSelectRange struct {
+	first uint32
+	fd    uint16
+}
+
+// fdSelect3 is used for both format 3 and 4,
+// which only differ in the size of the fields
+type fdSelect3 struct {
+	ranges   []fdSelectRange
+	sentinel uint32 // one past the last glyph
+}
+
+func (fd fdSelect3) fontDictIndex(glyph fonts.GID) (uint16, error) {
+	g := uint32(glyph)
+	// index of the first range starting after glyph
+	i := sort.Search(len(fd.ranges), func(i int) bool { return g < fd.ranges[i].first })
+	if i == 0 || fd.sentinel <= g {
+		return 0, fmt.Errorf("invalid glyph index %d in FDSelect", glyph)
+	}
+	return fd.ranges[i-1].fd, nil
+}
+
+func (fd fdSelect3) sanitize(numFonts int) error {
+	if len(fd.ranges) == 0 || fd.ranges[0].first != 0 {
+		return errors.New("invalid FDSelect: first range must start at 0")
+	}
+	for i, r := range fd.ranges {
+		if int(r.fd) >= numFonts {
+			return fmt.Errorf("invalid font dict index %d in FDSelect", r.fd)
+		}
+		if i > 0 && r.first <= fd.ranges[i-1].first {
+			return errors.New("invalid FDSelect: unsorted ranges")
+		}
+	}
+	if last := fd.ranges[len(fd.ranges)-1].first; fd.sentinel < last {
+		return errors.New("invalid FDSelect sentinel")
+	}
+	return nil
+}
+
+// cff2TopDict stores the offsets found in the Top DICT
+type cff2TopDict struct {
+	charstrings, fdArray, fdSelect, vstore uint32
+}
+
+func (cff2TopDict) Context() ps.PsContext { return ps.TopDict | ps.CFF2 }
+
+func (d *cff2TopDict) Apply(op ps.PsOperator, state *ps.Machine) error {
+	var dst *uint32
+	switch op {
+	case ps.PsOperator{Operator: 17}: // CharStrings
+		dst = &d.charstrings
+	case ps.PsOperator{Operator: 24}: // vstore
+		dst = &d.vstore
+	case ps.PsOperator{Operator: 36, IsEscaped: true}: // FDArray
+		dst = &d.fdArray
+	case ps.PsOperator{Operator: 37, IsEscaped: true}: // FDSelect
+		dst = &d.fdSelect
+	default: // FontMatrix is ignored
+		state.ArgStack.Clear()
+		return nil
+	}
+	if state.ArgStack.Top < 1 {
+		return fmt.Errorf("missing operand for %s", op)
+	}
+	v := state.ArgStack.Pop()
+	if v < 0 {
+		return fmt.Errorf("invalid offset %d for %s", v, op)
+	}
+	*dst = uint32(v)
+	state.ArgStack.Clear()
+	return nil
+}
+
+// cff2FontDict stores the location of the Private DICT
+type cff2FontDict struct {
+	privateSize, privateOffset int32
+}
+
+func (cff2FontDict) Context() ps.PsContext { return ps.TopDict | ps.CFF2 }
+
+func (d *cff2FontDict) Apply(op ps.PsOperator, state *ps.Machine) error {
+	if op == (ps.PsOperator{Operator: 18}) { // Private
+		if state.ArgStack.Top < 2 {
+			return errors.New("missing operands for Private")
+		}
+		d.privateOffset = state.ArgStack.Pop()
+		d.privateSize = state.ArgStack.Pop()
+	}
+	state.ArgStack.Clear()
+	return nil
+}
+
+// cff2PrivateDictHandler only fetch the fields needed to
+// load glyphs, but has to support blended values
+type cff2PrivateDictHandler struct {
+	vstore  VariationStore
+	subrs   int32 // offset from the start of the Private DICT
+	vsIndex uint16
+}
+
+func (cff2PrivateDictHandler) Context() ps.PsContext { return ps.PrivateDict | ps.CFF2 }
+
+func (d *cff2PrivateDictHandler) Apply(op ps.PsOperator, state *ps.Machine) error {
+	if op.IsEscaped {
+		state.ArgStack.Clear()
+		return nil
+	}
+	switch op.Operator {
+	case 19: // Subrs
+		if state.ArgStack.Top < 1 {
+			return errors.New("missing operand for Subrs")
+		}
+		d.subrs = state.ArgStack.Pop()
+	case 22: // vsindex
+		if state.ArgStack.Top < 1 {
+			return errors.New("missing operand for vsindex")
+		}
+		d.vsIndex = uint16(state.ArgStack.Pop())
+		if _, ok := d.regionsCount(); !ok {
+			return fmt.Errorf("invalid vsindex %d", d.vsIndex)
+		}
+	case 23: // blend
+		// only keep the default values, which are followed by the deltas
+		if state.ArgStack.Top < 1 {
+			return errors.New("missing operand for blend")
+		}
+		n := state.ArgStack.Pop()
+		k, _ := d.regionsCount()
+		if n < 0 || state.ArgStack.Top < n*(int32(k)+1) {
+			return errors.New("missing operands for blend")
+		}
+		state.ArgStack.Top -= n * int32(k)
+		return nil // the values are used by the next operator
+	}
+	state.ArgStack.Clear()
+	return nil
+}
+
+// regionsCount returns the number of regions of the active item variation data,
+// or false if it does not exist.
+func (d *cff2PrivateDictHandler) regionsCount() (int, bool) {
+	if d.vstore == nil {
+		return 0, false
+	}
+	return d.vstore.RegionsCount(d.vsIndex)
+}
+
+// LoadGlyph interprets the charstring of `glyph`, applying the variations
+// defined by the normalized `coords`, which may be empty.
+func (cff2 *CFF2) LoadGlyph(glyph fonts.GID, coords []float32) ([]fonts.Segment, ps.PathBounds, error) {
+	if int(glyph) >= len(cff2.charstrings) {
+		return nil, ps.PathBounds{}, fmt.Errorf("invalid glyph index %d", glyph)
+	}
+	var fd uint16
+	if cff2.fdSelect != nil {
+		var err error
+		fd, err = cff2.fdSelect.fontDictIndex(glyph)
+		if err != nil {
+			return nil, ps.PathBounds{}, err
+		}
+	}
+	private := cff2.fonts[fd] // fd is checked during parsing
+
+	var (
+		psi    ps.Machine
+		loader = cff2CharstringHandler{vstore: cff2.vstore, coords: coords}
+	)
+	if err := loader.setVSIndex(private.vsIndex); err != nil {
+		return nil, ps.PathBounds{}, err
+	}
+	err := psi.Run(cff2.charstrings[glyph], private.subrs, cff2.globalSubrs, &loader)
+	// there is no endchar operator in CFF2
+	loader.cs.ClosePath()
+	return loader.cs.Segments, loader.cs.Bounds, err
+}
+
+// cff2CharstringHandler implements the CFF2 charstring operators,
+// which are the Type2 ones, without the width and with blend support.
+type cff2CharstringHandler struct {
+	cs ps.CharstringReader
+
+	vstore VariationStore
+	coords []float32
+	// scalars for each region of the active item variation data
+	scalars []float32
+}
+
+func (cff2CharstringHandler) Context() ps.PsContext { return ps.Type2Charstring | ps.CFF2 }
+
+func (met *cff2CharstringHandler) setVSIndex(index uint16) error {
+	var ok bool
+	if met.vstore != nil {
+		_, ok = met.vstore.RegionsCount(index)
+	}
+	if !ok {
+		if index == 0 { // no variations
+			met.scalars = met.scalars[:0]
+			return nil
+		}
+		return fmt.Errorf("invalid vsindex %d", index)
+	}
+	met.scalars = met.vstore.RegionScalars(index, met.coords, met.scalars[:0])
+	return nil
+}
+
+// blend replaces the n*(k+1) arguments on the stack by the
+// n blended values, rounded since the stack only stores integers
+func (met *cff2CharstringHandler) blend(state *ps.Machine) error {
+	if state.ArgStack.Top < 1 {
+		return errors.New("invalid blend operator (empty stack)")
+	}
+	n := state.ArgStack.Pop()
+	k := int32(len(met.scalars))
+	if n < 0 || state.ArgStack.Top < n*(k+1) {
+		return errors.New("invalid blend operator (missing operands)")
+	}
+	start := state.ArgStack.Top - n*(k+1)
+	defaults := state.ArgStack.Vals[start : start+n]
+	deltas := state.ArgStack.Vals[start+n : state.ArgStack.Top]
+	for i := range defaults {
+		v := float64(defaults[i])
+		for j, scalar := range met.scalars {
+			v += float64(scalar) * float64(deltas[int32(i)*k+int32(j)])
+		}
+		defaults[i] = int32(math.Round(v))
+	}
+	state.ArgStack.Top = start + n
+	return nil
+}
+
+func (met *cff2CharstringHandler) Apply(op ps.PsOperator, state *ps.Machine) error {
+	var err error
+	if !op.IsEscaped {
+		switch op.Operator {
+		case 10: // callsubr
+			return ps.LocalSubr(state) // do not clear the arg stack
+		case 29: // callgsubr
+			return ps.GlobalSubr(state) // do not clear the arg stack
+		case 16: // blend
+			return met.blend(state) // do not clear the arg stack
+		case 15: // vsindex
+			if state.ArgStack.Top < 1 {
+				return errors.New("invalid vsindex operator (empty stack)")
+			}
+			err = met.setVSIndex(uint16(state.ArgStack.Pop()))
+		case 21: // rmoveto
+			err = met.cs.Rmoveto(state)
+		case 22: // hmoveto
+			err = met.cs.Hmoveto(state)
+		case 4: // vmoveto
+			err = met.cs.Vmoveto(state)
+		case 1, 18: // hstem, hstemhm
+			met.cs.Hstem(state)
+		case 3, 23: // vstem, vstemhm
+			met.cs.Vstem(state)
+		case 19, 20: // hintmask, cntrmask
+			met.cs.Hintmask(state)
+			// the stack is managed by the previous call
+			return nil
+		case 5: // rlineto
+			met.cs.Rlineto(state)
+		case 6: // hlineto
+			met.cs.Hlineto(state)
+		case 7: // vlineto
+			met.cs.Vlineto(state)
+		case 8: // rrcurveto
+			met.cs.Rrcurveto(state)
+		case 24: // rcurveline
+			err = met.cs.Rcurveline(state)
+		case 25: // rlinecurve
+			err = met.cs.Rlinecurve(state)
+		case 26: // vvcurveto
+			met.cs.Vvcurveto(state)
+		case 27: // hhcurveto
+			met.cs.Hhcurveto(state)
+		case 30: // vhcurveto
+			met.cs.Vhcurveto(state)
+		case 31: // hvcurveto
+			met.cs.Hvcurveto(state)
+		default:
+			// return and endchar are not allowed in CFF2
+			err = fmt.Errorf("invalid operator %s in CFF2 charstring", op)
+		}
+	} else {
+		switch op.Operator {
+		case 34: // hflex
+			err = met.cs.Hflex(state)
+		case 35: // flex
+			err = met.cs.Flex(state)
+		case 36: // hflex1
+			err = met.cs.Hflex1(state)
+		case 37: // flex1
+			err = met.cs.Flex1(state)
+		default:
+			err = fmt.Errorf("invalid operator %s in CFF2 charstring", op)
+		}
+	}
+	state.ArgStack.Clear()
+	return err
+}
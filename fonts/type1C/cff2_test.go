@@ -0,0 +1,214 @@
+package type1c
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	testdata "github.com/benoitkugler/textlayout-testdata/truetype"
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// rawTable returns the table `tag` of the OpenType font `file`
+func rawTable(t *testing.T, file, tag string) []byte {
+	f, err := testdata.Files.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numTables := int(binary.BigEndian.Uint16(f[4:]))
+	for i := 0; i < numTables; i++ {
+		record := f[12+16*i:]
+		if string(record[:4]) == tag {
+			offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+			return f[offset : offset+length]
+		}
+	}
+	t.Fatalf("missing table %s in %s", tag, file)
+	return nil
+}
+
+// testVariationStore only records the number of item variation datas
+type testVariationStore int
+
+func parseTestVariationStore(vstore []byte) (VariationStore, error) {
+	if len(vstore) < 8 {
+		return nil, errors.New("invalid item variation store (EOF)")
+	}
+	return testVariationStore(binary.BigEndian.Uint16(vstore[6:])), nil
+}
+
+func (vs testVariationStore) RegionsCount(vsIndex uint16) (int, bool) {
+	return 1, int(vsIndex) < int(vs)
+}
+
+func (vs testVariationStore) RegionScalars(vsIndex uint16, coords []float32, dst []float32) []float32 {
+	return append(dst, 0)
+}
+
+func TestParseCFF2(t *testing.T) {
+	const numGlyphs = 5
+	raw := rawTable(t, "TestCFF2VF.otf", "CFF2")
+	cff2, err := ParseCFF2(raw, numGlyphs, parseTestVariationStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if L := len(cff2.charstrings); L != numGlyphs {
+		t.Fatalf("expected %d charstrings, got %d", numGlyphs, L)
+	}
+	if len(cff2.fonts) != 1 || cff2.fdSelect != nil {
+		t.Fatalf("unexpected FDArray %v", cff2.fonts)
+	}
+	if L := len(cff2.fonts[0].subrs); L != 3 {
+		t.Fatalf("expected 3 local subroutines, got %d", L)
+	}
+	if vs := cff2.vstore; vs != testVariationStore(1) {
+		t.Fatalf("expected 1 item variation data, got %v", vs)
+	}
+
+	for gid := fonts.GID(0); gid < numGlyphs; gid++ {
+		segments, _, err := cff2.LoadGlyph(gid, []float32{0.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(segments) == 0 {
+			t.Fatalf("empty outline for glyph %d", gid)
+		}
+	}
+
+	for i := range raw {
+		// check for panics
+		ParseCFF2(raw[:i], numGlyphs, parseTestVariationStore)
+	}
+}
+
+// cff2DictInt encodes `v` as a 5-byte DICT integer
+func cff2DictInt(v int32) []byte {
+	out := []byte{29, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[1:], uint32(v))
+	return out
+}
+
+// cff2Index encodes `items` as an INDEX with 4-byte offsets
+func cff2Index(items ...[]byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(items)))
+	if len(items) == 0 {
+		return out
+	}
+	out = append(out, 4)
+	offset := uint32(1)
+	out = binary.BigEndian.AppendUint32(out, offset)
+	for _, item := range items {
+		offset += uint32(len(item))
+		out = binary.BigEndian.AppendUint32(out, offset)
+	}
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func TestCFF2Blend(t *testing.T) {
+	// the private dict blends the Subrs offset (the delta would be invalid),
+	// and the charstring starts with a 16.16 fixed point number
+	charstring := []byte{
+		255, 0, 10, 0xc0, 0, 139, 21, // 10.75 0 rmoveto
+		139, 159, 5, // 0 20 rlineto
+		32, 10, // -107 callsubr (first local subroutine)
+	}
+	subr := []byte{169, 139, 5} // 30 0 rlineto
+
+	const headerSize, topDictSize = 5, 19
+	var (
+		globalSubrs = cff2Index()
+		vstore      = []byte{0, 8, 0, 1, 0, 0, 0, 0, 0, 1} // length, then one item variation data
+		charstrings = cff2Index(charstring)
+	)
+	charstringsOffset := headerSize + topDictSize + len(globalSubrs) + len(vstore)
+	fdArrayOffset := charstringsOffset + len(charstrings)
+	private := append(append(cff2DictInt(13), cff2DictInt(1000)...), 140, 23, 19) // 13 1000 1 blend Subrs
+	// size offset Private
+	fontDict := func(privateOffset int) []byte {
+		return append(append(cff2DictInt(int32(len(private))), cff2DictInt(int32(privateOffset))...), 18)
+	}
+	privateOffset := fdArrayOffset + len(cff2Index(fontDict(0)))
+	fdArray := cff2Index(fontDict(privateOffset))
+
+	data := []byte{2, 0, headerSize, 0, topDictSize}
+	data = append(data, cff2DictInt(int32(charstringsOffset))...)
+	data = append(data, 17)
+	data = append(data, cff2DictInt(int32(charstringsOffset-len(vstore)))...)
+	data = append(data, 24)
+	data = append(data, cff2DictInt(int32(fdArrayOffset))...)
+	data = append(data, 12, 36)
+	data = append(data, globalSubrs...)
+	data = append(data, vstore...)
+	data = append(data, charstrings...)
+	data = append(data, fdArray...)
+	data = append(data, private...)
+	data = append(data, cff2Index(subr)...)
+
+	cff2, err := ParseCFF2(data, 1, parseTestVariationStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cff2.fonts[0].subrs) != 1 {
+		t.Fatalf("expected 1 local subroutine, got %d", len(cff2.fonts[0].subrs))
+	}
+
+	segments, _, err := cff2.LoadGlyph(0, []float32{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fonts.SegmentPoint{{X: 11, Y: 0}, {X: 11, Y: 20}, {X: 41, Y: 20}}
+	if len(segments) < len(expected) {
+		t.Fatalf("unexpected outline %v", segments)
+	}
+	for i, exp := range expected {
+		if got := segments[i].Args[0]; got != exp {
+			t.Fatalf("point %d: expected %v, got %v", i, exp, got)
+		}
+	}
+}
+
+func TestCFF2FDSelect(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		expected []uint16 // for each glyph
+	}{
+		{
+			[]byte{0, 0, 1, 1, 0},
+			[]uint16{0, 1, 1, 0},
+		},
+		{
+			[]byte{3, 0, 2, 0, 0, 1, 0, 2, 0, 0, 4},
+			[]uint16{1, 1, 0, 0},
+		},
+		{
+			[]byte{4, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 1, 0, 0, 0, 4},
+			[]uint16{0, 0, 0, 1},
+		},
+	} {
+		fd, err := parseCFF2FDSelect(test.data, 0, len(test.expected))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = fd.sanitize(2); err != nil {
+			t.Fatal(err)
+		}
+		for gid, exp := range test.expected {
+			got, err := fd.fontDictIndex(fonts.GID(gid))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != exp {
+				t.Fatalf("glyph %d: expected font dict %d, got %d", gid, exp, got)
+			}
+		}
+		if _, err = fd.fontDictIndex(fonts.GID(len(test.expected))); err == nil {
+			t.Fatal("expected error for out of range glyph")
+		}
+		if err = fd.sanitize(1); err == nil {
+			t.Fatal("expected error for invalid font dict index")
+		}
+	}
+}
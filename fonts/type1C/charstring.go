@@ -13,20 +13,21 @@ func (f *Font) LoadGlyph(glyph fonts.GID) ([]fonts.Segment, ps.PathBounds, error
 	var (
 		psi    ps.Machine
 		loader type2CharstringHandler
-		err    error
 	)
-	// if f.fdSelect != nil {
-	// 	index, err = f.fdSelect.fontDictIndex(glyph)
-	// 	if err != nil {
-	// 		return nil, ps.PathBounds{}, err
-	// 	}
-	// }
 	if int(glyph) >= len(f.CharStrings) {
 		return nil, ps.PathBounds{}, fmt.Errorf("invalid glyph index %d", glyph)
 	}
 
-	subrs := f.subrsIndex
-	err = psi.Run(f.CharStrings[glyph], subrs, f.global.globalSubrIndex, &loader)
+	// for CID-keyed fonts, subroutines and widths are
+	// defined by the Private DICT of the selected font dict
+	fd, err := f.fontDict(glyph)
+	if err != nil {
+		return nil, ps.PathBounds{}, err
+	}
+	loader.nominalWidthX = int32(fd.nominalWidthX)
+	loader.width = int32(fd.defaultWidthX)
+
+	err = psi.Run(f.CharStrings[glyph], fd.subrsIndex, f.global.globalSubrIndex, &loader)
 	return loader.cs.Segments, loader.cs.Bounds, err
 }
 
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"sort"
 
 	"github.com/boxesandglue/textlayout/fonts"
+	"github.com/boxesandglue/textlayout/fonts/simpleencodings"
 )
 
 // Top DICT Data - see CFF spec 9 p. 14
@@ -127,21 +127,30 @@ func (f *Font) parseDict(dict []byte) {
 				f.initialRandomSeed = popInt()
 			case 30:
 				// ROS
-				f.registry = SID(popInt())
-				f.ordering = SID(popInt())
 				f.supplement = popInt()
+				f.ordering = SID(popInt())
+				f.registry = SID(popInt())
 			case 31:
 				// CIDFontVersion
 				popInt()
+			case 32:
+				// CIDFontRevision
+				popInt()
+			case 33:
+				// CIDFontType
+				popInt()
 			case 34:
 				// CID count
 				f.cidcount = popInt()
+			case 35:
+				// UIDBase ignored
+				popInt()
 			case 36:
 				// FDArray
-				f.fdarray = int64(popInt())
+				f.fdarrayOffset = int64(popInt())
 			case 37:
 				// FDSelect
-				f.fdselect = int64(popInt())
+				f.fdselectOffset = int64(popInt())
 			case 38:
 				// fontname
 				f.name = SID(popInt())
@@ -283,6 +292,104 @@ func (f *Font) readSubrIndex(r io.ReadSeeker) error {
 	return nil
 }
 
+// readFDArray reads the Font DICTs of a CID-keyed font, together with
+// their Private DICT and local subroutines.
+func (f *Font) readFDArray(r io.ReadSeeker) error {
+	if _, err := r.Seek(f.fdarrayOffset, io.SeekStart); err != nil {
+		return err
+	}
+	data := cffReadIndexData(r, "FDArray")
+	if len(data) == 0 {
+		return fmt.Errorf("empty FDArray in CID-keyed font")
+	}
+	f.fdArray = make([]*Font, len(data))
+	for i, dict := range data {
+		fd := &Font{global: f.global}
+		fd.parseDict(dict)
+		if err := fd.readPrivateDict(r); err != nil {
+			return err
+		}
+		if err := fd.readSubrIndex(r); err != nil {
+			return err
+		}
+		f.fdArray[i] = fd
+	}
+	return nil
+}
+
+// readFDSelect reads the font dict index of each glyph, and
+// must be called after readCharStringsIndex and readFDArray.
+// Formats 0 and 3 are supported.
+func (f *Font) readFDSelect(r io.ReadSeeker) error {
+	if _, err := r.Seek(f.fdselectOffset, io.SeekStart); err != nil {
+		return err
+	}
+	numGlyphs := len(f.CharStrings)
+	f.fdSelect = make([]uint8, numGlyphs)
+
+	var format uint8
+	if err := read(r, &format); err != nil {
+		return err
+	}
+	switch format {
+	case 0:
+		if err := read(r, f.fdSelect); err != nil {
+			return err
+		}
+	case 3:
+		var nRanges uint16
+		if err := read(r, &nRanges); err != nil {
+			return err
+		}
+		var rng struct {
+			First uint16
+			FD    uint8
+		}
+		if err := read(r, &rng); err != nil {
+			return err
+		}
+		for i := 0; i < int(nRanges); i++ {
+			first, fd := int(rng.First), rng.FD
+			// the last range is closed by the sentinel
+			if i == int(nRanges)-1 {
+				if err := read(r, &rng.First); err != nil {
+					return err
+				}
+			} else if err := read(r, &rng); err != nil {
+				return err
+			}
+			if first > int(rng.First) || int(rng.First) > numGlyphs {
+				return fmt.Errorf("invalid FDSelect range [%d, %d)", first, rng.First)
+			}
+			for gid := first; gid < int(rng.First); gid++ {
+				f.fdSelect[gid] = fd
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported FDSelect format %d", format)
+	}
+
+	for gid, fd := range f.fdSelect {
+		if int(fd) >= len(f.fdArray) {
+			return fmt.Errorf("invalid font dict index %d for glyph %d", fd, gid)
+		}
+	}
+	return nil
+}
+
+// fontDict returns the font holding the Private DICT data
+// (local subroutines and widths) used by `glyph`: f itself
+// for regular fonts, or one of the FDArray entries for CID-keyed fonts.
+func (f *Font) fontDict(glyph fonts.GID) (*Font, error) {
+	if !f.IsCIDFont() {
+		return f, nil
+	}
+	if int(glyph) >= len(f.fdSelect) {
+		return nil, fmt.Errorf("invalid glyph index %d", glyph)
+	}
+	return f.fdArray[f.fdSelect[glyph]], nil
+}
+
 func (f *Font) readEncoding(r io.ReadSeeker) error {
 	var err error
 	f.encoding = make(map[int]int)
@@ -441,6 +548,19 @@ func (f *Font) GetRawIndexData(r io.ReadSeeker, index mainIndex) ([]byte, error)
 			return nil, err
 		}
 		err = f.readSubrIndex(r)
+	case FDArrayIndex:
+		indexStart, err = r.Seek(f.fdarrayOffset, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		// only the INDEX itself, not the private dicts
+		cffReadIndexData(r, "FDArray")
+	case FDSelect:
+		indexStart, err = r.Seek(f.fdselectOffset, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		err = f.readFDSelect(r)
 
 	default:
 		panic(fmt.Sprintf("unknown index %d", index))
@@ -481,6 +601,10 @@ func (f *Font) parseIndex(r io.ReadSeeker, index mainIndex) error {
 		err = f.readSubrIndex(r)
 	case Encoding:
 		err = f.readEncoding(r)
+	case FDArrayIndex:
+		err = f.readFDArray(r)
+	case FDSelect:
+		err = f.readFDSelect(r)
 	default:
 		panic(fmt.Sprintf("unknown index %d", index))
 	}
@@ -492,7 +616,26 @@ func (f *Font) parseIndex(r io.ReadSeeker, index mainIndex) error {
 
 // IsCIDFont returns true if the character encoding is based on CID instead of SID
 func (f *Font) IsCIDFont() bool {
-	return f.fdselect != 0
+	return f.fdselectOffset != 0
+}
+
+// CID returns the character identifier of `glyph`, as defined by the charset,
+// or false if the font is not CID-keyed or if `glyph` is invalid.
+func (f *Font) CID(glyph fonts.GID) (uint16, bool) {
+	if !f.IsCIDFont() || int(glyph) >= len(f.charset) {
+		return 0, false
+	}
+	return uint16(f.charset[glyph]), true
+}
+
+// StdVW returns the dominant width of the vertical stems, as defined
+// in the Private DICT (of the first font dict for CID-keyed fonts), or 0
+// if it is not specified.
+func (f *Font) StdVW() int {
+	if f.IsCIDFont() && len(f.fdArray) != 0 {
+		return f.fdArray[0].stdvw
+	}
+	return f.stdvw
 }
 
 // WriteSubset writes this font to the CFFFile
@@ -500,69 +643,190 @@ func (f *Font) WriteSubset(w io.Writer) error {
 	return f.global.WriteCFFData(w)
 }
 
-// Subset changes the font so that only the given code points remain in the font. Subset must only be called once.
-func (f *Font) Subset(codepoints []fonts.GID) {
-	var globalSubr [][]byte
-	globalSubr = f.global.globalSubrIndex
-	fonts.RemoveDuplicates(codepoints)
-	cpIdx := 0
-	charstringsIdx := 0
-	for {
-		cp := int(codepoints[cpIdx])
-		for j := 1; j+charstringsIdx < cp; j++ {
-			f.CharStrings[j+charstringsIdx] = []byte{0xe}
-			f.charset[j+charstringsIdx] = 0
+// Subset returns a new font containing only the given glyphs, the receiver being left unchanged.
+// The .notdef glyph is always included.
+// If `retainGIDs` is true, the glyphs keep their index, the other ones being replaced by empty glyphs;
+// otherwise, the glyphs are renumbered compactly, in ascending order of their original index.
+// For CID-keyed fonts, the CID of each glyph of the subset is its new index.
+func (f *Font) Subset(gids []fonts.GID, retainGIDs bool) (*Font, error) {
+	gids = fonts.RemoveDuplicates(append([]fonts.GID{0}, gids...))
+	lastGID := gids[len(gids)-1]
+	if int(lastGID) >= len(f.CharStrings) {
+		return nil, fmt.Errorf("invalid glyph index %d", lastGID)
+	}
+
+	out := *f
+	global := *f.global
+	global.Font = []*Font{&out}
+	global.Fontindex = 0
+	for i, fnt := range f.global.Font {
+		if fnt == f {
+			global.fontnames = []string{f.global.fontnames[i]}
+		}
+	}
+	out.global = &global
+
+	// the glyphs of the subset, with their new index
+	kept := gids
+	if retainGIDs {
+		out.CharStrings = make([][]byte, lastGID+1)
+		out.charset = make([]SID, lastGID+1)
+		for i := range out.CharStrings {
+			out.CharStrings[i] = []byte{0xe} // endchar
 		}
-		cpIdx++
-		charstringsIdx = cp
-		if cpIdx >= len(codepoints) {
-			break
+		for _, gid := range gids {
+			out.CharStrings[gid] = f.CharStrings[gid]
+			out.charset[gid] = f.charset[gid]
+		}
+		if f.IsCIDFont() {
+			out.charset = identityCharset(len(out.CharStrings))
+			out.fdSelect = append([]uint8(nil), f.fdSelect[:lastGID+1]...)
+		}
+	} else {
+		out.CharStrings = make([][]byte, len(gids))
+		out.charset = make([]SID, len(gids))
+		if f.IsCIDFont() {
+			out.fdSelect = make([]uint8, len(gids))
+		}
+		kept = make([]fonts.GID, len(gids))
+		for i, gid := range gids {
+			out.CharStrings[i] = f.CharStrings[gid]
+			out.charset[i] = f.charset[gid]
+			if f.IsCIDFont() {
+				out.fdSelect[i] = f.fdSelect[gid]
+			}
+			kept[i] = fonts.GID(i)
+		}
+		if f.IsCIDFont() {
+			out.charset = identityCharset(len(out.CharStrings))
 		}
 	}
 
-	lastcp := codepoints[len(codepoints)-1]
-	f.CharStrings = f.CharStrings[:lastcp+1]
-	f.charset = f.charset[:lastcp+1]
-
-	usedGlobalSubrsMap = make(map[int]bool)
-	usedLocalSubrsMap = make(map[int]bool)
-
-	for _, cp := range codepoints {
-		cs := f.CharStrings[cp]
-		getSubrsIndex(f.nominalWidthX, f.defaultWidthX, globalSubr, f.subrsIndex, cs, nil)
+	usedGlobalSubrs := make(map[int]bool)
+	if f.IsCIDFont() {
+		// each font dict has its own local subroutines
+		out.fdArray = make([]*Font, len(f.fdArray))
+		for i, fd := range f.fdArray {
+			usedLocalSubrs := make(map[int]bool)
+			for _, gid := range kept {
+				if int(out.fdSelect[gid]) != i {
+					continue
+				}
+				state := newType2State(fd.nominalWidthX, fd.defaultWidthX, usedGlobalSubrs, usedLocalSubrs)
+				if err := getSubrsIndex(f.global.globalSubrIndex, fd.subrsIndex, out.CharStrings[gid], state); err != nil {
+					return nil, err
+				}
+			}
+			fdCopy := *fd
+			fdCopy.subrsIndex = subsetSubrs(fd.subrsIndex, usedLocalSubrs)
+			out.fdArray[i] = &fdCopy
+		}
+		out.subsetFontDicts(kept)
+	} else {
+		usedLocalSubrs := make(map[int]bool)
+		for _, gid := range kept {
+			state := newType2State(f.nominalWidthX, f.defaultWidthX, usedGlobalSubrs, usedLocalSubrs)
+			if err := getSubrsIndex(f.global.globalSubrIndex, f.subrsIndex, out.CharStrings[gid], state); err != nil {
+				return nil, err
+			}
+		}
+		out.subrsIndex = subsetSubrs(f.subrsIndex, usedLocalSubrs)
 	}
+	global.globalSubrIndex = subsetSubrs(f.global.globalSubrIndex, usedGlobalSubrs)
 
-	clearSubr(globalSubr, usedGlobalSubrsMap)
-	clearSubr(f.subrsIndex, usedLocalSubrsMap)
+	return &out, nil
 }
 
-func clearSubr(subr [][]byte, usedSubrs map[int]bool) {
-	if len(usedSubrs) == 0 {
-		return
+// identityCharset returns the charset of a CID-keyed font
+// with `numGlyphs` glyphs, where each glyph index is also its CID.
+// It is used by subsets, since the PDF widths and /ToUnicode CMaps
+// are expressed with the new glyph indices.
+func identityCharset(numGlyphs int) []SID {
+	out := make([]SID, numGlyphs)
+	for i := range out {
+		out[i] = SID(i)
 	}
-	usedSubrSlice := []int{}
+	return out
+}
 
-	for k := range usedSubrs {
-		usedSubrSlice = append(usedSubrSlice, k)
+// SeacComponents returns the base and accent glyphs used by `gid`,
+// when its charstring relies on the deprecated seac form of the endchar operator.
+// It returns nil for regular glyphs.
+// Since the components are referenced by their standard encoding code,
+// they must be kept when subsetting the font.
+func (f *Font) SeacComponents(gid fonts.GID) ([]fonts.GID, error) {
+	if int(gid) >= len(f.CharStrings) {
+		return nil, fmt.Errorf("invalid glyph index %d", gid)
+	}
+	fd, err := f.fontDict(gid)
+	if err != nil {
+		return nil, err
+	}
+	state := newType2State(fd.nominalWidthX, fd.defaultWidthX, make(map[int]bool), make(map[int]bool))
+	if err := getSubrsIndex(f.global.globalSubrIndex, fd.subrsIndex, f.CharStrings[gid], state); err != nil {
+		return nil, err
+	}
+	if !state.hasSeac {
+		return nil, nil
 	}
 
-	sort.Ints(usedSubrSlice)
+	var out []fonts.GID
+	for _, code := range state.seac {
+		if code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid seac character code %d", code)
+		}
+		name := simpleencodings.AdobeStandard[code]
+		for i := range f.charset {
+			if f.GlyphName(fonts.GID(i)) == name {
+				out = append(out, fonts.GID(i))
+				break
+			}
+		}
+	}
+	return out, nil
+}
 
-	subrIdx := 0
-	i := 0
-	for {
-		subri := usedSubrSlice[subrIdx]
-		for j := 0; j+i < subri; j++ {
-			subr[j+i] = []byte{}
+// subsetFontDicts removes the font dicts not used by the (sorted) `codepoints`
+// and updates FDSelect accordingly. The removed glyphs are attached to
+// the font dict of the previous kept glyph, to keep FDSelect compact.
+func (f *Font) subsetFontDicts(codepoints []fonts.GID) {
+	newIndices := make([]int, len(f.fdArray))
+	for i := range newIndices {
+		newIndices[i] = -1
+	}
+	for _, cp := range codepoints {
+		newIndices[f.fdSelect[cp]] = 0
+	}
+	var fdArray []*Font
+	for i, fd := range f.fdArray {
+		if newIndices[i] == -1 {
+			continue
 		}
-		i = subri + 1
-		subrIdx++
+		newIndices[i] = len(fdArray)
+		fdArray = append(fdArray, fd)
+	}
 
-		if subrIdx >= len(usedSubrSlice) {
-			break
+	current := uint8(newIndices[f.fdSelect[codepoints[0]]])
+	for gid, cpIdx := 0, 0; gid < len(f.fdSelect); gid++ {
+		if cpIdx < len(codepoints) && int(codepoints[cpIdx]) == gid {
+			current = uint8(newIndices[f.fdSelect[gid]])
+			cpIdx++
 		}
+		f.fdSelect[gid] = current
 	}
-	lastidx := usedSubrSlice[len(usedSubrSlice)-1]
-	subr = subr[:lastidx+1]
+	f.fdArray = fdArray
+}
 
+// subsetSubrs returns a copy of `subrs` where the subroutines not used are
+// replaced by empty ones. The indices of the subroutines are not modified.
+func subsetSubrs(subrs [][]byte, used map[int]bool) [][]byte {
+	out := make([][]byte, len(subrs))
+	for i, subr := range subrs {
+		if used[i] {
+			out[i] = subr
+		} else {
+			out[i] = []byte{}
+		}
+	}
+	return out
 }
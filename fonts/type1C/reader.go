@@ -21,6 +21,11 @@ func read3uint32(r io.Reader) uint32 {
 
 func readBytes(r io.Reader, n int) []byte {
 	b := make([]byte, n)
+	if n == 0 {
+		// empty entries (like unused subroutines) may be found at the end of the data,
+		// where Read would return io.EOF
+		return b
+	}
 	l, err := r.Read(b)
 	if err != nil {
 		panic(err)
@@ -103,6 +108,7 @@ func (c *CFF) readDictIndex(r io.Reader) error {
 		fnt := &Font{
 			underlineThickness: 50,
 			underlinePosition:  -100,
+			cidcount:           8720,
 		}
 		fnt.parseDict(cffFont)
 		c.Font = append(c.Font, fnt)
@@ -237,6 +243,15 @@ func Parse(r io.ReadSeeker) (*Font, error) {
 		if fnt.subrsOffset > 0 {
 			fnt.parseIndex(r, LocalSubrsIndex)
 		}
+		if fnt.IsCIDFont() {
+			// FDSelect is validated against the FDArray
+			if err := fnt.parseIndex(r, FDArrayIndex); err != nil {
+				return nil, err
+			}
+			if err := fnt.parseIndex(r, FDSelect); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return cff.Font[0], nil
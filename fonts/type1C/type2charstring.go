@@ -14,11 +14,6 @@ func calculateBias(subrs [][]byte) int {
 	return 32768
 }
 
-var (
-	usedGlobalSubrsMap map[int]bool
-	usedLocalSubrsMap  map[int]bool
-)
-
 type type2state struct {
 	stack         []int
 	cHints        int
@@ -26,6 +21,25 @@ type type2state struct {
 	defaultWidthX int
 	nominalWidthX int
 	width         int
+
+	// subroutines used by the charstrings
+	usedGlobalSubrs map[int]bool
+	usedLocalSubrs  map[int]bool
+
+	// standard encoding codes of the base and accent
+	// characters, for the seac form of endchar
+	seac    [2]int
+	hasSeac bool
+}
+
+func newType2State(nominalWidthX, defaultWidthX int, usedGlobalSubrs, usedLocalSubrs map[int]bool) *type2state {
+	return &type2state{
+		stack:           make([]int, 0, 48),
+		nominalWidthX:   nominalWidthX,
+		defaultWidthX:   defaultWidthX,
+		usedGlobalSubrs: usedGlobalSubrs,
+		usedLocalSubrs:  usedLocalSubrs,
+	}
 }
 
 func (state *type2state) clearStack() {
@@ -71,16 +85,9 @@ func (state *type2state) checkWd() bool {
 }
 
 // getSubrsIndex goes recursively into all subroutines called by the char string cs and
-// sets the entries in the global maps usedGlobalSubrsMap and usedLocalSubrsMap to true
+// sets the entries in the maps state.usedGlobalSubrs and state.usedLocalSubrs to true
 // if the subroutine is used.
-func getSubrsIndex(nominalWidthX int, defaultWidthX int, globalSubrs [][]byte, localSubrs [][]byte, cs []byte, state *type2state) {
-	if state == nil {
-		state = &type2state{}
-		state.stack = make([]int, 0, 48)
-		state.nominalWidthX = nominalWidthX
-		state.defaultWidthX = defaultWidthX
-	}
-
+func getSubrsIndex(globalSubrs [][]byte, localSubrs [][]byte, cs []byte, state *type2state) error {
 	localBias := calculateBias(localSubrs)
 	globalBias := calculateBias(globalSubrs)
 
@@ -118,24 +125,28 @@ func getSubrsIndex(nominalWidthX int, defaultWidthX int, globalSubrs [][]byte, l
 			// callsubr
 			subrIdx := state.pop() + localBias
 
-			if subrIdx < len(localSubrs) {
-				getSubrsIndex(nominalWidthX, defaultWidthX, globalSubrs, localSubrs, localSubrs[subrIdx], state)
-				usedLocalSubrsMap[subrIdx] = true
-			} else {
-				panic("subrIdx must be < len(localSubrs)")
+			if subrIdx < 0 || subrIdx >= len(localSubrs) {
+				return fmt.Errorf("invalid local subroutine index %d", subrIdx)
 			}
+			if err := getSubrsIndex(globalSubrs, localSubrs, localSubrs[subrIdx], state); err != nil {
+				return err
+			}
+			state.usedLocalSubrs[subrIdx] = true
 			state.checkWd()
 		} else if b0 == 11 {
 			// return
 		} else if b0 == 12 {
 			// escape
-			b1 := cs[pos+1]
 			pos++
-			if b1 == 1 {
-
-			}
+			state.clearStack()
 		} else if b0 == 14 {
-			// endchar
+			// endchar, with the optional (deprecated) seac arguments:
+			// adx ady bchar achar
+			if L := len(state.stack); L >= 4 {
+				state.seac = [2]int{state.stack[L-2], state.stack[L-1]}
+				state.hasSeac = true
+			}
+			state.clearStack()
 		} else if b0 == 18 {
 			// hstemhm
 			state.cHints += state.clearEven()
@@ -196,12 +207,13 @@ func getSubrsIndex(nominalWidthX int, defaultWidthX int, globalSubrs [][]byte, l
 		} else if b0 == 29 {
 			top := state.pop()
 			subrIdx := top + globalBias
-			if subrIdx < len(globalSubrs) {
-				getSubrsIndex(nominalWidthX, defaultWidthX, globalSubrs, localSubrs, globalSubrs[subrIdx], state)
-				usedGlobalSubrsMap[subrIdx] = true
-			} else {
-				panic("subrIdx must be < len(globalSubrs)")
+			if subrIdx < 0 || subrIdx >= len(globalSubrs) {
+				return fmt.Errorf("invalid global subroutine index %d", subrIdx)
+			}
+			if err := getSubrsIndex(globalSubrs, localSubrs, globalSubrs[subrIdx], state); err != nil {
+				return err
 			}
+			state.usedGlobalSubrs[subrIdx] = true
 			state.checkWd()
 		} else if b0 == 30 {
 			// vhcurveto
@@ -237,4 +249,5 @@ func getSubrsIndex(nominalWidthX int, defaultWidthX int, globalSubrs [][]byte, l
 			// state.clearStack()
 		}
 	}
+	return nil
 }
@@ -17,6 +17,8 @@ const (
 	CharStringsIndex
 	PrivateDict
 	LocalSubrsIndex
+	FDArrayIndex
+	FDSelect
 )
 
 func (mi mainIndex) String() string {
@@ -39,6 +41,10 @@ func (mi mainIndex) String() string {
 		return "PrivateDict"
 	case LocalSubrsIndex:
 		return "LocalSubrsIndex"
+	case FDArrayIndex:
+		return "FDArrayIndex"
+	case FDSelect:
+		return "FDSelect"
 	}
 	return ""
 }
@@ -84,8 +90,10 @@ type Font struct {
 	encodingFormat     uint8
 	familyblues        []int
 	familyotherblues   []int
-	fdarray            int64
-	fdselect           int64
+	fdarrayOffset      int64
+	fdArray            []*Font // Font DICTs of a CID-keyed font, with their Private DICT
+	fdselectOffset     int64
+	fdSelect           []uint8 // index into fdArray, for each glyph
 	fullname           SID
 	familyname         SID
 	initialRandomSeed  int
@@ -208,15 +208,28 @@ func (c *CFF) WriteCFFData(w io.Writer) error {
 	cf.encodingOffset = 0
 
 	// the encoded size of the offsets can change. We calculate the delta and add this to the baselen
-	prevLen := len(cffDictEncodeNumber(int64(cf.charstringsOffset))) + len(cffDictEncodeNumber(int64(cf.charsetOffset))) + len(cffDictEncodeNumber(int64(cf.privatedictoffset)))
-	newLen := len(cffDictEncodeNumber(int64(baselen+fi.CharStringsOffset))) + len(cffDictEncodeNumber(int64(baselen+fi.CharSetOffset))) + len(cffDictEncodeNumber(int64(baselen+fi.PrivateDictOffset)))
+	prevLen := len(cffDictEncodeNumber(int64(cf.charstringsOffset))) + len(cffDictEncodeNumber(int64(cf.charsetOffset)))
+	newLen := len(cffDictEncodeNumber(int64(baselen+fi.CharStringsOffset))) + len(cffDictEncodeNumber(int64(baselen+fi.CharSetOffset)))
+	if cf.IsCIDFont() {
+		// the FDArray is written after the data of the font
+		prevLen += len(cffDictEncodeNumber(cf.fdselectOffset)) + len(cffDictEncodeNumber(cf.fdarrayOffset))
+		newLen += len(cffDictEncodeNumber(int64(baselen+fi.FDSelectOffset))) + len(cffDictEncodeNumber(int64(baselen+len(cf.data))))
+	} else {
+		prevLen += len(cffDictEncodeNumber(int64(cf.privatedictoffset)))
+		newLen += len(cffDictEncodeNumber(int64(baselen + fi.PrivateDictOffset)))
+	}
 	delta := newLen - prevLen
 
 	baselen += delta
 	cf.charstringsOffset = int64(baselen + fi.CharStringsOffset)
 	cf.charsetOffset = int64(baselen + fi.CharSetOffset)
-	cf.privatedictoffset = int64(baselen + fi.PrivateDictOffset)
-	cf.privatedictsize = fi.PrivateDictSize
+	if cf.IsCIDFont() {
+		cf.fdselectOffset = int64(baselen + fi.FDSelectOffset)
+		cf.fdarrayOffset = int64(baselen + len(cf.data))
+	} else {
+		cf.privatedictoffset = int64(baselen + fi.PrivateDictOffset)
+		cf.privatedictsize = fi.PrivateDictSize
+	}
 
 	// now we can write all data
 	// header + NameIndex is already written to w
@@ -234,6 +247,12 @@ func (c *CFF) WriteCFFData(w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if cf.IsCIDFont() {
+		_, err = cf.writeFDArray(w, baselen, fi)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -249,15 +268,25 @@ type fontinfo struct {
 	EncodingOffset    int
 	PrivateDictSize   int
 	PrivateDictOffset int
+	FDSelectOffset    int
+	// for CID-keyed fonts, the offsets of the private dict
+	// of each font dict
+	FontDictPrivateOffsets []int
 }
 
-// fontInfo writes the charstrings, charset and private dict index
+// fontInfo writes the charstrings, charset and private dict index.
+// For CID-keyed fonts, the FDSelect and the private dict and local subrs
+// of each font dict are written instead of the private dict.
 func (f *Font) fontInfo() (*fontinfo, error) {
 	fi := &fontinfo{}
 	fi.CharSetOffset = 0
 	var b bytes.Buffer
 
-	for _, index := range []mainIndex{CharStringsIndex, CharSet, PrivateDict, LocalSubrsIndex} {
+	indexes := []mainIndex{CharStringsIndex, CharSet, PrivateDict, LocalSubrsIndex}
+	if f.IsCIDFont() {
+		indexes = []mainIndex{CharStringsIndex, CharSet, FDSelect}
+	}
+	for _, index := range indexes {
 		switch index {
 		case CharSet:
 			fi.CharSetOffset = b.Len()
@@ -270,6 +299,8 @@ func (f *Font) fontInfo() (*fontinfo, error) {
 		case LocalSubrsIndex:
 			cur := b.Len()
 			fi.PrivateDictSize = cur - fi.PrivateDictOffset
+		case FDSelect:
+			fi.FDSelectOffset = b.Len()
 		}
 		if index != LocalSubrsIndex || len(f.subrsIndex) > 0 {
 			if _, err := f.writeIndex(&b, index); err != nil {
@@ -278,10 +309,34 @@ func (f *Font) fontInfo() (*fontinfo, error) {
 		}
 
 	}
+
+	for _, fd := range f.fdArray {
+		fi.FontDictPrivateOffsets = append(fi.FontDictPrivateOffsets, b.Len())
+		l, err := fd.writePrivateDict(&b)
+		if err != nil {
+			return nil, err
+		}
+		fd.privatedictsize = l
+		if _, err = fd.writeLocalSubrsIndex(&b); err != nil {
+			return nil, err
+		}
+	}
 	f.data = b.Bytes()
 	return fi, nil
 }
 
+// writeFDArray writes the font dicts of a CID-keyed font. The private dicts
+// have already been written by fontInfo, `baselen` being the offset of the
+// data of the font.
+func (f *Font) writeFDArray(w io.Writer, baselen int, fi *fontinfo) (int, error) {
+	data := make([][]byte, len(f.fdArray))
+	for i, fd := range f.fdArray {
+		fd.privatedictoffset = int64(baselen + fi.FontDictPrivateOffsets[i])
+		data[i] = fd.cffEncodeFontDict()
+	}
+	return writeIndexData(w, data, "fdarray")
+}
+
 // cffDictEncodeFloat encodes a number. If the number is an integer number, it will be encoded by cffDictEncodeNumber().
 func cffDictEncodeFloat(num float64) []byte {
 	if math.Abs(float64(int(num))-num) < 0.0001 {
@@ -370,6 +425,13 @@ func cffDictEncodeNumber(num int64) []byte {
 // cffEncodeTopDict returns a byte slice of the encoded dictionary
 func (f *Font) cffEncodeTopDict() []byte {
 	var b []byte
+	if f.IsCIDFont() {
+		// ROS must be the first operator
+		b = append(b, cffDictEncodeNumber(int64(f.registry))...)
+		b = append(b, cffDictEncodeNumber(int64(f.ordering))...)
+		b = append(b, cffDictEncodeNumber(int64(f.supplement))...)
+		b = append(b, 12, 30)
+	}
 	if i := f.version; i != 0 {
 		b = append(b, cffDictEncodeNumber(int64(i))...)
 		b = append(b, 0)
@@ -430,6 +492,30 @@ func (f *Font) cffEncodeTopDict() []byte {
 		b = append(b, cffDictEncodeNumber(int64(num))...)
 		b = append(b, 18)
 	}
+	if f.IsCIDFont() {
+		if num := f.cidcount; num != 8720 {
+			b = append(b, cffDictEncodeNumber(int64(num))...)
+			b = append(b, 12, 34)
+		}
+		b = append(b, cffDictEncodeNumber(f.fdarrayOffset)...)
+		b = append(b, 12, 36)
+		b = append(b, cffDictEncodeNumber(f.fdselectOffset)...)
+		b = append(b, 12, 37)
+	}
+	return b
+}
+
+// cffEncodeFontDict returns a byte slice of the encoded font dict
+// of a CID-keyed font, f being one entry of the FDArray.
+func (f *Font) cffEncodeFontDict() []byte {
+	var b []byte
+	if i := f.name; i != 0 {
+		b = append(b, cffDictEncodeNumber(int64(i))...)
+		b = append(b, 12, 38)
+	}
+	b = append(b, cffDictEncodeNumber(int64(f.privatedictsize))...)
+	b = append(b, cffDictEncodeNumber(f.privatedictoffset)...)
+	b = append(b, 18)
 	return b
 }
 
@@ -498,7 +584,13 @@ func (f *Font) cffEncodePrivateDict() []byte {
 		b = append(b, 21)
 	}
 	if len(f.subrsIndex) > 0 {
-		b = append(b, cffDictEncodeNumber(int64(len(b)+2))...)
+		// the local subrs directly follow the private dict, whose
+		// length depends on the size of the encoded offset
+		offset := len(b) + 2
+		for len(b)+len(cffDictEncodeNumber(int64(offset)))+1 != offset {
+			offset = len(b) + len(cffDictEncodeNumber(int64(offset))) + 1
+		}
+		b = append(b, cffDictEncodeNumber(int64(offset))...)
 		b = append(b, 19)
 	}
 	return b
@@ -634,6 +726,46 @@ func (f *Font) writeEncoding(w io.Writer) (int, error) {
 	return 2 + len(f.encoding), nil
 }
 
+// writeFDSelect writes the font dict index of each glyph, using
+// the smallest of format 0 and format 3.
+func (f *Font) writeFDSelect(w io.Writer) (int, error) {
+	type fdRange struct {
+		First uint16
+		FD    uint8
+	}
+	var ranges []fdRange
+	for gid, fd := range f.fdSelect {
+		if len(ranges) == 0 || ranges[len(ranges)-1].FD != fd {
+			ranges = append(ranges, fdRange{First: uint16(gid), FD: fd})
+		}
+	}
+
+	if len(f.fdSelect) <= 4+3*len(ranges) {
+		if err := write(w, uint8(0)); err != nil {
+			return 0, err
+		}
+		if err := write(w, f.fdSelect); err != nil {
+			return 0, err
+		}
+		return 1 + len(f.fdSelect), nil
+	}
+
+	if err := write(w, uint8(3)); err != nil {
+		return 0, err
+	}
+	if err := write(w, uint16(len(ranges))); err != nil {
+		return 0, err
+	}
+	if err := write(w, ranges); err != nil {
+		return 0, err
+	}
+	// sentinel
+	if err := write(w, uint16(len(f.fdSelect))); err != nil {
+		return 0, err
+	}
+	return 5 + 3*len(ranges), nil
+}
+
 // writeIndex returns the number of bytes written to the index and an error.
 func (f *Font) writeIndex(w io.Writer, index mainIndex) (int, error) {
 	switch index {
@@ -647,6 +779,8 @@ func (f *Font) writeIndex(w io.Writer, index mainIndex) (int, error) {
 		return f.writePrivateDict(w)
 	case LocalSubrsIndex:
 		return f.writeLocalSubrsIndex(w)
+	case FDSelect:
+		return f.writeFDSelect(w)
 	default:
 	}
 
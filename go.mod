@@ -3,6 +3,7 @@ module github.com/boxesandglue/textlayout
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/benoitkugler/pstokenizer v1.0.1
 	github.com/benoitkugler/textlayout-testdata v0.1.1
 	golang.org/x/image v0.18.0
@@ -1,7 +1,7 @@
 package harfbuzz
 
 import (
-	"math"
+	"fmt"
 	"sort"
 
 	"github.com/boxesandglue/textlayout/fonts"
@@ -32,9 +32,10 @@ const (
 	bsfHasDefaultIgnorables
 	bsfHasSpaceFallback
 	bsfHasGPOSAttachment
-	bsfHasUnsafeToBreak
+	bsfHasGlyphFlags
 	bsfHasCGJ
-	bsfDefault bufferScratchFlags = 0x00000000
+	bsfGlyphInput                    // the buffer was filled with glyphs, see AddGlyphs
+	bsfDefault    bufferScratchFlags = 0x00000000
 
 	// reserved for complex shapers' internal use.
 	bsfComplex0 bufferScratchFlags = 0x01000000
@@ -105,6 +106,11 @@ type Buffer struct {
 	scratchFlags bufferScratchFlags /* Have space-fallback, etc. */
 
 	haveOutput bool
+
+	content bufferContent // unicode or glyphs, used by Serialize
+
+	message      func(buf *Buffer, font *Font, msg string) bool // see SetMessageFunc
+	messageDepth int                                            // avoid recursive messages
 }
 
 // NewBuffer allocate a storage with default options.
@@ -122,16 +128,29 @@ func NewBuffer() *Buffer {
 // character in the input text stream and are output in the
 // `GlyphInfo.Cluster` field.
 // This also clears the posterior context (see `AddRunes`).
+// Since runes and glyphs can't be mixed, the glyphs held by the buffer (added with
+// `AddGlyph` or produced by `Shape`) are first discarded, as by `Clear`, but
+// the user settings, such as `Props` and `Flags`, are kept.
 func (b *Buffer) AddRune(codepoint rune, cluster int) {
 	b.append(codepoint, cluster)
 	b.clearContext(1)
 }
 
 func (b *Buffer) append(codepoint rune, cluster int) {
+	b.setContent(contentUnicode)
 	b.Info = append(b.Info, GlyphInfo{codepoint: codepoint, Cluster: cluster})
 	b.Pos = append(b.Pos, GlyphPosition{})
 }
 
+// setContent prepares `b` to be filled with `content`, discarding
+// the current runes or glyphs if they are of the other kind.
+func (b *Buffer) setContent(content bufferContent) {
+	if b.content != content {
+		b.clearContents()
+	}
+	b.content = content
+}
+
 // AddRunes appends characters from text array to b. itemOffset is the
 // position of the first character from text that will be appended, and
 // itemLength is the number of character to add (-1 means the end of the
@@ -142,7 +161,9 @@ func (b *Buffer) append(codepoint rune, cluster int) {
 // full context to be able, for example, to do cross-run Arabic shaping or
 // properly handle combining marks at start of run. The cluster value attributed
 // to each rune is the index in the text slice.
+// As AddRune, it discards the glyphs held by the buffer.
 func (b *Buffer) AddRunes(text []rune, itemOffset, itemLength int) {
+	b.setContent(contentUnicode)
 	/* If buffer is empty and pre-context provided, install it.
 	* This check is written this way, to make sure people can
 	* provide pre-context in one add_utf() call, then provide
@@ -173,6 +194,85 @@ func (b *Buffer) AddRunes(text []rune, itemOffset, itemLength int) {
 	b.context[1] = text[itemOffset+itemLength : s]
 }
 
+// AddGlyph appends a glyph to `b`, with the initial cluster value of `cluster`.
+// Buffers filled with glyphs instead of runes are shaped without the steps
+// depending on the Unicode properties of the text (see `AddGlyphs`).
+// Glyphs and runes can't be mixed in the same buffer: the runes held by
+// the buffer are first discarded, the user settings being kept (see `AddRune`).
+func (b *Buffer) AddGlyph(glyph fonts.GID, cluster int) {
+	b.setContent(contentGlyphs)
+	b.Info = append(b.Info, GlyphInfo{Glyph: glyph, Cluster: cluster})
+	b.Pos = append(b.Pos, GlyphPosition{})
+}
+
+// AddGlyphs appends glyphs from `glyphs` to b. itemOffset is the
+// position of the first glyph that will be appended, and
+// itemLength is the number of glyphs to add (-1 means the end of the
+// slice). The cluster value attributed to each glyph is its index in
+// the `glyphs` slice.
+//
+// This is useful to apply layout features to glyphs which have already been
+// selected, for instance the content of a PDF file : when shaping such a buffer,
+// the mapping from runes to glyphs, the normalization and the steps of the complex shapers
+// which depend on the Unicode properties of the text (such as Arabic joining or
+// Indic reordering) are skipped, so that only the OpenType (or AAT) layout
+// tables are applied. `Props` should be set explicitly, since it can't be guessed from
+// glyphs, and `features` may be used to select the features to apply. The default
+// features of the shaper are still applied, unless the `NoDefaultFeatures` flag is set.
+// Glyphs and runes can't be mixed in the same buffer (see `AddGlyph`).
+func (b *Buffer) AddGlyphs(glyphs []fonts.GID, itemOffset, itemLength int) {
+	if itemLength < 0 {
+		itemLength = len(glyphs) - itemOffset
+	}
+	for i, g := range glyphs[itemOffset : itemOffset+itemLength] {
+		b.AddGlyph(g, itemOffset+i)
+	}
+}
+
+// ToUnicode adds to `toUnicode` the text represented by each glyph of the shaped buffer,
+// as needed to build a /ToUnicode CMap in PDF files (see fonts.ToUnicodeCMap).
+// `text`, `itemOffset` and `itemLength` are the arguments given to AddRunes,
+// so that each cluster is mapped to its source runes: for instance, a ligature
+// glyph is mapped to all its components.
+// When a cluster is made of several glyphs, its text is attributed to the first one.
+// Glyphs already in `toUnicode` and the .notdef glyph are not modified, so that
+// the map may be filled by shaping several buffers.
+func (b *Buffer) ToUnicode(toUnicode map[fonts.GID][]rune, text []rune, itemOffset, itemLength int) {
+	if itemLength < 0 {
+		itemLength = len(text) - itemOffset
+	}
+	end := itemOffset + itemLength
+
+	// the source text of a cluster extends up to the next cluster
+	starts := make([]int, 0, len(b.Info))
+	for _, info := range b.Info {
+		starts = append(starts, info.Cluster)
+	}
+	sort.Ints(starts)
+	clusterEnd := func(cluster int) int {
+		i := sort.SearchInts(starts, cluster+1)
+		if i < len(starts) {
+			return starts[i]
+		}
+		return end
+	}
+
+	for i := 0; i < len(b.Info); {
+		// glyphs of the same cluster are contiguous
+		cluster, gid := b.Info[i].Cluster, b.Info[i].Glyph
+		for i < len(b.Info) && b.Info[i].Cluster == cluster {
+			i++
+		}
+		if gid == 0 || cluster < 0 || cluster >= end {
+			continue
+		}
+		if _, has := toUnicode[gid]; has {
+			continue
+		}
+		toUnicode[gid] = append([]rune(nil), text[cluster:clusterEnd(cluster)]...)
+	}
+}
+
 // GuessSegmentProperties fills unset buffer segment properties based on buffer Unicode
 // contents and can be used when no other information is available.
 //
@@ -221,9 +321,16 @@ func (b *Buffer) Clear() {
 	b.NotFound = 0
 
 	b.Props = SegmentProperties{}
+
+	b.clearContents()
+}
+
+// clearContents removes the runes or glyphs of `b`, keeping the user settings.
+func (b *Buffer) clearContents() {
 	b.scratchFlags = 0
 
 	b.haveOutput = false
+	b.content = contentUnknown
 
 	b.idx = 0
 	b.Info = b.Info[:0]
@@ -235,6 +342,35 @@ func (b *Buffer) Clear() {
 	b.serial = 0
 }
 
+// SetMessageFunc sets a callback, called at each step of the shaping process
+// with a short description of the step, such as "start table GSUB",
+// "start lookup 3", "end lookup 3" or "start reordering indic initial".
+// The buffer is in a consistent state when `f` is called, and may be inspected,
+// but should not be modified.
+//
+// For the start messages of tables, lookups and complex shaper steps, returning false
+// skips the step, which is useful to bisect font bugs. The return value is ignored
+// for the other messages.
+//
+// Passing nil disables the messages. The callback is preserved by `Clear`.
+func (b *Buffer) SetMessageFunc(f func(buf *Buffer, font *Font, msg string) bool) {
+	b.message = f
+}
+
+// messaging returns true if a message callback is set, and is not already running.
+func (b *Buffer) messaging() bool { return b.message != nil && b.messageDepth == 0 }
+
+// messagef formats and sends a message to the callback, if any, returning
+// false if the step should be skipped.
+func (b *Buffer) messagef(font *Font, format string, args ...interface{}) bool {
+	if !b.messaging() {
+		return true
+	}
+	b.messageDepth++
+	defer func() { b.messageDepth-- }()
+	return b.message(b, font, fmt.Sprintf(format, args...))
+}
+
 // cur returns the glyph at the cursor, optionally shifted by `i`.
 // Its simply a syntactic sugar for `&b.Info[b.idx+i] `
 func (b *Buffer) cur(i int) *GlyphInfo { return &b.Info[b.idx+i] }
@@ -440,18 +576,83 @@ done:
 	b.skipGlyph()
 }
 
-// unsafeToBreak adds the flag `GlyphFlagUnsafeToBreak`
+// unsafeToBreak adds the flags `GlyphUnsafeToBreak` and `GlyphUnsafeToConcat`
 // when needed, between `start` and `end`.
 func (b *Buffer) unsafeToBreak(start, end int) {
-	if end-start < 2 {
+	b.setGlyphFlags(GlyphUnsafeToBreak|GlyphUnsafeToConcat, start, end, true, false)
+}
+
+// safeToInsertTatweel adds the flag `GlyphSafeToInsertTatweel`
+// when needed, between `start` and `end`. If the flag is not
+// requested, it falls back to `unsafeToBreak`.
+func (b *Buffer) safeToInsertTatweel(start, end int) {
+	if b.Flags&ProduceSafeToInsertTatweel == 0 {
+		b.unsafeToBreak(start, end)
+		return
+	}
+	b.setGlyphFlags(GlyphSafeToInsertTatweel, start, end, true, false)
+}
+
+// unsafeToConcat adds the flag `GlyphUnsafeToConcat` on `start:end`,
+// if it is requested.
+func (b *Buffer) unsafeToConcat(start, end int) {
+	if b.Flags&ProduceUnsafeToConcat == 0 {
 		return
 	}
-	b.unsafeToBreakImpl(start, end)
+	b.setGlyphFlags(GlyphUnsafeToConcat, start, end, false, false)
 }
 
-func (b *Buffer) unsafeToBreakImpl(start, end int) {
-	cluster := findMinCluster(b.Info, start, end, maxInt)
-	b.unsafeToBreakSetMask(b.Info, start, end, cluster)
+func (b *Buffer) unsafeToBreakFromOutbuffer(start, end int) {
+	b.setGlyphFlags(GlyphUnsafeToBreak|GlyphUnsafeToConcat, start, end, true, true)
+}
+
+func (b *Buffer) unsafeToConcatFromOutbuffer(start, end int) {
+	if b.Flags&ProduceUnsafeToConcat == 0 {
+		return
+	}
+	b.setGlyphFlags(GlyphUnsafeToConcat, start, end, false, true)
+}
+
+// setGlyphFlags adds `mask` to the glyphs between `start` and `end`.
+// If `interior` is true, the glyphs of the first cluster are left untouched.
+// If `fromOutBuffer` is true, `start` is an index into the output buffer
+// and `end` an index into the input buffer.
+func (b *Buffer) setGlyphFlags(mask GlyphMask, start, end int, interior, fromOutBuffer bool) {
+	end = min(end, len(b.Info))
+
+	if interior && !fromOutBuffer && end-start < 2 {
+		return
+	}
+
+	b.scratchFlags |= bsfHasGlyphFlags
+
+	if !fromOutBuffer || !b.haveOutput {
+		if !interior {
+			for i := start; i < end; i++ {
+				b.Info[i].Mask |= mask
+			}
+		} else {
+			cluster := findMinCluster(b.Info, start, end, maxInt)
+			setGlyphFlagsInterior(b.Info, start, end, cluster, mask)
+		}
+	} else {
+		//   assert (start <= out_len);
+		//   assert (idx <= end);
+
+		if !interior {
+			for i := start; i < len(b.outInfo); i++ {
+				b.outInfo[i].Mask |= mask
+			}
+			for i := b.idx; i < end; i++ {
+				b.Info[i].Mask |= mask
+			}
+		} else {
+			cluster := findMinCluster(b.Info, b.idx, end, maxInt)
+			cluster = findMinCluster(b.outInfo, start, len(b.outInfo), cluster)
+			setGlyphFlagsInterior(b.outInfo, start, len(b.outInfo), cluster, mask)
+			setGlyphFlagsInterior(b.Info, b.idx, end, cluster, mask)
+		}
+	}
 }
 
 // return the smallest cluster between `cluster` and  infos[start:end]
@@ -462,33 +663,15 @@ func findMinCluster(infos []GlyphInfo, start, end, cluster int) int {
 	return cluster
 }
 
-func (b *Buffer) unsafeToBreakSetMask(infos []GlyphInfo,
-	start, end, cluster int,
-) {
+// adds `mask` to the glyphs of infos[start:end] not in `cluster`
+func setGlyphFlagsInterior(infos []GlyphInfo, start, end, cluster int, mask GlyphMask) {
 	for i := start; i < end; i++ {
 		if cluster != infos[i].Cluster {
-			b.scratchFlags |= bsfHasUnsafeToBreak
-			infos[i].Mask |= GlyphUnsafeToBreak
+			infos[i].Mask |= mask
 		}
 	}
 }
 
-func (b *Buffer) unsafeToBreakFromOutbuffer(start, end int) {
-	if !b.haveOutput {
-		b.unsafeToBreakImpl(start, end)
-		return
-	}
-
-	//   assert (start <= out_len);
-	//   assert (idx <= end);
-
-	cluster := math.MaxInt32
-	cluster = findMinCluster(b.outInfo, start, len(b.outInfo), cluster)
-	cluster = findMinCluster(b.Info, b.idx, end, cluster)
-	b.unsafeToBreakSetMask(b.outInfo, start, len(b.outInfo), cluster)
-	b.unsafeToBreakSetMask(b.Info, b.idx, end, cluster)
-}
-
 // reset `b.outInfo`, and adjust `pos` to have
 // same length as `Info` (without zeroing its values)
 func (b *Buffer) clearPositions() {
@@ -0,0 +1,491 @@
+package harfbuzz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/boxesandglue/textlayout/fonts"
+	"github.com/boxesandglue/textlayout/fonts/truetype"
+)
+
+// ported from harfbuzz/src/hb-buffer-serialize.cc Copyright © 2012,2013  Google, Inc. Behdad Esfahbod
+
+// SerializeFormat is the format used by Buffer.Serialize and Buffer.Deserialize.
+type SerializeFormat uint8
+
+const (
+	// SerializeFormatText is the compact format used by the hb-shape tool,
+	// such as [gid1=0+500|gid2=1@-20,10+0] for glyphs and <U+0061=0|U+0062=1>
+	// for Unicode characters.
+	SerializeFormatText SerializeFormat = iota
+	// SerializeFormatJSON is a JSON array of objects,
+	// such as [{"g":"gid1","cl":0,"dx":0,"dy":0,"ax":500,"ay":0}]
+	// for glyphs and [{"u":97,"cl":0}] for Unicode characters.
+	SerializeFormatJSON
+)
+
+// ParseSerializeFormat returns the format named `s`, "text" or "json".
+func ParseSerializeFormat(s string) (SerializeFormat, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return SerializeFormatText, nil
+	case "json":
+		return SerializeFormatJSON, nil
+	default:
+		return 0, fmt.Errorf("invalid serialize format %s", s)
+	}
+}
+
+// SerializeFlags controls what is written by Buffer.Serialize.
+type SerializeFlags uint8
+
+const (
+	// SerializeFlagNoClusters does not serialize the glyph cluster.
+	SerializeFlagNoClusters SerializeFlags = 1 << iota
+	// SerializeFlagNoPositions does not serialize the glyph position information.
+	SerializeFlagNoPositions
+	// SerializeFlagNoGlyphNames does not serialize the glyph names,
+	// but the glyph indices.
+	SerializeFlagNoGlyphNames
+	// SerializeFlagGlyphExtents serializes the glyph extents.
+	SerializeFlagGlyphExtents
+	// SerializeFlagGlyphFlags serializes the glyph flags (see GlyphUnsafeToBreak,
+	// GlyphUnsafeToConcat and GlyphSafeToInsertTatweel).
+	SerializeFlagGlyphFlags
+	// SerializeFlagNoAdvances does not serialize the glyph advances. The glyph
+	// offsets then include the accumulated advances of the previous glyphs.
+	SerializeFlagNoAdvances
+)
+
+// bufferContent records the kind of content of a buffer.
+type bufferContent uint8
+
+const (
+	contentUnknown bufferContent = iota // empty or filled by the user
+	contentUnicode                      // input runes, before shaping
+	contentGlyphs                       // output glyphs, after shaping
+)
+
+// Serialize returns a textual representation of the buffer, in the given `format`.
+// For shaped buffers, the glyphs are written, and their names are fetched from `font`.
+// For buffers with Unicode content, that is before shaping, the characters are
+// written, and `font` is not used.
+// The `flags` control which fields are included.
+// See Deserialize for the inverse operation.
+func (b *Buffer) Serialize(font *Font, format SerializeFormat, flags SerializeFlags) string {
+	if len(b.Info) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	if b.content == contentUnicode {
+		b.serializeUnicode(&out, format, flags)
+	} else {
+		b.serializeGlyphs(&out, font, format, flags)
+	}
+	return out.String()
+}
+
+func (b *Buffer) serializeUnicode(out *strings.Builder, format SerializeFormat, flags SerializeFlags) {
+	if format == SerializeFormatJSON {
+		out.WriteByte('[')
+		for i, info := range b.Info {
+			if i != 0 {
+				out.WriteByte(',')
+			}
+			fmt.Fprintf(out, `{"u":%d`, info.codepoint)
+			if flags&SerializeFlagNoClusters == 0 {
+				fmt.Fprintf(out, `,"cl":%d`, info.Cluster)
+			}
+			out.WriteByte('}')
+		}
+		out.WriteByte(']')
+		return
+	}
+
+	out.WriteByte('<')
+	for i, info := range b.Info {
+		if i != 0 {
+			out.WriteByte('|')
+		}
+		fmt.Fprintf(out, "U+%04X", info.codepoint)
+		if flags&SerializeFlagNoClusters == 0 {
+			fmt.Fprintf(out, "=%d", info.Cluster)
+		}
+	}
+	out.WriteByte('>')
+}
+
+func (b *Buffer) serializeGlyphs(out *strings.Builder, font *Font, format SerializeFormat, flags SerializeFlags) {
+	if font == nil { // names and extents are not available
+		flags |= SerializeFlagNoGlyphNames
+		flags &^= SerializeFlagGlyphExtents
+	}
+	isJSON := format == SerializeFormatJSON
+
+	out.WriteByte('[')
+	var x, y Position
+	for i, info := range b.Info {
+		pos := b.Pos[i]
+		if i != 0 {
+			if isJSON {
+				out.WriteByte(',')
+			} else {
+				out.WriteByte('|')
+			}
+		}
+
+		if isJSON {
+			out.WriteString(`{"g":`)
+			if flags&SerializeFlagNoGlyphNames != 0 {
+				fmt.Fprintf(out, "%d", info.Glyph)
+			} else {
+				out.WriteString(quoteJSON(font.glyphToString(info.Glyph)))
+			}
+			if flags&SerializeFlagNoClusters == 0 {
+				fmt.Fprintf(out, `,"cl":%d`, info.Cluster)
+			}
+			if flags&SerializeFlagNoPositions == 0 {
+				fmt.Fprintf(out, `,"dx":%d,"dy":%d`, x+pos.XOffset, y+pos.YOffset)
+				if flags&SerializeFlagNoAdvances == 0 {
+					fmt.Fprintf(out, `,"ax":%d,"ay":%d`, pos.XAdvance, pos.YAdvance)
+				}
+			}
+			if flags&SerializeFlagGlyphFlags != 0 && info.Mask&glyphFlagDefined != 0 {
+				fmt.Fprintf(out, `,"fl":%d`, info.Mask&glyphFlagDefined)
+			}
+			if flags&SerializeFlagGlyphExtents != 0 {
+				extents, _ := font.GlyphExtents(info.Glyph)
+				fmt.Fprintf(out, `,"xb":%d,"yb":%d,"w":%d,"h":%d`, extents.XBearing, extents.YBearing, extents.Width, extents.Height)
+			}
+			out.WriteByte('}')
+		} else {
+			if flags&SerializeFlagNoGlyphNames != 0 {
+				fmt.Fprintf(out, "%d", info.Glyph)
+			} else {
+				out.WriteString(font.glyphToString(info.Glyph))
+			}
+			if flags&SerializeFlagNoClusters == 0 {
+				fmt.Fprintf(out, "=%d", info.Cluster)
+			}
+			if flags&SerializeFlagNoPositions == 0 {
+				if x+pos.XOffset != 0 || y+pos.YOffset != 0 {
+					fmt.Fprintf(out, "@%d,%d", x+pos.XOffset, y+pos.YOffset)
+				}
+				if flags&SerializeFlagNoAdvances == 0 {
+					fmt.Fprintf(out, "+%d", pos.XAdvance)
+					if pos.YAdvance != 0 {
+						fmt.Fprintf(out, ",%d", pos.YAdvance)
+					}
+				}
+			}
+			if flags&SerializeFlagGlyphFlags != 0 && info.Mask&glyphFlagDefined != 0 {
+				fmt.Fprintf(out, "#%X", info.Mask&glyphFlagDefined)
+			}
+			if flags&SerializeFlagGlyphExtents != 0 {
+				extents, _ := font.GlyphExtents(info.Glyph)
+				fmt.Fprintf(out, "<%d,%d,%d,%d>", extents.XBearing, extents.YBearing, extents.Width, extents.Height)
+			}
+		}
+
+		if flags&SerializeFlagNoAdvances != 0 {
+			x += pos.XAdvance
+			y += pos.YAdvance
+		}
+	}
+	out.WriteByte(']')
+}
+
+// quoteJSON only escapes the quote and backslash characters, as HarfBuzz does.
+func quoteJSON(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteRune(r)
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// Deserialize parses `s`, as written by Serialize (or the hb-shape tool) in the given
+// `format`, and appends its content to the buffer, which must be empty or have the same
+// kind of content.
+// Both glyphs and Unicode characters are supported, and the kind of content is
+// deduced from `s`. For glyphs, the names are resolved using `font`, which
+// may be nil if only glyph indices are used. The glyph extents are ignored.
+func (b *Buffer) Deserialize(font *Font, s string, format SerializeFormat) error {
+	var (
+		items   []serializedItem
+		content bufferContent
+		err     error
+	)
+	if format == SerializeFormatJSON {
+		items, content, err = parseSerializedJSON(s)
+	} else {
+		items, content, err = parseSerializedText(s)
+	}
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if len(b.Info) != 0 && b.content != content {
+		return errors.New("invalid serialized content for the buffer")
+	}
+
+	var names map[string]fonts.GID // lazily built
+	for _, item := range items {
+		if content == contentUnicode {
+			b.append(item.codepoint, item.cluster)
+			continue
+		}
+
+		gid, isIndex := item.glyphIndex()
+		if !isIndex {
+			if font == nil {
+				return fmt.Errorf("invalid glyph index %s", item.glyph)
+			}
+			if names == nil {
+				names = font.glyphNames()
+			}
+			var ok bool
+			gid, ok = names[item.glyph]
+			if !ok {
+				return fmt.Errorf("unknown glyph name %s", item.glyph)
+			}
+		}
+		b.Info = append(b.Info, GlyphInfo{Glyph: gid, Cluster: item.cluster, Mask: item.mask & glyphFlagDefined})
+		b.Pos = append(b.Pos, item.pos)
+	}
+	b.content = content
+	return nil
+}
+
+// serializedItem stores one parsed glyph or character.
+type serializedItem struct {
+	glyph     string // name or index
+	codepoint rune
+	cluster   int
+	pos       GlyphPosition
+	mask      GlyphMask
+}
+
+// glyphIndex parses glyph indices, written as "12" or "gid12".
+func (item serializedItem) glyphIndex() (fonts.GID, bool) {
+	s := strings.TrimPrefix(item.glyph, "gid")
+	gid, err := strconv.ParseUint(s, 10, 16)
+	return fonts.GID(gid), err == nil
+}
+
+// glyphNames returns a map from glyph names to glyph indices,
+// the inverse of glyphToString.
+func (f *Font) glyphNames() map[string]fonts.GID {
+	numGlyphs := 0xFFFF
+	if tt, ok := f.face.(*truetype.Font); ok {
+		numGlyphs = tt.NumGlyphs
+	}
+	out := make(map[string]fonts.GID)
+	for gid := 0; gid < numGlyphs; gid++ {
+		if name := f.face.GlyphName(fonts.GID(gid)); name != "" {
+			if _, has := out[name]; !has {
+				out[name] = fonts.GID(gid)
+			}
+		}
+	}
+	return out
+}
+
+func parseSerializedText(s string) ([]serializedItem, bufferContent, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, contentUnknown, nil
+	}
+	content := contentGlyphs
+	switch s[0] {
+	case '<':
+		content = contentUnicode
+		if !strings.HasSuffix(s, ">") {
+			return nil, 0, errors.New("invalid serialized Unicode characters: missing '>'")
+		}
+		s = s[1 : len(s)-1]
+	case '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, 0, errors.New("invalid serialized glyphs: missing ']'")
+		}
+		s = s[1 : len(s)-1]
+	}
+
+	var items []serializedItem
+	for _, chunk := range strings.Split(s, "|") {
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+		var (
+			item serializedItem
+			err  error
+		)
+		if content == contentUnicode {
+			item, err = parseSerializedRune(chunk)
+		} else {
+			item, err = parseSerializedGlyph(chunk)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+	return items, content, nil
+}
+
+// parseSerializedRune parses U+0061=0
+func parseSerializedRune(chunk string) (item serializedItem, err error) {
+	code, cluster, hasCluster := strings.Cut(chunk, "=")
+	code = strings.TrimPrefix(strings.TrimPrefix(code, "U+"), "u+")
+	r, err := strconv.ParseUint(code, 16, 32)
+	if err != nil {
+		return item, fmt.Errorf("invalid serialized Unicode character %s", chunk)
+	}
+	item.codepoint = rune(r)
+	if hasCluster {
+		if item.cluster, err = strconv.Atoi(cluster); err != nil {
+			return item, fmt.Errorf("invalid serialized cluster in %s", chunk)
+		}
+	}
+	return item, nil
+}
+
+// parseSerializedGlyph parses name=cluster@dx,dy+ax,ay#flags<xb,yb,w,h>
+func parseSerializedGlyph(chunk string) (item serializedItem, err error) {
+	end := strings.IndexAny(chunk, "=@+#<")
+	if end == -1 {
+		end = len(chunk)
+	}
+	item.glyph, chunk = chunk[:end], chunk[end:]
+
+	// parseInts parses the comma separated values up to the next field
+	parseInts := func(maxCount int) ([]int, error) {
+		end := strings.IndexAny(chunk, "=@+#<>")
+		if end == -1 {
+			end = len(chunk)
+		}
+		fields := strings.Split(chunk[:end], ",")
+		chunk = chunk[end:]
+		if len(fields) > maxCount {
+			return nil, fmt.Errorf("invalid serialized glyph %s: too many values", item.glyph)
+		}
+		out := make([]int, len(fields))
+		for i, field := range fields {
+			out[i], err = strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, fmt.Errorf("invalid serialized glyph %s: %s", item.glyph, err)
+			}
+		}
+		return out, nil
+	}
+
+	for len(chunk) != 0 {
+		sep := chunk[0]
+		chunk = chunk[1:]
+		switch sep {
+		case '=':
+			values, err := parseInts(1)
+			if err != nil {
+				return item, err
+			}
+			item.cluster = values[0]
+		case '@':
+			values, err := parseInts(2)
+			if err != nil {
+				return item, err
+			}
+			if len(values) != 2 {
+				return item, fmt.Errorf("invalid serialized glyph %s: expected offsets", item.glyph)
+			}
+			item.pos.XOffset, item.pos.YOffset = Position(values[0]), Position(values[1])
+		case '+':
+			values, err := parseInts(2)
+			if err != nil {
+				return item, err
+			}
+			item.pos.XAdvance = Position(values[0])
+			if len(values) == 2 {
+				item.pos.YAdvance = Position(values[1])
+			}
+		case '#':
+			end := strings.IndexAny(chunk, "=@+<")
+			if end == -1 {
+				end = len(chunk)
+			}
+			mask, err := strconv.ParseUint(chunk[:end], 16, 32)
+			if err != nil {
+				return item, fmt.Errorf("invalid serialized glyph %s: %s", item.glyph, err)
+			}
+			item.mask = GlyphMask(mask)
+			chunk = chunk[end:]
+		case '<': // extents are ignored
+			end := strings.IndexByte(chunk, '>')
+			if end == -1 {
+				return item, fmt.Errorf("invalid serialized glyph %s: missing '>'", item.glyph)
+			}
+			chunk = chunk[end+1:]
+		default:
+			return item, fmt.Errorf("invalid serialized glyph %s: unexpected %q", item.glyph, sep)
+		}
+	}
+	return item, nil
+}
+
+func parseSerializedJSON(s string) ([]serializedItem, bufferContent, error) {
+	var entries []struct {
+		G  json.RawMessage `json:"g"`
+		U  *rune           `json:"u"`
+		Cl int             `json:"cl"`
+		Dx Position        `json:"dx"`
+		Dy Position        `json:"dy"`
+		Ax Position        `json:"ax"`
+		Ay Position        `json:"ay"`
+		Fl GlyphMask       `json:"fl"`
+	}
+	if err := json.Unmarshal([]byte(s), &entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid serialized JSON: %s", err)
+	}
+	if len(entries) == 0 {
+		return nil, contentUnknown, nil
+	}
+
+	content := contentGlyphs
+	if entries[0].U != nil {
+		content = contentUnicode
+	}
+	items := make([]serializedItem, len(entries))
+	for i, entry := range entries {
+		item := serializedItem{cluster: entry.Cl, mask: entry.Fl}
+		if content == contentUnicode {
+			if entry.U == nil {
+				return nil, 0, fmt.Errorf("invalid serialized JSON: missing Unicode character for item %d", i)
+			}
+			item.codepoint = *entry.U
+		} else {
+			if len(entry.G) == 0 {
+				return nil, 0, fmt.Errorf("invalid serialized JSON: missing glyph for item %d", i)
+			}
+			// either a name (string) or an index (number)
+			var name string
+			if err := json.Unmarshal(entry.G, &name); err == nil {
+				item.glyph = name
+			} else {
+				item.glyph = string(entry.G)
+			}
+			item.pos = GlyphPosition{XOffset: entry.Dx, YOffset: entry.Dy, XAdvance: entry.Ax, YAdvance: entry.Ay}
+		}
+		items[i] = item
+	}
+	return items, content, nil
+}
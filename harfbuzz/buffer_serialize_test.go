@@ -0,0 +1,124 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/textlayout/language"
+)
+
+func TestBufferSerializeUnicode(t *testing.T) {
+	b := NewBuffer()
+	b.AddRunes([]rune("abé"), 0, -1)
+
+	for _, test := range []struct {
+		format   SerializeFormat
+		flags    SerializeFlags
+		expected string
+	}{
+		{SerializeFormatText, 0, "<U+0061=0|U+0062=1|U+00E9=2>"},
+		{SerializeFormatText, SerializeFlagNoClusters, "<U+0061|U+0062|U+00E9>"},
+		{SerializeFormatJSON, 0, `[{"u":97,"cl":0},{"u":98,"cl":1},{"u":233,"cl":2}]`},
+		{SerializeFormatJSON, SerializeFlagNoClusters, `[{"u":97},{"u":98},{"u":233}]`},
+	} {
+		got := b.Serialize(nil, test.format, test.flags)
+		if got != test.expected {
+			t.Fatalf("expected %s, got %s", test.expected, got)
+		}
+
+		back := NewBuffer()
+		if err := back.Deserialize(nil, got, test.format); err != nil {
+			t.Fatal(err)
+		}
+		if got := back.Serialize(nil, test.format, test.flags); got != test.expected {
+			t.Fatalf("expected %s, got %s", test.expected, got)
+		}
+	}
+}
+
+func TestBufferSerializeGlyphs(t *testing.T) {
+	font := NewFont(openFontFileTT("DejaVuSerif.ttf"))
+
+	b := NewBuffer()
+	b.AddRunes([]rune("Tea"), 0, -1)
+	b.Props = SegmentProperties{Direction: LeftToRight, Script: language.Latin, Language: language.NewLanguage("en")}
+	b.Shape(font, nil)
+
+	for _, test := range []struct {
+		format SerializeFormat
+		flags  SerializeFlags
+	}{
+		{SerializeFormatText, 0},
+		{SerializeFormatText, SerializeFlagNoGlyphNames | SerializeFlagGlyphFlags},
+		{SerializeFormatText, SerializeFlagNoClusters | SerializeFlagGlyphExtents},
+		{SerializeFormatText, SerializeFlagNoPositions},
+		{SerializeFormatJSON, 0},
+		{SerializeFormatJSON, SerializeFlagNoGlyphNames | SerializeFlagGlyphFlags | SerializeFlagGlyphExtents},
+	} {
+		s := b.Serialize(font, test.format, test.flags)
+
+		back := NewBuffer()
+		if err := back.Deserialize(font, s, test.format); err != nil {
+			t.Fatal(err)
+		}
+		if got := back.Serialize(font, test.format, test.flags); got != s {
+			t.Fatalf("expected %s, got %s", s, got)
+		}
+	}
+
+	if got, exp := b.Serialize(font, SerializeFormatText, SerializeFlagGlyphFlags), "[T=0+1207|e=1+1212#1|a=2+1221]"; got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+	exp := `[{"g":55,"cl":0,"dx":0,"dy":0,"ax":1207,"ay":0},{"g":72,"cl":1,"dx":0,"dy":0,"ax":1212,"ay":0},{"g":68,"cl":2,"dx":0,"dy":0,"ax":1221,"ay":0}]`
+	if got := b.Serialize(font, SerializeFormatJSON, SerializeFlagNoGlyphNames); got != exp {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+
+	// glyph positions and flags are preserved, extents are ignored
+	back := NewBuffer()
+	if err := back.Deserialize(font, b.Serialize(font, SerializeFormatJSON, SerializeFlagGlyphFlags|SerializeFlagGlyphExtents), SerializeFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	for i := range b.Info {
+		assert(t, back.Info[i].Glyph == b.Info[i].Glyph)
+		assert(t, back.Info[i].Cluster == b.Info[i].Cluster)
+		assert(t, back.Info[i].Mask == b.Info[i].Mask&glyphFlagDefined)
+		assert(t, back.Pos[i] == b.Pos[i])
+	}
+}
+
+func TestBufferDeserialize(t *testing.T) {
+	b := NewBuffer()
+	err := b.Deserialize(nil, "[1=0@10,20+500,30#1|gid2=1+600<1,2,3,4>|3]", SerializeFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualInt(t, len(b.Info), 3)
+	assert(t, b.Info[0].Glyph == 1 && b.Info[1].Glyph == 2 && b.Info[2].Glyph == 3)
+	assert(t, b.Info[1].Cluster == 1 && b.Info[0].Mask == GlyphUnsafeToBreak)
+	assert(t, b.Pos[0] == GlyphPosition{XOffset: 10, YOffset: 20, XAdvance: 500, YAdvance: 30})
+	assert(t, b.Pos[1].XAdvance == 600)
+
+	// glyphs can't be added to a Unicode buffer
+	b = NewBuffer()
+	b.AddRune('a', 0)
+	if err := b.Deserialize(nil, "[1=0]", SerializeFormatText); err == nil {
+		t.Fatal("expected error for mixed content")
+	}
+
+	for _, invalid := range []struct {
+		s      string
+		format SerializeFormat
+	}{
+		{"[1=0", SerializeFormatText},
+		{"<U+XYZ=0>", SerializeFormatText},
+		{"[1=a]", SerializeFormatText},
+		{"[a=0]", SerializeFormatText}, // names require a font
+		{"[1@2]", SerializeFormatText},
+		{`[{"g":1,"cl":0}`, SerializeFormatJSON},
+		{`[{"u":97},{"g":1}]`, SerializeFormatJSON},
+	} {
+		if err := NewBuffer().Deserialize(nil, invalid.s, invalid.format); err == nil {
+			t.Fatalf("expected error for %s", invalid.s)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package harfbuzz
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/boxesandglue/textlayout/fonts"
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
 	"github.com/boxesandglue/textlayout/language"
 )
 
@@ -340,3 +343,260 @@ func bufferDiff(buffer, reference *Buffer, dottedcircleGlyph fonts.GID, position
 
 	return result
 }
+
+func TestBufferToUnicode(t *testing.T) {
+	for _, test := range []struct {
+		font, text string
+		itemOffset int
+		expected   []string // expected texts
+	}{
+		{"DejaVuSerif.ttf", "an offer", 3, []string{"o", "ff", "e", "r"}},
+		{"NotoSansArabic.ttf", "لا سلام", 0, []string{"لا", " ", "س", "م"}}, // with two lam-alef ligatures
+	} {
+		font := openFontFileTT(test.font)
+		text := []rune(test.text)
+		buf := NewBuffer()
+		buf.AddRunes(text, test.itemOffset, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(NewFont(font), nil)
+
+		toUnicode := make(map[fonts.GID][]rune)
+		buf.ToUnicode(toUnicode, text, test.itemOffset, -1)
+		texts := make(map[string]bool)
+		for _, runes := range toUnicode {
+			texts[string(runes)] = true
+		}
+		for _, exp := range test.expected {
+			if !texts[exp] {
+				t.Fatalf("%s: missing glyph for %q in %v", test.font, exp, texts)
+			}
+		}
+		if len(texts) != len(test.expected) {
+			t.Fatalf("%s: unexpected texts %v", test.font, texts)
+		}
+
+		// the ligature is written in the CMap of the subset
+		if test.itemOffset == 0 {
+			continue
+		}
+		var gids []fonts.GID
+		for gid := range toUnicode {
+			gids = append(gids, gid)
+		}
+		res, err := font.Subset(gids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for gid, runes := range toUnicode {
+			if string(runes) != "ff" {
+				continue
+			}
+			exp := fmt.Sprintf("<%04X><00660066>", res.GIDMap[gid])
+			if cmap := res.ToUnicodeCMap(toUnicode); !strings.Contains(cmap, exp) {
+				t.Fatalf("expected %s in CMap:\n%s", exp, cmap)
+			}
+		}
+	}
+}
+
+func TestBufferMessage(t *testing.T) {
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	shape := func(text string, message func(buf *Buffer, font *Font, msg string) bool) *Buffer {
+		b := NewBuffer()
+		b.SetMessageFunc(message)
+		b.AddRunes([]rune(text), 0, -1)
+		b.GuessSegmentProperties()
+		b.Shape(font, nil)
+		return b
+	}
+
+	var messages []string
+	ref := shape("ffi", func(buf *Buffer, _ *Font, msg string) bool {
+		messages = append(messages, msg)
+		return true
+	})
+	assert(t, len(messages) != 0 && messages[0] == "shaping with shaper ot")
+	for _, exp := range []string{"shaping with complex shaper default", "start table GSUB", "end table GSUB", "start table GPOS", "end table GPOS"} {
+		found := false
+		for _, msg := range messages {
+			found = found || msg == exp
+		}
+		if !found {
+			t.Fatalf("missing message %s in %v", exp, messages)
+		}
+	}
+	// lookups are properly nested
+	var lookup string
+	for _, msg := range messages {
+		if strings.HasPrefix(msg, "start lookup") {
+			assert(t, lookup == "")
+			lookup = strings.TrimPrefix(msg, "start ")
+		} else if strings.HasPrefix(msg, "end lookup") {
+			assert(t, lookup == strings.TrimPrefix(msg, "end "))
+			lookup = ""
+		}
+	}
+
+	// skipping GSUB disables ligatures
+	noLiga := shape("ffi", func(buf *Buffer, _ *Font, msg string) bool {
+		return msg != "start table GSUB"
+	})
+	assertEqualInt(t, len(ref.Info), 1)
+	assertEqualInt(t, len(noLiga.Info), 3)
+	// as well as skipping all its lookups
+	inGSUB := false
+	noLookups := shape("ffi", func(buf *Buffer, _ *Font, msg string) bool {
+		switch msg {
+		case "start table GSUB":
+			inGSUB = true
+		case "end table GSUB":
+			inGSUB = false
+		}
+		return !(inGSUB && strings.HasPrefix(msg, "start lookup"))
+	})
+	assertEqualInt(t, len(noLookups.Info), 3)
+	for i := range noLiga.Info {
+		assert(t, noLiga.Info[i].Glyph == noLookups.Info[i].Glyph)
+	}
+
+	// complex shapers steps
+	font = NewFont(openFontFile("harfbuzz_reference/in-house/fonts/d629e7fedc0b350222d7987345fe61613fa3929a.ttf"))
+	messages = messages[:0]
+	indic := shape("कि", func(buf *Buffer, _ *Font, msg string) bool {
+		messages = append(messages, msg)
+		return true
+	})
+	assert(t, strings.Contains(strings.Join(messages, ";"), "found 1 syllables;end stage"))
+	assert(t, strings.Contains(strings.Join(messages, ";"), "start reordering indic initial;end reordering indic initial"))
+	assert(t, strings.Contains(strings.Join(messages, ";"), "start reordering indic final;end reordering indic final"))
+
+	noReordering := shape("कि", func(buf *Buffer, _ *Font, msg string) bool {
+		return !strings.HasPrefix(msg, "start reordering")
+	})
+	assertEqualInt(t, len(indic.Info), 2)
+	assertEqualInt(t, len(noReordering.Info), 2)
+	assert(t, indic.Info[1].Glyph == noReordering.Info[0].Glyph) // the vowel sign is not moved before the consonant
+}
+
+func TestBufferUnsafeToConcat(t *testing.T) {
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+
+	shape := func(flags ShapingOptions) *Buffer {
+		b := NewBuffer()
+		b.AddRunes([]rune("office AV"), 0, -1)
+		b.Flags = flags
+		b.GuessSegmentProperties()
+		b.Shape(font, nil)
+		return b
+	}
+
+	b := shape(0)
+	for _, info := range b.Info {
+		assert(t, info.Mask&GlyphUnsafeToConcat == 0)
+	}
+
+	withConcat := shape(ProduceUnsafeToConcat)
+	assertEqualInt(t, len(withConcat.Info), len(b.Info))
+	hasConcat := false
+	for i, info := range withConcat.Info {
+		// shaping results are not modified
+		assert(t, info.Glyph == b.Info[i].Glyph && withConcat.Pos[i] == b.Pos[i])
+		// unsafe to break implies unsafe to concat
+		assert(t, info.Mask&GlyphUnsafeToBreak == b.Info[i].Mask&GlyphUnsafeToBreak)
+		if info.Mask&GlyphUnsafeToBreak != 0 {
+			assert(t, info.Mask&GlyphUnsafeToConcat != 0)
+		}
+		hasConcat = hasConcat || info.Mask&(GlyphUnsafeToConcat|GlyphUnsafeToBreak) == GlyphUnsafeToConcat
+	}
+	assert(t, hasConcat)
+}
+
+func TestBufferAddGlyphs(t *testing.T) {
+	nominalGlyphs := func(font *Font, text string) []fonts.GID {
+		var glyphs []fonts.GID
+		for _, r := range text {
+			g, _ := font.face.NominalGlyph(r)
+			glyphs = append(glyphs, g)
+		}
+		return glyphs
+	}
+	props := SegmentProperties{Direction: LeftToRight, Script: language.Latin, Language: language.NewLanguage("en")}
+
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	for _, features := range [][]Feature{nil, {{Tag: tt.NewTag('l', 'i', 'g', 'a'), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}} {
+		text := NewBuffer()
+		text.AddRunes([]rune("office AV"), 0, -1)
+		text.Props = props
+		text.Shape(font, features)
+
+		glyphs := NewBuffer()
+		glyphs.AddGlyphs(nominalGlyphs(font, "office AV"), 0, -1)
+		glyphs.Props = props
+		glyphs.Shape(font, features)
+
+		// same output for simple Latin text
+		assertEqualInt(t, len(glyphs.Info), len(text.Info))
+		for i, info := range glyphs.Info {
+			assert(t, info.Glyph == text.Info[i].Glyph)
+			assert(t, info.Cluster == text.Info[i].Cluster)
+			assert(t, glyphs.Pos[i] == text.Pos[i])
+		}
+	}
+
+	// item selection
+	b := NewBuffer()
+	b.AddGlyphs([]fonts.GID{1, 2, 3, 4}, 1, 2)
+	assertEqualInt(t, len(b.Info), 2)
+	assert(t, b.Info[0].Glyph == 2 && b.Info[0].Cluster == 1 && b.Info[1].Cluster == 2)
+	assert(t, b.Serialize(nil, SerializeFormatText, SerializeFlagNoPositions) == "[2=1|3=2]")
+
+	// Arabic joining is not applied to glyphs
+	font = NewFont(openFontFileTT("NotoSansArabic.ttf"))
+	input := nominalGlyphs(font, "بب")
+	b = NewBuffer()
+	b.AddGlyphs(input, 0, -1)
+	b.Props = SegmentProperties{Direction: RightToLeft, Script: language.Arabic, Language: language.NewLanguage("ar")}
+	b.Shape(font, nil)
+	assertEqualInt(t, len(b.Info), 2)
+	assert(t, b.Info[0].Glyph == input[1] && b.Info[1].Glyph == input[0])
+	assert(t, b.Info[0].Cluster == 1 && b.Info[1].Cluster == 0)
+
+	// the default features may be disabled
+	font = NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	input = nominalGlyphs(font, "office AV")
+	ligatures := []Feature{{Tag: tt.NewTag('l', 'i', 'g', 'a'), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}
+	for _, features := range [][]Feature{nil, ligatures} {
+		b = NewBuffer()
+		b.AddGlyphs(input, 0, -1)
+		b.Props = props
+		b.Flags = NoDefaultFeatures
+		b.Shape(font, features)
+		if features == nil { // glyphs and advances are not modified
+			assertEqualInt(t, len(b.Info), len(input))
+			for i, info := range b.Info {
+				assert(t, info.Glyph == input[i])
+				assert(t, b.Pos[i].XAdvance == font.GlyphHAdvance(input[i]))
+			}
+		} else { // the user features are still applied
+			assert(t, len(b.Info) < len(input))
+		}
+	}
+
+	// runes and glyphs can't be mixed: the previous content is discarded
+	b = NewBuffer()
+	b.AddGlyph(1, 0)
+	b.AddRune('a', 1)
+	assertEqualInt(t, len(b.Info), 1)
+	assert(t, b.content == contentUnicode && b.Info[0].codepoint == 'a')
+	b.Props = props
+	b.AddGlyph(2, 2)
+	assertEqualInt(t, len(b.Info), 1)
+	assert(t, b.content == contentGlyphs && b.Info[0].Glyph == 2 && b.Props == props)
+	// a shaped buffer may be re-filled
+	b = NewBuffer()
+	b.AddRunes([]rune("abc"), 0, -1)
+	b.Shape(font, nil)
+	b.AddRunes([]rune("ab"), 0, -1)
+	b.Shape(font, nil)
+	assertEqualInt(t, len(b.Info), 2)
+}
@@ -21,13 +21,16 @@ func (shaperFallback) shape(font *Font, buffer *Buffer, _ []Feature) {
 	direction := buffer.Props.Direction
 	info := buffer.Info
 	pos := buffer.Pos
+	glyphInput := buffer.content == contentGlyphs
 	for i := range info {
-		if hasSpace && uni.isDefaultIgnorable(info[i].codepoint) {
+		if !glyphInput && hasSpace && uni.isDefaultIgnorable(info[i].codepoint) {
 			info[i].Glyph = space
 			pos[i].XAdvance = 0
 			pos[i].YAdvance = 0
 		} else {
-			info[i].Glyph, _ = font.face.NominalGlyph(info[i].codepoint)
+			if !glyphInput { // glyphs are already selected
+				info[i].Glyph, _ = font.face.NominalGlyph(info[i].codepoint)
+			}
 			pos[i].XAdvance, pos[i].YAdvance = font.GlyphAdvanceForDirection(info[i].Glyph, direction)
 			pos[i].XOffset, pos[i].YOffset = font.subtractGlyphOriginForDirection(info[i].Glyph, direction,
 				pos[i].XOffset, pos[i].YOffset)
@@ -0,0 +1,207 @@
+package harfbuzz
+
+import (
+	"sort"
+
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// ShapeWithFallback shapes the buffer with the first font of `fontChain`, and then
+// shapes again the clusters whose glyph is not found in the font (see `NotFound`)
+// with the following fonts, in order, until every glyph is found or all
+// the fonts have been tried.
+//
+// Each range of missing clusters is grown to the closest boundaries where it is
+// safe to break the text (see `GlyphUnsafeToBreak`), and is shaped with its
+// surrounding text as context. The results are merged into the buffer, so that
+// `Info` and `Pos` hold one positioned glyph run. The index in `fontChain` of the font
+// used for each glyph is returned, with the same length as `Info`.
+//
+// The positions of the glyphs shaped with a fallback font are rescaled to the
+// `XScale` and `YScale` of the first font.
+// The clusters of the input must be increasing, as set by `AddRunes`.
+// Buffers filled with glyphs (see `AddGlyphs`) are only shaped with the first font.
+// If `fontChain` is empty, the buffer is left unchanged and nil is returned.
+func (b *Buffer) ShapeWithFallback(fontChain []*Font, features []Feature) []int {
+	if len(fontChain) == 0 {
+		return nil
+	}
+	input := append([]GlyphInfo(nil), b.Info...)
+	content := b.content
+	b.Shape(fontChain[0], features)
+
+	sources := make([]int, len(b.Info))
+	if content == contentGlyphs {
+		return sources
+	}
+
+	fb := fontFallback{
+		buffer:   b,
+		first:    fontChain[0],
+		input:    input,
+		features: features,
+		infos:    b.Info,
+		pos:      b.Pos,
+		sources:  sources,
+	}
+	backward := b.Props.Direction.isBackward()
+	if backward { // work in logical order
+		fb.reverse()
+	}
+	for i := 1; i < len(fontChain) && fb.hasNotFound(); i++ {
+		fb.reshape(fontChain[i], i)
+	}
+	if backward {
+		fb.reverse()
+	}
+
+	b.Info, b.Pos = fb.infos, fb.pos
+	return fb.sources
+}
+
+// fontFallback stores the merged shaping results, in logical order
+type fontFallback struct {
+	buffer   *Buffer
+	first    *Font       // the font defining the scale of the positions
+	input    []GlyphInfo // the runes before shaping
+	features []Feature
+
+	infos   []GlyphInfo
+	pos     []GlyphPosition
+	sources []int // font index of each glyph
+}
+
+func (fb *fontFallback) reverse() {
+	for i, j := 0, len(fb.infos)-1; i < j; i, j = i+1, j-1 {
+		fb.infos[i], fb.infos[j] = fb.infos[j], fb.infos[i]
+		fb.pos[i], fb.pos[j] = fb.pos[j], fb.pos[i]
+		fb.sources[i], fb.sources[j] = fb.sources[j], fb.sources[i]
+	}
+}
+
+func (fb *fontFallback) isNotFound(i int) bool { return fb.infos[i].Glyph == fb.buffer.NotFound }
+
+func (fb *fontFallback) hasNotFound() bool {
+	for i := range fb.infos {
+		if fb.isNotFound(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBreakSafe returns true if the glyph run may be split before the glyph `i`
+func (fb *fontFallback) isBreakSafe(i int) bool {
+	if i <= 0 || i >= len(fb.infos) {
+		return true
+	}
+	return fb.infos[i].Cluster != fb.infos[i-1].Cluster && fb.infos[i].Mask&GlyphUnsafeToBreak == 0
+}
+
+// reshape shapes the ranges containing glyphs not found with `font`,
+// whose index is `fontIndex`
+func (fb *fontFallback) reshape(font *Font, fontIndex int) {
+	for i := 0; i < len(fb.infos); i++ {
+		if !fb.isNotFound(i) {
+			continue
+		}
+		start, end := i, i+1
+		for !fb.isBreakSafe(start) {
+			start--
+		}
+		for end < len(fb.infos) && (fb.isNotFound(end) || !fb.isBreakSafe(end)) {
+			end++
+		}
+
+		infos, pos := fb.shapeRange(font, start, end)
+		i = end - 1
+		if countNotFound(infos, fb.buffer.NotFound) >= countNotFound(fb.infos[start:end], fb.buffer.NotFound) {
+			continue // no improvement: keep the current glyphs
+		}
+
+		fb.rescale(pos, font)
+		sources := make([]int, len(infos))
+		for j := range sources {
+			sources[j] = fontIndex
+		}
+		fb.infos = append(fb.infos[:start], append(infos, fb.infos[end:]...)...)
+		fb.pos = append(fb.pos[:start], append(pos, fb.pos[end:]...)...)
+		fb.sources = append(fb.sources[:start], append(sources, fb.sources[end:]...)...)
+		i = start + len(infos) - 1
+	}
+}
+
+// shapeRange shapes the input runes corresponding to the glyphs [start, end)
+// with `font`, returning the glyphs in logical order.
+func (fb *fontFallback) shapeRange(font *Font, start, end int) ([]GlyphInfo, []GlyphPosition) {
+	// find the input runes, whose clusters are increasing
+	inStart := sort.Search(len(fb.input), func(i int) bool { return fb.input[i].Cluster >= fb.infos[start].Cluster })
+	inEnd := len(fb.input)
+	if end < len(fb.infos) {
+		inEnd = sort.Search(len(fb.input), func(i int) bool { return fb.input[i].Cluster >= fb.infos[end].Cluster })
+	}
+
+	b := fb.buffer
+	text := make([]rune, len(fb.input))
+	for i, info := range fb.input {
+		text[i] = info.codepoint
+	}
+	sub := NewBuffer()
+	sub.Props = b.Props
+	sub.ClusterLevel = b.ClusterLevel
+	sub.Invisible = b.Invisible
+	sub.NotFound = b.NotFound
+	sub.Flags = b.Flags
+	if inStart != 0 {
+		sub.Flags &^= Bot
+	} else {
+		sub.context[0] = append([]rune(nil), b.context[0]...)
+	}
+	sub.AddRunes(text, inStart, inEnd-inStart)
+	if inEnd != len(text) {
+		sub.Flags &^= Eot
+	} else {
+		sub.context[1] = append([]rune(nil), b.context[1]...)
+	}
+	sub.Shape(font, fb.features)
+
+	// restore the input clusters, which have been replaced by indices
+	for i := range sub.Info {
+		sub.Info[i].Cluster = fb.input[sub.Info[i].Cluster].Cluster
+	}
+	if sub.Props.Direction.isBackward() {
+		sub.Reverse()
+	}
+	return sub.Info, sub.Pos
+}
+
+// rescale converts `pos`, expressed in the scale of `font`,
+// to the scale of the first font
+func (fb *fontFallback) rescale(pos []GlyphPosition, font *Font) {
+	xFrom, yFrom, xTo, yTo := font.XScale, font.YScale, fb.first.XScale, fb.first.YScale
+	if xFrom == xTo && yFrom == yTo {
+		return
+	}
+	scale := func(v Position, from, to int32) Position {
+		if from == 0 {
+			return v
+		}
+		return Position(int64(v) * int64(to) / int64(from))
+	}
+	for i := range pos {
+		pos[i].XAdvance = scale(pos[i].XAdvance, xFrom, xTo)
+		pos[i].XOffset = scale(pos[i].XOffset, xFrom, xTo)
+		pos[i].YAdvance = scale(pos[i].YAdvance, yFrom, yTo)
+		pos[i].YOffset = scale(pos[i].YOffset, yFrom, yTo)
+	}
+}
+
+func countNotFound(infos []GlyphInfo, notFound fonts.GID) int {
+	count := 0
+	for _, info := range infos {
+		if info.Glyph == notFound {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,73 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/textlayout/language"
+)
+
+func TestShapeWithFallback(t *testing.T) {
+	arabic, latin := NewFont(openFontFileTT("NotoSansArabic.ttf")), NewFont(openFontFileTT("DejaVuSerif.ttf"))
+
+	text := []rune("سلام abc سلام")
+	buffer := NewBuffer()
+	buffer.Props = SegmentProperties{Language: "ar", Script: language.Arabic, Direction: RightToLeft}
+	buffer.AddRunes(text, 0, -1)
+	sources := buffer.ShapeWithFallback([]*Font{arabic, latin}, nil)
+
+	assertEqualInt(t, len(buffer.Info), len(sources))
+	assertEqualInt(t, len(buffer.Info), len(buffer.Pos))
+	for i, info := range buffer.Info {
+		if info.Glyph == buffer.NotFound {
+			t.Fatalf("unexpected .notdef glyph at %d", i)
+		}
+		// the glyphs are in visual order
+		if i > 0 && info.Cluster > buffer.Info[i-1].Cluster {
+			t.Fatalf("unexpected cluster order at %d", i)
+		}
+		isLatin := 'a' <= text[info.Cluster] && text[info.Cluster] <= 'c'
+		if expected := map[bool]int{true: 1, false: 0}[isLatin]; sources[i] != expected {
+			t.Fatalf("for cluster %d, expected font %d, got %d", info.Cluster, expected, sources[i])
+		}
+	}
+
+	// the Latin glyphs are the ones obtained with the second font,
+	// with positions in the scale of the first one
+	assert(t, latin.XScale != arabic.XScale)
+	ref := NewBuffer()
+	ref.Props = buffer.Props
+	ref.AddRunes(text, 5, 3)
+	ref.Shape(latin, nil)
+	var (
+		got    []GlyphInfo
+		gotPos []GlyphPosition
+	)
+	for i, info := range buffer.Info {
+		if sources[i] == 1 {
+			got = append(got, info)
+			gotPos = append(gotPos, buffer.Pos[i])
+		}
+	}
+	assertEqualInt(t, len(ref.Info), len(got))
+	for i := range got {
+		if got[i].Glyph != ref.Info[i].Glyph || got[i].Cluster != ref.Info[i].Cluster {
+			t.Fatalf("expected %v, got %v", ref.Info[i], got[i])
+		}
+		expected := ref.Pos[i].XAdvance * arabic.XScale / latin.XScale
+		assertEqualInt(t, int(expected), int(gotPos[i].XAdvance))
+	}
+
+	// a rune missing in every font is left as .notdef
+	buffer = NewBuffer()
+	buffer.Props = SegmentProperties{Language: "en", Script: language.Latin, Direction: LeftToRight}
+	buffer.AddRunes([]rune("a\uE000b"), 0, -1)
+	sources = buffer.ShapeWithFallback([]*Font{latin, arabic}, nil)
+	assertEqualInt(t, 3, len(buffer.Info))
+	assert(t, buffer.Info[1].Glyph == buffer.NotFound && sources[1] == 0)
+
+	// an empty chain leaves the buffer unchanged
+	buffer = NewBuffer()
+	buffer.AddRunes([]rune("abc"), 0, -1)
+	sources = buffer.ShapeWithFallback(nil, nil)
+	assert(t, sources == nil && len(buffer.Info) == 3 && buffer.Info[0].codepoint == 'a')
+}
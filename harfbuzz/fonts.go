@@ -64,6 +64,8 @@ type Font struct {
 	gsubAccels, gposAccels []otLayoutLookupAccelerator // accelerators for lookup
 	faceUpem               int32                       // cached value of Face.Upem()
 
+	plans *shapePlanCache // used by Buffer.Shape, shared by copies of the font
+
 	// Point size of the font. Set to zero to unset.
 	// This is used in AAT layout, when applying 'trak' table.
 	Ptem float32
@@ -80,6 +82,12 @@ type Font struct {
 	// Is is used to select bitmap sizes and to perform some OpenType
 	// positioning.
 	XPpem, YPpem uint16
+
+	// synthetic emboldening (in em units) and slant,
+	// see SetSyntheticBold and SetSyntheticSlant
+	xEmbolden, yEmbolden float32
+	emboldenInPlace      bool
+	slant                float32
 }
 
 // NewFont constructs a new font object from the specified face.
@@ -99,6 +107,7 @@ func NewFont(face Face) *Font {
 	font.faceUpem = Position(font.face.Upem())
 	font.XScale = font.faceUpem
 	font.YScale = font.faceUpem
+	font.plans = &shapePlanCache{size: defaultShapePlanCacheSize}
 
 	if opentypeFace, ok := face.(FaceOpenType); ok {
 		lt := opentypeFace.LayoutTables()
@@ -130,6 +139,64 @@ func (f *Font) SetVarCoordsDesign(coords []float32) {
 	}
 }
 
+// SetSyntheticBold defines the synthetic emboldening of the font, in em units:
+// the outlines are thickened by `xEmbolden` * `XScale` horizontally
+// and by `yEmbolden` * `YScale` vertically. Zero values disable the emboldening,
+// and typical values are around 0.02.
+//
+// If `inPlace` is false, the advances are also increased by the
+// amount of emboldening, so that glyphs do not overlap. Otherwise, they are kept unchanged,
+// which is useful when the glyph positions must not change, for instance to
+// emulate a hover effect.
+//
+// The emboldening applies to the advances, extents and outlines returned by the font,
+// and thus to the shaping results of the OpenType and fallback shapers.
+func (f *Font) SetSyntheticBold(xEmbolden, yEmbolden float32, inPlace bool) {
+	f.xEmbolden, f.yEmbolden, f.emboldenInPlace = xEmbolden, yEmbolden, inPlace
+}
+
+// SyntheticBold returns the synthetic emboldening of the font, as set
+// by SetSyntheticBold.
+func (f *Font) SyntheticBold() (xEmbolden, yEmbolden float32, inPlace bool) {
+	return f.xEmbolden, f.yEmbolden, f.emboldenInPlace
+}
+
+// SetSyntheticSlant defines the synthetic slant of the font, that is the
+// ratio of the horizontal shift to the vertical position, applied to the outlines.
+// A value of 0.2 is typical to emulate an oblique style, and 0 disables the slant.
+//
+// The slant applies to the extents, vertical origins and outlines returned by the font,
+// and to the mark positions computed during shaping.
+func (f *Font) SetSyntheticSlant(slant float32) { f.slant = slant }
+
+// SyntheticSlant returns the synthetic slant of the font, as set
+// by SetSyntheticSlant.
+func (f *Font) SyntheticSlant() float32 { return f.slant }
+
+// xStrength returns the horizontal emboldening, in font scale.
+func (f *Font) xStrength() Position {
+	if f.XScale < 0 {
+		return roundf(-float32(f.XScale) * f.xEmbolden)
+	}
+	return roundf(float32(f.XScale) * f.xEmbolden)
+}
+
+// yStrength returns the vertical emboldening, in font scale.
+func (f *Font) yStrength() Position {
+	if f.YScale < 0 {
+		return roundf(-float32(f.YScale) * f.yEmbolden)
+	}
+	return roundf(float32(f.YScale) * f.yEmbolden)
+}
+
+// slantXY returns the slant, adjusted for the font scale.
+func (f *Font) slantXY() float32 {
+	if f.slant == 0 || f.YScale == 0 {
+		return 0
+	}
+	return f.slant * float32(f.XScale) / float32(f.YScale)
+}
+
 // Face returns the underlying face.
 // Note that field is readonly, since some caching may happen
 // in the `NewFont` constructor.
@@ -175,10 +242,45 @@ func (f *Font) GlyphExtents(glyph fonts.GID) (out GlyphExtents, ok bool) {
 	if !ok {
 		return out, false
 	}
+	if f.xEmbolden != 0 || f.yEmbolden != 0 || f.slant != 0 {
+		// use the actual outline, if available
+		if outline, ok := f.GlyphOutline(glyph); ok {
+			return outlineExtents(outline), true
+		}
+	}
+
 	out.XBearing = f.emScalefX(ext.XBearing)
 	out.Width = f.emScalefX(ext.Width)
 	out.YBearing = f.emScalefY(ext.YBearing)
 	out.Height = f.emScalefY(ext.Height)
+
+	if slant := f.slantXY(); slant != 0 {
+		// slant the corners of the box, before emboldening it, as HarfBuzz does
+		x0, x1 := float32(out.XBearing), float32(out.XBearing+out.Width)
+		top, bottom := float32(out.YBearing), float32(out.YBearing+out.Height)
+		xMin := minf(x0+slant*top, x0+slant*bottom)
+		xMax := maxf(x1+slant*top, x1+slant*bottom)
+		out.XBearing = roundf(xMin)
+		out.Width = roundf(xMax) - out.XBearing
+	}
+
+	if xStrength, yStrength := f.xStrength(), f.yStrength(); xStrength != 0 || yStrength != 0 {
+		yShift := yStrength
+		if f.YScale < 0 {
+			yShift = -yShift
+		}
+		out.YBearing += yShift
+		out.Height -= yShift
+
+		xShift := xStrength
+		if f.XScale < 0 {
+			xShift = -xShift
+		}
+		if f.emboldenInPlace {
+			out.XBearing -= xShift / 2
+		}
+		out.Width += xShift
+	}
 	return out, true
 }
 
@@ -197,15 +299,30 @@ func (f *Font) GlyphAdvanceForDirection(glyph fonts.GID, dir Direction) (x, y Po
 // GlyphHAdvance fetches the advance for a glyph ID in the font,
 // for horizontal text segments.
 func (f *Font) GlyphHAdvance(glyph fonts.GID) Position {
-	adv := f.face.HorizontalAdvance(glyph)
-	return f.emScalefX(adv)
+	adv := f.emScalefX(f.face.HorizontalAdvance(glyph))
+	if !f.emboldenInPlace {
+		if f.XScale < 0 {
+			adv -= f.xStrength()
+		} else {
+			adv += f.xStrength()
+		}
+	}
+	return adv
 }
 
 // Fetches the advance for a glyph ID in the font,
 // for vertical text segments.
 func (f *Font) getGlyphVAdvance(glyph fonts.GID) Position {
-	adv := f.face.VerticalAdvance(glyph)
-	return f.emScalefY(adv)
+	adv := f.emScalefY(f.face.VerticalAdvance(glyph))
+	// vertical advances are negative
+	if !f.emboldenInPlace {
+		if f.YScale < 0 {
+			adv += f.yStrength()
+		} else {
+			adv -= f.yStrength()
+		}
+	}
+	return adv
 }
 
 // Subtracts the origin coordinates from an (X,Y) point coordinate,
@@ -250,9 +367,13 @@ func (f *Font) getGlyphVOriginWithFallback(glyph fonts.GID) (Position, Position)
 		x, y, ok = f.face.GlyphHOrigin(glyph)
 		if ok {
 			dx, dy := f.guessVOriginMinusHOrigin(glyph)
-			return x + dx, y + dy
+			x, y = x+dx, y+dy
 		}
 	}
+	// follow the slanted outline
+	if slant := f.slantXY(); slant != 0 {
+		x += roundf(slant * float32(y))
+	}
 	return x, y
 }
 
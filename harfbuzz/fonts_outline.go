@@ -0,0 +1,245 @@
+package harfbuzz
+
+import (
+	"math"
+
+	"github.com/boxesandglue/textlayout/fonts"
+)
+
+// ported from harfbuzz/src/hb-outline.cc Copyright © 2023  Behdad Esfahbod,
+// itself adapted from FreeType's FT_Outline_EmboldenXY
+
+// GlyphOutline returns the outline of `glyph`, scaled to the font scale (see `XScale` and `YScale`),
+// with the synthetic slant and emboldening of the font applied.
+// For color glyphs (COLR or SVG), the regular outline provided by the font is used.
+// It returns false if the face does not implement fonts.FaceRenderer, or
+// if the glyph is not described by an outline (as for bitmap glyphs).
+//
+// It is consistent with the advances and extents returned by the font, and thus
+// may be used to render the shaping results.
+func (f *Font) GlyphOutline(glyph fonts.GID) (fonts.GlyphOutline, bool) {
+	renderer, ok := f.face.(fonts.FaceRenderer)
+	if !ok {
+		return fonts.GlyphOutline{}, false
+	}
+	var data fonts.GlyphOutline
+	switch glyphData := renderer.GlyphData(glyph, f.XPpem, f.YPpem).(type) {
+	case fonts.GlyphOutline:
+		data = glyphData
+	case fonts.GlyphColor: // use the fallback outline
+		data = glyphData.Outline
+	case fonts.GlyphSVG:
+		data = glyphData.Outline
+	default:
+		return fonts.GlyphOutline{}, false
+	}
+
+	// scale into a copy, since the face may return cached data
+	xScale := float32(f.XScale) / float32(f.faceUpem)
+	yScale := float32(f.YScale) / float32(f.faceUpem)
+	segments := make([]fonts.Segment, len(data.Segments))
+	for i, seg := range data.Segments {
+		segments[i].Op = seg.Op
+		for j, p := range seg.ArgsSlice() {
+			segments[i].Args[j] = fonts.SegmentPoint{X: p.X * xScale, Y: p.Y * yScale}
+		}
+	}
+
+	// as HarfBuzz, slant the outline before emboldening it
+	if slant := f.slantXY(); slant != 0 {
+		for i := range segments {
+			args := segments[i].ArgsSlice()
+			for j := range args {
+				args[j].X += slant * args[j].Y
+			}
+		}
+	}
+
+	if xStrength, yStrength := f.xStrength(), f.yStrength(); xStrength != 0 || yStrength != 0 {
+		// keep the left side bearing if the advance is increased
+		var xShift float32
+		if !f.emboldenInPlace {
+			xShift = float32(xStrength) / 2
+		}
+		yShift := float32(yStrength) / 2
+		if f.XScale < 0 {
+			xShift = -xShift
+		}
+		if f.YScale < 0 {
+			yShift = -yShift
+		}
+		emboldenOutline(segments, float32(xStrength), float32(yStrength), xShift, yShift)
+	}
+
+	return fonts.GlyphOutline{Segments: segments}, true
+}
+
+// outlineExtents returns the bounding box of the control points of `outline`.
+func outlineExtents(outline fonts.GlyphOutline) GlyphExtents {
+	if len(outline.Segments) == 0 {
+		return GlyphExtents{}
+	}
+	xMin, yMin := float32(math.Inf(+1)), float32(math.Inf(+1))
+	xMax, yMax := float32(math.Inf(-1)), float32(math.Inf(-1))
+	for i := range outline.Segments {
+		for _, p := range outline.Segments[i].ArgsSlice() {
+			xMin, xMax = minf(xMin, p.X), maxf(xMax, p.X)
+			yMin, yMax = minf(yMin, p.Y), maxf(yMax, p.Y)
+		}
+	}
+	out := GlyphExtents{XBearing: roundf(xMin), YBearing: roundf(yMax)}
+	out.Width = roundf(xMax) - out.XBearing
+	out.Height = roundf(yMin) - out.YBearing
+	return out
+}
+
+// emboldenOutline thickens the contours of the outline by `xStrength` and `yStrength`,
+// and then translates them by (`xShift`, `yShift`).
+func emboldenOutline(segments []fonts.Segment, xStrength, yStrength, xShift, yShift float32) {
+	// gather the points, split by contours
+	var (
+		points []*fonts.SegmentPoint
+		ends   []int // end of each contour in points (exclusive)
+	)
+	for i := range segments {
+		if segments[i].Op == fonts.SegmentOpMoveTo && len(points) != 0 {
+			ends = append(ends, len(points))
+		}
+		args := segments[i].ArgsSlice()
+		for j := range args {
+			points = append(points, &args[j])
+		}
+	}
+	if len(points) == 0 {
+		return
+	}
+	ends = append(ends, len(points))
+
+	xStrength /= 2
+	yStrength /= 2
+
+	orientationNegative := controlArea(points, ends) < 0
+
+	first := 0
+	for _, end := range ends {
+		var (
+			in, out, anchor, shift fonts.SegmentPoint
+			lIn, lOut, lAnchor     float32
+		)
+		last := end - 1
+
+		// counter j cycles though the points; counter i advances only
+		// when points are moved; anchor k marks the first moved point.
+		for i, j, k := last, first, -1; j != i && i != k; {
+			if j != k {
+				out = fonts.SegmentPoint{X: points[j].X - points[i].X, Y: points[j].Y - points[i].Y}
+				lOut = normalizeLength(&out)
+				if lOut == 0 {
+					j = nextInContour(j, first, last)
+					continue
+				}
+			} else {
+				out = anchor
+				lOut = lAnchor
+			}
+
+			if lIn != 0 {
+				if k < 0 {
+					k = i
+					anchor = in
+					lAnchor = lIn
+				}
+
+				d := in.X*out.X + in.Y*out.Y
+
+				// shift only if turn is less than ~160 degrees
+				if d > -15./16 {
+					d = d + 1
+
+					// shift components along lateral bisector in proper orientation
+					shift.X = in.Y + out.Y
+					shift.Y = in.X + out.X
+
+					if orientationNegative {
+						shift.X = -shift.X
+					} else {
+						shift.Y = -shift.Y
+					}
+
+					// restrict shift magnitude to better handle collapsing segments
+					q := out.X*in.Y - out.Y*in.X
+					if orientationNegative {
+						q = -q
+					}
+
+					l := minf(lIn, lOut)
+
+					// non-strict inequalities avoid divide-by-zero when q == l == 0
+					if xStrength*q <= l*d {
+						shift.X = shift.X * xStrength / d
+					} else {
+						shift.X = shift.X * l / q
+					}
+
+					if yStrength*q <= l*d {
+						shift.Y = shift.Y * yStrength / d
+					} else {
+						shift.Y = shift.Y * l / q
+					}
+				} else {
+					shift = fonts.SegmentPoint{}
+				}
+
+				for ; i != j; i = nextInContour(i, first, last) {
+					points[i].X += xShift + shift.X
+					points[i].Y += yShift + shift.Y
+				}
+			} else {
+				i = j
+			}
+
+			in = out
+			lIn = lOut
+			j = nextInContour(j, first, last)
+		}
+
+		first = end
+	}
+}
+
+func nextInContour(i, first, last int) int {
+	if i < last {
+		return i + 1
+	}
+	return first
+}
+
+// normalizeLength normalizes `v` and returns its original length.
+func normalizeLength(v *fonts.SegmentPoint) float32 {
+	l := float32(math.Hypot(float64(v.X), float64(v.Y)))
+	if l != 0 {
+		v.X /= l
+		v.Y /= l
+	}
+	return l
+}
+
+// controlArea returns the signed area of the polygon
+// defined by the control points, which is negative
+// for clockwise contours (as used by TrueType).
+func controlArea(points []*fonts.SegmentPoint, ends []int) float32 {
+	var a float32
+	first := 0
+	for _, end := range ends {
+		for i := first; i < end; i++ {
+			j := i + 1
+			if j >= end {
+				j = first
+			}
+			pi, pj := points[i], points[j]
+			a += pi.X*pj.Y - pj.X*pi.Y
+		}
+		first = end
+	}
+	return a * .5
+}
@@ -1,10 +1,12 @@
 package harfbuzz
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
 	"github.com/boxesandglue/textlayout/fonts"
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
 )
 
 // ported from harfbuzz/test/api/test-font.c Copyright © 2011  Google, Inc. Behdad Esfahbod
@@ -226,6 +228,46 @@ func TestAdvanceTtVarGvarInfer(t *testing.T) {
 	assert(t, ok)
 }
 
+func TestShapeInstance(t *testing.T) {
+	// Commissioner has feature variations (rvrn) and variable anchors and kerning
+	variations := []tt.Variation{{Tag: tt.MustNewTag("wght"), Value: 700}}
+	shape := func(face *tt.Font) *Buffer {
+		buf := NewBuffer()
+		buf.AddRunes([]rune("Affinity VAWAY To, office $100"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(NewFont(face), nil)
+		return buf
+	}
+
+	face := openFontFileTT("Commissioner-VF.ttf")
+	inst, err := face.Instance(variations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.SetVariations(face, variations)
+	exp, got := shape(face), shape(inst)
+
+	if len(exp.Info) != len(got.Info) {
+		t.Fatalf("expected %d glyphs, got %d", len(exp.Info), len(got.Info))
+	}
+	abs := func(v Position) Position {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	for i := range exp.Info {
+		if exp.Info[i].Glyph != got.Info[i].Glyph {
+			t.Fatalf("glyph %d: expected %d, got %d", i, exp.Info[i].Glyph, got.Info[i].Glyph)
+		}
+		// the instance is rounded
+		pe, pg := exp.Pos[i], got.Pos[i]
+		if abs(pe.XAdvance-pg.XAdvance) > 1 || abs(pe.XOffset-pg.XOffset) > 1 || abs(pe.YOffset-pg.YOffset) > 1 {
+			t.Fatalf("glyph %d: expected position %v, got %v", i, pe, pg)
+		}
+	}
+}
+
 func TestLoadGraphite(t *testing.T) {
 	face := openFontFile("fonts/Simple-Graphite-Font.ttf")
 	font := NewFont(face)
@@ -268,3 +310,107 @@ func TestLigCarets(t *testing.T) {
 		t.Fatalf("for glyph %d, expected %v, got %v", 1023, expected, carets)
 	}
 }
+
+func TestSyntheticBold(t *testing.T) {
+	font := NewFont(openFontFileTT("DejaVuSerif.ttf"))
+	glyph, _ := font.face.NominalGlyph('o')
+	advance := font.GlyphHAdvance(glyph)
+	extents, _ := font.GlyphExtents(glyph)
+	outline, ok := font.GlyphOutline(glyph)
+	assert(t, ok)
+	assert(t, outlineExtents(outline) == extents)
+
+	font.SetSyntheticBold(0.02, 0.02, false)
+	strength := font.xStrength()
+	assert(t, strength > 0)
+	assertEqualInt(t, int(font.GlyphHAdvance(glyph)), int(advance+strength))
+	bold, _ := font.GlyphExtents(glyph)
+	assertEqualInt(t, int(bold.XBearing), int(extents.XBearing))
+	assert(t, bold.Width > extents.Width && bold.YBearing > extents.YBearing && bold.Height < extents.Height)
+	outline, _ = font.GlyphOutline(glyph)
+	assert(t, outlineExtents(outline) == bold)
+
+	buf := NewBuffer()
+	buf.AddRunes([]rune("o"), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(font, nil)
+	assertEqualInt(t, int(buf.Pos[0].XAdvance), int(advance+strength))
+
+	// in place: the advance is unchanged and the glyph is centered
+	font.SetSyntheticBold(0.02, 0.02, true)
+	assertEqualInt(t, int(font.GlyphHAdvance(glyph)), int(advance))
+	inPlace, _ := font.GlyphExtents(glyph)
+	assertEqualInt(t, int(inPlace.XBearing), int(extents.XBearing-strength/2))
+	assertEqualInt(t, int(inPlace.Width), int(bold.Width))
+}
+
+func TestGlyphOutlineColor(t *testing.T) {
+	// glyph 8 is a COLR glyph, with a regular outline
+	font := NewFont(openFontFile("harfbuzz_reference/in-house/fonts/53374c7ca3657be37efde7ed02ae34229a56ae1f.ttf"))
+	outline, ok := font.GlyphOutline(8)
+	assert(t, ok && len(outline.Segments) != 0)
+
+	font.SetSyntheticSlant(0.2)
+	extents, ok := font.GlyphExtents(8)
+	assert(t, ok && outlineExtents(outline) != extents)
+	outline, _ = font.GlyphOutline(8)
+	assert(t, outlineExtents(outline) == extents)
+}
+
+func TestSyntheticSlant(t *testing.T) {
+	font := NewFont(openFontFileTT("DejaVuSerif.ttf"))
+	glyph, _ := font.face.NominalGlyph('o')
+	ref, _ := font.GlyphOutline(glyph)
+
+	font.SetSyntheticSlant(0.2)
+	outline, _ := font.GlyphOutline(glyph)
+	assertEqualInt(t, len(outline.Segments), len(ref.Segments))
+	for i, seg := range outline.Segments {
+		for j, p := range seg.Args {
+			refP := ref.Segments[i].Args[j]
+			if p.Y != refP.Y || math.Abs(float64(p.X-refP.X-0.2*refP.Y)) > 1e-3 {
+				t.Fatalf("unexpected slanted point %v for %v", p, refP)
+			}
+		}
+	}
+	extents, _ := font.GlyphExtents(glyph)
+	assert(t, outlineExtents(outline) == extents)
+
+	// mark offsets are slanted
+	shape := func(slant float32) []GlyphPosition {
+		font.SetSyntheticSlant(slant)
+		buf := NewBuffer()
+		buf.AddRunes([]rune("q̣́"), 0, -1)
+		buf.GuessSegmentProperties()
+		buf.Shape(font, nil)
+		return buf.Pos
+	}
+	upright, slanted := shape(0), shape(0.2)
+	for i := range upright {
+		expected := upright[i].XOffset + Position(0.2*float32(upright[i].YOffset))
+		if d := slanted[i].XOffset - expected; d < -1 || d > 1 {
+			t.Fatalf("glyph %d: expected XOffset %d, got %d", i, expected, slanted[i].XOffset)
+		}
+	}
+
+	// the offsets of the fallback mark positioning are slanted too
+	font = NewFont(openFontFileTT("ToyKern1.ttf")) // no GPOS table
+	font.SetSyntheticSlant(0.2)
+	buf := NewBuffer()
+	buf.AddRunes([]rune("X\u0301"), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(font, nil)
+	assertEqualInt(t, 2, len(buf.Pos))
+	assert(t, buf.Pos[1].YOffset != 0)
+	mark, _ := font.GlyphExtents(buf.Info[1].Glyph)
+	// the mark is centered over the advance of the base, and then slanted
+	centered := (buf.Pos[0].XAdvance-mark.Width)/2 - mark.XBearing - buf.Pos[0].XAdvance
+	expected := centered + roundf(font.slantXY()*float32(buf.Pos[1].YOffset))
+	assertEqualInt(t, int(expected), int(buf.Pos[1].XOffset))
+
+	// the extents match the outline, slanted and then emboldened
+	font.SetSyntheticBold(0.02, 0.02, false)
+	outline, _ = font.GlyphOutline(buf.Info[0].Glyph)
+	extents, _ = font.GlyphExtents(buf.Info[0].Glyph)
+	assert(t, outlineExtents(outline) == extents)
+}
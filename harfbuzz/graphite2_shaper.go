@@ -55,6 +55,12 @@ func tagFromString(str string) truetype.Tag {
 }
 
 func (sh *shaperGraphite) shape(font *Font, buffer *Buffer, features []Feature) {
+	if buffer.content == contentGlyphs {
+		// Graphite rules operate on characters: simply position the glyphs
+		shaperFallback{}.shape(font, buffer, features)
+		return
+	}
+
 	grface := (*graphite.GraphiteFace)(sh)
 
 	lang := languageToString(buffer.Props.Language)
@@ -211,5 +217,9 @@ func (sh *shaperGraphite) shape(font *Font, buffer *Buffer, features []Feature)
 		buffer.reverseClusters()
 	}
 
-	buffer.clearGlyphFlags(GlyphUnsafeToBreak)
+	mask := GlyphUnsafeToBreak
+	if buffer.Flags&ProduceUnsafeToConcat != 0 {
+		mask |= GlyphUnsafeToConcat
+	}
+	buffer.clearGlyphFlags(mask)
 }
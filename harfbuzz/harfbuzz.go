@@ -23,7 +23,8 @@ import (
 // pending the change for Unicode 14 that are not merged yet, starting at
 // 195c05df9925c7c4a4982a286ef9c416b2cde3af
 
-// debugMode is only used in test:
+// debugMode is only used in test, see Buffer.SetMessageFunc
+// for a runtime alternative:
 //
 //		0 : nothing
 //	 1 : only the main steps are printed
@@ -145,6 +146,20 @@ const (
 	// not be inserted in the rendering of incorrect
 	// character sequences (such at <0905 093E>).
 	DoNotinsertDottedCircle
+	// Flag indicating that the `GlyphUnsafeToConcat` glyph flag
+	// should be produced by the shaper. By default it will not be
+	// produced since it incurs a cost.
+	ProduceUnsafeToConcat
+	// Flag indicating that the `GlyphSafeToInsertTatweel` glyph flag
+	// should be produced by the shaper. By default it will not be
+	// produced.
+	ProduceSafeToInsertTatweel
+	// Flag indicating that the default features of the shaper (such as 'liga' or 'kern')
+	// should not be applied by `Buffer.Shape`, so that only the features given to `Shape`,
+	// and the ones required by the font, are used. This is typically useful for buffers
+	// filled with glyphs (see `Buffer.AddGlyphs`), whose substitutions have already been
+	// performed.
+	NoDefaultFeatures
 )
 
 // ClusterLevel allows selecting more fine-grained Cluster handling.
@@ -462,6 +477,20 @@ func max32(a, b uint32) uint32 {
 	return b
 }
 
+func minf(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxf(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
 func isAlnum(c byte) bool { return isAlpha(c) || (c >= '0' && c <= '9') }
 func toUpper(c byte) byte {
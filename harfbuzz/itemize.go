@@ -0,0 +1,236 @@
+package harfbuzz
+
+import (
+	"github.com/boxesandglue/textlayout/bidi"
+	"github.com/boxesandglue/textlayout/fonts"
+	"github.com/boxesandglue/textlayout/language"
+	"github.com/boxesandglue/textlayout/segmenter"
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// Item is a range of text with a constant script, direction
+// and font, which may be shaped in one Buffer.
+type Item struct {
+	// Start and End are the indices of the runes of the item, [Start, End),
+	// in the itemized text.
+	Start, End int
+
+	// Props are the properties to use when shaping the item,
+	// its Direction being either LeftToRight or RightToLeft.
+	Props SegmentProperties
+
+	// Level is the embedding level of the item, as resolved by the
+	// bidirectional algorithm, which may be used to reorder
+	// the items of a line (see the package bidi).
+	Level bidi.Level
+
+	// Face is the index of the font face used by the item
+	// in the list given to Itemize, or -1 if this list is empty.
+	Face int
+}
+
+// Itemize splits `text` into items which may be shaped on their own, in logical order.
+//
+// The text is split in paragraphs, whose base direction is given by `dir`, and
+// runs of constant embedding level, as resolved by the Unicode Bidirectional Algorithm.
+// Runs are then split by script: characters of the Common or Inherited scripts are
+// resolved from their context, so that a closing bracket uses the script of the
+// matching opening bracket, and the other ones continue the script of the preceding
+// character (or, at the start of the text, of the following one).
+//
+// Finally, each grapheme cluster is assigned the first face in `faces`
+// supporting all its runes, characters of the Common and Inherited scripts staying in the
+// current face when possible. When no face supports the cluster, the face supporting its
+// first rune, or the current one, is used, and the missing glyphs are reported
+// by the shaping (see Buffer.NotFound and Buffer.ShapeWithFallback).
+//
+// The Language of the items is `lang`, or the default language if it is empty.
+func Itemize(text []rune, dir bidi.Direction, lang language.Language, faces []fonts.Face) []Item {
+	if lang == "" {
+		lang = language.DefaultLanguage()
+	}
+	levels := resolveParagraphsLevels(text, dir)
+	scripts := resolveScripts(text)
+	selected := newFaceSelector(faces).selectFaces(text)
+
+	var items []Item
+	for i := range text {
+		if len(items) != 0 {
+			last := &items[len(items)-1]
+			if last.Level == levels[i] && last.Props.Script == scripts[i] && last.Face == selected[i] {
+				last.End++
+				continue
+			}
+		}
+		props := SegmentProperties{Language: lang, Script: scripts[i], Direction: LeftToRight}
+		if levels[i].IsRTL() {
+			props.Direction = RightToLeft
+		}
+		items = append(items, Item{Start: i, End: i + 1, Props: props, Level: levels[i], Face: selected[i]})
+	}
+	return items
+}
+
+// resolveParagraphsLevels splits `text` after each paragraph separator
+// and resolves the embedding levels of each paragraph.
+func resolveParagraphsLevels(text []rune, dir bidi.Direction) []bidi.Level {
+	levels := make([]bidi.Level, 0, len(text))
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && bidi.LookupClass(text[end]) != bidi.B {
+			end++
+		}
+		if end < len(text) { // include the separator
+			end++
+		}
+		levels = append(levels, bidi.NewParagraph(text[start:end], dir).Levels()...)
+		start = end
+	}
+	return levels
+}
+
+// maxBracketDepth is the maximum number of nested brackets
+// used to resolve the scripts (see BD16 in UAX #9).
+const maxBracketDepth = 63
+
+// resolveScripts returns the script of each rune of `text`, where
+// the Common, Inherited and Unknown scripts are resolved from their context.
+func resolveScripts(text []rune) []language.Script {
+	type bracket struct {
+		closing rune // the closing bracket matching the opening one
+		script  language.Script
+	}
+	var (
+		scripts = make([]language.Script, len(text))
+		stack   []bracket
+		current = language.Common // resolved script of the last rune
+	)
+	for i, r := range text {
+		script := language.LookupScript(r)
+		if script.IsRealScript() {
+			if !current.IsRealScript() { // resolve the leading runes
+				for j := range scripts[:i] {
+					scripts[j] = script
+				}
+				for j := range stack {
+					stack[j].script = script
+				}
+			}
+			current = script
+			scripts[i] = script
+			continue
+		}
+
+		switch pair, typ := ucd.LookupPairedBracket(r); typ {
+		case ucd.BracketOpen:
+			if len(stack) < maxBracketDepth {
+				stack = append(stack, bracket{closing: pair, script: current})
+			}
+		case ucd.BracketClose:
+			// look for the matching opening bracket, discarding the unclosed ones
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].closing == r {
+					current = stack[j].script
+					stack = stack[:j]
+					break
+				}
+			}
+		}
+		scripts[i] = current
+	}
+	return scripts
+}
+
+// faceSelector selects the faces supporting a text.
+type faceSelector struct {
+	cmaps []fonts.Cmap
+}
+
+func newFaceSelector(faces []fonts.Face) faceSelector {
+	cmaps := make([]fonts.Cmap, len(faces))
+	for i, face := range faces {
+		cmaps[i], _ = face.Cmap()
+	}
+	return faceSelector{cmaps: cmaps}
+}
+
+// supports returns true if the face `index` has a glyph for
+// all the runes of `cluster`, ignoring the default ignorable ones.
+func (fs faceSelector) supports(index int, cluster []rune) bool {
+	if index < 0 || fs.cmaps[index] == nil {
+		return false
+	}
+	for _, r := range cluster {
+		if _, ok := fs.cmaps[index].Lookup(r); !ok && !IsDefaultIgnorable(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectFaces returns the face index of each rune of `text`, with
+// the same index for all the runes of a grapheme cluster.
+func (fs faceSelector) selectFaces(text []rune) []int {
+	out := make([]int, len(text))
+	current := -1
+	if len(fs.cmaps) == 0 {
+		for i := range out {
+			out[i] = current
+		}
+		return out
+	}
+	// clusters of the Common or Inherited scripts at the start of the text
+	// use the face of the following cluster, when possible
+	var pending [][2]int
+	resolvePending := func() {
+		for _, cl := range pending {
+			face := fs.selectFace(text[cl[0]:cl[1]], current, true)
+			for i := cl[0]; i < cl[1]; i++ {
+				out[i] = face
+			}
+		}
+		pending = nil
+	}
+
+	gb, start := segmenter.NewGraphemeBreaker(text), 0
+	for end, ok := gb.Next(); ok; end, ok = gb.Next() {
+		cluster := text[start:end]
+		isCommon := !language.LookupScript(cluster[0]).IsRealScript()
+		if isCommon && current == -1 {
+			pending = append(pending, [2]int{start, end})
+			start = end
+			continue
+		}
+		current = fs.selectFace(cluster, current, isCommon)
+		for i := start; i < end; i++ {
+			out[i] = current
+		}
+		resolvePending()
+		start = end
+	}
+	resolvePending()
+	return out
+}
+
+// selectFace returns the face to use for `cluster`, `current` being the face of
+// the previous cluster (or -1).
+func (fs faceSelector) selectFace(cluster []rune, current int, preferCurrent bool) int {
+	if preferCurrent && fs.supports(current, cluster) {
+		return current
+	}
+	for i := range fs.cmaps {
+		if fs.supports(i, cluster) {
+			return i
+		}
+	}
+	// no face supports the whole cluster: try with its base only
+	for i := range fs.cmaps {
+		if fs.supports(i, cluster[:1]) {
+			return i
+		}
+	}
+	if current == -1 {
+		return 0
+	}
+	return current
+}
@@ -0,0 +1,79 @@
+package harfbuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/textlayout/bidi"
+	"github.com/boxesandglue/textlayout/fonts"
+	"github.com/boxesandglue/textlayout/language"
+)
+
+func TestResolveScripts(t *testing.T) {
+	L, A, G := language.Latin, language.Arabic, language.Greek
+	for _, test := range []struct {
+		text     string
+		expected []language.Script
+	}{
+		{"", []language.Script{}},
+		{"12 ab", []language.Script{L, L, L, L, L}},
+		{"a (αβ) b", []language.Script{L, L, L, G, G, L, L, L}},
+		{"a [β (γ] c", []language.Script{L, L, L, G, G, G, G, L, L, L}},
+		{"سلام، a.", []language.Script{A, A, A, A, A, A, L, L}},
+		{"éά", []language.Script{L, L, G, G}},
+		{"!?", []language.Script{language.Common, language.Common}},
+	} {
+		if got := resolveScripts([]rune(test.text)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %q, expected %v, got %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestItemize(t *testing.T) {
+	latin, arabic := openFontFileTT("DejaVuSerif.ttf"), openFontFileTT("NotoSansArabic.ttf")
+	faces := []fonts.Face{latin, arabic}
+
+	text := []rune("abc (سلام) def\nسلام")
+	items := Itemize(text, bidi.Auto, "fr", faces)
+	L, R := LeftToRight, RightToLeft
+	expected := []Item{
+		{0, 5, SegmentProperties{"fr", language.Latin, L}, 0, 0},
+		{5, 9, SegmentProperties{"fr", language.Arabic, R}, 1, 1},
+		{9, 15, SegmentProperties{"fr", language.Latin, L}, 0, 0},
+		{15, 19, SegmentProperties{"fr", language.Arabic, R}, 1, 1},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatalf("expected %v, got %v", expected, items)
+	}
+
+	// the space is kept in the Arabic face, which supports it
+	text = []rune(" سلام سلام")
+	items = Itemize(text, bidi.Auto, "ar", faces)
+	if len(items) != 1 || items[0].Face != 1 || items[0].Props.Direction != RightToLeft {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	// without faces, only the script and the direction are used
+	items = Itemize([]rune("ab سلام"), bidi.LeftToRight, "en", nil)
+	expected = []Item{
+		{0, 3, SegmentProperties{"en", language.Latin, L}, 0, -1},
+		{3, 7, SegmentProperties{"en", language.Arabic, R}, 1, -1},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Fatalf("expected %v, got %v", expected, items)
+	}
+
+	// each item may be shaped
+	text = []rune("abc سلام")
+	for _, item := range Itemize(text, bidi.Auto, "", faces) {
+		buffer := NewBuffer()
+		buffer.Props = item.Props
+		buffer.AddRunes(text, item.Start, item.End-item.Start)
+		buffer.Shape(NewFont(faces[item.Face]), nil)
+		for i, info := range buffer.Info {
+			if info.Glyph == buffer.NotFound {
+				t.Fatalf("unexpected .notdef glyph at %d for item %v", i, item)
+			}
+		}
+	}
+}
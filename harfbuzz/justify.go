@@ -0,0 +1,304 @@
+package harfbuzz
+
+import (
+	"sort"
+
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
+)
+
+// ported from harfbuzz/src/hb-shape.cc (hb_shape_justify), Copyright © 2022 Behdad Esfahbod,
+// extended with 'jalt' alternates and tatweel insertion.
+
+var (
+	tagJstfAxis = tt.NewTag('j', 's', 't', 'f')
+	tagWdthAxis = tt.NewTag('w', 'd', 't', 'h')
+	tagJalt     = tt.NewTag('j', 'a', 'l', 't')
+)
+
+const (
+	tatweel = 0x0640
+
+	// maximum number of shaping rounds for each step of the justification
+	justifyMaxIterations = 16
+)
+
+// ShapeJustify shapes the buffer, as `Shape` does, and then tries to adjust the result so that
+// its advance along the buffer direction falls in the range [`minWidth`, `maxWidth`],
+// expressed in the scale of `font`. The following steps are used, until the target is reached:
+//   - the value of a variation axis of the font is searched, preferring the 'jstf' axis
+//     to the 'wdth' axis,
+//   - if the text is still too narrow, the 'jalt' feature is applied to as many clusters as possible,
+//   - if the text is still too narrow, U+0640 ARABIC TATWEEL characters are inserted
+//     at the positions flagged by `GlyphSafeToInsertTatweel`.
+//
+// The achieved advance is returned; it may be out of the target range if
+// the font does not provide enough ways to justify the text.
+// The buffer is left shaped with the chosen settings. `font` is not modified: the variation
+// axis is searched on a copy, and its selected value is returned in `variation`, whose
+// Tag is zero if no axis has been used. It should be applied to the font
+// used to render the glyphs (see `Font.SetVarCoordsDesign`).
+// Tatweel insertion is only supported for buffers filled with runes.
+func (b *Buffer) ShapeJustify(font *Font, features []Feature, minWidth, maxWidth Position) (advance Position, variation tt.Variation) {
+	if minWidth > maxWidth {
+		minWidth, maxWidth = maxWidth, minWidth
+	}
+	j := justifier{
+		buffer:   b,
+		font:     font,
+		features: features,
+		input:    append([]GlyphInfo(nil), b.Info...),
+		content:  b.content,
+		minWidth: minWidth,
+		maxWidth: maxWidth,
+	}
+
+	b.Shape(font, features)
+	width := b.advance()
+	if j.distance(width) == 0 {
+		return width, variation
+	}
+
+	width, variation = j.varyAxis(width)
+	if width < minWidth {
+		width = j.applyJalt(width)
+	}
+	if width < minWidth {
+		width = j.insertTatweels(width)
+	}
+	return width, variation
+}
+
+// advance returns the total advance of the buffer, along its direction.
+func (b *Buffer) advance() Position {
+	var out Position
+	horizontal := b.Props.Direction.isHorizontal()
+	for _, pos := range b.Pos {
+		if horizontal {
+			out += pos.XAdvance
+		} else {
+			out -= pos.YAdvance
+		}
+	}
+	return out
+}
+
+type justifier struct {
+	buffer   *Buffer
+	font     *Font
+	features []Feature
+
+	// the original content of the buffer, before shaping
+	input   []GlyphInfo
+	content bufferContent
+
+	minWidth, maxWidth Position
+}
+
+// distance returns how far `width` is from the target range.
+func (j *justifier) distance(width Position) Position {
+	if width < j.minWidth {
+		return j.minWidth - width
+	} else if width > j.maxWidth {
+		return width - j.maxWidth
+	}
+	return 0
+}
+
+// reshape restores the original content of the buffer, shapes it
+// and returns the resulting advance.
+func (j *justifier) reshape(input []GlyphInfo, features []Feature) Position {
+	b := j.buffer
+	b.Info = append(b.Info[:0], input...)
+	b.Pos = append(b.Pos[:0], make([]GlyphPosition, len(input))...)
+	b.content = j.content
+	b.Shape(j.font, features)
+	return b.advance()
+}
+
+// varyAxis searches for the value of the justification axis
+// best matching the target, assuming the advance increases with the axis value.
+// The search is done on a copy of the font, which is then used by the following steps.
+func (j *justifier) varyAxis(width Position) (Position, tt.Variation) {
+	ttFace, ok := j.font.face.(*tt.Font)
+	if !ok {
+		return width, tt.Variation{}
+	}
+	axes := ttFace.Variations().Axis
+	axisIndex := -1
+	for _, tag := range [...]tt.Tag{tagJstfAxis, tagWdthAxis} {
+		for i, axis := range axes {
+			if axis.Tag == tag {
+				axisIndex = i
+				break
+			}
+		}
+		if axisIndex != -1 {
+			break
+		}
+	}
+	if axisIndex == -1 {
+		return width, tt.Variation{}
+	}
+
+	// the variation coordinates are stored in the face: use a copy
+	// of both the face and the font
+	font, faceCopy := j.font, *ttFace
+	fontCopy := *font
+	fontCopy.face = &faceCopy
+	j.font = &fontCopy
+
+	coords := make([]float32, len(axes))
+	copy(coords, ttFace.VarCoordinates())
+	design := make([]float32, len(axes))
+	for i, axis := range axes {
+		design[i] = axis.Default
+	}
+	setAxis := func(value float32) Position {
+		design[axisIndex] = value
+		coords[axisIndex] = faceCopy.NormalizeVariations(design)[axisIndex]
+		faceCopy.SetVarCoordinates(append([]float32(nil), coords...))
+		return j.reshape(j.input, j.features)
+	}
+
+	axis := axes[axisIndex]
+	bestWidth, found := width, false
+	var bestValue float32
+	low, high := axis.Minimum, axis.Maximum
+	for iter := 0; iter < justifyMaxIterations; iter++ {
+		value := (low + high) / 2
+		w := setAxis(value)
+		if j.distance(w) < j.distance(bestWidth) {
+			bestValue, bestWidth, found = value, w, true
+		}
+		if j.distance(w) == 0 {
+			return w, tt.Variation{Tag: axis.Tag, Value: value}
+		}
+		if w < j.minWidth {
+			low = value
+		} else {
+			high = value
+		}
+	}
+
+	// check the bounds of the axis, which are not reached by the bisection
+	for _, value := range [2]float32{axis.Minimum, axis.Maximum} {
+		if w := setAxis(value); j.distance(w) < j.distance(bestWidth) {
+			bestValue, bestWidth, found = value, w, true
+		}
+	}
+	if !found { // go back to the original font
+		j.font = font
+		return j.reshape(j.input, j.features), tt.Variation{}
+	}
+	return setAxis(bestValue), tt.Variation{Tag: axis.Tag, Value: bestValue}
+}
+
+// applyJalt enables the 'jalt' feature on the longest prefix of the
+// text (in logical order) which does not exceed the maximum width.
+func (j *justifier) applyJalt(width Position) Position {
+	if j.font.otTables == nil || findFeature(&j.font.otTables.GSUB.TableLayout, tagJalt) == NoFeatureIndex {
+		return width
+	}
+	withJalt := func(end int) []Feature {
+		return append(append([]Feature(nil), j.features...), Feature{Tag: tagJalt, Value: 1, Start: FeatureGlobalStart, End: end})
+	}
+
+	if w := j.reshape(j.input, withJalt(FeatureGlobalEnd)); w <= j.maxWidth {
+		if w > width {
+			j.features = withJalt(FeatureGlobalEnd)
+			return w
+		}
+		return j.reshape(j.input, j.features)
+	}
+
+	// too wide: find the number of clusters to justify
+	var clusters []int
+	for i, info := range j.input {
+		if i == 0 || info.Cluster != j.input[i-1].Cluster {
+			clusters = append(clusters, info.Cluster)
+		}
+	}
+	sort.Ints(clusters)
+	// the prefix of length 0 fits, the full text does not
+	low, high := 0, len(clusters)
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if w := j.reshape(j.input, withJalt(clusters[mid])); w <= j.maxWidth {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	if low == 0 {
+		return j.reshape(j.input, j.features)
+	}
+	j.features = withJalt(clusters[low])
+	return j.reshape(j.input, j.features)
+}
+
+// insertTatweels elongates the text with tatweels.
+func (j *justifier) insertTatweels(width Position) Position {
+	if j.content != contentUnicode || j.font.otTables == nil {
+		return width
+	}
+	glyph, ok := j.font.face.NominalGlyph(tatweel)
+	if !ok {
+		return width
+	}
+	tatweelAdvance := j.font.GlyphHAdvance(glyph)
+	if !j.buffer.Props.Direction.isHorizontal() || tatweelAdvance <= 0 {
+		return width
+	}
+
+	// find the places where tatweels may be inserted
+	flags := j.buffer.Flags
+	j.buffer.Flags |= ProduceSafeToInsertTatweel
+	j.reshape(j.input, j.features)
+	j.buffer.Flags = flags
+	safe := map[int]bool{}
+	for _, info := range j.buffer.Info {
+		if info.Mask&GlyphSafeToInsertTatweel != 0 {
+			safe[info.Cluster] = true
+		}
+	}
+	// insert before the flagged clusters
+	var positions []int // indices into input
+	for i := 1; i < len(j.input); i++ {
+		prev, cur := j.input[i-1].Cluster, j.input[i].Cluster
+		if prev != cur && safe[cur] {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) == 0 {
+		return j.reshape(j.input, j.features)
+	}
+
+	count := int((j.minWidth - width + tatweelAdvance - 1) / tatweelAdvance)
+	for iter := 0; count > 0 && iter < justifyMaxIterations; iter, count = iter+1, count-1 {
+		w := j.reshape(j.withTatweels(positions, count), j.features)
+		if w <= j.maxWidth {
+			return w
+		}
+	}
+	return j.reshape(j.input, j.features)
+}
+
+// withTatweels returns a copy of the input with `count` tatweels
+// evenly distributed at `positions`.
+func (j *justifier) withTatweels(positions []int, count int) []GlyphInfo {
+	out := make([]GlyphInfo, 0, len(j.input)+count)
+	last := 0
+	for k, pos := range positions {
+		n := count / len(positions)
+		if k < count%len(positions) {
+			n++
+		}
+		out = append(out, j.input[last:pos]...)
+		for ; n > 0; n-- {
+			// tatweels extend the previous character
+			out = append(out, GlyphInfo{codepoint: tatweel, Cluster: j.input[pos-1].Cluster})
+		}
+		last = pos
+	}
+	return append(out, j.input[last:]...)
+}
@@ -0,0 +1,154 @@
+package harfbuzz
+
+import (
+	"testing"
+
+	"github.com/boxesandglue/textlayout/fonts"
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
+	"github.com/boxesandglue/textlayout/language"
+)
+
+func shapeArabicJustify(filename string, factor float64) (w0, min, w Position, variation tt.Variation, font *Font, b *Buffer) {
+	text := []rune("بسم الله الرحمن")
+	props := SegmentProperties{Direction: RightToLeft, Script: language.Arabic}
+
+	font = NewFont(openFontFileTT(filename))
+	b = NewBuffer()
+	b.AddRunes(text, 0, -1)
+	b.Props = props
+	b.Shape(font, nil)
+	w0 = b.advance()
+
+	b = NewBuffer()
+	b.AddRunes(text, 0, -1)
+	b.Props = props
+	min = Position(float64(w0) * factor)
+	w, variation = b.ShapeJustify(font, nil, min, min+50)
+	return w0, min, w, variation, font, b
+}
+
+func TestShapeJustifyAxis(t *testing.T) {
+	// wdth axis from 100 to 200
+	w0, min, w, variation, font, b := shapeArabicJustify("Estedad-VF.ttf", 1.3)
+	assert(t, min <= w && w <= min+50)
+	assert(t, variation.Tag == tagWdthAxis && variation.Value > 100)
+	assert(t, len(b.Info) == 12) // no tatweel needed
+	// the font is not modified
+	assert(t, font.varCoords() == nil)
+
+	// the returned variation gives the same advances
+	fvar := font.face.(*tt.Font).Variations()
+	font.SetVarCoordsDesign(fvar.GetDesignCoordsDefault([]tt.Variation{variation}))
+	ref := NewBuffer()
+	ref.AddRunes([]rune("بسم الله الرحمن"), 0, -1)
+	ref.Props = b.Props
+	ref.Shape(font, nil)
+	assert(t, ref.advance() == w)
+
+	// already justified
+	_, _, w, variation, _, _ = shapeArabicJustify("Estedad-VF.ttf", 1)
+	assert(t, w == w0 && variation == tt.Variation{})
+
+	// the axis can't narrow the text
+	_, _, w, variation, _, _ = shapeArabicJustify("Estedad-VF.ttf", 0.9)
+	assert(t, w == w0 && variation == tt.Variation{})
+
+	// wdth axis from 62.5 to 100
+	_, min, w, variation, font, _ = shapeArabicJustify("NotoSansArabic.ttf", 0.9)
+	assert(t, min <= w && w <= min+50)
+	assert(t, variation.Tag == tagWdthAxis && variation.Value < 100)
+	assert(t, font.varCoords() == nil)
+}
+
+func TestShapeJustifyTatweel(t *testing.T) {
+	_, min, w, _, font, b := shapeArabicJustify("NotoSansArabic.ttf", 1.3)
+	assert(t, min <= w && w <= min+50)
+
+	tatweelGlyph, _ := font.face.NominalGlyph(tatweel)
+	count := 0
+	for _, info := range b.Info {
+		if info.Glyph == tatweelGlyph {
+			count++
+		}
+	}
+	assert(t, count == len(b.Info)-12)
+	assert(t, count > 0)
+}
+
+func TestShapeJustifyLatin(t *testing.T) {
+	font := NewFont(openFontFileTT("DejaVuSerif.ttf"))
+	b := NewBuffer()
+	b.AddRunes([]rune("justify"), 0, -1)
+	b.GuessSegmentProperties()
+	b.Shape(font, nil)
+	w0 := b.advance()
+	info := append([]GlyphInfo(nil), b.Info...)
+
+	b = NewBuffer()
+	b.AddRunes([]rune("justify"), 0, -1)
+	b.GuessSegmentProperties()
+	// nothing to stretch: the text is shaped as usual
+	w, _ := b.ShapeJustify(font, nil, 2*w0, 3*w0)
+	assert(t, w == w0)
+	assert(t, len(b.Info) == len(info))
+	for i := range info {
+		assert(t, b.Info[i].Glyph == info[i].Glyph)
+	}
+}
+
+// addJaltFeature adds to the GSUB table of `font` a 'jalt' feature
+// replacing `from` by `to`, for all the scripts.
+func addJaltFeature(font *Font, from, to fonts.GID) {
+	gsub := &font.otTables.GSUB
+	gsub.Lookups = append(gsub.Lookups, tt.LookupGSUB{
+		Type:      tt.GSUBSingle,
+		Subtables: []tt.GSUBSubtable{{Coverage: tt.CoverageList{from}, Data: tt.GSUBSingle2{to}}},
+	})
+	gsub.Features = append(gsub.Features, tt.FeatureRecord{
+		Tag:     tagJalt,
+		Feature: tt.Feature{LookupIndices: []uint16{uint16(len(gsub.Lookups) - 1)}},
+	})
+	for _, script := range gsub.Scripts {
+		if script.DefaultLanguage != nil {
+			script.DefaultLanguage.Features = append(script.DefaultLanguage.Features, uint16(len(gsub.Features)-1))
+		}
+	}
+	font.gsubAccels = append(font.gsubAccels, otLayoutLookupAccelerator{})
+	font.gsubAccels[len(font.gsubAccels)-1].init(lookupGSUB(gsub.Lookups[len(gsub.Lookups)-1]))
+}
+
+func TestShapeJustifyJalt(t *testing.T) {
+	font := NewFont(openFontFileTT("DejaVuSerif.ttf"))
+	glyphI, _ := font.face.NominalGlyph('i')
+	glyphM, _ := font.face.NominalGlyph('m')
+	addJaltFeature(font, glyphI, glyphM)
+	delta := font.GlyphHAdvance(glyphM) - font.GlyphHAdvance(glyphI)
+	assert(t, delta > 0)
+
+	shape := func(minWidth, maxWidth Position) (*Buffer, Position) {
+		b := NewBuffer()
+		b.AddRunes([]rune("iiii"), 0, -1)
+		b.GuessSegmentProperties()
+		w, variation := b.ShapeJustify(font, nil, minWidth, maxWidth)
+		assert(t, variation == tt.Variation{})
+		return b, w
+	}
+	b, w0 := shape(0, 0)
+	for _, info := range b.Info {
+		assert(t, info.Glyph == glyphI)
+	}
+
+	// every cluster is substituted
+	b, w := shape(w0+4*delta, w0+5*delta)
+	assertEqualInt(t, int(w0+4*delta), int(w))
+	for _, info := range b.Info {
+		assert(t, info.Glyph == glyphM)
+	}
+
+	// only the first clusters are substituted, to fit the maximum width
+	b, w = shape(w0+2*delta, w0+2*delta+delta/2)
+	assertEqualInt(t, int(w0+2*delta), int(w))
+	for i, info := range b.Info {
+		assert(t, (info.Glyph == glyphM) == (i < 2))
+	}
+}
@@ -7,3 +7,38 @@ func TestNumArabicLookup(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestArabicSafeToInsertTatweel(t *testing.T) {
+	font := NewFont(openFontFileTT("NotoSansArabic.ttf"))
+
+	shape := func(flags ShapingOptions) *Buffer {
+		b := NewBuffer()
+		b.AddRunes([]rune("ببا"), 0, -1)
+		b.Flags = flags
+		b.GuessSegmentProperties()
+		b.Shape(font, nil)
+		return b
+	}
+
+	// without the option, joining positions are only unsafe to break
+	b := shape(0)
+	assertEqualInt(t, len(b.Info), 3)
+	for _, info := range b.Info {
+		assert(t, info.Mask&(GlyphSafeToInsertTatweel|GlyphUnsafeToConcat) == 0)
+	}
+	assert(t, b.Info[0].Mask&GlyphUnsafeToBreak != 0) // alef, joined to the second beh
+	assert(t, b.Info[1].Mask&GlyphUnsafeToBreak != 0)
+	assert(t, b.Info[2].Mask&GlyphUnsafeToBreak == 0) // first cluster
+
+	b = shape(ProduceSafeToInsertTatweel)
+	for _, info := range b.Info {
+		if info.Cluster == 0 {
+			assert(t, info.Mask&glyphFlagDefined == 0)
+		} else {
+			// tatweel insertion points are also unsafe to break
+			assert(t, info.Mask&GlyphSafeToInsertTatweel != 0)
+			assert(t, info.Mask&GlyphUnsafeToBreak != 0)
+		}
+		assert(t, info.Mask&GlyphUnsafeToConcat == 0)
+	}
+}
@@ -263,7 +263,7 @@ func newIndicWouldSubstituteFeature(map_ *otMap, featureTag tt.Tag, zeroContext
 
 func (ws indicWouldSubstituteFeature) wouldSubstitute(glyphs []fonts.GID, font *Font) bool {
 	for _, lk := range ws.lookups {
-		if otLayoutLookupWouldSubstitute(font, lk.index, glyphs, ws.zeroContext) {
+		if font.LookupWouldSubstitute(lk.index, glyphs, ws.zeroContext) {
 			return true
 		}
 	}
@@ -542,12 +542,15 @@ func (cs *complexShaperIndic) setupMasks(plan *otShapePlan, buffer *Buffer, _ *F
 	}
 }
 
-func setupSyllablesIndic(_ *otShapePlan, _ *Font, buffer *Buffer) {
+func setupSyllablesIndic(_ *otShapePlan, font *Font, buffer *Buffer) {
 	findSyllablesIndic(buffer)
 	iter, count := buffer.syllableIterator()
+	syllables := 0
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		buffer.unsafeToBreak(start, end)
+		syllables++
 	}
+	buffer.messagef(font, "found %d syllables", syllables)
 }
 
 func foundSyllableIndic(syllableType uint8, ts, te int, info []GlyphInfo, syllableSerial *uint8) {
@@ -1106,6 +1109,9 @@ func (cs *complexShaperIndic) initialReorderingIndic(_ *otShapePlan, font *Font,
 	if debugMode >= 1 {
 		fmt.Println("INDIC - start reordering indic initial")
 	}
+	if !buffer.messagef(font, "start reordering indic initial") {
+		return
+	}
 
 	cs.plan.updateConsonantPositionsIndic(font, buffer)
 	syllabicInsertDottedCircles(font, buffer, indicBrokenCluster,
@@ -1116,6 +1122,8 @@ func (cs *complexShaperIndic) initialReorderingIndic(_ *otShapePlan, font *Font,
 		cs.plan.initialReorderingSyllableIndic(font, buffer, start, end)
 	}
 
+	buffer.messagef(font, "end reordering indic initial")
+
 	if debugMode >= 1 {
 		fmt.Println("INDIC - end reordering indic initial")
 	}
@@ -1573,12 +1581,17 @@ func (indicPlan *indicShapePlan) finalReorderingIndic(plan *otShapePlan, font *F
 	if debugMode >= 1 {
 		fmt.Println("INDIC - start reordering indic final")
 	}
+	if !buffer.messagef(font, "start reordering indic final") {
+		return
+	}
 
 	iter, count := buffer.syllableIterator()
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		indicPlan.finalReorderingSyllableIndic(plan, buffer, start, end)
 	}
 
+	buffer.messagef(font, "end reordering indic final")
+
 	if debugMode >= 1 {
 		fmt.Println("INDIC - end reordering indic final")
 	}
@@ -182,12 +182,15 @@ func setKhmerProperties(info *GlyphInfo) {
 	info.complexCategory = cat
 }
 
-func setupSyllablesKhmer(_ *otShapePlan, _ *Font, buffer *Buffer) {
+func setupSyllablesKhmer(_ *otShapePlan, font *Font, buffer *Buffer) {
 	findSyllablesKhmer(buffer)
 	iter, count := buffer.syllableIterator()
+	syllables := 0
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		buffer.unsafeToBreak(start, end)
+		syllables++
 	}
+	buffer.messagef(font, "found %d syllables", syllables)
 }
 
 func foundSyllableKhmer(syllableType uint8, ts, te int, info []GlyphInfo, syllableSerial *uint8) {
@@ -283,6 +286,9 @@ func (cs *complexShaperKhmer) reorderKhmer(_ *otShapePlan, font *Font, buffer *B
 	if debugMode >= 1 {
 		fmt.Println("KHMER - start reordering khmer")
 	}
+	if !buffer.messagef(font, "start reordering khmer") {
+		return
+	}
 
 	syllabicInsertDottedCircles(font, buffer, khmerBrokenCluster, otDOTTEDCIRCLE, otRepha, -1)
 	iter, count := buffer.syllableIterator()
@@ -290,6 +296,8 @@ func (cs *complexShaperKhmer) reorderKhmer(_ *otShapePlan, font *Font, buffer *B
 		cs.reorderSyllableKhmer(buffer, start, end)
 	}
 
+	buffer.messagef(font, "end reordering khmer")
+
 	if debugMode >= 1 {
 		fmt.Println("KHMER - end reordering khmer")
 	}
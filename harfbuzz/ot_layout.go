@@ -124,7 +124,10 @@ func (sp *otShapePlan) otLayoutKern(font *Font, buffer *Buffer) {
 	c.applyKernx(kern)
 }
 
-var otTagLatinScript = tt.NewTag('l', 'a', 't', 'n')
+var (
+	otTagLatinScript = tt.NewTag('l', 'a', 't', 'n')
+	tagSize          = tt.NewTag('s', 'i', 'z', 'e')
+)
 
 // SelectScript selects an OpenType script from the `scriptTags` array,
 // returning its index in the Scripts slice and the script tag.
@@ -229,38 +232,162 @@ func getRequiredFeature(g *tt.TableLayout, scriptIndex, languageIndex int) (uint
 
 // getFeatureLookupsWithVar fetches a list of all lookups enumerated for the specified feature, in
 // the given table, enabled at the specified variations index.
-// it returns the basic feature if `variationsIndex == noVariationsIndex`
+// it returns the basic feature if `variationsIndex == noVariationsIndex`, or if the
+// feature is not substituted by the selected feature variation (as HarfBuzz does)
 func getFeatureLookupsWithVar(table *tt.TableLayout, featureIndex uint16, variationsIndex int) []uint16 {
 	if featureIndex == NoFeatureIndex {
 		return nil
 	}
+	return table.FeatureLookups(featureIndex, variationsIndex)
+}
+
+// LanguageFeatureIndices returns the indices of the features of the specified
+// script and language, starting with the required feature, if any.
+// `languageIndex` may be `DefaultLanguageIndex`, and nil is returned if
+// `scriptIndex` is `NoScriptIndex`.
+func LanguageFeatureIndices(table *tt.TableLayout, scriptIndex, languageIndex int) []uint16 {
+	if scriptIndex == NoScriptIndex {
+		return nil
+	}
+	return table.Scripts[scriptIndex].GetLangSys(uint16(languageIndex)).FeatureIndices()
+}
 
-	if variationsIndex == noVariationsIndex { // just fetch the feature
-		return table.Features[featureIndex].LookupIndices
+// layoutTable returns the GSUB or GPOS table, or nil for other tags.
+func (f *Font) layoutTable(tableTag tt.Tag) *tt.TableLayout {
+	switch tableTag {
+	case tt.TagGsub:
+		return &f.otTables.GSUB.TableLayout
+	case tt.TagGpos:
+		return &f.otTables.GPOS.TableLayout
+	default:
+		return nil
 	}
+}
 
-	// hook the variations
-	subs := table.FeatureVariations[variationsIndex].FeatureSubstitutions
-	for _, sub := range subs {
-		if sub.FeatureIndex == featureIndex {
-			return sub.AlternateFeature.LookupIndices
+// FeatureLookups returns the indices of the lookups of the feature at `featureIndex`,
+// in the table specified by `tableTag` (either truetype.TagGsub or truetype.TagGpos).
+// The feature variations matching the current variation coordinates of the font are applied.
+func (f *Font) FeatureLookups(tableTag tt.Tag, featureIndex uint16) []uint16 {
+	table := f.layoutTable(tableTag)
+	if table == nil {
+		return nil
+	}
+	return table.FeatureLookups(featureIndex, table.FindVariationIndex(f.varCoords()))
+}
+
+// CollectLookupGlyphs returns the glyphs which may be matched or produced
+// by the given lookups of the table specified by `tableTag` (either truetype.TagGsub or truetype.TagGpos).
+// Combined with FeatureLookups, it may be used to find the glyphs
+// a feature could substitute (the Input set of a GSUB feature).
+func (f *Font) CollectLookupGlyphs(tableTag tt.Tag, lookupIndices []uint16) tt.LookupGlyphs {
+	switch tableTag {
+	case tt.TagGsub:
+		return f.otTables.GSUB.CollectGlyphs(lookupIndices)
+	case tt.TagGpos:
+		return f.otTables.GPOS.CollectGlyphs(lookupIndices)
+	default:
+		return tt.LookupGlyphs{}
+	}
+}
+
+// SizeParams returns the parameters of the first valid 'size' feature of the GPOS table,
+// or false if there is none.
+func (f *Font) SizeParams() (tt.FeatureParamsSize, bool) {
+	for _, feature := range f.otTables.GPOS.Features {
+		if params, ok := feature.Params.(tt.FeatureParamsSize); ok && feature.Tag == tagSize {
+			return params, true
 		}
 	}
-	return nil
+	return tt.FeatureParamsSize{}, false
 }
 
-// tests whether a specified lookup index in the specified face would
-// trigger a substitution on the given glyph sequence.
-// zeroContext indicating whether substitutions should be context-free.
-func otLayoutLookupWouldSubstitute(font *Font, lookupIndex uint16, glyphs []fonts.GID, zeroContext bool) bool {
-	gsub := font.otTables.GSUB
+// LookupWouldSubstitute tests whether the GSUB lookup at `lookupIndex`
+// would trigger a substitution on the given glyph sequence.
+// `zeroContext` indicates whether substitutions should be context-free, that is
+// if contextual lookups with backtrack or lookahead glyphs should be rejected.
+func (f *Font) LookupWouldSubstitute(lookupIndex uint16, glyphs []fonts.GID, zeroContext bool) bool {
+	gsub := f.otTables.GSUB
 	if int(lookupIndex) >= len(gsub.Lookups) {
 		return false
 	}
-	c := wouldApplyContext{font.face, glyphs, nil, zeroContext}
+	c := wouldApplyContext{f.face, glyphs, nil, zeroContext}
 
 	l := lookupGSUB(gsub.Lookups[lookupIndex])
-	return l.wouldApply(&c, &font.gsubAccels[lookupIndex])
+	return l.wouldApply(&c, &f.gsubAccels[lookupIndex])
+}
+
+// FeatureWouldSubstitute is the same as LookupWouldSubstitute, but tests
+// all the lookups of the GSUB feature at `featureIndex`, taking into account the
+// variation coordinates of the font.
+func (f *Font) FeatureWouldSubstitute(featureIndex uint16, glyphs []fonts.GID, zeroContext bool) bool {
+	for _, lookupIndex := range f.FeatureLookups(tt.TagGsub, featureIndex) {
+		if f.LookupWouldSubstitute(lookupIndex, glyphs, zeroContext) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupGlyphAlternates returns the alternates of `glyph` defined by the GSUB lookup at `lookupIndex`,
+// that is the glyphs which may replace it through a single or alternate substitution.
+// As for Harfbuzz, only the first subtable covering `glyph` is used.
+// It returns nil if the lookup does not apply to `glyph`.
+func (f *Font) LookupGlyphAlternates(lookupIndex uint16, glyph fonts.GID) []fonts.GID {
+	gsub := f.otTables.GSUB
+	if int(lookupIndex) >= len(gsub.Lookups) {
+		return nil
+	}
+	for _, subtable := range gsub.Lookups[lookupIndex].Subtables {
+		index, ok := subtable.Coverage.Index(glyph)
+		if !ok {
+			continue
+		}
+		switch data := subtable.Data.(type) {
+		case tt.GSUBSingle1:
+			return []fonts.GID{fonts.GID(uint16(int(glyph) + int(data)))}
+		case tt.GSUBSingle2:
+			if index < len(data) {
+				return []fonts.GID{data[index]}
+			}
+		case tt.GSUBAlternate1:
+			if index < len(data) {
+				return append([]fonts.GID(nil), data[index]...)
+			}
+		}
+	}
+	return nil
+}
+
+// GlyphAlternates returns the alternates of `glyph` defined by the lookups of the GSUB
+// feature at `featureIndex` (such as 'salt', 'aalt' or 'swsh'), in lookup order and without duplicates.
+// The variation coordinates of the font are taken into account.
+func (f *Font) GlyphAlternates(featureIndex uint16, glyph fonts.GID) []fonts.GID {
+	var (
+		out  []fonts.GID
+		seen = map[fonts.GID]bool{glyph: true}
+	)
+	for _, lookupIndex := range f.FeatureLookups(tt.TagGsub, featureIndex) {
+		for _, alternate := range f.LookupGlyphAlternates(lookupIndex, glyph) {
+			if !seen[alternate] {
+				seen[alternate] = true
+				out = append(out, alternate)
+			}
+		}
+	}
+	return out
+}
+
+// SubstituteClosure adds to `glyphs` all the glyphs reachable through the substitutions
+// of the given GSUB features, applied repeatedly to `glyphs`.
+// The variation coordinates of the font are taken into account.
+// The context of contextual lookups is not checked, so that the closure
+// may include more glyphs than strictly needed.
+func (f *Font) SubstituteClosure(featureIndices []uint16, glyphs map[fonts.GID]bool) {
+	var lookups []uint16
+	for _, featureIndex := range featureIndices {
+		lookups = append(lookups, f.FeatureLookups(tt.TagGsub, featureIndex)...)
+	}
+	f.otTables.GSUB.SubstituteClosure(lookups, glyphs)
 }
 
 // Called before substitution lookups are performed, to ensure that glyph
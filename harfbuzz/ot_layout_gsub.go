@@ -250,6 +250,7 @@ func (c *otApplyContext) applySubsLigature(ligatureSet []tt.LigatureGlyph) bool
 
 		ok, matchLength, totalComponentCount := c.matchInput(lig.Components, matchGlyph, &matchPositions)
 		if !ok {
+			c.buffer.unsafeToConcat(c.buffer.idx, c.buffer.idx+matchLength)
 			continue
 		}
 		c.ligateInput(count, matchPositions, matchLength, lig.Glyph, totalComponentCount)
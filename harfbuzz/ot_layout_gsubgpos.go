@@ -228,8 +228,17 @@ func (it *skippingIterator) reject() {
 
 func (it *skippingIterator) maySkip(info *GlyphInfo) uint8 { return it.matcher.maySkip(it.c, info) }
 
-func (it *skippingIterator) next() bool {
-	for it.idx+it.numItems < it.end {
+// next advances to the next matching glyph. On failure, if `unsafeTo`
+// is not nil, it is set to the end of the range whose shaping
+// depended on the failed match.
+func (it *skippingIterator) next(unsafeTo *int) bool {
+	// The alternate condition below is faster at string boundaries,
+	// but produces subpar "unsafe-to-concat" values.
+	stop := it.end - it.numItems
+	if it.c.buffer.Flags&ProduceUnsafeToConcat != 0 {
+		stop = it.end - 1
+	}
+	for it.idx < stop {
 		it.idx++
 		info := &it.c.buffer.Info[it.idx]
 
@@ -248,16 +257,29 @@ func (it *skippingIterator) next() bool {
 		}
 
 		if skip == no {
+			if unsafeTo != nil {
+				*unsafeTo = it.idx + 1
+			}
 			return false
 		}
 	}
+	if unsafeTo != nil {
+		*unsafeTo = it.end
+	}
 	return false
 }
 
-func (it *skippingIterator) prev() bool {
+// prev moves back to the previous matching glyph. On failure, if `unsafeFrom`
+// is not nil, it is set to the start of the range whose shaping
+// depended on the failed match.
+func (it *skippingIterator) prev(unsafeFrom *int) bool {
 	L := len(it.c.buffer.outInfo)
 	//    assert (num_items > 0);
-	for it.idx > it.numItems-1 {
+	stop := it.numItems - 1
+	if it.c.buffer.Flags&ProduceUnsafeToConcat != 0 {
+		stop = 0
+	}
+	for it.idx > stop {
 		it.idx--
 		var info *GlyphInfo
 		if it.idx < L {
@@ -283,9 +305,15 @@ func (it *skippingIterator) prev() bool {
 		}
 
 		if skip == no {
+			if unsafeFrom != nil {
+				*unsafeFrom = max(1, it.idx) - 1
+			}
 			return false
 		}
 	}
+	if unsafeFrom != nil {
+		*unsafeFrom = 0
+	}
 	return false
 }
 
@@ -486,6 +514,7 @@ func (c *otApplyContext) contextApplyLookup(input []uint16, lookupRecord []tt.Se
 	var matchPositions [maxContextLength]int
 	hasMatch, matchLength, _ := c.matchInput(input, lookupContext, &matchPositions)
 	if !hasMatch {
+		c.buffer.unsafeToConcat(c.buffer.idx, c.buffer.idx+matchLength)
 		return false
 	}
 	c.buffer.unsafeToBreak(c.buffer.idx, c.buffer.idx+matchLength)
@@ -502,16 +531,19 @@ func (c *otApplyContext) chainContextApplyLookup(backtrack, input, lookahead []u
 
 	hasMatch, matchLength, _ := c.matchInput(input, lookupContexts[1], &matchPositions)
 	if !hasMatch {
+		c.buffer.unsafeToConcat(c.buffer.idx, c.buffer.idx+matchLength)
 		return false
 	}
 
-	hasMatch, startIndex := c.matchBacktrack(backtrack, lookupContexts[0])
+	hasMatch, endIndex := c.matchLookahead(lookahead, lookupContexts[2], matchLength)
 	if !hasMatch {
+		c.buffer.unsafeToConcat(c.buffer.idx, endIndex)
 		return false
 	}
 
-	hasMatch, endIndex := c.matchLookahead(lookahead, lookupContexts[2], matchLength)
+	hasMatch, startIndex := c.matchBacktrack(backtrack, lookupContexts[0])
 	if !hasMatch {
+		c.buffer.unsafeToConcatFromOutbuffer(startIndex, endIndex)
 		return false
 	}
 
@@ -603,6 +635,8 @@ func (c *wouldApplyContext) wouldMatchInput(input []uint16, matchFunc matcherFun
 }
 
 // `input` starts with second glyph (`inputCount` = len(input)+1)
+// On failure, the returned length delimits the glyphs which should be
+// marked as unsafe to concat.
 func (c *otApplyContext) matchInput(input []uint16, matchFunc matcherFunc,
 	matchPositions *[maxContextLength]int) (bool, int, uint8) {
 	count := len(input) + 1
@@ -651,8 +685,9 @@ func (c *otApplyContext) matchInput(input []uint16, matchFunc matcherFunc,
 	ligbase := ligbaseNotChecked
 	matchPositions[0] = buffer.idx
 	for i := 1; i < count; i++ {
-		if !skippyIter.next() {
-			return false, 0, 0
+		var unsafeTo int
+		if !skippyIter.next(&unsafeTo) {
+			return false, unsafeTo - buffer.idx, 0
 		}
 
 		matchPositions[i] = skippyIter.idx
@@ -955,28 +990,34 @@ func (c *otApplyContext) applyLookup(count int, matchPositions *[maxContextLengt
 	buffer.moveTo(end)
 }
 
+// matchBacktrack returns the start of the match, or on failure,
+// the start of the range unsafe to concat
 func (c *otApplyContext) matchBacktrack(backtrack []uint16, matchFunc matcherFunc) (bool, int) {
 	skippyIter := &c.iterContext
 	skippyIter.reset(c.buffer.backtrackLen(), len(backtrack))
 	skippyIter.setMatchFunc(matchFunc, backtrack)
 
 	for i := 0; i < len(backtrack); i++ {
-		if !skippyIter.prev() {
-			return false, 0
+		var unsafeFrom int
+		if !skippyIter.prev(&unsafeFrom) {
+			return false, unsafeFrom
 		}
 	}
 
 	return true, skippyIter.idx
 }
 
+// matchLookahead returns the end of the match, or on failure,
+// the end of the range unsafe to concat
 func (c *otApplyContext) matchLookahead(lookahead []uint16, matchFunc matcherFunc, offset int) (bool, int) {
 	skippyIter := &c.iterContext
 	skippyIter.reset(c.buffer.idx+offset-1, len(lookahead))
 	skippyIter.setMatchFunc(matchFunc, lookahead)
 
 	for i := 0; i < len(lookahead); i++ {
-		if !skippyIter.next() {
-			return false, 0
+		var unsafeTo int
+		if !skippyIter.next(&unsafeTo) {
+			return false, unsafeTo
 		}
 	}
 
@@ -0,0 +1,116 @@
+package harfbuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxesandglue/textlayout/fonts"
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
+)
+
+func TestLayoutIntrospection(t *testing.T) {
+	font := NewFont(openFontFileTT("ToyGPOSCursive.ttf"))
+	gsub := &font.otTables.GSUB.TableLayout
+
+	scriptIndex, _, _ := SelectScript(gsub, gsub.ScriptTags()[:1])
+	assert(t, scriptIndex == 0)
+
+	var tags []tt.Tag
+	for _, featureIndex := range LanguageFeatureIndices(gsub, scriptIndex, DefaultLanguageIndex) {
+		tags = append(tags, gsub.Features[featureIndex].Tag)
+	}
+	assert(t, len(tags) != 0)
+	assert(t, LanguageFeatureIndices(gsub, NoScriptIndex, DefaultLanguageIndex) == nil)
+
+	featureIndex := findFeature(gsub, tt.NewTag('m', 'e', 'd', 'i'))
+	lookups := font.FeatureLookups(tt.TagGsub, featureIndex)
+	assert(t, reflect.DeepEqual(lookups, []uint16{2}))
+	assert(t, font.FeatureLookups(tt.TagGdef, featureIndex) == nil)
+
+	glyphs := font.CollectLookupGlyphs(tt.TagGsub, lookups)
+	assert(t, reflect.DeepEqual(glyphs.Input, map[fonts.GID]bool{1: true, 4: true}))
+	assert(t, reflect.DeepEqual(glyphs.Output, map[fonts.GID]bool{5: true, 6: true, 9: true}))
+
+	_, ok := font.SizeParams()
+	assert(t, !ok)
+}
+
+func TestFeatureLookupsVariations(t *testing.T) {
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	featureIndex := findFeature(&font.otTables.GSUB.TableLayout, tt.NewTag('r', 'v', 'r', 'n'))
+	assert(t, featureIndex != NoFeatureIndex)
+
+	assert(t, len(font.FeatureLookups(tt.TagGsub, featureIndex)) == 0)
+
+	font.SetVarCoordsDesign([]float32{900, 0, 0, 0})
+	assert(t, reflect.DeepEqual(font.FeatureLookups(tt.TagGsub, featureIndex), []uint16{83}))
+}
+
+func TestShapeFeatureVariations(t *testing.T) {
+	// at wght=900, the feature variations of Commissioner only substitute 'rvrn':
+	// the other features must keep their default lookups
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	font.SetVarCoordsDesign([]float32{900, 0, 0, 0})
+	assert(t, font.otTables.GSUB.FindVariationIndex(font.varCoords()) >= 0)
+
+	buf := NewBuffer()
+	buf.AddRunes([]rune("office"), 0, -1)
+	buf.GuessSegmentProperties()
+	buf.Shape(font, nil)
+	assertEqualInt(t, 4, len(buf.Info))
+	assertEqualInt(t, 471, int(buf.Info[1].Glyph)) // ffi ligature
+}
+
+func TestSizeParams(t *testing.T) {
+	font := NewFont(openFontFileTT("TestCFF2VF.otf"))
+	params, ok := font.SizeParams()
+	assert(t, ok)
+	assert(t, params == tt.FeatureParamsSize{DesignSize: 100})
+}
+
+func TestGlyphAlternates(t *testing.T) {
+	ft := openFontFileTT("Raleway-v4020-Regular.otf")
+	font := NewFont(ft)
+	gsub := &font.otTables.GSUB.TableLayout
+
+	a, _ := ft.NominalGlyph('a')
+	aalt := findFeature(gsub, tt.NewTag('a', 'a', 'l', 't'))
+	salt := findFeature(gsub, tt.NewTag('s', 'a', 'l', 't'))
+	smcp := findFeature(gsub, tt.NewTag('s', 'm', 'c', 'p'))
+	liga := findFeature(gsub, tt.NewTag('l', 'i', 'g', 'a'))
+
+	// alternate substitution
+	assert(t, reflect.DeepEqual(font.GlyphAlternates(aalt, a), []fonts.GID{502, 476, 459, 460}))
+	// single substitutions
+	assert(t, reflect.DeepEqual(font.GlyphAlternates(salt, a), []fonts.GID{459}))
+	assert(t, reflect.DeepEqual(font.GlyphAlternates(smcp, a), []fonts.GID{476}))
+	assert(t, font.GlyphAlternates(liga, a) == nil)
+	assert(t, font.LookupGlyphAlternates(1000, a) == nil)
+
+	assert(t, font.FeatureWouldSubstitute(smcp, []fonts.GID{a}, false))
+	assert(t, !font.FeatureWouldSubstitute(liga, []fonts.GID{a}, false))
+}
+
+func TestSubstituteClosure(t *testing.T) {
+	ft := openFontFileTT("Raleway-v4020-Regular.otf")
+	font := NewFont(ft)
+	gsub := &font.otTables.GSUB.TableLayout
+
+	a, _ := ft.NominalGlyph('a')
+	salt := findFeature(gsub, tt.NewTag('s', 'a', 'l', 't'))
+	smcp := findFeature(gsub, tt.NewTag('s', 'm', 'c', 'p'))
+	glyphs := map[fonts.GID]bool{a: true}
+	font.SubstituteClosure([]uint16{salt, smcp}, glyphs)
+	assert(t, reflect.DeepEqual(glyphs, map[fonts.GID]bool{a: true, 459: true, 476: true}))
+
+	// ligatures require all their components
+	f, _ := ft.NominalGlyph('f')
+	i, _ := ft.NominalGlyph('i')
+	liga := findFeature(gsub, tt.NewTag('l', 'i', 'g', 'a'))
+	glyphs = map[fonts.GID]bool{f: true}
+	font.SubstituteClosure([]uint16{liga}, glyphs)
+	assert(t, reflect.DeepEqual(glyphs, map[fonts.GID]bool{f: true, 470: true})) // f_f
+	glyphs[i] = true
+	font.SubstituteClosure([]uint16{liga}, glyphs)
+	assert(t, glyphs[473] && glyphs[471]) // f_i, f_f_i
+}
@@ -443,9 +443,15 @@ func (m *otMap) substitute(plan *otShapePlan, font *Font, buffer *Buffer) {
 		fmt.Println("SUBSTITUTE - start table GSUB")
 	}
 
+	if !buffer.messagef(font, "start table GSUB") {
+		return
+	}
+
 	proxy := otProxy{otProxyMeta: proxyGSUB, accels: font.gsubAccels}
 	m.apply(proxy, plan, font, buffer)
 
+	buffer.messagef(font, "end table GSUB")
+
 	if debugMode >= 1 {
 		fmt.Println("SUBSTITUTE - end table GSUB")
 	}
@@ -457,9 +463,15 @@ func (m *otMap) position(plan *otShapePlan, font *Font, buffer *Buffer) {
 		fmt.Println("POSITION - start table GPOS")
 	}
 
+	if !buffer.messagef(font, "start table GPOS") {
+		return
+	}
+
 	proxy := otProxy{otProxyMeta: proxyGPOS, accels: font.gposAccels}
 	m.apply(proxy, plan, font, buffer)
 
+	buffer.messagef(font, "end table GPOS")
+
 	if debugMode >= 1 {
 		fmt.Println("POSITION - end table GPOS")
 	}
@@ -476,6 +488,9 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 		if debugMode >= 2 {
 			fmt.Printf("\tAPPLY - stage %d\n", stageI)
 		}
+		if buffer.messaging() {
+			buffer.messagef(font, "start stage %d", stageI)
+		}
 
 		for ; i < stage.lastLookup; i++ {
 			lookupIndex := m.lookups[tableIndex][i].index
@@ -484,6 +499,11 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 				fmt.Printf("\t\tLookup %d start\n", lookupIndex)
 			}
 
+			// avoid formatting the message if not needed
+			if buffer.messaging() && !buffer.messagef(font, "start lookup %d", lookupIndex) {
+				continue
+			}
+
 			c.lookupIndex = lookupIndex
 			c.setLookupMask(m.lookups[tableIndex][i].mask)
 			c.setAutoZWJ(m.lookups[tableIndex][i].autoZWJ)
@@ -496,6 +516,10 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 			}
 			c.applyString(proxy.otProxyMeta, &proxy.accels[lookupIndex])
 
+			if buffer.messaging() {
+				buffer.messagef(font, "end lookup %d", lookupIndex)
+			}
+
 			if debugMode >= 1 {
 				fmt.Println("\t\tLookup end")
 				fmt.Println(c.buffer.Info)
@@ -503,12 +527,17 @@ func (m *otMap) apply(proxy otProxy, plan *otShapePlan, font *Font, buffer *Buff
 
 		}
 
-		if stage.pauseFunc != nil {
+		// complex shapers pauses require Unicode properties
+		if stage.pauseFunc != nil && buffer.scratchFlags&bsfGlyphInput == 0 {
 			if debugMode >= 1 {
 				fmt.Println("\t\tExecuting pause function")
 			}
 
 			stage.pauseFunc(plan, font, buffer)
 		}
+
+		if buffer.messaging() {
+			buffer.messagef(font, "end stage %d", stageI)
+		}
 	}
 }
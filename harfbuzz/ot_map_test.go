@@ -16,33 +16,20 @@ func TestOTFeature(t *testing.T) {
 		t.Fatal("failed to find feature index")
 	}
 
-	// if (!hb_ot_layout_feature_get_name_ids (face, HB_OT_TAG_GSUB, featureIndex,
-	// 					&label_id, &tooltip_id, &sample_id,
-	// 					&num_named_parameters, &first_param_id))
-	//   g_error ("Failed to get name ids");
-
-	// assertEqualInt (t, label_id, 256);
-	// assertEqualInt (t, tooltip_id, 257);
-	// assertEqualInt (t, sample_id, 258);
-	// assertEqualInt (t, num_named_parameters, 2);
-	// assertEqualInt (t, first_param_id, 259);
-
-	// all_chars = hb_ot_layout_feature_get_characters (face, HB_OT_TAG_GSUB, featureIndex,
-	// 						 0, &char_count, characters);
-
-	// assertEqualInt (t, all_chars, 2);
-	// assertEqualInt (t, char_count, 2);
-	// assertEqualInt (t, characters, ==, 10);
-	// assertEqualInt (t, characters, ==, 24030);
-
-	// char_count = 100;
-	// characters[1] = 1234;
-	// all_chars = hb_ot_layout_feature_get_characters (face, HB_OT_TAG_GSUB, featureIndex,
-	// 						 1, &char_count, characters);
-	// assertEqualInt (t, all_chars, 2);
-	// assertEqualInt (t, char_count, 1);
-	// assertEqualInt (t, characters[0],  24030);
-	// assertEqualInt (t, characters[1],  1234);
+	params, ok := face.GSUB.Features[featureIndex].Params.(tt.FeatureParamsCharacterVariants)
+	if !ok {
+		t.Fatal("failed to get name ids")
+	}
+
+	assertEqualInt(t, int(params.FeatUILabelNameID), 256)
+	assertEqualInt(t, int(params.FeatUITooltipTextNameID), 257)
+	assertEqualInt(t, int(params.SampleTextNameID), 258)
+	assertEqualInt(t, int(params.NumNamedParameters), 2)
+	assertEqualInt(t, int(params.FirstParamUILabelNameID), 259)
+
+	assertEqualInt(t, len(params.Characters), 2)
+	assertEqualInt(t, int(params.Characters[0]), 10)
+	assertEqualInt(t, int(params.Characters[1]), 24030)
 
 	// unsigned int num_entries;
 	// const hb_ot_name_entry_t *entries;
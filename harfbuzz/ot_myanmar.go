@@ -82,12 +82,15 @@ func foundSyllableMyanmar(syllableType uint8, ts, te int, info []GlyphInfo, syll
 	}
 }
 
-func setupSyllablesMyanmar(_ *otShapePlan, _ *Font, buffer *Buffer) {
+func setupSyllablesMyanmar(_ *otShapePlan, font *Font, buffer *Buffer) {
 	findSyllablesMyanmar(buffer)
 	iter, count := buffer.syllableIterator()
+	syllables := 0
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		buffer.unsafeToBreak(start, end)
+		syllables++
 	}
+	buffer.messagef(font, "found %d syllables", syllables)
 }
 
 /* Rules from:
@@ -190,6 +193,9 @@ func reorderMyanmar(_ *otShapePlan, font *Font, buffer *Buffer) {
 	if debugMode >= 1 {
 		fmt.Println("MYANMAR - start reordering myanmar")
 	}
+	if !buffer.messagef(font, "start reordering myanmar") {
+		return
+	}
 
 	syllabicInsertDottedCircles(font, buffer, myanmarBrokenCluster, otGB, -1, -1)
 
@@ -198,6 +204,8 @@ func reorderMyanmar(_ *otShapePlan, font *Font, buffer *Buffer) {
 		reorderSyllableMyanmar(buffer, start, end)
 	}
 
+	buffer.messagef(font, "end reordering myanmar")
+
 	if debugMode >= 1 {
 		fmt.Println("MYANMAR - end reordering myanmar")
 	}
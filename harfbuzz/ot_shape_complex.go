@@ -149,6 +149,35 @@ func (planner *otShapePlanner) categorizeComplex() otComplexShaper {
 	}
 }
 
+// complexShaperName returns a short name for `shaper`, as used in buffer messages.
+func complexShaperName(shaper otComplexShaper) string {
+	switch shaper := shaper.(type) {
+	case *complexShaperArabic:
+		return "arabic"
+	case complexShaperThai:
+		return "thai"
+	case *complexShaperHangul:
+		return "hangul"
+	case complexShaperHebrew:
+		return "hebrew"
+	case *complexShaperIndic:
+		return "indic"
+	case *complexShaperKhmer:
+		return "khmer"
+	case complexShaperMyanmar:
+		return "myanmar"
+	case *complexShaperUSE:
+		return "use"
+	case complexShaperDefault:
+		if shaper.dumb {
+			return "dumber"
+		}
+		return "default"
+	default:
+		return "default"
+	}
+}
+
 // zero byte struct providing no-ops, used to reduced boilerplate
 type complexShaperNil struct{}
 
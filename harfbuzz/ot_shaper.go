@@ -481,9 +481,11 @@ func (c *otContext) setupMasks() {
 	map_ := &c.plan.map_
 	buffer := c.buffer
 
-	c.setupMasksFraction()
+	if buffer.scratchFlags&bsfGlyphInput == 0 {
+		c.setupMasksFraction()
 
-	c.plan.shaper.setupMasks(c.plan, buffer, c.font)
+		c.plan.shaper.setupMasks(c.plan, buffer, c.font)
+	}
 
 	for _, feature := range c.userFeatures {
 		if !(feature.Start == FeatureGlobalStart && feature.End == FeatureGlobalEnd) {
@@ -558,20 +560,24 @@ func synthesizeGlyphClasses(buffer *Buffer) {
 
 func (c *otContext) substituteBeforePosition() {
 	buffer := c.buffer
-	// normalize and sets Glyph
+	glyphInput := buffer.scratchFlags&bsfGlyphInput != 0
 
-	c.otRotateChars()
+	// normalize and sets Glyph
+	if !glyphInput {
+		c.otRotateChars()
 
-	otShapeNormalize(c.plan, buffer, c.font)
+		otShapeNormalize(c.plan, buffer, c.font)
+	}
 
 	c.setupMasks()
 
 	// this is unfortunate to go here, but necessary...
-	if c.plan.fallbackMarkPositioning {
+	if c.plan.fallbackMarkPositioning && !glyphInput {
 		fallbackMarkPositionRecategorizeMarks(buffer)
 	}
 
 	// Glyph fields are now set up ...
+	buffer.content = contentGlyphs
 	// ... apply complex substitution from font
 
 	layoutSubstituteStart(c.font, buffer)
@@ -589,10 +595,17 @@ func (c *otContext) substituteAfterPosition() {
 		aatLayoutRemoveDeletedGlyphsInplace(c.buffer)
 	}
 
+	if c.buffer.scratchFlags&bsfGlyphInput != 0 {
+		return
+	}
+
 	if debugMode >= 1 {
 		fmt.Printf("POSTPROCESS glyphs start (%T)\n", c.plan.shaper)
 	}
-	c.plan.shaper.postprocessGlyphs(c.plan, c.buffer, c.font)
+	if c.buffer.messagef(c.font, "start postprocess-glyphs") {
+		c.plan.shaper.postprocessGlyphs(c.plan, c.buffer, c.font)
+		c.buffer.messagef(c.font, "end postprocess-glyphs")
+	}
 	if debugMode >= 1 {
 		fmt.Println("POSTPROCESS glyphs end ")
 	}
@@ -687,7 +700,7 @@ func (c *otContext) positionComplex() {
 		pos[i].XOffset, pos[i].YOffset = c.font.subtractGlyphHOrigin(inf.Glyph, pos[i].XOffset, pos[i].YOffset)
 	}
 
-	if c.plan.fallbackMarkPositioning {
+	if c.plan.fallbackMarkPositioning && c.buffer.scratchFlags&bsfGlyphInput == 0 {
 		fallbackMarkPosition(c.plan, c.font, c.buffer, adjustOffsetsWhenZeroing)
 	}
 }
@@ -703,6 +716,17 @@ func (c *otContext) position() {
 
 	c.positionComplex()
 
+	// follow the synthetic slant of the outlines, once all
+	// the offsets (including the fallback ones) are known
+	if slant := c.font.slantXY(); slant != 0 {
+		pos := c.buffer.Pos
+		for i := range pos {
+			if pos[i].YOffset != 0 {
+				pos[i].XOffset += roundf(slant * float32(pos[i].YOffset))
+			}
+		}
+	}
+
 	if c.buffer.Props.Direction.isBackward() {
 		c.buffer.Reverse()
 	}
@@ -711,26 +735,47 @@ func (c *otContext) position() {
 /* Propagate cluster-level glyph flags to be the same on all cluster glyphs.
  * Simplifies using them. */
 func propagateFlags(buffer *Buffer) {
-	if buffer.scratchFlags&bsfHasUnsafeToBreak == 0 {
+	if buffer.scratchFlags&bsfHasGlyphFlags == 0 {
 		return
 	}
 
+	/* If we are producing SAFE_TO_INSERT_TATWEEL, then do two things:
+	 *
+	 * - If the places that the Arabic shaper marked as SAFE_TO_INSERT_TATWEEL,
+	 *   are UNSAFE_TO_BREAK, then clear the SAFE_TO_INSERT_TATWEEL,
+	 * - Any place that is SAFE_TO_INSERT_TATWEEL, is also now UNSAFE_TO_BREAK.
+	 *
+	 * We couldn't make this interaction earlier. It has to be done here.
+	 */
+	flipTatweel := buffer.Flags&ProduceSafeToInsertTatweel != 0
+
+	clearConcat := buffer.Flags&ProduceUnsafeToConcat == 0
+
 	info := buffer.Info
 
 	iter, count := buffer.clusterIterator()
 	for start, end := iter.next(); start < count; start, end = iter.next() {
-		var mask uint32
+		var mask GlyphMask
 		for i := start; i < end; i++ {
-			if info[i].Mask&GlyphUnsafeToBreak != 0 {
-				mask = GlyphUnsafeToBreak
-				break
-			}
+			mask |= info[i].Mask & glyphFlagDefined
 		}
-		if mask != 0 {
-			for i := start; i < end; i++ {
-				info[i].Mask |= mask
+
+		if flipTatweel {
+			if mask&GlyphUnsafeToBreak != 0 {
+				mask &^= GlyphSafeToInsertTatweel
+			}
+			if mask&GlyphSafeToInsertTatweel != 0 {
+				mask |= GlyphUnsafeToBreak | GlyphUnsafeToConcat
 			}
 		}
+
+		if clearConcat {
+			mask &^= GlyphUnsafeToConcat
+		}
+
+		for i := start; i < end; i++ {
+			info[i].Mask = (info[i].Mask &^ glyphFlagDefined) | mask
+		}
 	}
 }
 
@@ -766,6 +811,11 @@ func (sp *shaperOpenType) compile(props SegmentProperties, userFeatures []Featur
 func (sp *shaperOpenType) shape(font *Font, buffer *Buffer, features []Feature) {
 	c := otContext{plan: &sp.plan, font: font, face: font.face, buffer: buffer, userFeatures: features}
 	c.buffer.scratchFlags = bsfDefault
+	if c.buffer.content == contentGlyphs {
+		// skip the steps depending on the Unicode properties of the text
+		c.buffer.scratchFlags |= bsfGlyphInput
+	}
+	glyphInput := c.buffer.scratchFlags&bsfGlyphInput != 0
 
 	const maxLenFactor = 64
 	const maxLenMin = 16384
@@ -774,14 +824,18 @@ func (sp *shaperOpenType) shape(font *Font, buffer *Buffer, features []Feature)
 	c.buffer.maxOps = max(len(c.buffer.Info)*maxOpsFactor, maxOpsMin)
 	c.buffer.maxLen = max(len(c.buffer.Info)*maxLenFactor, maxLenMin)
 
+	c.buffer.messagef(font, "shaping with complex shaper %s", complexShaperName(sp.plan.shaper))
+
 	// save the original direction, we use it later.
 	c.targetDirection = c.buffer.Props.Direction
 
 	c.initializeMasks()
-	c.buffer.setUnicodeProps()
-	c.buffer.insertDottedCircle(c.font)
+	if !glyphInput {
+		c.buffer.setUnicodeProps()
+		c.buffer.insertDottedCircle(c.font)
 
-	c.buffer.formClusters()
+		c.buffer.formClusters()
+	}
 
 	if debugMode >= 1 {
 		fmt.Println("FORMING CLUSTER :", c.buffer.Info)
@@ -792,7 +846,10 @@ func (sp *shaperOpenType) shape(font *Font, buffer *Buffer, features []Feature)
 	if debugMode >= 1 {
 		fmt.Printf("PREPROCESS text start (complex shaper %T)\n", c.plan.shaper)
 	}
-	c.plan.shaper.preprocessText(c.plan, c.buffer, c.font)
+	if !glyphInput && c.buffer.messagef(c.font, "start preprocess-text") {
+		c.plan.shaper.preprocessText(c.plan, c.buffer, c.font)
+		c.buffer.messagef(c.font, "end preprocess-text")
+	}
 	if debugMode >= 1 {
 		fmt.Println("PREPROCESS text end")
 	}
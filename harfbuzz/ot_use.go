@@ -219,12 +219,15 @@ func (cs *complexShaperUSE) setupTopographicalMasks(plan *otShapePlan, buffer *B
 	}
 }
 
-func (cs *complexShaperUSE) setupSyllablesUse(plan *otShapePlan, _ *Font, buffer *Buffer) {
+func (cs *complexShaperUSE) setupSyllablesUse(plan *otShapePlan, font *Font, buffer *Buffer) {
 	findSyllablesUse(buffer)
 	iter, count := buffer.syllableIterator()
+	syllables := 0
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		buffer.unsafeToBreak(start, end)
+		syllables++
 	}
+	buffer.messagef(font, "found %d syllables", syllables)
 	cs.setupRphfMask(buffer)
 	cs.setupTopographicalMasks(plan, buffer)
 }
@@ -347,6 +350,9 @@ func reorderUse(_ *otShapePlan, font *Font, buffer *Buffer) {
 	if debugMode >= 1 {
 		fmt.Println("USE - start reordering USE")
 	}
+	if !buffer.messagef(font, "start reordering USE") {
+		return
+	}
 	syllabicInsertDottedCircles(font, buffer, useBrokenCluster,
 		useSyllableMachine_ex_B, useSyllableMachine_ex_R, -1)
 
@@ -354,6 +360,8 @@ func reorderUse(_ *otShapePlan, font *Font, buffer *Buffer) {
 	for start, end := iter.next(); start < count; start, end = iter.next() {
 		reorderSyllableUse(buffer, start, end)
 	}
+	buffer.messagef(font, "end reordering USE")
+
 	if debugMode >= 1 {
 		fmt.Println("USE - end reordering USE")
 	}
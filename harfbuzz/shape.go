@@ -2,7 +2,10 @@ package harfbuzz
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+
+	tt "github.com/boxesandglue/textlayout/fonts/truetype"
 )
 
 // ported from harfbuzz/src/hb-shape.cc, harfbuzz/src/hb-shape-plan.cc Copyright © 2009, 2012 Behdad Esfahbod
@@ -20,14 +23,47 @@ import (
 // overlapping ranges the value of the feature with the higher index takes
 // precedence.
 //
+// Buffers filled with glyphs instead of runes (see `AddGlyphs`) are also
+// supported: only the layout tables of the font are then applied.
+//
 // The shaping plan depends on the font capabilities. See `NewFont` and `Face` and
 // its extension interfaces for more details.
 //
 // It also depends on the properties of the segment of text : the `Props`
 // field of the buffer must be set before calling `Shape`.
+//
+// The shaping plans are cached by `font`, see `Font.SetShapePlanCacheSize`,
+// and `NewShapePlan` for an explicit alternative.
 func (b *Buffer) Shape(font *Font, features []Feature) {
+	if b.Flags&NoDefaultFeatures != 0 {
+		features = disableDefaultFeatures(font, features)
+	}
 	shapePlan := newShapePlanCached(font, b.Props, features, font.varCoords())
-	shapePlan.execute(font, b, features)
+	shapePlan.Execute(font, b, features)
+}
+
+// disableDefaultFeatures returns `features`, preceded by features disabling
+// all the features of the font, except the required 'rvrn'.
+func disableDefaultFeatures(font *Font, features []Feature) []Feature {
+	tags := map[tt.Tag]bool{tt.NewTag('k', 'e', 'r', 'n'): true} // also used by the 'kern' and 'kerx' tables
+	if font.otTables != nil {
+		for _, feature := range font.otTables.GSUB.Features {
+			tags[feature.Tag] = true
+		}
+		for _, feature := range font.otTables.GPOS.Features {
+			tags[feature.Tag] = true
+		}
+	}
+	delete(tags, tt.NewTag('r', 'v', 'r', 'n'))
+
+	out := make([]Feature, 0, len(tags)+len(features))
+	for tag := range tags {
+		out = append(out, Feature{Tag: tag, Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd})
+	}
+	// use a deterministic order, so that the shape plans may be cached
+	sort.Slice(out, func(i, j int) bool { return out[i].Tag < out[j].Tag })
+	// the user features take precedence
+	return append(out, features...)
 }
 
 type shaperKind uint8
@@ -38,6 +74,20 @@ const (
 	skGraphite
 )
 
+// String returns the shaper name, as used by HarfBuzz.
+func (sk shaperKind) String() string {
+	switch sk {
+	case skFallback:
+		return "fallback"
+	case skOpenType:
+		return "ot"
+	case skGraphite:
+		return "graphite2"
+	default:
+		return fmt.Sprintf("<shaper kind %d>", sk)
+	}
+}
+
 // shaper shapes a string of runes.
 // Depending on the font used, different shapers will be chosen.
 type shaper interface {
@@ -51,24 +101,21 @@ type shaper interface {
 	shape(*Font, *Buffer, []Feature)
 }
 
-// Shape plans are an internal mechanism. Each plan contains state
-// describing how HarfBuzz will shape a particular text segment, based on
-// the combination of segment properties and the capabilities in the
-// font face in use.
-//
-// Shape plans are not used for shaping directly, but can be queried to
-// access certain information about how shaping will perform, given a set
-// of specific input parameters (script, language, direction, features,
-// etc.).
+// ShapePlan contains the state describing how a particular text segment
+// will be shaped, based on the combination of segment properties, user features
+// and the capabilities of the font face in use.
 //
-// Most client programs will not need to deal with shape plans directly.
-type shapePlan struct {
+// Building a plan may be costly: it should be created once with `NewShapePlan`,
+// and reused with `Execute` for each buffer sharing the same properties.
+// Most client programs will not need to deal with shape plans directly,
+// since `Buffer.Shape` uses a cache of plans stored in the font.
+type ShapePlan struct {
 	shaper       shaper
 	props        SegmentProperties
 	userFeatures []Feature
 }
 
-func (plan *shapePlan) init(copy bool, font *Font, props SegmentProperties,
+func (plan *ShapePlan) init(copy bool, font *Font, props SegmentProperties,
 	userFeatures []Feature, coords []float32) {
 	plan.props = props
 	if !copy {
@@ -96,7 +143,7 @@ func (plan *shapePlan) init(copy bool, font *Font, props SegmentProperties,
 	}
 }
 
-func (plan shapePlan) userFeaturesMatch(other shapePlan) bool {
+func (plan ShapePlan) userFeaturesMatch(other ShapePlan) bool {
 	if len(plan.userFeatures) != len(other.userFeatures) {
 		return false
 	}
@@ -110,21 +157,35 @@ func (plan shapePlan) userFeaturesMatch(other shapePlan) bool {
 	return true
 }
 
-func (plan shapePlan) equal(other shapePlan) bool {
-	return plan.props == other.props &&
-		plan.userFeaturesMatch(other) && plan.shaper.kind() == other.shaper.kind()
+func (plan ShapePlan) equal(other ShapePlan) bool {
+	if !(plan.props == other.props &&
+		plan.userFeaturesMatch(other) && plan.shaper.kind() == other.shaper.kind()) {
+		return false
+	}
+	// the OpenType plan also depends on the feature variations selected by the coordinates
+	if ot, ok := plan.shaper.(*shaperOpenType); ok {
+		return ot.key == other.shaper.(*shaperOpenType).key
+	}
+	return true
+}
+
+// NewShapePlan constructs a shaping plan for a combination of `font`, `props` and `userFeatures`,
+// taking into account the variation coordinates of `font`.
+// The plan may then be used with `Execute` for any buffer with the same properties.
+func NewShapePlan(font *Font, props SegmentProperties, userFeatures []Feature) *ShapePlan {
+	return newShapePlan(font, props, userFeatures, font.varCoords())
 }
 
 // Constructs a shaping plan for a combination of @face, @userFeatures, @props,
 // plus the variation-space coordinates @coords.
 // See newShapePlanCached for caching support.
 func newShapePlan(font *Font, props SegmentProperties,
-	userFeatures []Feature, coords []float32) *shapePlan {
+	userFeatures []Feature, coords []float32) *ShapePlan {
 	if debugMode >= 1 {
 		fmt.Printf("NEW SHAPE PLAN: face:%p features:%v coords:%v\n", &font.face, userFeatures, coords)
 	}
 
-	var sp shapePlan
+	var sp ShapePlan
 
 	sp.init(true, font, props, userFeatures, coords)
 
@@ -136,50 +197,126 @@ func newShapePlan(font *Font, props SegmentProperties,
 	return &sp
 }
 
-// Executes the given shaping plan on the specified `buffer`, using
-// the given `font` and `features`.
-func (sp *shapePlan) execute(font *Font, buffer *Buffer, features []Feature) {
+// Shaper returns the name of the shaper chosen by the plan: "ot", "graphite2" or "fallback".
+func (sp *ShapePlan) Shaper() string { return sp.shaper.kind().String() }
+
+// Execute shapes `buffer` with the plan, using `font`, which must be the font
+// used to create the plan (or one with the same face and variations).
+// The `Props` field of the buffer must match the properties of the plan, and `features`
+// the features of the plan, except for their Start and End fields.
+// See `Buffer.Shape` for more details.
+func (sp *ShapePlan) Execute(font *Font, buffer *Buffer, features []Feature) {
 	if debugMode >= 1 {
 		fmt.Printf("EXECUTE shape plan %p features:%v shaper:%T\n", sp, features, sp.shaper)
 	}
 
+	buffer.messagef(font, "shaping with shaper %s", sp.shaper.kind())
+
 	sp.shaper.shape(font, buffer, features)
+	buffer.content = contentGlyphs
 }
 
 /*
  * Caching
  */
 
-var (
-	planCache     = map[Face][]*shapePlan{}
-	planCacheLock sync.Mutex
-)
+// defaultShapePlanCacheSize is the default number of plans cached by a Font.
+const defaultShapePlanCacheSize = 32
+
+// shapePlanCache stores a limited number of plans,
+// evicting the least recently used one when full.
+type shapePlanCache struct {
+	lock  sync.Mutex
+	plans []*ShapePlan // the most recently used plan comes last
+	size  int          // maximum number of plans
+}
+
+// lookup returns the plan equal to `key`, or nil
+func (cache *shapePlanCache) lookup(key ShapePlan) *ShapePlan {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for i, plan := range cache.plans {
+		if plan.equal(key) {
+			// move the plan to the end
+			copy(cache.plans[i:], cache.plans[i+1:])
+			cache.plans[len(cache.plans)-1] = plan
+			return plan
+		}
+	}
+	return nil
+}
+
+func (cache *shapePlanCache) insert(plan *ShapePlan) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	cache.plans = append(cache.plans, plan)
+	cache.evict()
+}
+
+// evict removes the least recently used plans, if needed
+func (cache *shapePlanCache) evict() {
+	if extra := len(cache.plans) - cache.size; extra > 0 {
+		n := copy(cache.plans, cache.plans[extra:])
+		for i := n; i < len(cache.plans); i++ { // release the memory
+			cache.plans[i] = nil
+		}
+		cache.plans = cache.plans[:n]
+	}
+}
+
+// SetShapePlanCacheSize sets the maximum number of shaping plans cached
+// by the font and used by `Buffer.Shape`, evicting the least recently
+// used ones if needed. The default is 32, and zero disables the cache.
+func (f *Font) SetShapePlanCacheSize(size int) {
+	if f.plans == nil {
+		f.plans = &shapePlanCache{}
+	}
+	f.plans.lock.Lock()
+	defer f.plans.lock.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	f.plans.size = size
+	f.plans.evict()
+}
+
+// PurgeShapePlans removes all the shaping plans cached by the font.
+func (f *Font) PurgeShapePlans() {
+	if f.plans == nil {
+		return
+	}
+	f.plans.lock.Lock()
+	defer f.plans.lock.Unlock()
+
+	for i := range f.plans.plans {
+		f.plans.plans[i] = nil
+	}
+	f.plans.plans = f.plans.plans[:0]
+}
 
 // creates (or returns) a cached shaping plan suitable for reuse, for a combination
-// of `face`, `userFeatures`, `props`, plus the variation-space coordinates `coords`.
+// of `font`, `userFeatures`, `props`, plus the variation-space coordinates `coords`.
 func newShapePlanCached(font *Font, props SegmentProperties,
-	userFeatures []Feature, coords []float32) *shapePlan {
+	userFeatures []Feature, coords []float32) *ShapePlan {
+	if font.plans == nil { // the font has not been created by NewFont
+		return newShapePlan(font, props, userFeatures, coords)
+	}
 
-	var key shapePlan
+	var key ShapePlan
 	key.init(false, font, props, userFeatures, coords)
 
-	planCacheLock.Lock()
-	defer planCacheLock.Unlock()
-
-	plans := planCache[font.face]
-
-	for _, plan := range plans {
-		if plan.equal(key) {
-			if debugMode >= 1 {
-				fmt.Printf("\tPLAN %p fulfilled from cache\n", plan)
-			}
-			return plan
+	if plan := font.plans.lookup(key); plan != nil {
+		if debugMode >= 1 {
+			fmt.Printf("\tPLAN %p fulfilled from cache\n", plan)
 		}
+		return plan
 	}
-	plan := newShapePlan(font, props, userFeatures, coords)
 
-	plans = append(plans, plan)
-	planCache[font.face] = plans
+	plan := newShapePlan(font, props, userFeatures, coords)
+	font.plans.insert(plan)
 
 	if debugMode >= 1 {
 		fmt.Printf("\tPLAN %p inserted into cache\n", plan)
@@ -33,54 +33,28 @@ type formatOptions struct {
 	showFlags      bool
 }
 
-// return a compact representation of the buffer contents
-func (b *Buffer) serialize(font *Font, opt formatOptions) string {
-	if len(b.Info) == 0 {
-		return "" //  the reference does not return []
-	}
-	gs := new(strings.Builder)
-	gs.WriteByte('[')
-	var x, y Position
-	for i, glyph := range b.Info {
-		if opt.hideGlyphNames {
-			fmt.Fprintf(gs, "%d", glyph.Glyph)
-		} else {
-			gs.WriteString(font.glyphToString(glyph.Glyph))
-		}
-
-		if !opt.hideClusters {
-			fmt.Fprintf(gs, "=%d", glyph.Cluster)
-		}
-		pos := b.Pos[i]
-
-		if !opt.hidePositions {
-			if x+pos.XOffset != 0 || y+pos.YOffset != 0 {
-				fmt.Fprintf(gs, "@%d,%d", x+pos.XOffset, y+pos.YOffset)
-			}
-			if !opt.hideAdvances {
-				fmt.Fprintf(gs, "+%d", pos.XAdvance)
-				if pos.YAdvance != 0 {
-					fmt.Fprintf(gs, ",%d", pos.YAdvance)
-				}
-			}
-		}
-
-		if opt.showExtents {
-			extents, _ := font.GlyphExtents(glyph.Glyph)
-			fmt.Fprintf(gs, "<%d,%d,%d,%d>", extents.XBearing, extents.YBearing, extents.Width, extents.Height)
-		}
-
-		if i != len(b.Info)-1 {
-			gs.WriteByte('|')
-		}
-
-		if opt.hideAdvances {
-			x += pos.XAdvance
-			y += pos.YAdvance
-		}
+// serializeFlags returns the flags for Buffer.Serialize
+func (opt formatOptions) serializeFlags() SerializeFlags {
+	var flags SerializeFlags
+	if opt.hideGlyphNames {
+		flags |= SerializeFlagNoGlyphNames
+	}
+	if opt.hidePositions {
+		flags |= SerializeFlagNoPositions
+	}
+	if opt.hideAdvances {
+		flags |= SerializeFlagNoAdvances
 	}
-	gs.WriteByte(']')
-	return gs.String()
+	if opt.hideClusters {
+		flags |= SerializeFlagNoClusters
+	}
+	if opt.showExtents {
+		flags |= SerializeFlagGlyphExtents
+	}
+	if opt.showFlags {
+		flags |= SerializeFlagGlyphFlags
+	}
+	return flags
 }
 
 type fontOptions struct {
@@ -300,6 +274,8 @@ func (so *shapeOptions) shape(font *Font, buffer *Buffer, verify bool) error {
 	var textBuffer *Buffer
 
 	if verify {
+		// the unsafe-to-concat check requires the flag to be computed
+		buffer.Flags |= ProduceUnsafeToConcat
 		textBuffer = NewBuffer()
 		appendBuffer(textBuffer, buffer, 0, len(buffer.Info))
 	}
@@ -326,6 +302,9 @@ func (so *shapeOptions) verifyBuffer(buffer, textBuffer *Buffer, font *Font) err
 	if err := so.verifyBufferSafeToBreak(buffer, textBuffer, font); err != nil {
 		return err
 	}
+	if err := so.verifyBufferSafeToConcat(buffer, textBuffer, font); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -420,6 +399,8 @@ func (so *shapeOptions) verifyBufferSafeToBreak(buffer, textBuffer *Buffer, font
 		if textEnd < len(textBuffer.Info) {
 			flags = (flags & ^Eot)
 		}
+		// unsafe-to-concat flags are checked separately
+		flags &^= ProduceUnsafeToConcat
 		fragment.Flags = flags
 
 		appendBuffer(fragment, textBuffer, textStart, textEnd)
@@ -451,6 +432,134 @@ func (so *shapeOptions) verifyBufferSafeToBreak(buffer, textBuffer *Buffer, font
 	return nil
 }
 
+func (so *shapeOptions) verifyBufferSafeToConcat(buffer, textBuffer *Buffer, font *Font) error {
+	if so.clusterLevel != MonotoneGraphemes && so.clusterLevel != MonotoneCharacters {
+		/* Cannot perform this check without monotone clusters. */
+		return nil
+	}
+
+	/* Check that shuffling up text before unsafe-to-concat point is indeed safe.
+	 *
+	 * This is what we do:
+	 *
+	 * 1. We shape text once. Then segment the text at all the unsafe-to-concat
+	 *    points;
+	 *
+	 * 2. Then we create two buffers, one containing all the even segments and
+	 *    one all the odd segments.
+	 *
+	 * 3. Because all these segments were safe-to-concat at both ends, we
+	 *    expect that concatenating them and shaping should NOT change the
+	 *    shaping results of each segment. As such, we expect that after
+	 *    shaping the two buffers, we still get cluster boundaries at the
+	 *    segment boundaries, and that those all are safe-to-concat points.
+	 *    Moreover, that there are NOT any safe-to-concat points within the
+	 *    segments.
+	 *
+	 * 4. Finally, we reconstruct the shaping results of the original text by
+	 *    simply interleaving the shaping results of the segments from the two
+	 *    buffers, and assert that the total shaping results is the same as
+	 *    the one from original buffer in step 1.
+	 */
+
+	fragments := [2]*Buffer{NewBuffer(), NewBuffer()}
+	reconstruction := NewBuffer()
+	copyBufferProperties(fragments[0], buffer)
+	copyBufferProperties(fragments[1], buffer)
+	copyBufferProperties(reconstruction, buffer)
+
+	info := buffer.Info
+	text := textBuffer.Info
+
+	forward := buffer.Props.Direction.isForward()
+	if !forward {
+		buffer.Reverse()
+	}
+
+	/* Split text into segments and collect into to fragment streams. */
+	fragmentIdx := 0
+	textStart, textEnd := 0, 0
+	for end := 1; end < len(info)+1; end++ {
+		if end < len(info) && (info[end].Cluster == info[end-1].Cluster ||
+			info[end].Mask&GlyphUnsafeToConcat != 0) {
+			continue
+		}
+
+		/* Accumulate segment corresponding to glyphs start..end. */
+		if end == len(info) {
+			textEnd = len(text)
+		} else {
+			cluster := info[end].Cluster
+			for textEnd < len(text) && text[textEnd].Cluster < cluster {
+				textEnd++
+			}
+		}
+		if !(textStart < textEnd) {
+			return fmt.Errorf("unexpected %d >= %d", textStart, textEnd)
+		}
+
+		if debugMode >= 1 {
+			fmt.Println()
+			fmt.Printf("VERIFY SAFE TO CONCAT : end %d text start %d end %d\n", end, textStart, textEnd)
+			fmt.Println()
+		}
+
+		appendBuffer(fragments[fragmentIdx], textBuffer, textStart, textEnd)
+
+		textStart = textEnd
+		fragmentIdx = 1 - fragmentIdx
+	}
+
+	/* Shape the two fragment streams. */
+	features, err := so.parseFeatures()
+	if err != nil {
+		return err
+	}
+	fragments[0].Shape(font, features)
+	fragments[1].Shape(font, features)
+
+	if !forward {
+		fragments[0].Reverse()
+		fragments[1].Reverse()
+	}
+
+	/* Reconstruct results. */
+	fragmentIdx = 0
+	var fragmentStart [2]int
+	for fragmentStart[0] < len(fragments[0].Info) || fragmentStart[1] < len(fragments[1].Info) {
+		fragmentInfo := fragments[fragmentIdx].Info
+		fragmentEnd := min(fragmentStart[fragmentIdx]+1, len(fragmentInfo))
+		for fragmentEnd < len(fragmentInfo) &&
+			(fragmentInfo[fragmentEnd].Cluster == fragmentInfo[fragmentEnd-1].Cluster ||
+				fragmentInfo[fragmentEnd].Mask&GlyphUnsafeToConcat != 0) {
+			fragmentEnd++
+		}
+
+		appendBuffer(reconstruction, fragments[fragmentIdx], fragmentStart[fragmentIdx], fragmentEnd)
+
+		fragmentStart[fragmentIdx] = fragmentEnd
+		fragmentIdx = 1 - fragmentIdx
+	}
+
+	if !forward {
+		buffer.Reverse()
+		reconstruction.Reverse()
+	}
+
+	/* Diff results. */
+	diff := bufferDiff(reconstruction, buffer, ^fonts.GID(0), 0)
+	if diff&^HB_BUFFER_DIFF_FLAG_GLYPH_FLAGS_MISMATCH != bufferDiffFlagEqual {
+		/* Return the reconstructed result instead so it can be inspected. */
+		buffer.Info = nil
+		buffer.Pos = nil
+		appendBuffer(buffer, reconstruction, 0, len(reconstruction.Info))
+
+		return fmt.Errorf("unsafe-to-concat test failed: %d", diff)
+	}
+
+	return nil
+}
+
 func (opts *shapeOptions) parseDirection(s string) error {
 	switch toLower(s[0]) {
 	case 'l':
@@ -477,7 +586,7 @@ func (mft testOptions) shape(verify bool) (string, error) {
 		return "", err
 	}
 
-	return buffer.serialize(font, mft.format), nil
+	return buffer.Serialize(font, SerializeFormatText, mft.format.serializeFlags()), nil
 }
 
 const featuresUsage = `Comma-separated list of font features
@@ -893,3 +1002,73 @@ func TestExample(t *testing.T) {
 		fmt.Println(pos.XAdvance, pos.XOffset, ext.Width, ext.XBearing)
 	}
 }
+
+func TestShapePlan(t *testing.T) {
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	props := SegmentProperties{Direction: LeftToRight, Script: language.Latin, Language: language.NewLanguage("en")}
+	features := []Feature{{Tag: tt.MustNewTag("liga"), Value: 0, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}
+	text := []rune("office")
+
+	plan := NewShapePlan(font, props, features)
+	assert(t, plan.Shaper() == "ot")
+
+	ref, b := NewBuffer(), NewBuffer()
+	ref.AddRunes(text, 0, -1)
+	ref.Props = props
+	ref.Shape(font, features)
+	for range [2]int{} { // plans may be reused
+		b.Clear()
+		b.AddRunes(text, 0, -1)
+		b.Props = props
+		plan.Execute(font, b, features)
+		assert(t, ref.Serialize(font, SerializeFormatText, 0) == b.Serialize(font, SerializeFormatText, 0))
+	}
+	assertEqualInt(t, len(b.Info), len(text)) // no ligatures
+
+	assert(t, NewShapePlan(NewFont(dummyFaceShape{xScale: 100}), props, nil).Shaper() == "fallback")
+}
+
+func TestShapePlanCache(t *testing.T) {
+	font := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+	props := SegmentProperties{Direction: LeftToRight, Script: language.Latin, Language: language.NewLanguage("en")}
+	feature := func(tag string) []Feature {
+		return []Feature{{Tag: tt.MustNewTag(tag), Value: 1, Start: FeatureGlobalStart, End: FeatureGlobalEnd}}
+	}
+
+	plan := newShapePlanCached(font, props, nil, font.varCoords())
+	assert(t, plan == newShapePlanCached(font, props, nil, font.varCoords()))
+	assertEqualInt(t, len(font.plans.plans), 1)
+
+	// plans are evicted when the cache is full
+	font.SetShapePlanCacheSize(2)
+	newShapePlanCached(font, props, feature("smcp"), font.varCoords())
+	assert(t, plan == newShapePlanCached(font, props, nil, font.varCoords())) // plan is now the most recent
+	newShapePlanCached(font, props, feature("onum"), font.varCoords())        // evicts smcp
+	assertEqualInt(t, len(font.plans.plans), 2)
+	assert(t, plan == newShapePlanCached(font, props, nil, font.varCoords()))
+	assert(t, font.plans.plans[0].userFeatures[0].Tag == tt.MustNewTag("onum"))
+
+	font.PurgeShapePlans()
+	assertEqualInt(t, len(font.plans.plans), 0)
+	assert(t, plan != newShapePlanCached(font, props, nil, font.varCoords()))
+
+	font.SetShapePlanCacheSize(0)
+	assertEqualInt(t, len(font.plans.plans), 0)
+	assert(t, newShapePlanCached(font, props, nil, font.varCoords()) != newShapePlanCached(font, props, nil, font.varCoords()))
+
+	// the plans depend on the variations, which may select alternate features
+	font.SetShapePlanCacheSize(10)
+	shape := func(font *Font, weight float32) string {
+		tt.SetVariations(font.face.(*tt.Font), []tt.Variation{{Tag: tt.MustNewTag("wght"), Value: weight}})
+		b := NewBuffer()
+		b.AddRunes([]rune("$ 1/2"), 0, -1)
+		b.Props = props
+		b.Shape(font, nil)
+		return b.Serialize(font, SerializeFormatText, SerializeFlagNoPositions)
+	}
+	for _, weight := range []float32{100, 900, 100} {
+		fresh := NewFont(openFontFileTT("Commissioner-VF.ttf"))
+		assert(t, shape(font, weight) == shape(fresh, weight))
+	}
+	assertEqualInt(t, len(font.plans.plans), 2)
+}
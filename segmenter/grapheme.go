@@ -0,0 +1,156 @@
+package segmenter
+
+import (
+	"unicode"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// This file implements the extended grapheme clusters boundaries of
+// Unicode Standard Annex #29, version 13.0.0 (https://www.unicode.org/reports/tr29/tr29-37.html).
+
+// graphemeClass is a Grapheme_Cluster_Break class, see unicodedata.LookupGraphemeBreakClass
+type graphemeClass uint8
+
+const (
+	gbOther graphemeClass = iota
+	gbCR
+	gbLF
+	gbControl
+	gbExtend
+	gbZWJ
+	gbRI
+	gbPrepend
+	gbSpacingMark
+	gbL
+	gbV
+	gbT
+	gbLV
+	gbLVT
+)
+
+var graphemeClasses = map[*unicode.RangeTable]graphemeClass{
+	ucd.GraphemeBreakCR:                 gbCR,
+	ucd.GraphemeBreakLF:                 gbLF,
+	ucd.GraphemeBreakControl:            gbControl,
+	ucd.GraphemeBreakExtend:             gbExtend,
+	ucd.GraphemeBreakZWJ:                gbZWJ,
+	ucd.GraphemeBreakRegional_Indicator: gbRI,
+	ucd.GraphemeBreakPrepend:            gbPrepend,
+	ucd.GraphemeBreakSpacingMark:        gbSpacingMark,
+	ucd.GraphemeBreakL:                  gbL,
+	ucd.GraphemeBreakV:                  gbV,
+	ucd.GraphemeBreakT:                  gbT,
+	ucd.GraphemeBreakLV:                 gbLV,
+	ucd.GraphemeBreakLVT:                gbLVT,
+}
+
+// Graphemes returns the boundaries of the extended grapheme clusters
+// of `text`, see GraphemeBreaker.
+func Graphemes(text []rune) []int {
+	var out []int
+	gb := NewGraphemeBreaker(text)
+	for index, ok := gb.Next(); ok; index, ok = gb.Next() {
+		out = append(out, index)
+	}
+	return out
+}
+
+// GraphemeBreaker iterates over the boundaries of the extended
+// grapheme clusters of a text, that is the user-perceived characters,
+// as used for cursor movement or text selection.
+// Emoji sequences, such as flags, emoji with modifiers or ZWJ
+// sequences, are kept in one cluster.
+// Boundaries are never reported at the start of the text, and
+// always reported at its end.
+type GraphemeBreaker struct {
+	text    []rune
+	classes []graphemeClass
+	pos     int // the next candidate position
+
+	// context of the current position
+
+	riCount  int  // number of consecutive RI before pos
+	emojiSeq bool // true after ExtPict Extend*
+	emojiZWJ bool // true after ExtPict Extend* ZWJ, used by GB11
+}
+
+// NewGraphemeBreaker returns an iterator over the grapheme cluster boundaries of `text`.
+func NewGraphemeBreaker(text []rune) *GraphemeBreaker {
+	gb := &GraphemeBreaker{
+		text:    text,
+		classes: make([]graphemeClass, len(text)),
+		pos:     1,
+	}
+	for i, r := range text {
+		gb.classes[i] = graphemeClasses[ucd.LookupGraphemeBreakClass(r)]
+	}
+	if len(text) != 0 {
+		gb.advance(0)
+	}
+	return gb
+}
+
+// Next returns the next grapheme cluster boundary, or false
+// if the end of the text has been reached.
+func (gb *GraphemeBreaker) Next() (int, bool) {
+	for ; gb.pos < len(gb.text); gb.pos++ {
+		brk := gb.breakBefore(gb.pos)
+		gb.advance(gb.pos)
+		if brk {
+			gb.pos++
+			return gb.pos - 1, true
+		}
+	}
+	if gb.pos == len(gb.text) { // GB2
+		gb.pos++
+		return len(gb.text), true
+	}
+	return 0, false
+}
+
+// advance updates the context with the character at `i`
+func (gb *GraphemeBreaker) advance(i int) {
+	c := gb.classes[i]
+	if c == gbRI {
+		gb.riCount++
+	} else {
+		gb.riCount = 0
+	}
+	gb.emojiZWJ = gb.emojiSeq && c == gbZWJ
+	if unicode.Is(ucd.Extended_Pictographic, gb.text[i]) {
+		gb.emojiSeq = true
+	} else if c != gbExtend {
+		gb.emojiSeq = false
+	}
+}
+
+// breakBefore returns true if there is a boundary before the character at `i`.
+func (gb *GraphemeBreaker) breakBefore(i int) bool {
+	a, b := gb.classes[i-1], gb.classes[i]
+	switch {
+	case a == gbCR && b == gbLF: // GB3
+		return false
+	case a == gbControl || a == gbCR || a == gbLF: // GB4
+		return true
+	case b == gbControl || b == gbCR || b == gbLF: // GB5
+		return true
+	case a == gbL && (b == gbL || b == gbV || b == gbLV || b == gbLVT): // GB6
+		return false
+	case (a == gbLV || a == gbV) && (b == gbV || b == gbT): // GB7
+		return false
+	case (a == gbLVT || a == gbT) && b == gbT: // GB8
+		return false
+	case b == gbExtend || b == gbZWJ: // GB9
+		return false
+	case b == gbSpacingMark: // GB9a
+		return false
+	case a == gbPrepend: // GB9b
+		return false
+	case gb.emojiZWJ && unicode.Is(ucd.Extended_Pictographic, gb.text[i]): // GB11
+		return false
+	case a == gbRI && b == gbRI: // GB12 and GB13
+		return gb.riCount%2 == 0
+	}
+	return true // GB999
+}
@@ -0,0 +1,41 @@
+package segmenter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemeUnicode(t *testing.T) {
+	texts, breaks := parseBreakTest(t, "../unicodedata/generate/GraphemeBreakTest.txt", ucdVersion)
+	if len(texts) < 600 {
+		t.Fatalf("unexpected number of tests: %d", len(texts))
+	}
+	for i, text := range texts {
+		if got := Graphemes(text); !reflect.DeepEqual(got, breaks[i]) {
+			t.Errorf("for %U, expected %v, got %v", text, breaks[i], got)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected []int
+	}{
+		{"", nil},
+		{"e\u0301a", []int{2, 3}},
+		{"\r\n\n", []int{2, 3}},
+		{"\uAC01\uAC01", []int{1, 2}},    // precomposed syllables
+		{"\u1100\u1161\u11A8", []int{3}}, // conjoining jamos
+		{"🇫🇷🇩🇪", []int{2, 4}},            // flags are pairs of regional indicators
+		{"👍🏽!", []int{2, 3}},             // emoji modifier
+		{"👨\u200D👩\u200D👧", []int{5}},    // ZWJ sequence
+		{"a\u200D👧", []int{2, 3}},        // ZWJ not following a pictographic
+		{"🏳\uFE0F\u200D🌈", []int{4}},     // rainbow flag
+		{"क\u094Dष\u093F", []int{2, 4}},  // no special handling for Indic conjuncts
+	} {
+		if got := Graphemes([]rune(test.text)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %q, expected %v, got %v", test.text, test.expected, got)
+		}
+	}
+}
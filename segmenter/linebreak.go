@@ -0,0 +1,583 @@
+package segmenter
+
+import (
+	"unicode"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// This file implements the line breaking algorithm of
+// Unicode Standard Annex #14, version 13.0.0 (https://www.unicode.org/reports/tr14/tr14-45.html),
+// with the tailoring of numbers described in the Example 7 of Section 8.2.
+
+// lineClass is a line breaking class, see unicodedata.LookupBreakClass
+type lineClass uint8
+
+const (
+	lbXX lineClass = iota // Unknown, also used for the start of text
+	lbOP
+	lbCL
+	lbCP
+	lbQU
+	lbGL
+	lbNS
+	lbEX
+	lbSY
+	lbIS
+	lbPR
+	lbPO
+	lbNU
+	lbAL
+	lbHL
+	lbID
+	lbIN
+	lbHY
+	lbBA
+	lbBB
+	lbB2
+	lbZW
+	lbCM
+	lbWJ
+	lbH2
+	lbH3
+	lbJL
+	lbJV
+	lbJT
+	lbRI
+	lbBK
+	lbCR
+	lbLF
+	lbNL
+	lbSG
+	lbSP
+	lbCB
+	lbAI
+	lbCJ
+	lbSA
+	lbEB
+	lbEM
+	lbZWJ
+)
+
+var lineClasses = map[*unicode.RangeTable]lineClass{
+	ucd.BreakOP:  lbOP,
+	ucd.BreakCL:  lbCL,
+	ucd.BreakCP:  lbCP,
+	ucd.BreakQU:  lbQU,
+	ucd.BreakGL:  lbGL,
+	ucd.BreakNS:  lbNS,
+	ucd.BreakEX:  lbEX,
+	ucd.BreakSY:  lbSY,
+	ucd.BreakIS:  lbIS,
+	ucd.BreakPR:  lbPR,
+	ucd.BreakPO:  lbPO,
+	ucd.BreakNU:  lbNU,
+	ucd.BreakAL:  lbAL,
+	ucd.BreakHL:  lbHL,
+	ucd.BreakID:  lbID,
+	ucd.BreakIN:  lbIN,
+	ucd.BreakHY:  lbHY,
+	ucd.BreakBA:  lbBA,
+	ucd.BreakBB:  lbBB,
+	ucd.BreakB2:  lbB2,
+	ucd.BreakZW:  lbZW,
+	ucd.BreakCM:  lbCM,
+	ucd.BreakWJ:  lbWJ,
+	ucd.BreakH2:  lbH2,
+	ucd.BreakH3:  lbH3,
+	ucd.BreakJL:  lbJL,
+	ucd.BreakJV:  lbJV,
+	ucd.BreakJT:  lbJT,
+	ucd.BreakRI:  lbRI,
+	ucd.BreakBK:  lbBK,
+	ucd.BreakCR:  lbCR,
+	ucd.BreakLF:  lbLF,
+	ucd.BreakNL:  lbNL,
+	ucd.BreakSG:  lbSG,
+	ucd.BreakSP:  lbSP,
+	ucd.BreakCB:  lbCB,
+	ucd.BreakAI:  lbAI,
+	ucd.BreakCJ:  lbCJ,
+	ucd.BreakSA:  lbSA,
+	ucd.BreakEB:  lbEB,
+	ucd.BreakEM:  lbEM,
+	ucd.BreakZWJ: lbZWJ,
+	ucd.BreakXX:  lbXX,
+}
+
+// LineBreakStrictness selects how strictly line breaks are restricted
+// in Chinese and Japanese text, as the CSS property 'line-break' does.
+type LineBreakStrictness uint8
+
+const (
+	// LineBreakStrict is the default behavior of UAX #14, which forbids breaks
+	// before small kana (class CJ), matching 'line-break: strict'.
+	LineBreakStrict LineBreakStrictness = iota
+	// LineBreakNormal allows breaks before small kana, and before
+	// the hyphens U+2010, U+2013, U+301C and U+30A0 following a CJK character,
+	// matching 'line-break: normal'.
+	LineBreakNormal
+	// LineBreakLoose relaxes LineBreakNormal, allowing breaks before iteration marks,
+	// around centered punctuation, before postfixes and after prefixes adjacent
+	// to an ideograph, and between inseparable characters, matching 'line-break: loose'.
+	LineBreakLoose
+)
+
+// LineBreakOptions tailors the default line breaking algorithm.
+// The zero value applies the rules of UAX #14 unchanged.
+type LineBreakOptions struct {
+	Strictness LineBreakStrictness
+
+	// KeepAll forbids breaks between letters, digits and ideographs,
+	// as 'word-break: keep-all' does, so that CJK text
+	// is only broken at spaces and punctuation.
+	KeepAll bool
+
+	// NoBreakAfterHyphens removes the break opportunities after
+	// hyphens, which are otherwise allowed (as in "well-|known").
+	NoBreakAfterHyphens bool
+}
+
+// LineBreak is a line break opportunity.
+type LineBreak struct {
+	// Index is the position of the break in the text: the line is broken
+	// before the rune at Index, which is the length of the text for the last break.
+	Index int
+	// Mandatory is true for the breaks which must be taken, that is after
+	// a newline character and at the end of the text.
+	Mandatory bool
+}
+
+// LineBreaks returns all the line break opportunities of `text`, see LineBreaker.
+func LineBreaks(text []rune, options LineBreakOptions) []LineBreak {
+	var out []LineBreak
+	lb := NewLineBreaker(text, options)
+	for brk, ok := lb.Next(); ok; brk, ok = lb.Next() {
+		out = append(out, brk)
+	}
+	return out
+}
+
+type numberState uint8
+
+const (
+	noNumber    numberState = iota
+	inNumber                // NU (NU|SY|IS)*
+	afterNumber             // NU (NU|SY|IS)* (CL|CP)
+)
+
+// LineBreaker iterates over the line break opportunities of a text.
+// Breaks are never reported at the start of the text, and
+// always reported at its end.
+type LineBreaker struct {
+	text    []rune
+	classes []lineClass // resolved according to LB1
+	options LineBreakOptions
+	pos     int // the next candidate position
+
+	// context of the current position
+
+	base         lineClass // class of the previous character, after LB9 and LB10
+	baseRune     rune
+	beforeBase   lineClass // class of the character before base
+	beforeSpaces lineClass // class of the last character which is not a space
+	riCount      int       // number of consecutive RI ending at base
+	number       numberState
+}
+
+// NewLineBreaker returns an iterator over the line breaks of `text`.
+func NewLineBreaker(text []rune, options LineBreakOptions) *LineBreaker {
+	lb := &LineBreaker{
+		text:    text,
+		classes: make([]lineClass, len(text)),
+		options: options,
+		pos:     1,
+	}
+	for i, r := range text {
+		lb.classes[i] = options.Strictness.resolve(r, lineClasses[ucd.LookupBreakClass(r)])
+	}
+	if len(text) != 0 {
+		c := lb.classes[0]
+		if c == lbCM || c == lbZWJ { // LB10
+			c = lbAL
+		}
+		lb.update(c, text[0])
+	}
+	return lb
+}
+
+// resolve applies the rule LB1
+func (s LineBreakStrictness) resolve(r rune, c lineClass) lineClass {
+	switch c {
+	case lbAI, lbSG, lbXX:
+		return lbAL
+	case lbSA:
+		if unicode.In(r, unicode.Mn, unicode.Mc) {
+			return lbCM
+		}
+		return lbAL
+	case lbCJ:
+		if s == LineBreakStrict {
+			return lbNS
+		}
+		return lbID
+	}
+	return c
+}
+
+// Next returns the next line break opportunity, or false
+// if the end of the text has been reached.
+func (lb *LineBreaker) Next() (LineBreak, bool) {
+	for ; lb.pos < len(lb.text); lb.pos++ {
+		brk, mandatory := lb.breakBefore(lb.pos)
+		lb.advance(lb.pos)
+		if brk {
+			lb.pos++
+			return LineBreak{Index: lb.pos - 1, Mandatory: mandatory}, true
+		}
+	}
+	if lb.pos == len(lb.text) { // LB3
+		lb.pos++
+		return LineBreak{Index: len(lb.text), Mandatory: true}, true
+	}
+	return LineBreak{}, false
+}
+
+// advance updates the context with the character at `i`
+func (lb *LineBreaker) advance(i int) {
+	c := lb.classes[i]
+	if c == lbCM || c == lbZWJ {
+		switch lb.base {
+		case lbBK, lbCR, lbLF, lbNL, lbSP, lbZW: // LB10
+			c = lbAL
+		default: // LB9: the base is unchanged
+			return
+		}
+	}
+	lb.update(c, lb.text[i])
+}
+
+func (lb *LineBreaker) update(c lineClass, r rune) {
+	switch {
+	case c == lbNU:
+		lb.number = inNumber
+	case (c == lbSY || c == lbIS) && lb.number == inNumber:
+	case (c == lbCL || c == lbCP) && lb.number == inNumber:
+		lb.number = afterNumber
+	default:
+		lb.number = noNumber
+	}
+
+	if c == lbRI && lb.base == lbRI {
+		lb.riCount++
+	} else if c == lbRI {
+		lb.riCount = 1
+	}
+
+	lb.beforeBase = lb.base
+	lb.base, lb.baseRune = c, r
+	if c != lbSP {
+		lb.beforeSpaces = c
+	}
+}
+
+// breakBefore returns true if a line break is allowed before the character at `i`,
+// and if it is mandatory.
+func (lb *LineBreaker) breakBefore(i int) (brk, mandatory bool) {
+	prev, b := lb.classes[i-1], lb.classes[i]
+
+	// LB4 and LB5
+	switch prev {
+	case lbBK, lbLF, lbNL:
+		return true, true
+	case lbCR:
+		return b != lbLF, b != lbLF
+	}
+
+	// LB6 and LB7
+	switch b {
+	case lbBK, lbCR, lbLF, lbNL, lbSP, lbZW:
+		return false, false
+	}
+
+	// LB8
+	if lb.beforeSpaces == lbZW {
+		return true, false
+	}
+
+	// LB8a
+	if prev == lbZWJ {
+		return false, false
+	}
+
+	if b == lbCM || b == lbZWJ {
+		// LB9: attach to the base; other classes have been handled by the previous rules
+		if lb.base != lbSP {
+			return false, false
+		}
+		b = lbAL // LB10
+	}
+
+	return lb.tailor(i, b, lb.pairBreak(i, b)), false
+}
+
+// pairBreak applies the rules LB11 to LB31 between the
+// current base and the character at `i`, with class `b`.
+func (lb *LineBreaker) pairBreak(i int, b lineClass) bool {
+	a := lb.base
+
+	// LB11
+	if a == lbWJ || b == lbWJ {
+		return false
+	}
+	// LB12
+	if a == lbGL {
+		return false
+	}
+	// LB12a
+	if b == lbGL && a != lbSP && a != lbBA && a != lbHY {
+		return false
+	}
+	// LB13
+	switch b {
+	case lbCL, lbCP, lbEX, lbIS, lbSY:
+		return false
+	}
+	// LB14
+	if lb.beforeSpaces == lbOP {
+		return false
+	}
+	// LB15
+	if b == lbOP && lb.beforeSpaces == lbQU {
+		return false
+	}
+	// LB16
+	if b == lbNS && (lb.beforeSpaces == lbCL || lb.beforeSpaces == lbCP) {
+		return false
+	}
+	// LB17
+	if b == lbB2 && lb.beforeSpaces == lbB2 {
+		return false
+	}
+	// LB18
+	if a == lbSP {
+		return true
+	}
+	// LB19
+	if a == lbQU || b == lbQU {
+		return false
+	}
+	// LB20
+	if a == lbCB || b == lbCB {
+		return true
+	}
+	// LB21
+	switch b {
+	case lbBA, lbHY, lbNS:
+		return false
+	}
+	if a == lbBB {
+		return false
+	}
+	// LB21a
+	if lb.beforeBase == lbHL && (a == lbHY || a == lbBA) {
+		return false
+	}
+	// LB21b
+	if a == lbSY && b == lbHL {
+		return false
+	}
+	// LB22
+	if b == lbIN {
+		return false
+	}
+	// LB23
+	if isAlphabetic(a) && b == lbNU || a == lbNU && isAlphabetic(b) {
+		return false
+	}
+	// LB23a
+	if a == lbPR && (b == lbID || b == lbEB || b == lbEM) ||
+		(a == lbID || a == lbEB || a == lbEM) && b == lbPO {
+		return false
+	}
+	// LB24
+	if (a == lbPR || a == lbPO) && isAlphabetic(b) || isAlphabetic(a) && (b == lbPR || b == lbPO) {
+		return false
+	}
+	// LB25
+	if lb.isNumber(i, a, b) {
+		return false
+	}
+	// LB26
+	switch a {
+	case lbJL:
+		if b == lbJL || b == lbJV || b == lbH2 || b == lbH3 {
+			return false
+		}
+	case lbJV, lbH2:
+		if b == lbJV || b == lbJT {
+			return false
+		}
+	case lbJT, lbH3:
+		if b == lbJT {
+			return false
+		}
+	}
+	// LB27
+	if isKorean(a) && b == lbPO || a == lbPR && isKorean(b) {
+		return false
+	}
+	// LB28
+	if isAlphabetic(a) && isAlphabetic(b) {
+		return false
+	}
+	// LB29
+	if a == lbIS && isAlphabetic(b) {
+		return false
+	}
+	// LB30
+	if (isAlphabetic(a) || a == lbNU) && b == lbOP && !isEastAsian(lb.text[i]) ||
+		a == lbCP && !isEastAsian(lb.baseRune) && (isAlphabetic(b) || b == lbNU) {
+		return false
+	}
+	// LB30a
+	if a == lbRI && b == lbRI && lb.riCount%2 == 1 {
+		return false
+	}
+	// LB30b
+	if b == lbEM && (a == lbEB || unicode.Is(ucd.Extended_Pictographic, lb.baseRune) && isUnassigned(lb.baseRune)) {
+		return false
+	}
+	// LB31
+	return true
+}
+
+// isNumber applies the tailored version of LB25:
+//
+//	(PR | PO) × ( OP | HY )? NU
+//	( OP | HY ) × NU
+//	NU × (NU | SY | IS)
+//	NU (NU | SY | IS)* × (NU | SY | IS | CL | CP )
+//	NU (NU | SY | IS)* (CL | CP)? × (PO | PR)
+func (lb *LineBreaker) isNumber(i int, a, b lineClass) bool {
+	switch {
+	case (a == lbPR || a == lbPO) && b == lbNU:
+		return true
+	case (a == lbPR || a == lbPO) && (b == lbOP || b == lbHY):
+		return lb.nextClass(i) == lbNU
+	case (a == lbOP || a == lbHY) && b == lbNU:
+		return true
+	case lb.number == inNumber:
+		return b == lbNU || b == lbSY || b == lbIS || b == lbCL || b == lbCP || b == lbPO || b == lbPR
+	case lb.number == afterNumber:
+		return b == lbPO || b == lbPR
+	}
+	return false
+}
+
+// nextClass returns the class of the character following the one at `i`,
+// skipping combining marks.
+func (lb *LineBreaker) nextClass(i int) lineClass {
+	for _, c := range lb.classes[i+1:] {
+		if c != lbCM && c != lbZWJ {
+			return c
+		}
+	}
+	return lbXX
+}
+
+// tailor applies the options of the breaker on top of the default rules
+func (lb *LineBreaker) tailor(i int, b lineClass, brk bool) bool {
+	a, prev, r := lb.base, lb.baseRune, lb.text[i]
+	opts := lb.options
+	if !brk && opts.Strictness != LineBreakStrict && isCJK(a) && isCJKHyphen(r) {
+		brk = true
+	}
+	if !brk && opts.Strictness == LineBreakLoose {
+		switch {
+		case isCJK(a) && (isIterationMark(r) || isCenteredPunctuation(r)),
+			isCenteredPunctuation(prev) && isCJK(b),
+			a == lbID && isLoosePostfix(r),
+			isLoosePrefix(prev) && b == lbID,
+			a == lbIN && b == lbIN:
+			brk = true
+		}
+	}
+	if brk && opts.KeepAll && isLetter(a) && isLetter(b) {
+		brk = false
+	}
+	if brk && opts.NoBreakAfterHyphens && (a == lbHY || unicode.Is(unicode.Hyphen, prev)) {
+		brk = false
+	}
+	return brk
+}
+
+func isAlphabetic(c lineClass) bool { return c == lbAL || c == lbHL }
+
+func isKorean(c lineClass) bool {
+	switch c {
+	case lbJL, lbJV, lbJT, lbH2, lbH3:
+		return true
+	}
+	return false
+}
+
+// isCJK returns true for the ideographs, Hangul and (resolved) small kana.
+func isCJK(c lineClass) bool { return c == lbID || isKorean(c) }
+
+// isLetter returns true for the classes whose breaks are suppressed
+// by 'word-break: keep-all'.
+func isLetter(c lineClass) bool {
+	return c == lbNU || isAlphabetic(c) || isCJK(c)
+}
+
+// isUnassigned returns true for the runes of the general category Cn,
+// which is not reported by LookupType in every version of the unicode package.
+func isUnassigned(r rune) bool {
+	cat := ucd.LookupType(r)
+	return cat == nil || cat == unicode.Categories["Cn"]
+}
+
+func isEastAsian(r rune) bool {
+	return unicode.In(r, ucd.EastAsianFullwidth, ucd.EastAsianWide, ucd.EastAsianHalfwidth)
+}
+
+func isCJKHyphen(r rune) bool {
+	switch r {
+	case 0x2010, 0x2013, 0x301C, 0x30A0:
+		return true
+	}
+	return false
+}
+
+func isIterationMark(r rune) bool {
+	switch r {
+	case 0x3005, 0x303B, 0x309D, 0x309E, 0x30FD, 0x30FE:
+		return true
+	}
+	return false
+}
+
+func isCenteredPunctuation(r rune) bool {
+	switch r {
+	case 0x30FB, 0xFF1A, 0xFF1B, 0xFF65, 0x203C, 0x2047, 0x2048, 0x2049, 0xFF01, 0xFF1F:
+		return true
+	}
+	return false
+}
+
+func isLoosePostfix(r rune) bool {
+	switch r {
+	case 0x0025, 0x00A2, 0x00B0, 0x2030, 0x2032, 0x2033, 0x2103, 0xFE6A, 0xFF05, 0xFFE0:
+		return true
+	}
+	return false
+}
+
+func isLoosePrefix(r rune) bool {
+	switch r {
+	case 0x0024, 0x00A3, 0x00A5, 0x20AC, 0x2116, 0xFE69, 0xFF04, 0xFFE1, 0xFFE5:
+		return true
+	}
+	return false
+}
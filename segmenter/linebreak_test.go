@@ -0,0 +1,135 @@
+package segmenter
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ucdVersion is the version of the Unicode Character Database
+// used to generate the tables of the unicodedata package, and of
+// the vendored test files.
+const ucdVersion = "13.0.0"
+
+// lineBreakTestVersion is the version of the vendored LineBreakTest.txt,
+// whose test cases also hold for the ucdVersion tables.
+const lineBreakTestVersion = "14.0.0"
+
+// parseBreakTest parses a test file from the Unicode Character Database,
+// for the Unicode `version`, returning the texts and the expected break
+// positions (including the end of text)
+func parseBreakTest(t *testing.T, filename, version string) (texts [][]rune, breaks [][]int) {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 0; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if lineNumber == 0 && !strings.HasSuffix(line, "-"+version+".txt") {
+			t.Fatalf("%s is not the test file of Unicode %s (header %q)", filename, version, line)
+		}
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var (
+			text []rune
+			brks []int
+		)
+		for _, field := range fields {
+			switch field {
+			case "÷":
+				if len(text) != 0 {
+					brks = append(brks, len(text))
+				}
+			case "×":
+			default:
+				r, err := strconv.ParseUint(field, 16, 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				text = append(text, rune(r))
+			}
+		}
+		texts = append(texts, text)
+		breaks = append(breaks, brks)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return texts, breaks
+}
+
+func lineBreakIndices(text []rune, options LineBreakOptions) []int {
+	var out []int
+	for _, brk := range LineBreaks(text, options) {
+		out = append(out, brk.Index)
+	}
+	return out
+}
+
+func TestLineBreakUnicode(t *testing.T) {
+	texts, breaks := parseBreakTest(t, "../unicodedata/generate/LineBreakTest.txt", lineBreakTestVersion)
+	if len(texts) < 7000 {
+		t.Fatalf("unexpected number of tests: %d", len(texts))
+	}
+	failed := 0
+	for i, text := range texts {
+		if got := lineBreakIndices(text, LineBreakOptions{}); !reflect.DeepEqual(got, breaks[i]) {
+			failed++
+			if failed < 30 {
+				t.Errorf("for %U, expected %v, got %v", text, breaks[i], got)
+			}
+		}
+	}
+	if failed != 0 {
+		t.Errorf("%d failures", failed)
+	}
+}
+
+func TestLineBreakMandatory(t *testing.T) {
+	got := LineBreaks([]rune("a b\r\nc"), LineBreakOptions{})
+	expected := []LineBreak{{Index: 2}, {Index: 5, Mandatory: true}, {Index: 6, Mandatory: true}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := LineBreaks(nil, LineBreakOptions{}); len(got) != 0 {
+		t.Fatalf("expected no breaks, got %v", got)
+	}
+}
+
+func TestLineBreakOptions(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		options  LineBreakOptions
+		expected []int
+	}{
+		{"漢ぁ", LineBreakOptions{}, []int{2}},
+		{"漢ぁ", LineBreakOptions{Strictness: LineBreakNormal}, []int{1, 2}},
+		{"漢‐字", LineBreakOptions{}, []int{2, 3}},
+		{"漢‐字", LineBreakOptions{Strictness: LineBreakNormal}, []int{1, 2, 3}},
+		{"漢々", LineBreakOptions{Strictness: LineBreakNormal}, []int{2}},
+		{"漢々", LineBreakOptions{Strictness: LineBreakLoose}, []int{1, 2}},
+		{"a……b", LineBreakOptions{}, []int{3, 4}},
+		{"a……b", LineBreakOptions{Strictness: LineBreakLoose}, []int{2, 3, 4}},
+		{"漢字です", LineBreakOptions{}, []int{1, 2, 3, 4}},
+		{"漢字です", LineBreakOptions{KeepAll: true}, []int{4}},
+		{"한국어 문장", LineBreakOptions{}, []int{1, 2, 4, 5, 6}},
+		{"한국어 문장", LineBreakOptions{KeepAll: true}, []int{4, 6}},
+		{"well-known", LineBreakOptions{}, []int{5, 10}},
+		{"well-known", LineBreakOptions{NoBreakAfterHyphens: true}, []int{10}},
+	} {
+		if got := lineBreakIndices([]rune(test.text), test.options); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %s with %v, expected %v, got %v", test.text, test.options, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package segmenter implements the Unicode algorithms used to split a text
+// into smaller units: the line breaking algorithm described in
+// Unicode Standard Annex #14 (https://www.unicode.org/reports/tr14/), and
+// the grapheme cluster, word and sentence boundaries described in
+// Unicode Standard Annex #29 (https://www.unicode.org/reports/tr29/).
+//
+// The character properties are provided by the package unicodedata.
+package segmenter
@@ -0,0 +1,201 @@
+package segmenter
+
+import (
+	"unicode"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// This file implements the sentence boundaries of
+// Unicode Standard Annex #29, version 13.0.0 (https://www.unicode.org/reports/tr29/tr29-37.html).
+
+// sentenceClass is a Sentence_Break class, see unicodedata.LookupSentenceBreakClass
+type sentenceClass uint8
+
+const (
+	sbOther sentenceClass = iota
+	sbCR
+	sbLF
+	sbExtend
+	sbSep
+	sbFormat
+	sbSp
+	sbLower
+	sbUpper
+	sbOLetter
+	sbNumeric
+	sbATerm
+	sbSContinue
+	sbSTerm
+	sbClose
+)
+
+var sentenceClasses = map[*unicode.RangeTable]sentenceClass{
+	ucd.SentenceBreakCR:        sbCR,
+	ucd.SentenceBreakLF:        sbLF,
+	ucd.SentenceBreakExtend:    sbExtend,
+	ucd.SentenceBreakSep:       sbSep,
+	ucd.SentenceBreakFormat:    sbFormat,
+	ucd.SentenceBreakSp:        sbSp,
+	ucd.SentenceBreakLower:     sbLower,
+	ucd.SentenceBreakUpper:     sbUpper,
+	ucd.SentenceBreakOLetter:   sbOLetter,
+	ucd.SentenceBreakNumeric:   sbNumeric,
+	ucd.SentenceBreakATerm:     sbATerm,
+	ucd.SentenceBreakSContinue: sbSContinue,
+	ucd.SentenceBreakSTerm:     sbSTerm,
+	ucd.SentenceBreakClose:     sbClose,
+}
+
+// isIgnored returns true for the classes skipped by the rule SB5
+func (c sentenceClass) isIgnored() bool { return c == sbExtend || c == sbFormat }
+
+// isParaSep returns true for (Sep | CR | LF)
+func (c sentenceClass) isParaSep() bool { return c == sbSep || c == sbCR || c == sbLF }
+
+// isSATerm returns true for (STerm | ATerm)
+func (c sentenceClass) isSATerm() bool { return c == sbSTerm || c == sbATerm }
+
+// Sentences returns the sentence boundaries of `text`, see SentenceBreaker.
+func Sentences(text []rune) []int {
+	var out []int
+	sb := NewSentenceBreaker(text)
+	for index, ok := sb.Next(); ok; index, ok = sb.Next() {
+		out = append(out, index)
+	}
+	return out
+}
+
+// SentenceBreaker iterates over the sentence boundaries of a text, using the default rules
+// of UAX #29. Note that abbreviations such as "Mr. Smith" are not detected, so that
+// a boundary is reported after "Mr. ".
+// Boundaries are never reported at the start of the text, and
+// always reported at its end.
+type SentenceBreaker struct {
+	text    []rune
+	classes []sentenceClass
+	pos     int // the next candidate position
+}
+
+// NewSentenceBreaker returns an iterator over the sentence boundaries of `text`.
+func NewSentenceBreaker(text []rune) *SentenceBreaker {
+	sb := &SentenceBreaker{
+		text:    text,
+		classes: make([]sentenceClass, len(text)),
+		pos:     1,
+	}
+	for i, r := range text {
+		sb.classes[i] = sentenceClasses[ucd.LookupSentenceBreakClass(r)]
+	}
+	return sb
+}
+
+// Next returns the next sentence boundary, or false
+// if the end of the text has been reached.
+func (sb *SentenceBreaker) Next() (int, bool) {
+	for ; sb.pos <= len(sb.text); sb.pos++ {
+		if sb.pos == len(sb.text) || sb.breakBefore(sb.pos) { // SB2
+			sb.pos++
+			return sb.pos - 1, true
+		}
+	}
+	return 0, false
+}
+
+// previous returns the index of the character before `i`, skipping
+// the characters ignored by SB5, or -1.
+func (sb *SentenceBreaker) previous(i int) int {
+	for i--; i >= 0; i-- {
+		if !sb.classes[i].isIgnored() {
+			return i
+		}
+		// the ignored characters are only attached to a preceding character
+		// which is not a paragraph separator
+		if i == 0 || sb.classes[i-1].isParaSep() {
+			return i
+		}
+	}
+	return -1
+}
+
+// class returns the class at `i`, skipping the characters ignored by SB5,
+// or sbOther if `i` is out of bounds
+func (sb *SentenceBreaker) class(i int) sentenceClass {
+	if i < 0 || i >= len(sb.classes) || sb.classes[i].isIgnored() {
+		return sbOther
+	}
+	return sb.classes[i]
+}
+
+// breakBefore returns true if there is a sentence boundary before the character at `i`.
+func (sb *SentenceBreaker) breakBefore(i int) bool {
+	a, b := sb.classes[i-1], sb.classes[i]
+	switch {
+	case a == sbCR && b == sbLF: // SB3
+		return false
+	case a.isParaSep(): // SB4
+		return true
+	case b.isIgnored(): // SB5
+		return false
+	}
+
+	// from now on, the ignored characters are skipped (SB5)
+	prev := sb.previous(i)
+	a = sb.class(prev)
+
+	// SB6 and SB7
+	if a == sbATerm && b == sbNumeric {
+		return false
+	}
+	if a == sbATerm && b == sbUpper {
+		if before := sb.class(sb.previous(prev)); before == sbUpper || before == sbLower {
+			return false
+		}
+	}
+
+	// match SATerm Close* Sp* before `i`; a paragraph separator
+	// before `i` has been handled by SB4
+	j, spaces := prev, false
+	for ; sb.class(j) == sbSp; j = sb.previous(j) {
+		spaces = true
+	}
+	for sb.class(j) == sbClose {
+		j = sb.previous(j)
+	}
+	term := sb.class(j)
+	if !term.isSATerm() {
+		return false // SB998
+	}
+
+	// SB8
+	if term == sbATerm && sb.lowerFollows(i) {
+		return false
+	}
+	// SB8a
+	if b == sbSContinue || b.isSATerm() {
+		return false
+	}
+	// SB9
+	if !spaces && (b == sbClose || b == sbSp || b.isParaSep()) {
+		return false
+	}
+	// SB10
+	if b == sbSp || b.isParaSep() {
+		return false
+	}
+	return true // SB11
+}
+
+// lowerFollows returns true if the text starting at `i` matches
+// ( ¬(OLetter | Upper | Lower | ParaSep | SATerm) )* Lower, as required by SB8.
+func (sb *SentenceBreaker) lowerFollows(i int) bool {
+	for ; i < len(sb.classes); i++ {
+		switch sb.classes[i] {
+		case sbLower:
+			return true
+		case sbOLetter, sbUpper, sbSep, sbCR, sbLF, sbSTerm, sbATerm:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package segmenter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSentenceUnicode(t *testing.T) {
+	texts, breaks := parseBreakTest(t, "../unicodedata/generate/SentenceBreakTest.txt", ucdVersion)
+	if len(texts) < 400 {
+		t.Fatalf("unexpected number of tests: %d", len(texts))
+	}
+	for i, text := range texts {
+		if got := Sentences(text); !reflect.DeepEqual(got, breaks[i]) {
+			t.Errorf("for %U, expected %v, got %v", text, breaks[i], got)
+		}
+	}
+}
+
+func TestSentences(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected []int
+	}{
+		{"", nil},
+		{"Hello. World!", []int{7, 13}},
+		{"Is it? (Yes.) Sure", []int{7, 14, 18}},
+		{"It costs 3.5 dollars.", []int{21}},               // SB6
+		{"U.S.A. Is it large?", []int{7, 19}},              // SB7
+		{"etc. and more", []int{13}},                       // SB8
+		{"Wait... what?! Ok", []int{15, 17}},               // SB8a
+		{"“Stop.” He left.", []int{8, 16}},                 // SB9 and SB10
+		{"First line\nSecond\r\nThird", []int{11, 19, 24}}, // SB4
+		{"文です。次の文。", []int{4, 8}},
+	} {
+		if got := Sentences([]rune(test.text)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %q, expected %v, got %v", test.text, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package segmenter
+
+import (
+	"unicode"
+
+	ucd "github.com/boxesandglue/textlayout/unicodedata"
+)
+
+// This file implements the word boundaries of
+// Unicode Standard Annex #29, version 13.0.0 (https://www.unicode.org/reports/tr29/tr29-37.html).
+
+// wordClass is a Word_Break class, see unicodedata.LookupWordBreakClass
+type wordClass uint8
+
+const (
+	wbOther wordClass = iota
+	wbCR
+	wbLF
+	wbNewline
+	wbExtend
+	wbZWJ
+	wbRI
+	wbFormat
+	wbKatakana
+	wbHebrewLetter
+	wbALetter
+	wbSingleQuote
+	wbDoubleQuote
+	wbMidNumLet
+	wbMidLetter
+	wbMidNum
+	wbNumeric
+	wbExtendNumLet
+	wbWSegSpace
+)
+
+var wordClasses = map[*unicode.RangeTable]wordClass{
+	ucd.WordBreakCR:                 wbCR,
+	ucd.WordBreakLF:                 wbLF,
+	ucd.WordBreakNewline:            wbNewline,
+	ucd.WordBreakExtend:             wbExtend,
+	ucd.WordBreakZWJ:                wbZWJ,
+	ucd.WordBreakRegional_Indicator: wbRI,
+	ucd.WordBreakFormat:             wbFormat,
+	ucd.WordBreakKatakana:           wbKatakana,
+	ucd.WordBreakHebrew_Letter:      wbHebrewLetter,
+	ucd.WordBreakALetter:            wbALetter,
+	ucd.WordBreakSingle_Quote:       wbSingleQuote,
+	ucd.WordBreakDouble_Quote:       wbDoubleQuote,
+	ucd.WordBreakMidNumLet:          wbMidNumLet,
+	ucd.WordBreakMidLetter:          wbMidLetter,
+	ucd.WordBreakMidNum:             wbMidNum,
+	ucd.WordBreakNumeric:            wbNumeric,
+	ucd.WordBreakExtendNumLet:       wbExtendNumLet,
+	ucd.WordBreakWSegSpace:          wbWSegSpace,
+}
+
+// isIgnored returns true for the classes skipped by the rule WB4
+func (c wordClass) isIgnored() bool { return c == wbExtend || c == wbFormat || c == wbZWJ }
+
+func (c wordClass) isNewline() bool { return c == wbNewline || c == wbCR || c == wbLF }
+
+// isAHLetter returns true for (ALetter | Hebrew_Letter)
+func (c wordClass) isAHLetter() bool { return c == wbALetter || c == wbHebrewLetter }
+
+// isMidNumLetQ returns true for (MidNumLet | Single_Quote)
+func (c wordClass) isMidNumLetQ() bool { return c == wbMidNumLet || c == wbSingleQuote }
+
+// WordBreak is a word boundary.
+type WordBreak struct {
+	// Index is the position of the boundary in the text: the
+	// segment ends before the rune at Index.
+	Index int
+	// IsWord is true if the segment ending at Index contains a letter
+	// or a digit, that is, if it is not made of spaces or punctuation.
+	// It may be used to count or select the words of a text.
+	IsWord bool
+}
+
+// Words returns the word boundaries of `text`, see WordBreaker.
+func Words(text []rune) []WordBreak {
+	var out []WordBreak
+	wb := NewWordBreaker(text)
+	for brk, ok := wb.Next(); ok; brk, ok = wb.Next() {
+		out = append(out, brk)
+	}
+	return out
+}
+
+// WordBreaker iterates over the word boundaries of a text, using the default rules
+// of UAX #29. Note that ideographic text is not split into words, since it requires
+// a dictionary.
+// Boundaries are never reported at the start of the text, and
+// always reported at its end.
+type WordBreaker struct {
+	text    []rune
+	classes []wordClass
+	pos     int // the next candidate position
+	start   int // the start of the current segment
+}
+
+// NewWordBreaker returns an iterator over the word boundaries of `text`.
+func NewWordBreaker(text []rune) *WordBreaker {
+	wb := &WordBreaker{
+		text:    text,
+		classes: make([]wordClass, len(text)),
+		pos:     1,
+	}
+	for i, r := range text {
+		wb.classes[i] = wordClasses[ucd.LookupWordBreakClass(r)]
+	}
+	return wb
+}
+
+// Next returns the next word boundary, or false
+// if the end of the text has been reached.
+func (wb *WordBreaker) Next() (WordBreak, bool) {
+	for ; wb.pos <= len(wb.text); wb.pos++ {
+		if wb.pos == len(wb.text) || wb.breakBefore(wb.pos) { // WB2
+			brk := WordBreak{Index: wb.pos, IsWord: wb.isWord(wb.start, wb.pos)}
+			wb.start = wb.pos
+			wb.pos++
+			return brk, true
+		}
+	}
+	return WordBreak{}, false
+}
+
+func (wb *WordBreaker) isWord(start, end int) bool {
+	for _, r := range wb.text[start:end] {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// previous returns the index of the character before `i`, skipping
+// the characters ignored by WB4, or -1.
+func (wb *WordBreaker) previous(i int) int {
+	for i--; i >= 0; i-- {
+		if !wb.classes[i].isIgnored() {
+			return i
+		}
+		// the ignored characters are only attached to a preceding character
+		// which is not a newline
+		if i == 0 || wb.classes[i-1].isNewline() {
+			return i
+		}
+	}
+	return -1
+}
+
+// next returns the index of the character after `i`, skipping
+// the characters ignored by WB4, or len(text).
+func (wb *WordBreaker) next(i int) int {
+	for i++; i < len(wb.classes) && wb.classes[i].isIgnored(); i++ {
+	}
+	return i
+}
+
+// class returns the class at `i`, or wbOther if `i` is out of bounds
+func (wb *WordBreaker) class(i int) wordClass {
+	if i < 0 || i >= len(wb.classes) {
+		return wbOther
+	}
+	return wb.classes[i]
+}
+
+// breakBefore returns true if there is a word boundary before the character at `i`.
+func (wb *WordBreaker) breakBefore(i int) bool {
+	a, b := wb.classes[i-1], wb.classes[i]
+	switch {
+	case a == wbCR && b == wbLF: // WB3
+		return false
+	case a.isNewline() || b.isNewline(): // WB3a and WB3b
+		return true
+	case a == wbZWJ && unicode.Is(ucd.Extended_Pictographic, wb.text[i]): // WB3c
+		return false
+	case a == wbWSegSpace && b == wbWSegSpace: // WB3d
+		return false
+	case b.isIgnored(): // WB4
+		return false
+	}
+
+	// from now on, the ignored characters are skipped (WB4)
+	prev := wb.previous(i)
+	a = wb.classes[prev]
+	if a.isIgnored() { // not attached to a previous character
+		a = wbOther
+	}
+	before := wb.class(wb.previous(prev))
+	after := wb.class(wb.next(i))
+
+	switch {
+	case a.isAHLetter() && b.isAHLetter(): // WB5
+		return false
+	case a.isAHLetter() && (b == wbMidLetter || b.isMidNumLetQ()) && after.isAHLetter(): // WB6
+		return false
+	case before.isAHLetter() && (a == wbMidLetter || a.isMidNumLetQ()) && b.isAHLetter(): // WB7
+		return false
+	case a == wbHebrewLetter && b == wbSingleQuote: // WB7a
+		return false
+	case a == wbHebrewLetter && b == wbDoubleQuote && after == wbHebrewLetter: // WB7b
+		return false
+	case before == wbHebrewLetter && a == wbDoubleQuote && b == wbHebrewLetter: // WB7c
+		return false
+	case a == wbNumeric && b == wbNumeric: // WB8
+		return false
+	case a.isAHLetter() && b == wbNumeric: // WB9
+		return false
+	case a == wbNumeric && b.isAHLetter(): // WB10
+		return false
+	case before == wbNumeric && (a == wbMidNum || a.isMidNumLetQ()) && b == wbNumeric: // WB11
+		return false
+	case a == wbNumeric && (b == wbMidNum || b.isMidNumLetQ()) && after == wbNumeric: // WB12
+		return false
+	case a == wbKatakana && b == wbKatakana: // WB13
+		return false
+	case (a.isAHLetter() || a == wbNumeric || a == wbKatakana || a == wbExtendNumLet) && b == wbExtendNumLet: // WB13a
+		return false
+	case a == wbExtendNumLet && (b.isAHLetter() || b == wbNumeric || b == wbKatakana): // WB13b
+		return false
+	case a == wbRI && b == wbRI: // WB15 and WB16
+		return wb.riCount(prev)%2 == 0
+	}
+	return true // WB999
+}
+
+// riCount returns the number of consecutive RI ending at `i`,
+// skipping the characters ignored by WB4.
+func (wb *WordBreaker) riCount(i int) int {
+	count := 0
+	for ; i >= 0 && wb.classes[i] == wbRI; i = wb.previous(i) {
+		count++
+	}
+	return count
+}
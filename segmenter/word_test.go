@@ -0,0 +1,60 @@
+package segmenter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func wordIndices(text []rune) []int {
+	var out []int
+	for _, brk := range Words(text) {
+		out = append(out, brk.Index)
+	}
+	return out
+}
+
+func TestWordUnicode(t *testing.T) {
+	texts, breaks := parseBreakTest(t, "../unicodedata/generate/WordBreakTest.txt", ucdVersion)
+	if len(texts) < 1800 {
+		t.Fatalf("unexpected number of tests: %d", len(texts))
+	}
+	for i, text := range texts {
+		if got := wordIndices(text); !reflect.DeepEqual(got, breaks[i]) {
+			t.Errorf("for %U, expected %v, got %v", text, breaks[i], got)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		expected []int
+	}{
+		{"", nil},
+		{"can't stop", []int{5, 6, 10}},
+		{"e.g. 3.14,", []int{3, 4, 5, 9, 10}},
+		{"a_b  c", []int{3, 5, 6}},
+		{"👨\u200D👩 🇫🇷🇩🇪", []int{3, 4, 6, 8}},
+		{"\r\nab\u0301", []int{2, 5}},
+	} {
+		if got := wordIndices([]rune(test.text)); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("for %q, expected %v, got %v", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestWordsIsWord(t *testing.T) {
+	text := []rune("Hello, 世界 42!")
+	var words []string
+	start := 0
+	for _, brk := range Words(text) {
+		if brk.IsWord {
+			words = append(words, string(text[start:brk.Index]))
+		}
+		start = brk.Index
+	}
+	expected := []string{"Hello", "世", "界", "42"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %q, got %q", expected, words)
+	}
+}
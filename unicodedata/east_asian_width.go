@@ -0,0 +1,301 @@
+package unicodedata
+
+import "unicode"
+
+// Code generated by generate/main.go DO NOT EDIT.
+
+// EastAsianFullwidth is the East Asian Width class F
+var EastAsianFullwidth = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x3000, Hi: 0xff01, Stride: 52993},
+		{Lo: 0xff02, Hi: 0xff60, Stride: 1},
+		{Lo: 0xffe0, Hi: 0xffe6, Stride: 1},
+	},
+}
+
+// EastAsianHalfwidth is the East Asian Width class H
+var EastAsianHalfwidth = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x20a9, Hi: 0xff61, Stride: 57016},
+		{Lo: 0xff62, Hi: 0xffbe, Stride: 1},
+		{Lo: 0xffc2, Hi: 0xffc7, Stride: 1},
+		{Lo: 0xffca, Hi: 0xffcf, Stride: 1},
+		{Lo: 0xffd2, Hi: 0xffd7, Stride: 1},
+		{Lo: 0xffda, Hi: 0xffdc, Stride: 1},
+		{Lo: 0xffe8, Hi: 0xffee, Stride: 1},
+	},
+}
+
+// EastAsianWide is the East Asian Width class W
+var EastAsianWide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115f, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232a, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23ec, Stride: 1},
+		{Lo: 0x23f0, Hi: 0x23f3, Stride: 3},
+		{Lo: 0x25fd, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267f, Hi: 0x2693, Stride: 20},
+		{Lo: 0x26a1, Hi: 0x26aa, Stride: 9},
+		{Lo: 0x26ab, Hi: 0x26bd, Stride: 18},
+		{Lo: 0x26be, Hi: 0x26c4, Stride: 6},
+		{Lo: 0x26c5, Hi: 0x26ce, Stride: 9},
+		{Lo: 0x26d4, Hi: 0x26ea, Stride: 22},
+		{Lo: 0x26f2, Hi: 0x26f3, Stride: 1},
+		{Lo: 0x26f5, Hi: 0x26fa, Stride: 5},
+		{Lo: 0x26fd, Hi: 0x2705, Stride: 8},
+		{Lo: 0x270a, Hi: 0x270b, Stride: 1},
+		{Lo: 0x2728, Hi: 0x274c, Stride: 36},
+		{Lo: 0x274e, Hi: 0x2753, Stride: 5},
+		{Lo: 0x2754, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2795, Stride: 62},
+		{Lo: 0x2796, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27b0, Hi: 0x27bf, Stride: 15},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b55, Stride: 5},
+		{Lo: 0x2e80, Hi: 0x2e99, Stride: 1},
+		{Lo: 0x2e9b, Hi: 0x2ef3, Stride: 1},
+		{Lo: 0x2f00, Hi: 0x2fd5, Stride: 1},
+		{Lo: 0x2ff0, Hi: 0x2ffb, Stride: 1},
+		{Lo: 0x3001, Hi: 0x303e, Stride: 1},
+		{Lo: 0x3041, Hi: 0x3096, Stride: 1},
+		{Lo: 0x3099, Hi: 0x30ff, Stride: 1},
+		{Lo: 0x3105, Hi: 0x312f, Stride: 1},
+		{Lo: 0x3131, Hi: 0x318e, Stride: 1},
+		{Lo: 0x3190, Hi: 0x31e3, Stride: 1},
+		{Lo: 0x31f0, Hi: 0x321e, Stride: 1},
+		{Lo: 0x3220, Hi: 0x3247, Stride: 1},
+		{Lo: 0x3250, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0xa48c, Stride: 1},
+		{Lo: 0xa490, Hi: 0xa4c6, Stride: 1},
+		{Lo: 0xa960, Hi: 0xa97c, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7a3, Stride: 1},
+		{Lo: 0xf900, Hi: 0xfaff, Stride: 1},
+		{Lo: 0xfe10, Hi: 0xfe19, Stride: 1},
+		{Lo: 0xfe30, Hi: 0xfe52, Stride: 1},
+		{Lo: 0xfe54, Hi: 0xfe66, Stride: 1},
+		{Lo: 0xfe68, Hi: 0xfe6b, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16fe0, Hi: 0x16fe4, Stride: 1},
+		{Lo: 0x16ff0, Hi: 0x16ff1, Stride: 1},
+		{Lo: 0x17000, Hi: 0x187f7, Stride: 1},
+		{Lo: 0x18800, Hi: 0x18cd5, Stride: 1},
+		{Lo: 0x18d00, Hi: 0x18d08, Stride: 1},
+		{Lo: 0x1b000, Hi: 0x1b11e, Stride: 1},
+		{Lo: 0x1b150, Hi: 0x1b152, Stride: 1},
+		{Lo: 0x1b164, Hi: 0x1b167, Stride: 1},
+		{Lo: 0x1b170, Hi: 0x1b2fb, Stride: 1},
+		{Lo: 0x1f004, Hi: 0x1f0cf, Stride: 203},
+		{Lo: 0x1f18e, Hi: 0x1f191, Stride: 3},
+		{Lo: 0x1f192, Hi: 0x1f19a, Stride: 1},
+		{Lo: 0x1f200, Hi: 0x1f202, Stride: 1},
+		{Lo: 0x1f210, Hi: 0x1f23b, Stride: 1},
+		{Lo: 0x1f240, Hi: 0x1f248, Stride: 1},
+		{Lo: 0x1f250, Hi: 0x1f251, Stride: 1},
+		{Lo: 0x1f260, Hi: 0x1f265, Stride: 1},
+		{Lo: 0x1f300, Hi: 0x1f320, Stride: 1},
+		{Lo: 0x1f32d, Hi: 0x1f335, Stride: 1},
+		{Lo: 0x1f337, Hi: 0x1f37c, Stride: 1},
+		{Lo: 0x1f37e, Hi: 0x1f393, Stride: 1},
+		{Lo: 0x1f3a0, Hi: 0x1f3ca, Stride: 1},
+		{Lo: 0x1f3cf, Hi: 0x1f3d3, Stride: 1},
+		{Lo: 0x1f3e0, Hi: 0x1f3f0, Stride: 1},
+		{Lo: 0x1f3f4, Hi: 0x1f3f8, Stride: 4},
+		{Lo: 0x1f3f9, Hi: 0x1f43e, Stride: 1},
+		{Lo: 0x1f440, Hi: 0x1f442, Stride: 2},
+		{Lo: 0x1f443, Hi: 0x1f4fc, Stride: 1},
+		{Lo: 0x1f4ff, Hi: 0x1f53d, Stride: 1},
+		{Lo: 0x1f54b, Hi: 0x1f54e, Stride: 1},
+		{Lo: 0x1f550, Hi: 0x1f567, Stride: 1},
+		{Lo: 0x1f57a, Hi: 0x1f595, Stride: 27},
+		{Lo: 0x1f596, Hi: 0x1f5a4, Stride: 14},
+		{Lo: 0x1f5fb, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f680, Hi: 0x1f6c5, Stride: 1},
+		{Lo: 0x1f6cc, Hi: 0x1f6d0, Stride: 4},
+		{Lo: 0x1f6d1, Hi: 0x1f6d2, Stride: 1},
+		{Lo: 0x1f6d5, Hi: 0x1f6d7, Stride: 1},
+		{Lo: 0x1f6eb, Hi: 0x1f6ec, Stride: 1},
+		{Lo: 0x1f6f4, Hi: 0x1f6fc, Stride: 1},
+		{Lo: 0x1f7e0, Hi: 0x1f7eb, Stride: 1},
+		{Lo: 0x1f90c, Hi: 0x1f93a, Stride: 1},
+		{Lo: 0x1f93c, Hi: 0x1f945, Stride: 1},
+		{Lo: 0x1f947, Hi: 0x1f978, Stride: 1},
+		{Lo: 0x1f97a, Hi: 0x1f9cb, Stride: 1},
+		{Lo: 0x1f9cd, Hi: 0x1f9ff, Stride: 1},
+		{Lo: 0x1fa70, Hi: 0x1fa74, Stride: 1},
+		{Lo: 0x1fa78, Hi: 0x1fa7a, Stride: 1},
+		{Lo: 0x1fa80, Hi: 0x1fa86, Stride: 1},
+		{Lo: 0x1fa90, Hi: 0x1faa8, Stride: 1},
+		{Lo: 0x1fab0, Hi: 0x1fab6, Stride: 1},
+		{Lo: 0x1fac0, Hi: 0x1fac2, Stride: 1},
+		{Lo: 0x1fad0, Hi: 0x1fad6, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2fffd, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3fffd, Stride: 1},
+	},
+}
+
+// EastAsianNarrow is the East Asian Width class Na
+var EastAsianNarrow = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0020, Hi: 0x007e, Stride: 1},
+		{Lo: 0x00a2, Hi: 0x00a3, Stride: 1},
+		{Lo: 0x00a5, Hi: 0x00a6, Stride: 1},
+		{Lo: 0x00ac, Hi: 0x00af, Stride: 3},
+		{Lo: 0x27e6, Hi: 0x27ed, Stride: 1},
+		{Lo: 0x2985, Hi: 0x2986, Stride: 1},
+	},
+	LatinOffset: 4,
+}
+
+// EastAsianAmbiguous is the East Asian Width class A
+var EastAsianAmbiguous = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a1, Hi: 0x00a7, Stride: 3},
+		{Lo: 0x00a8, Hi: 0x00aa, Stride: 2},
+		{Lo: 0x00ad, Hi: 0x00ae, Stride: 1},
+		{Lo: 0x00b0, Hi: 0x00b4, Stride: 1},
+		{Lo: 0x00b6, Hi: 0x00ba, Stride: 1},
+		{Lo: 0x00bc, Hi: 0x00bf, Stride: 1},
+		{Lo: 0x00c6, Hi: 0x00d0, Stride: 10},
+		{Lo: 0x00d7, Hi: 0x00d8, Stride: 1},
+		{Lo: 0x00de, Hi: 0x00e1, Stride: 1},
+		{Lo: 0x00e6, Hi: 0x00e8, Stride: 2},
+		{Lo: 0x00e9, Hi: 0x00ea, Stride: 1},
+		{Lo: 0x00ec, Hi: 0x00ed, Stride: 1},
+		{Lo: 0x00f0, Hi: 0x00f2, Stride: 2},
+		{Lo: 0x00f3, Hi: 0x00f7, Stride: 4},
+		{Lo: 0x00f8, Hi: 0x00fa, Stride: 1},
+		{Lo: 0x00fc, Hi: 0x00fe, Stride: 2},
+		{Lo: 0x0101, Hi: 0x0111, Stride: 16},
+		{Lo: 0x0113, Hi: 0x011b, Stride: 8},
+		{Lo: 0x0126, Hi: 0x0127, Stride: 1},
+		{Lo: 0x012b, Hi: 0x0131, Stride: 6},
+		{Lo: 0x0132, Hi: 0x0133, Stride: 1},
+		{Lo: 0x0138, Hi: 0x013f, Stride: 7},
+		{Lo: 0x0140, Hi: 0x0142, Stride: 1},
+		{Lo: 0x0144, Hi: 0x0148, Stride: 4},
+		{Lo: 0x0149, Hi: 0x014b, Stride: 1},
+		{Lo: 0x014d, Hi: 0x0152, Stride: 5},
+		{Lo: 0x0153, Hi: 0x0166, Stride: 19},
+		{Lo: 0x0167, Hi: 0x016b, Stride: 4},
+		{Lo: 0x01ce, Hi: 0x01dc, Stride: 2},
+		{Lo: 0x0251, Hi: 0x0261, Stride: 16},
+		{Lo: 0x02c4, Hi: 0x02c7, Stride: 3},
+		{Lo: 0x02c9, Hi: 0x02cb, Stride: 1},
+		{Lo: 0x02cd, Hi: 0x02d0, Stride: 3},
+		{Lo: 0x02d8, Hi: 0x02db, Stride: 1},
+		{Lo: 0x02dd, Hi: 0x02df, Stride: 2},
+		{Lo: 0x0300, Hi: 0x036f, Stride: 1},
+		{Lo: 0x0391, Hi: 0x03a1, Stride: 1},
+		{Lo: 0x03a3, Hi: 0x03a9, Stride: 1},
+		{Lo: 0x03b1, Hi: 0x03c1, Stride: 1},
+		{Lo: 0x03c3, Hi: 0x03c9, Stride: 1},
+		{Lo: 0x0401, Hi: 0x0410, Stride: 15},
+		{Lo: 0x0411, Hi: 0x044f, Stride: 1},
+		{Lo: 0x0451, Hi: 0x2010, Stride: 7103},
+		{Lo: 0x2013, Hi: 0x2016, Stride: 1},
+		{Lo: 0x2018, Hi: 0x2019, Stride: 1},
+		{Lo: 0x201c, Hi: 0x201d, Stride: 1},
+		{Lo: 0x2020, Hi: 0x2022, Stride: 1},
+		{Lo: 0x2024, Hi: 0x2027, Stride: 1},
+		{Lo: 0x2030, Hi: 0x2032, Stride: 2},
+		{Lo: 0x2033, Hi: 0x2035, Stride: 2},
+		{Lo: 0x203b, Hi: 0x203e, Stride: 3},
+		{Lo: 0x2074, Hi: 0x207f, Stride: 11},
+		{Lo: 0x2081, Hi: 0x2084, Stride: 1},
+		{Lo: 0x20ac, Hi: 0x2103, Stride: 87},
+		{Lo: 0x2105, Hi: 0x2109, Stride: 4},
+		{Lo: 0x2113, Hi: 0x2116, Stride: 3},
+		{Lo: 0x2121, Hi: 0x2122, Stride: 1},
+		{Lo: 0x2126, Hi: 0x212b, Stride: 5},
+		{Lo: 0x2153, Hi: 0x2154, Stride: 1},
+		{Lo: 0x215b, Hi: 0x215e, Stride: 1},
+		{Lo: 0x2160, Hi: 0x216b, Stride: 1},
+		{Lo: 0x2170, Hi: 0x2179, Stride: 1},
+		{Lo: 0x2189, Hi: 0x2190, Stride: 7},
+		{Lo: 0x2191, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21b8, Hi: 0x21b9, Stride: 1},
+		{Lo: 0x21d2, Hi: 0x21d4, Stride: 2},
+		{Lo: 0x21e7, Hi: 0x2200, Stride: 25},
+		{Lo: 0x2202, Hi: 0x2203, Stride: 1},
+		{Lo: 0x2207, Hi: 0x2208, Stride: 1},
+		{Lo: 0x220b, Hi: 0x220f, Stride: 4},
+		{Lo: 0x2211, Hi: 0x2215, Stride: 4},
+		{Lo: 0x221a, Hi: 0x221d, Stride: 3},
+		{Lo: 0x221e, Hi: 0x2220, Stride: 1},
+		{Lo: 0x2223, Hi: 0x2227, Stride: 2},
+		{Lo: 0x2228, Hi: 0x222c, Stride: 1},
+		{Lo: 0x222e, Hi: 0x2234, Stride: 6},
+		{Lo: 0x2235, Hi: 0x2237, Stride: 1},
+		{Lo: 0x223c, Hi: 0x223d, Stride: 1},
+		{Lo: 0x2248, Hi: 0x224c, Stride: 4},
+		{Lo: 0x2252, Hi: 0x2260, Stride: 14},
+		{Lo: 0x2261, Hi: 0x2264, Stride: 3},
+		{Lo: 0x2265, Hi: 0x2267, Stride: 1},
+		{Lo: 0x226a, Hi: 0x226b, Stride: 1},
+		{Lo: 0x226e, Hi: 0x226f, Stride: 1},
+		{Lo: 0x2282, Hi: 0x2283, Stride: 1},
+		{Lo: 0x2286, Hi: 0x2287, Stride: 1},
+		{Lo: 0x2295, Hi: 0x2299, Stride: 4},
+		{Lo: 0x22a5, Hi: 0x22bf, Stride: 26},
+		{Lo: 0x2312, Hi: 0x2460, Stride: 334},
+		{Lo: 0x2461, Hi: 0x24e9, Stride: 1},
+		{Lo: 0x24eb, Hi: 0x254b, Stride: 1},
+		{Lo: 0x2550, Hi: 0x2573, Stride: 1},
+		{Lo: 0x2580, Hi: 0x258f, Stride: 1},
+		{Lo: 0x2592, Hi: 0x2595, Stride: 1},
+		{Lo: 0x25a0, Hi: 0x25a1, Stride: 1},
+		{Lo: 0x25a3, Hi: 0x25a9, Stride: 1},
+		{Lo: 0x25b2, Hi: 0x25b3, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25b7, Stride: 1},
+		{Lo: 0x25bc, Hi: 0x25bd, Stride: 1},
+		{Lo: 0x25c0, Hi: 0x25c1, Stride: 1},
+		{Lo: 0x25c6, Hi: 0x25c8, Stride: 1},
+		{Lo: 0x25cb, Hi: 0x25ce, Stride: 3},
+		{Lo: 0x25cf, Hi: 0x25d1, Stride: 1},
+		{Lo: 0x25e2, Hi: 0x25e5, Stride: 1},
+		{Lo: 0x25ef, Hi: 0x2605, Stride: 22},
+		{Lo: 0x2606, Hi: 0x2609, Stride: 3},
+		{Lo: 0x260e, Hi: 0x260f, Stride: 1},
+		{Lo: 0x261c, Hi: 0x261e, Stride: 2},
+		{Lo: 0x2640, Hi: 0x2642, Stride: 2},
+		{Lo: 0x2660, Hi: 0x2661, Stride: 1},
+		{Lo: 0x2663, Hi: 0x2665, Stride: 1},
+		{Lo: 0x2667, Hi: 0x266a, Stride: 1},
+		{Lo: 0x266c, Hi: 0x266d, Stride: 1},
+		{Lo: 0x266f, Hi: 0x269e, Stride: 47},
+		{Lo: 0x269f, Hi: 0x26bf, Stride: 32},
+		{Lo: 0x26c6, Hi: 0x26cd, Stride: 1},
+		{Lo: 0x26cf, Hi: 0x26d3, Stride: 1},
+		{Lo: 0x26d5, Hi: 0x26e1, Stride: 1},
+		{Lo: 0x26e3, Hi: 0x26e8, Stride: 5},
+		{Lo: 0x26e9, Hi: 0x26eb, Stride: 2},
+		{Lo: 0x26ec, Hi: 0x26f1, Stride: 1},
+		{Lo: 0x26f4, Hi: 0x26f6, Stride: 2},
+		{Lo: 0x26f7, Hi: 0x26f9, Stride: 1},
+		{Lo: 0x26fb, Hi: 0x26fc, Stride: 1},
+		{Lo: 0x26fe, Hi: 0x26ff, Stride: 1},
+		{Lo: 0x273d, Hi: 0x2776, Stride: 57},
+		{Lo: 0x2777, Hi: 0x277f, Stride: 1},
+		{Lo: 0x2b56, Hi: 0x2b59, Stride: 1},
+		{Lo: 0x3248, Hi: 0x324f, Stride: 1},
+		{Lo: 0xe000, Hi: 0xf8ff, Stride: 1},
+		{Lo: 0xfe00, Hi: 0xfe0f, Stride: 1},
+		{Lo: 0xfffd, Hi: 0xfffd, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f100, Hi: 0x1f10a, Stride: 1},
+		{Lo: 0x1f110, Hi: 0x1f12d, Stride: 1},
+		{Lo: 0x1f130, Hi: 0x1f169, Stride: 1},
+		{Lo: 0x1f170, Hi: 0x1f18d, Stride: 1},
+		{Lo: 0x1f18f, Hi: 0x1f190, Stride: 1},
+		{Lo: 0x1f19b, Hi: 0x1f1ac, Stride: 1},
+		{Lo: 0xe0100, Hi: 0xe01ef, Stride: 1},
+		{Lo: 0xf0000, Hi: 0xffffd, Stride: 1},
+		{Lo: 0x100000, Hi: 0x10fffd, Stride: 1},
+	},
+	LatinOffset: 16,
+}
@@ -25,6 +25,14 @@ const (
 	urlLineBreak     = "https://unicode.org/Public/" + version + "/ucd/LineBreak.txt"
 	urlSentenceBreak = "https://unicode.org/Public/" + version + "/ucd/auxiliary/SentenceBreakProperty.txt"
 	urlDerivedCore   = "https://unicode.org/Public/" + version + "/ucd/DerivedCoreProperties.txt"
+
+	// test files, used by the segmenter and bidi packages
+	urlLineBreakTest     = "https://unicode.org/Public/14.0.0/ucd/auxiliary/LineBreakTest.txt" // see segmenter/linebreak_test.go
+	urlGraphemeBreakTest = "https://unicode.org/Public/" + version + "/ucd/auxiliary/GraphemeBreakTest.txt"
+	urlWordBreakTest     = "https://unicode.org/Public/" + version + "/ucd/auxiliary/WordBreakTest.txt"
+	urlSentenceBreakTest = "https://unicode.org/Public/" + version + "/ucd/auxiliary/SentenceBreakTest.txt"
+	urlBidiTest          = "https://unicode.org/Public/15.1.0/ucd/BidiTest.txt"          // see bidi/bidi_test.go
+	urlBidiCharacterTest = "https://unicode.org/Public/15.1.0/ucd/BidiCharacterTest.txt" // see bidi/bidi_test.go
 )
 
 func fetchData(url string) {
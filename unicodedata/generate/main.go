@@ -35,6 +35,12 @@ func main() {
 		fetchData(urlLineBreak)
 		fetchData(urlSentenceBreak)
 		fetchData(urlDerivedCore)
+		fetchData(urlLineBreakTest)
+		fetchData(urlGraphemeBreakTest)
+		fetchData(urlWordBreakTest)
+		fetchData(urlSentenceBreakTest)
+		fetchData(urlBidiTest)
+		fetchData(urlBidiCharacterTest)
 	}
 
 	// parse
@@ -59,7 +65,7 @@ func main() {
 	mirrors, err := parseMirroring(b)
 	check(err)
 
-	dms, compEx := parseXML("ucd.nounihan.grouped.zip")
+	xmlProps := parseXML("ucd.nounihan.grouped.zip")
 
 	b, err = os.ReadFile("ArabicShaping.txt")
 	check(err)
@@ -138,7 +144,7 @@ func main() {
 		generateMirroring(mirrors, w)
 	})
 	process("../decomposition.go", func(w io.Writer) {
-		generateDecomposition(dms, compEx, w)
+		generateDecomposition(xmlProps.dms, xmlProps.compEx, w)
 	})
 	process("../arabic.go", func(w io.Writer) {
 		generateArabicShaping(joiningTypes, w)
@@ -156,6 +162,15 @@ func main() {
 	process("../linebreak.go", func(w io.Writer) {
 		generateLineBreak(lineBreak, w)
 	})
+	process("../east_asian_width.go", func(w io.Writer) {
+		generateEastAsianWidth(xmlProps.eastAsianWidth, w)
+	})
+	process("../bidi.go", func(w io.Writer) {
+		generateBidi(xmlProps.bidiClasses, xmlProps.pairedBrackets, w)
+	})
+	process("../text_segmentation.go", func(w io.Writer) {
+		generateTextSegmentation(xmlProps.graphemeBreaks, xmlProps.wordBreaks, xmlProps.sentenceBreaks, w)
+	})
 	process("../indic.go", func(w io.Writer) {
 		generateIndicCategories(indicS, w)
 	})
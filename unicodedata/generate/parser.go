@@ -251,6 +251,13 @@ type group struct {
 	Dm        string `xml:"dm,attr"`
 	Dt        string `xml:"dt,attr"`
 	CompEx    string `xml:"Comp_Ex,attr"`
+	Ea        string `xml:"ea,attr"`
+	Bc        string `xml:"bc,attr"`
+	Bpt       string `xml:"bpt,attr"`
+	Bpb       string `xml:"bpb,attr"`
+	Gcb       string `xml:"GCB,attr"`
+	Wb        string `xml:"WB,attr"`
+	Sb        string `xml:"SB,attr"`
 	Chars     []char `xml:"char"`
 	Reserved  []char `xml:"reserved"`
 	NonChar   []char `xml:"noncharacter"`
@@ -264,9 +271,36 @@ type char struct {
 	Dm      string `xml:"dm,attr"`
 	Dt      string `xml:"dt,attr"`
 	CompEx  string `xml:"Comp_Ex,attr"`
+	Ea      string `xml:"ea,attr"`
+	Bc      string `xml:"bc,attr"`
+	Bpt     string `xml:"bpt,attr"`
+	Bpb     string `xml:"bpb,attr"`
+	Gcb     string `xml:"GCB,attr"`
+	Wb      string `xml:"WB,attr"`
+	Sb      string `xml:"SB,attr"`
 }
 
-func parseXML(filename string) (map[rune][]rune, map[rune]bool) {
+// xmlProperties stores the properties read from the XML database
+type xmlProperties struct {
+	dms    map[rune][]rune // canonical decompositions
+	compEx map[rune]bool   // composition exclusions
+
+	eastAsianWidth map[string][]rune // class -> runes
+	bidiClasses    map[string][]rune // class -> runes
+	pairedBrackets map[rune]pairedBracket
+
+	// text segmentation properties (UAX #29)
+	graphemeBreaks map[string][]rune // class -> runes
+	wordBreaks     map[string][]rune // class -> runes
+	sentenceBreaks map[string][]rune // class -> runes
+}
+
+type pairedBracket struct {
+	pair    rune
+	opening bool
+}
+
+func parseXML(filename string) xmlProperties {
 	f, err := zip.OpenReader(filename)
 	check(err)
 	if len(f.File) != 1 {
@@ -289,8 +323,50 @@ func parseXML(filename string) (map[rune][]rune, map[rune]bool) {
 
 	dms := map[rune][]rune{}
 	compEx := map[rune]bool{}
+	eastAsianWidth := map[string][]rune{}
+	bidiClasses := map[string][]rune{}
+	pairedBrackets := map[rune]pairedBracket{}
+	graphemeBreaks := map[string][]rune{}
+	wordBreaks := map[string][]rune{}
+	sentenceBreaks := map[string][]rune{}
 	handleRunes := func(l []char, gr group) {
 		for _, ch := range l {
+			if ch.Ea == "" {
+				ch.Ea = gr.Ea
+			}
+			if ch.Bc == "" {
+				ch.Bc = gr.Bc
+			}
+			if ch.Bpt == "" {
+				ch.Bpt = gr.Bpt
+			}
+			if ch.Bpb == "" {
+				ch.Bpb = gr.Bpb
+			}
+			if ch.Gcb == "" {
+				ch.Gcb = gr.Gcb
+			}
+			if ch.Wb == "" {
+				ch.Wb = gr.Wb
+			}
+			if ch.Sb == "" {
+				ch.Sb = gr.Sb
+			}
+			firstRune, lastRune := ch.Cp, ch.Cp
+			if ch.Cp == "" {
+				firstRune, lastRune = ch.FirstCp, ch.LastCp
+			}
+			for ru := parseRune(firstRune); ru <= parseRune(lastRune); ru++ {
+				eastAsianWidth[ch.Ea] = append(eastAsianWidth[ch.Ea], ru)
+				bidiClasses[ch.Bc] = append(bidiClasses[ch.Bc], ru)
+				graphemeBreaks[ch.Gcb] = append(graphemeBreaks[ch.Gcb], ru)
+				wordBreaks[ch.Wb] = append(wordBreaks[ch.Wb], ru)
+				sentenceBreaks[ch.Sb] = append(sentenceBreaks[ch.Sb], ru)
+				if ch.Bpt == "o" || ch.Bpt == "c" {
+					pairedBrackets[ru] = pairedBracket{pair: parseRune(ch.Bpb), opening: ch.Bpt == "o"}
+				}
+			}
+
 			if ch.Dm == "" {
 				ch.Dm = gr.Dm
 			}
@@ -340,7 +416,16 @@ func parseXML(filename string) (map[rune][]rune, map[rune]bool) {
 		delete(dms, rune(i))
 	}
 
-	return dms, compEx
+	return xmlProperties{
+		dms:            dms,
+		compEx:         compEx,
+		eastAsianWidth: eastAsianWidth,
+		bidiClasses:    bidiClasses,
+		pairedBrackets: pairedBrackets,
+		graphemeBreaks: graphemeBreaks,
+		wordBreaks:     wordBreaks,
+		sentenceBreaks: sentenceBreaks,
+	}
 }
 
 // return the joining type and joining group
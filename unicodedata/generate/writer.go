@@ -359,6 +359,166 @@ func generateLineBreak(datas map[string][]rune, w io.Writer) {
 	`, dict)
 }
 
+// East Asian Width classes, the Neutral class being the default
+var eastAsianWidthClasses = [][2]string{
+	{"F", "Fullwidth"},
+	{"H", "Halfwidth"},
+	{"W", "Wide"},
+	{"Na", "Narrow"},
+	{"A", "Ambiguous"},
+}
+
+func generateEastAsianWidth(datas map[string][]rune, w io.Writer) {
+	fmt.Fprint(w, header)
+	for _, class := range eastAsianWidthClasses {
+		table := rangetable.New(datas[class[0]]...)
+		s := printTable(table, false)
+		fmt.Fprintf(w, "// EastAsian%s is the East Asian Width class %s\n", class[1], class[0])
+		fmt.Fprintf(w, "var EastAsian%s = %s\n\n", class[1], s)
+	}
+}
+
+// Bidirectional classes
+var bidiClasses = [][2]string{
+	{"L", "Left_To_Right"},
+	{"R", "Right_To_Left"},
+	{"AL", "Arabic_Letter"},
+	{"EN", "European_Number"},
+	{"ES", "European_Separator"},
+	{"ET", "European_Terminator"},
+	{"AN", "Arabic_Number"},
+	{"CS", "Common_Separator"},
+	{"NSM", "Nonspacing_Mark"},
+	{"BN", "Boundary_Neutral"},
+	{"B", "Paragraph_Separator"},
+	{"S", "Segment_Separator"},
+	{"WS", "White_Space"},
+	{"ON", "Other_Neutral"},
+	{"LRE", "Left_To_Right_Embedding"},
+	{"LRO", "Left_To_Right_Override"},
+	{"RLE", "Right_To_Left_Embedding"},
+	{"RLO", "Right_To_Left_Override"},
+	{"PDF", "Pop_Directional_Format"},
+	{"LRI", "Left_To_Right_Isolate"},
+	{"RLI", "Right_To_Left_Isolate"},
+	{"FSI", "First_Strong_Isolate"},
+	{"PDI", "Pop_Directional_Isolate"},
+}
+
+func generateBidi(classes map[string][]rune, brackets map[rune]pairedBracket, w io.Writer) {
+	dict := ""
+
+	fmt.Fprint(w, header)
+	for _, class := range bidiClasses {
+		table := rangetable.New(classes[class[0]]...)
+		s := printTable(table, false)
+		fmt.Fprintf(w, "// %s\n", class[1])
+		fmt.Fprintf(w, "var Bidi%s = %s\n\n", class[0], s)
+
+		dict += fmt.Sprintf("Bidi%s, // %s \n", class[0], class[0])
+	}
+
+	fmt.Fprintf(w, `var bidiClasses = [...]*unicode.RangeTable{
+		%s}
+	`, dict)
+
+	var sorted []rune
+	for r := range brackets {
+		sorted = append(sorted, r)
+	}
+	sortRunes(sorted)
+	fmt.Fprintln(w, "\n// Bidi_Paired_Bracket and Bidi_Paired_Bracket_Type properties")
+	fmt.Fprintf(w, "var pairedBrackets = map[rune]pairedBracket{ // %d entries \n", len(sorted))
+	for _, r := range sorted {
+		b := brackets[r]
+		typ := "BracketClose"
+		if b.opening {
+			typ = "BracketOpen"
+		}
+		fmt.Fprintf(w, "0x%04x: {0x%04x, %s},\n", r, b.pair, typ)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// Text segmentation classes (UAX #29), the Other class being the default
+var (
+	graphemeBreakClasses = [][2]string{
+		{"CR", "CR"},
+		{"LF", "LF"},
+		{"CN", "Control"},
+		{"EX", "Extend"},
+		{"ZWJ", "ZWJ"},
+		{"RI", "Regional_Indicator"},
+		{"PP", "Prepend"},
+		{"SM", "SpacingMark"},
+		{"L", "L"},
+		{"V", "V"},
+		{"T", "T"},
+		{"LV", "LV"},
+		{"LVT", "LVT"},
+	}
+	wordBreakClasses = [][2]string{
+		{"CR", "CR"},
+		{"LF", "LF"},
+		{"NL", "Newline"},
+		{"Extend", "Extend"},
+		{"ZWJ", "ZWJ"},
+		{"RI", "Regional_Indicator"},
+		{"FO", "Format"},
+		{"KA", "Katakana"},
+		{"HL", "Hebrew_Letter"},
+		{"LE", "ALetter"},
+		{"SQ", "Single_Quote"},
+		{"DQ", "Double_Quote"},
+		{"MB", "MidNumLet"},
+		{"ML", "MidLetter"},
+		{"MN", "MidNum"},
+		{"NU", "Numeric"},
+		{"EX", "ExtendNumLet"},
+		{"WSegSpace", "WSegSpace"},
+	}
+	sentenceBreakClasses = [][2]string{
+		{"CR", "CR"},
+		{"LF", "LF"},
+		{"EX", "Extend"},
+		{"SE", "Sep"},
+		{"FO", "Format"},
+		{"SP", "Sp"},
+		{"LO", "Lower"},
+		{"UP", "Upper"},
+		{"LE", "OLetter"},
+		{"NU", "Numeric"},
+		{"AT", "ATerm"},
+		{"SC", "SContinue"},
+		{"ST", "STerm"},
+		{"CL", "Close"},
+	}
+)
+
+func generateSegmentationProperty(property, propertyName, varName string, classes [][2]string, datas map[string][]rune, w io.Writer) {
+	dict := ""
+	for _, class := range classes {
+		table := rangetable.New(datas[class[0]]...)
+		s := printTable(table, false)
+		fmt.Fprintf(w, "// %s%s is the %s class %s\n", property, class[1], propertyName, class[1])
+		fmt.Fprintf(w, "var %s%s = %s\n\n", property, class[1], s)
+
+		dict += fmt.Sprintf("%s%s, // %s \n", property, class[1], class[0])
+	}
+
+	fmt.Fprintf(w, `var %s = [...]*unicode.RangeTable{
+		%s}
+
+	`, varName, dict)
+}
+
+func generateTextSegmentation(graphemes, words, sentences map[string][]rune, w io.Writer) {
+	fmt.Fprint(w, header)
+	generateSegmentationProperty("GraphemeBreak", "Grapheme_Cluster_Break", "graphemeBreaks", graphemeBreakClasses, graphemes, w)
+	generateSegmentationProperty("WordBreak", "Word_Break", "wordBreaks", wordBreakClasses, words, w)
+	generateSegmentationProperty("SentenceBreak", "Sentence_Break", "sentenceBreaks", sentenceBreakClasses, sentences, w)
+}
+
 func generateIndicCategories(datas map[string][]rune, w io.Writer) {
 	fmt.Fprint(w, header)
 	for _, className := range []string{"Virama", "Vowel_Dependent"} {
@@ -57,6 +57,76 @@ func LookupBreakClass(ch rune) *unicode.RangeTable {
 	return BreakXX
 }
 
+// LookupGraphemeBreakClass returns the grapheme cluster break class of the rune
+// (see the constants GraphemeBreakXXX), or nil for the class Other.
+func LookupGraphemeBreakClass(ch rune) *unicode.RangeTable {
+	for _, class := range graphemeBreaks {
+		if unicode.Is(class, ch) {
+			return class
+		}
+	}
+	return nil
+}
+
+// LookupWordBreakClass returns the word break class of the rune
+// (see the constants WordBreakXXX), or nil for the class Other.
+func LookupWordBreakClass(ch rune) *unicode.RangeTable {
+	for _, class := range wordBreaks {
+		if unicode.Is(class, ch) {
+			return class
+		}
+	}
+	return nil
+}
+
+// LookupSentenceBreakClass returns the sentence break class of the rune
+// (see the constants SentenceBreakXXX), or nil for the class Other.
+func LookupSentenceBreakClass(ch rune) *unicode.RangeTable {
+	for _, class := range sentenceBreaks {
+		if unicode.Is(class, ch) {
+			return class
+		}
+	}
+	return nil
+}
+
+// LookupBidiClass returns the bidirectional class of the rune (see the constants BidiXXX),
+// including the default values for unassigned runes.
+func LookupBidiClass(ch rune) *unicode.RangeTable {
+	for _, class := range bidiClasses {
+		if unicode.Is(class, ch) {
+			return class
+		}
+	}
+	return BidiL
+}
+
+// BracketType is the Bidi_Paired_Bracket_Type property, used
+// to match pairs of brackets in the bidirectional algorithm.
+type BracketType uint8
+
+const (
+	BracketNone BracketType = iota
+	BracketOpen
+	BracketClose
+)
+
+type pairedBracket struct {
+	pair rune
+	typ  BracketType
+}
+
+// LookupPairedBracket returns the opening or closing bracket
+// matching `ch` (Bidi_Paired_Bracket property) and its
+// Bidi_Paired_Bracket_Type, which is BracketNone if `ch` is not a paired bracket.
+func LookupPairedBracket(ch rune) (rune, BracketType) {
+	b, ok := pairedBrackets[ch]
+	if !ok {
+		return ch, BracketNone
+	}
+	return b.pair, b.typ
+}
+
 // LookupMirrorChar finds the mirrored equivalent of a character as defined in
 // the file BidiMirroring.txt of the Unicode Character Database available at
 // http://www.unicode.org/Public/UNIDATA/BidiMirroring.txt.
@@ -1,6 +1,9 @@
 package unicodedata
 
-import "testing"
+import (
+	"testing"
+	"unicode"
+)
 
 func TestUnicodeNormalization(t *testing.T) {
 	assertCompose := func(a, b rune, okExp bool, abExp rune) {
@@ -77,3 +80,62 @@ func TestUnicodeNormalization(t *testing.T) {
 	assertDecompose(0xCE31, true, 0xCE20, 0x11B8)
 	assertDecompose(0xCE20, true, 0x110E, 0x1173)
 }
+
+func TestBidi(t *testing.T) {
+	for _, test := range []struct {
+		r        rune
+		expected *unicode.RangeTable
+	}{
+		{'a', BidiL},
+		{'א', BidiR},
+		{'ب', BidiAL},
+		{'1', BidiEN},
+		{'٣', BidiAN},
+		{' ', BidiWS},
+		{'⁧', BidiRLI},
+		{0x05FF, BidiR},  // unassigned in the Hebrew block
+		{0x08BF, BidiAL}, // unassigned in the Arabic Extended-A block
+	} {
+		if got := LookupBidiClass(test.r); got != test.expected {
+			t.Errorf("for %U, unexpected class", test.r)
+		}
+	}
+
+	if pair, typ := LookupPairedBracket('('); pair != ')' || typ != BracketOpen {
+		t.Errorf("unexpected bracket %c %d", pair, typ)
+	}
+	if pair, typ := LookupPairedBracket(']'); pair != '[' || typ != BracketClose {
+		t.Errorf("unexpected bracket %c %d", pair, typ)
+	}
+	if _, typ := LookupPairedBracket('a'); typ != BracketNone {
+		t.Errorf("unexpected bracket type %d", typ)
+	}
+}
+
+func TestTextSegmentationClasses(t *testing.T) {
+	for _, test := range []struct {
+		lookup   func(rune) *unicode.RangeTable
+		r        rune
+		expected *unicode.RangeTable
+	}{
+		{LookupGraphemeBreakClass, '\r', GraphemeBreakCR},
+		{LookupGraphemeBreakClass, 0x0301, GraphemeBreakExtend},
+		{LookupGraphemeBreakClass, 0x200D, GraphemeBreakZWJ},
+		{LookupGraphemeBreakClass, 0xAC00, GraphemeBreakLV},
+		{LookupGraphemeBreakClass, 'a', nil},
+		{LookupWordBreakClass, 'a', WordBreakALetter},
+		{LookupWordBreakClass, '\'', WordBreakSingle_Quote},
+		{LookupWordBreakClass, 'ア', WordBreakKatakana},
+		{LookupWordBreakClass, ' ', WordBreakWSegSpace},
+		{LookupWordBreakClass, '漢', nil},
+		{LookupSentenceBreakClass, 'a', SentenceBreakLower},
+		{LookupSentenceBreakClass, 'A', SentenceBreakUpper},
+		{LookupSentenceBreakClass, '.', SentenceBreakATerm},
+		{LookupSentenceBreakClass, '?', SentenceBreakSTerm},
+		{LookupSentenceBreakClass, '$', nil},
+	} {
+		if got := test.lookup(test.r); got != test.expected {
+			t.Errorf("for %U, unexpected class", test.r)
+		}
+	}
+}